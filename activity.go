@@ -0,0 +1,86 @@
+package main
+
+import "strings"
+
+// Darwin activity codes (the act attribute) are up to six two-character
+// codes packed together
+// https://wiki.openraildata.com/index.php/Activity_codes
+var ActivityCodes = map[string]string{
+    "A":  "Stops or shunts for other trains to pass",
+    "AE": "Attach/detach assisting locomotive",
+    "BL": "Stops for banking locomotive",
+    "C":  "Stops to change trainmen",
+    "D":  "Stops to set down passengers",
+    "-D": "Stops to detach vehicles",
+    "E":  "Stops for examination",
+    "L":  "Stops to change locomotives",
+    "N":  "Stop not advertised",
+    "OP": "Stops for other operating reasons",
+    "OR": "Train locomotive on rear",
+    "PR": "Propelling between points shown",
+    "R":  "Stops when required",
+    "RM": "Reversing movement",
+    "RR": "Stops for locomotive to run round train",
+    "S":  "Stops for railway personnel only",
+    "T":  "Stops to take up and set down passengers",
+    "-T": "Stops to attach and detach vehicles",
+    "TB": "Train begins",
+    "TF": "Train finishes",
+    "TW": "Stops (or at pass) for tablet, staff or token",
+    "U":  "Stops to take up passengers",
+    "-U": "Stops to attach vehicles",
+    "W":  "Stops for watering of coaches",
+    "X":  "Passes another train at crossing point on single line",
+}
+
+func activityList(act string) []string {
+    var codes []string
+    for i := 0; i < len(act); i += 2 {
+        end := i + 2
+        if end > len(act) {
+            end = len(act)
+        }
+        if code := strings.TrimSpace(act[i:end]); code != "" {
+            codes = append(codes, code)
+        }
+    }
+    return codes
+}
+
+func hasActivity(act, code string) bool {
+    for _, c := range activityList(act) {
+        if c == code {
+            return true
+        }
+    }
+    return false
+}
+
+// Whether a calling point belongs in passenger-facing views at all
+func isPublicCall(p CallingPoint) bool {
+    if strings.HasPrefix(p.Type, "OP") || p.Type == "PP" {
+        return false
+    }
+    if p.Pta == "" && p.Ptd == "" {
+        return false
+    }
+    return !hasActivity(p.Act, "N")
+}
+
+// Passengers can board here: excludes set-down-only calls
+func isPublicDeparture(p CallingPoint) bool {
+    return isPublicCall(p) && p.Ptd != "" && !(hasActivity(p.Act, "D") && !hasActivity(p.Act, "T"))
+}
+
+// Note to show against a call with restrictions, as a translation key
+func activityNote(act string) string {
+    switch {
+    case hasActivity(act, "R"):
+        return "Request stop"
+    case hasActivity(act, "D") && !hasActivity(act, "T"):
+        return "Set down only"
+    case hasActivity(act, "U") && !hasActivity(act, "T"):
+        return "Pick up only"
+    }
+    return ""
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "html/template"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Browsers can't send a bearer token, so the admin pages offer a sign-in
+// at /admin/login: an admin token pasted there is kept in an HttpOnly,
+// SameSite=Strict cookie and accepted in place of the header. A form
+// posted with the cookie must carry a CSRF token made from it, so another
+// site can't post one on an admin's behalf. Requests with the header
+// don't need one.
+const adminCookie = "minimaltrains_token"
+
+const adminSessionLength = 12 * time.Hour
+
+func adminCSRF(token string) string {
+    sum := sha256.Sum256([]byte("csrf:" + token))
+    return hex.EncodeToString(sum[:16])
+}
+
+// The token a request presents, and whether it came from the cookie
+func requestToken(r *http.Request) (string, bool) {
+    if token := bearerToken(r); token != "" {
+        return token, false
+    }
+    if c, err := r.Cookie(adminCookie); err == nil && c.Value != "" {
+        return c.Value, true
+    }
+    return "", false
+}
+
+// The CSRF token for a page's forms, or "" if it wasn't signed in to by
+// cookie
+func csrfToken(r *http.Request) string {
+    token, fromCookie := requestToken(r)
+    if !fromCookie {
+        return ""
+    }
+    return adminCSRF(token)
+}
+
+// Whether a request made with the cookie may go ahead: reads always can,
+// anything else needs the form's CSRF token
+func validCSRF(r *http.Request, token string) bool {
+    if r.Method == http.MethodGet || r.Method == http.MethodHead {
+        return true
+    }
+    return subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(adminCSRF(token))) == 1
+}
+
+// Where to go after signing in: a path on this site, or the snapshots page
+func loginNext(r *http.Request) string {
+    next := r.FormValue("next")
+    if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+        return "/admin/snapshots"
+    }
+    return next
+}
+
+var adminLoginTmpl = template.Must(template.New("adminLogin").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Sign in</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>Sign in</h1>
+    {{with .Error}}<p class="late">{{.}}</p>{{end}}
+    <form method="post" action="/admin/login">
+        <input type="hidden" name="next" value="{{.Next}}">
+        <label>Admin token <input type="password" name="token" autocomplete="off" required></label>
+        <button type="submit">Sign in</button>
+    </form>
+    <p class="muted">Tokens are made with <code>minimaltrains token create --scope admin</code>.</p>
+</body>
+</html>
+`))
+
+func renderAdminLogin(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+    data := struct {
+        Theme, Next, Error string
+    }{requestTheme(w, r), loginNext(r), errMsg}
+    if status != http.StatusOK {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        w.WriteHeader(status)
+    }
+    executeTemplate(w, r, adminLoginTmpl, data)
+}
+
+// GET /admin/login
+func adminLoginPageHandler(w http.ResponseWriter, r *http.Request) {
+    renderAdminLogin(w, r, http.StatusOK, "")
+}
+
+// POST /admin/login with token and next
+func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
+    if archiveDB == nil {
+        http.Error(w, "API tokens need the archive database (ARCHIVE_PATH)", http.StatusServiceUnavailable)
+        return
+    }
+    token := strings.TrimSpace(r.FormValue("token"))
+    t, ok, err := lookupToken(archiveDB, token)
+    if err != nil {
+        log.Printf("Failed to look up API token: %v", err)
+        http.Error(w, "failed to check token", http.StatusInternalServerError)
+        return
+    }
+    if !ok || !t.allows("admin") {
+        renderAdminLogin(w, r, http.StatusUnauthorized, "That isn't a live token with the admin scope.")
+        return
+    }
+    http.SetCookie(w, &http.Cookie{
+        Name:     adminCookie,
+        Value:    token,
+        Path:     "/",
+        MaxAge:   int(adminSessionLength.Seconds()),
+        HttpOnly: true,
+        Secure:   strings.HasPrefix(requestBaseURL(r), "https:"),
+        SameSite: http.SameSiteStrictMode,
+    })
+    log.Printf("Admin signed in with token %s (%s)", t.ID, t.Name)
+    http.Redirect(w, r, loginNext(r), http.StatusSeeOther)
+}
+
+// POST /admin/logout
+func adminLogoutHandler(w http.ResponseWriter, r *http.Request) {
+    http.SetCookie(w, &http.Cookie{Name: adminCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+    http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
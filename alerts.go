@@ -0,0 +1,46 @@
+package main
+
+import (
+    "fmt"
+    "log"
+)
+
+// Where delay alerts go; nil unless a notifier is configured
+var alertNotifier Notifier
+
+// Alert everyone whose rules cover a train that's cancelled or running
+// late enough for them. Runs on the ingest pipeline's notify workers.
+func checkAlerts(p TrainProgress) {
+    if alertNotifier == nil || len(p.Stops) == 0 {
+        return
+    }
+    rules := currentAlertRules()
+    if len(rules) == 0 {
+        return
+    }
+    delay, _ := trainDelay(p)
+    _, cancelled := cancelledRanges(p.Stops)
+    var s TrainSummary
+    for _, rule := range rules {
+        if !rule.matches(p) {
+            continue
+        }
+        if (cancelled && !rule.Cancellations) || (!cancelled && (delay <= 0 || delay < rule.MinDelay)) {
+            continue
+        }
+        if s.Title == "" {
+            s = summariseTrain(p, defaultLang)
+        }
+        n := Notification{
+            To:        rule.To,
+            Subject:   fmt.Sprintf("%s: %s", s.Title, s.Status),
+            Body:      s.Route + "\n" + s.Status + "\n" + s.Detail + "\n",
+            Train:     p.RID,
+            Delay:     delay,
+            Cancelled: cancelled,
+        }
+        if err := alertNotifier.Notify(n); err != nil {
+            log.Printf("Failed to send alert for %s to %s: %v", p.RID, n.To, err)
+        }
+    }
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Which units are working which services, for enthusiasts. Darwin doesn't
+// say, so the allocations come from ALLOCATIONS_SOURCE, a path or URL
+// fetched again every ALLOCATIONS_REFRESH. ALLOCATIONS_FORMAT is json or
+// csv; left unset it's csv if the source ends .csv, else json.
+//
+// Each record names a service by RID, or by headcode with an optional
+// date (YYYY-MM-DD) for allocations that change from day to day:
+//
+//	[{"service": "2B15", "date": "2026-10-14", "class": "150", "units": ["150245"], "built": 1987}]
+//
+// or as CSV with a header row naming the same columns, units joined with +:
+//
+//	service,date,class,units,built
+//	2B15,2026-10-14,150,150245+150250,1987
+//
+// Allocations are only shown with enthusiast mode on, which ?enthusiast=on
+// and ?enthusiast=off switch and a cookie remembers.
+type Allocation struct {
+    Class string   `json:"class,omitempty"`
+    Units []string `json:"units,omitempty"`
+    Built int      `json:"built,omitempty"` // year the class was built
+}
+
+// The units as a formation, e.g. 150245+150250
+func (a Allocation) Formation() string { return strings.Join(a.Units, "+") }
+
+// Years since the units were built, 0 if not known
+func (a Allocation) Age() int {
+    if a.Built == 0 {
+        return 0
+    }
+    return max(clock.Now().In(ukLocation).Year()-a.Built, 0)
+}
+
+type allocationRecord struct {
+    Service string   `json:"service"`
+    Date    string   `json:"date"`
+    Class   string   `json:"class"`
+    Units   []string `json:"units"`
+    Built   int      `json:"built"`
+}
+
+var (
+    // By RID, headcode/date, or headcode alone
+    allocations   = map[string]Allocation{}
+    allocationsMu sync.RWMutex
+)
+
+func allocationKey(service, date string) string {
+    service = strings.ToUpper(strings.TrimSpace(service))
+    if date = strings.TrimSpace(date); date != "" {
+        return service + "/" + date
+    }
+    return service
+}
+
+// The allocation for a run of a service, matched by RID, then headcode and
+// date, then headcode
+func allocationFor(rid, headcode, ssd string) *Allocation {
+    allocationsMu.RLock()
+    defer allocationsMu.RUnlock()
+    for _, key := range []string{rid, allocationKey(headcode, ssd), allocationKey(headcode, "")} {
+        if a, ok := allocations[strings.ToUpper(key)]; ok && key != "" {
+            return &a
+        }
+    }
+    return nil
+}
+
+func allocationFormat(source string) string {
+    if f := strings.ToLower(envOr("ALLOCATIONS_FORMAT", "")); f != "" {
+        return f
+    }
+    if strings.HasSuffix(strings.ToLower(source), ".csv") {
+        return "csv"
+    }
+    return "json"
+}
+
+func parseAllocations(r io.Reader, format string) ([]allocationRecord, error) {
+    switch format {
+    case "json":
+        var records []allocationRecord
+        if err := json.NewDecoder(r).Decode(&records); err != nil {
+            return nil, err
+        }
+        return records, nil
+    case "csv":
+        return parseAllocationsCSV(r)
+    }
+    return nil, fmt.Errorf("unknown allocations format %q", format)
+}
+
+func parseAllocationsCSV(r io.Reader) ([]allocationRecord, error) {
+    cr := csv.NewReader(r)
+    cr.FieldsPerRecord = -1
+    header, err := cr.Read()
+    if err != nil {
+        return nil, err
+    }
+    columns := map[string]int{}
+    for i, name := range header {
+        columns[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    if _, ok := columns["service"]; !ok {
+        return nil, fmt.Errorf("no service column in the header")
+    }
+    field := func(rec []string, name string) string {
+        if i, ok := columns[name]; ok && i < len(rec) {
+            return strings.TrimSpace(rec[i])
+        }
+        return ""
+    }
+    var records []allocationRecord
+    for {
+        rec, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        a := allocationRecord{Service: field(rec, "service"), Date: field(rec, "date"), Class: field(rec, "class")}
+        if units := field(rec, "units"); units != "" {
+            a.Units = strings.Split(units, "+")
+        }
+        a.Built, _ = strconv.Atoi(field(rec, "built"))
+        records = append(records, a)
+    }
+    return records, nil
+}
+
+func loadAllocations(source string) (int, error) {
+    loaded := map[string]Allocation{}
+    err := withSource(source, func(r io.Reader) error {
+        records, err := parseAllocations(r, allocationFormat(source))
+        if err != nil {
+            return err
+        }
+        for _, rec := range records {
+            if rec.Service == "" || (rec.Class == "" && len(rec.Units) == 0) {
+                continue
+            }
+            loaded[allocationKey(rec.Service, rec.Date)] = Allocation{rec.Class, rec.Units, rec.Built}
+        }
+        return nil
+    })
+    if err != nil {
+        return 0, err
+    }
+    allocationsMu.Lock()
+    allocations = loaded
+    allocationsMu.Unlock()
+    return len(loaded), nil
+}
+
+// ALLOCATIONS_REFRESH, read each time so a config reload can change it
+func allocationsRefresh() time.Duration {
+    interval, err := time.ParseDuration(envOr("ALLOCATIONS_REFRESH", "15m"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid ALLOCATIONS_REFRESH; using 15m")
+        return 15 * time.Minute
+    }
+    return interval
+}
+
+// Fetch the allocations now and then every ALLOCATIONS_REFRESH, if a
+// source is set
+func startAllocations() {
+    source := envOr("ALLOCATIONS_SOURCE", "")
+    if source == "" {
+        return
+    }
+    for {
+        if n, err := loadAllocations(source); err != nil {
+            log.Printf("Failed to load unit allocations: %v", err)
+        } else {
+            log.Printf("Loaded unit allocations for %d services", n)
+        }
+        time.Sleep(allocationsRefresh())
+    }
+}
+
+// Whether the request wants allocations shown, from ?enthusiast= or the
+// cookie it sets
+func enthusiastMode(r *http.Request) bool {
+    switch r.URL.Query().Get("enthusiast") {
+    case "on":
+        return true
+    case "off":
+        return false
+    }
+    c, err := r.Cookie("enthusiast")
+    return err == nil && c.Value == "on"
+}
+
+// Remember a ?enthusiast= choice for later pages and their fragments
+func rememberEnthusiast(w http.ResponseWriter, r *http.Request) {
+    switch v := r.URL.Query().Get("enthusiast"); v {
+    case "on", "off":
+        http.SetCookie(w, &http.Cookie{Name: "enthusiast", Value: v, Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds()), SameSite: http.SameSiteLaxMode})
+    }
+}
+
+// A link to the same page with enthusiast mode switched over
+func enthusiastToggle(r *http.Request) string {
+    q := r.URL.Query()
+    if enthusiastMode(r) {
+        q.Set("enthusiast", "off")
+    } else {
+        q.Set("enthusiast", "on")
+    }
+    return "?" + q.Encode()
+}
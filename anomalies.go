@@ -0,0 +1,138 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "maps"
+    "net/http"
+    "slices"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// Darwin now and then sends actuals that can't be right: a train arriving
+// before it was due to leave its origin, calls reached in the wrong order,
+// or a run between stations at a speed no train does. Rather than show
+// them, a TS update that would introduce one is quarantined: the train's
+// progress is left as it was, the update is logged and counted by kind,
+// and the last few are listed on /admin/anomalies. Anomalies already in
+// the progress before an update don't hold later ones back.
+const (
+    anomalyBeforeOrigin    = "before_origin"
+    anomalyOutOfOrder      = "out_of_order"
+    anomalyImpossibleSpeed = "impossible_speed"
+)
+
+type Anomaly struct {
+    Kind   string `json:"kind"`
+    Tiploc string `json:"tiploc"`
+    Detail string `json:"detail"`
+}
+
+type QuarantinedUpdate struct {
+    At        time.Time `json:"at"`
+    RID       string    `json:"rid"`
+    TrainID   string    `json:"train_id"`
+    Anomalies []Anomaly `json:"anomalies"`
+}
+
+// How many quarantined updates /admin/anomalies keeps
+const quarantineKept = 100
+
+var (
+    quarantine       []QuarantinedUpdate
+    quarantineCounts = map[string]int{}
+    quarantineMu     sync.Mutex
+)
+
+var quarantinedUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "minimaltrains_quarantined_updates_total",
+    Help: "Darwin TS updates held back for anomalous actuals, by kind of anomaly.",
+}, []string{"kind"})
+
+// Speeds are between stations in a straight line, so lower than the
+// train's real ones; anything above ANOMALY_MAX_MPH is a bad time
+func anomalyMaxMPH() float64 { return float64(envInt("ANOMALY_MAX_MPH", 150)) }
+
+// Actuals more than ANOMALY_EARLY_MINS before the origin's scheduled
+// departure are taken to be wrong
+func anomalyEarlyMins() int { return envInt("ANOMALY_EARLY_MINS", 30) }
+
+// Anomalies in a train's actual times
+func findAnomalies(p TrainProgress) []Anomaly {
+    if len(p.Stops) == 0 {
+        return nil
+    }
+    var found []Anomaly
+    origin := p.Stops[0].Scheduled
+    early := anomalyEarlyMins()
+    prev := -1 // last stop with an actual
+    for i, s := range p.Stops {
+        if s.Actual == "" {
+            continue
+        }
+        if d, ok := minutesLate(origin, s.Actual); ok && d < -early {
+            found = append(found, Anomaly{anomalyBeforeOrigin, s.Station, fmt.Sprintf("actual %s is %d min before the %s departure from the origin", s.Actual, -d, origin)})
+        }
+        if prev >= 0 {
+            // Times are to the minute, so allow one either way
+            if d, ok := minutesLate(p.Stops[prev].Actual, s.Actual); ok && d < -1 {
+                found = append(found, Anomaly{anomalyOutOfOrder, s.Station, fmt.Sprintf("actual %s is before %s at %s", s.Actual, p.Stops[prev].Actual, p.Stops[prev].Station)})
+            }
+        }
+        prev = i
+    }
+    limit := anomalyMaxMPH()
+    for _, seg := range segmentSpeeds(p) {
+        // A minute more, in case both times were rounded against it
+        if seg.Minutes > 0 && seg.Miles/(float64(seg.Minutes+1)/60) > limit {
+            found = append(found, Anomaly{anomalyImpossibleSpeed, seg.To, fmt.Sprintf("%.1f miles from %s in %d min", seg.Miles, seg.From, seg.Minutes)})
+        }
+    }
+    return found
+}
+
+// Anomalies in after that weren't in before
+func newAnomalies(before, after TrainProgress) []Anomaly {
+    old := findAnomalies(before)
+    var added []Anomaly
+    for _, a := range findAnomalies(after) {
+        if !slices.ContainsFunc(old, func(o Anomaly) bool { return o.Kind == a.Kind && o.Tiploc == a.Tiploc }) {
+            added = append(added, a)
+        }
+    }
+    return added
+}
+
+func quarantineUpdate(p TrainProgress, anomalies []Anomaly) {
+    for _, a := range anomalies {
+        quarantinedUpdates.WithLabelValues(a.Kind).Inc()
+        log.Printf("Quarantined TS update for %s (%s): %s at %s: %s", p.RID, p.TrainID, a.Kind, a.Tiploc, a.Detail)
+    }
+    quarantineMu.Lock()
+    defer quarantineMu.Unlock()
+    for _, a := range anomalies {
+        quarantineCounts[a.Kind]++
+    }
+    quarantine = append(quarantine, QuarantinedUpdate{clock.Now(), p.RID, p.TrainID, anomalies})
+    if len(quarantine) > quarantineKept {
+        quarantine = slices.Clone(quarantine[len(quarantine)-quarantineKept:])
+    }
+}
+
+// GET /admin/anomalies: quarantined updates, newest first, with counts
+// by kind since the process started
+func anomaliesHandler(w http.ResponseWriter, r *http.Request) {
+    quarantineMu.Lock()
+    recent := slices.Clone(quarantine)
+    counts := map[string]int{anomalyBeforeOrigin: 0, anomalyOutOfOrder: 0, anomalyImpossibleSpeed: 0}
+    maps.Copy(counts, quarantineCounts)
+    quarantineMu.Unlock()
+    slices.Reverse(recent)
+    writeJSON(w, struct {
+        Counts map[string]int      `json:"counts"`
+        Recent []QuarantinedUpdate `json:"recent"`
+    }{counts, recent})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "encoding/json"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+func writeJSON(w http.ResponseWriter, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(v); err != nil {
+        log.Printf("Failed to write JSON response: %v", err)
+    }
+}
+
+// Most days of delay history one request can ask for
+const maxDelayHistoryDays = 365
+
+// GET /api/v1/train/{headcode}/delay-history?days=30
+func delayHistoryHandler(w http.ResponseWriter, r *http.Request) {
+    headcode := strings.ToUpper(r.PathValue("headcode"))
+    days := 30
+    if v := r.URL.Query().Get("days"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > maxDelayHistoryDays {
+            http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+            return
+        }
+        days = n
+    }
+    q, err := parseListQuery(r, dayDelayFields)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    history, err := delayHistory(headcode, days, clock.Now())
+    if err != nil {
+        log.Printf("Failed to read delay history for %s: %v", headcode, err)
+        http.Error(w, "failed to read delay history", http.StatusInternalServerError)
+        return
+    }
+    history = applyListQuery(w, r, history, dayDelayFields, q)
+    writeJSON(w, struct {
+        Headcode string     `json:"headcode"`
+        Days     int        `json:"days"`
+        History  []DayDelay `json:"history"`
+    }{headcode, days, history})
+}
+
+var dayDelayFields = listFields[DayDelay]{
+    "date": func(d DayDelay) string { return d.Date },
+    "delay": func(d DayDelay) string {
+        if d.Delay == nil {
+            return ""
+        }
+        return strconv.Itoa(*d.Delay)
+    },
+    "cancelled": func(d DayDelay) string { return strconv.FormatBool(d.Cancelled) },
+}
+
+var boardRowFields = listFields[BoardRow]{
+    "time":        func(b BoardRow) string { return b.Time },
+    "train_id":    func(b BoardRow) string { return b.TrainID },
+    "destination": func(b BoardRow) string { return stationKey(b.Destination) },
+    "platform":    func(b BoardRow) string { return b.Platform },
+    "toc":         func(b BoardRow) string { return b.TOC },
+    "status":      func(b BoardRow) string { return b.Status },
+    "mode":        func(b BoardRow) string { return b.Mode },
+}
+
+// GET /api/v1/board/{crs}?limit=&offset=&sort=&toc=&all=true
+func boardAPIHandler(w http.ResponseWriter, r *http.Request) {
+    crs := strings.ToUpper(r.PathValue("crs"))
+    q, err := parseListQuery(r, boardRowFields)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    opts := boardOptionsFor(r)
+    board := buildBoard(crs, boardOptions{All: opts.All, To: opts.To}, clock.Now())
+    rows := applyListQuery(w, r, board.allRows(), boardRowFields, q)
+    writeJSON(w, struct {
+        CRS        string     `json:"crs"`
+        Departures []BoardRow `json:"departures"`
+    }{crs, rows})
+}
+
+// POST /api/v1/admin/reload-timetable: fetch the timetable again from its
+// configured source, e.g. after Darwin publishes a corrected snapshot
+func reloadTimetableHandler(w http.ResponseWriter, r *http.Request) {
+    log.Printf("Timetable reload requested")
+    go loadTimetable()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusAccepted)
+    writeJSON(w, struct {
+        Status string `json:"status"`
+    }{"reloading"})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "log"
+    "time"
+)
+
+// Record of finished journeys and their stop-level times, kept in SQLite
+// independently of the live progress store. Set ARCHIVE_PATH=off to
+// disable it.
+var archiveDB *sql.DB
+
+func initArchive() error {
+    path := envOr("ARCHIVE_PATH", "archive.db")
+    if path == "off" {
+        return nil
+    }
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        return err
+    }
+    _, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS journeys (
+            rid TEXT PRIMARY KEY,
+            ssd TEXT NOT NULL,
+            train_id TEXT NOT NULL,
+            toc TEXT NOT NULL,
+            origin TEXT NOT NULL,
+            destination TEXT NOT NULL,
+            terminal_delay INTEGER,
+            cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+            archived_at DATETIME NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS journeys_train_id ON journeys (train_id, ssd);
+        CREATE INDEX IF NOT EXISTS journeys_ssd ON journeys (ssd);
+        CREATE TABLE IF NOT EXISTS stops (
+            rid TEXT NOT NULL,
+            seq INTEGER NOT NULL,
+            tiploc TEXT NOT NULL,
+            event TEXT NOT NULL,
+            scheduled TEXT NOT NULL,
+            actual TEXT NOT NULL,
+            cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+            PRIMARY KEY (rid, seq)
+        );
+        CREATE TABLE IF NOT EXISTS rid_links (
+            old_rid TEXT PRIMARY KEY,
+            new_rid TEXT NOT NULL,
+            linked_at DATETIME NOT NULL
+        );` + tokenTableSQL + ruleTableSQL + outboxTableSQL + shortLinkTableSQL)
+    if err == nil {
+        err = migrateOutbox(db)
+    }
+    if err != nil {
+        db.Close()
+        return err
+    }
+    archiveDB = db
+    return nil
+}
+
+// A journey is finished once it has arrived at its destination or been
+// cancelled there
+func journeyFinished(p TrainProgress) bool {
+    if len(p.Stops) == 0 {
+        return false
+    }
+    last := p.Stops[len(p.Stops)-1]
+    return last.Actual != "" || last.Status == "Cancelled"
+}
+
+// Lateness at the destination in minutes, if it arrived
+func terminalDelay(p TrainProgress) (int, bool) {
+    if len(p.Stops) == 0 {
+        return 0, false
+    }
+    last := p.Stops[len(p.Stops)-1]
+    if last.Actual == "" {
+        return 0, false
+    }
+    return minutesLate(last.Scheduled, last.Actual)
+}
+
+// Write a finished journey to the archive, replacing any earlier copy
+func archiveJourney(p TrainProgress) {
+    if archiveDB == nil || len(p.Stops) == 0 {
+        return
+    }
+    var delay sql.NullInt64
+    if d, ok := terminalDelay(p); ok {
+        delay = sql.NullInt64{Int64: int64(d), Valid: true}
+    }
+    cancelled := p.Stops[len(p.Stops)-1].Status == "Cancelled"
+
+    tx, err := archiveDB.Begin()
+    if err != nil {
+        log.Printf("Failed to archive %s: %v", p.RID, err)
+        return
+    }
+    defer tx.Rollback()
+    _, err = tx.Exec(`INSERT OR REPLACE INTO journeys
+        (rid, ssd, train_id, toc, origin, destination, terminal_delay, cancelled, archived_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        p.RID, p.SSD, p.TrainID, p.TOC, p.Stops[0].Station, p.Stops[len(p.Stops)-1].Station, delay, cancelled, clock.Now().UTC())
+    if err != nil {
+        log.Printf("Failed to archive %s: %v", p.RID, err)
+        return
+    }
+    if _, err := tx.Exec(`DELETE FROM stops WHERE rid = ?`, p.RID); err != nil {
+        log.Printf("Failed to archive %s: %v", p.RID, err)
+        return
+    }
+    for i, s := range p.Stops {
+        _, err := tx.Exec(`INSERT INTO stops (rid, seq, tiploc, event, scheduled, actual, cancelled) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+            p.RID, i, s.Station, s.Event, s.Scheduled, s.Actual, s.Status == "Cancelled")
+        if err != nil {
+            log.Printf("Failed to archive %s: %v", p.RID, err)
+            return
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        log.Printf("Failed to archive %s: %v", p.RID, err)
+    }
+}
+
+// A finished journey as archived, with the stops and times it recorded
+func archivedProgress(rid string) (TrainProgress, bool, error) {
+    p := TrainProgress{RID: rid}
+    if archiveDB == nil {
+        return p, false, nil
+    }
+    err := archiveDB.QueryRow(`SELECT ssd, train_id, toc FROM journeys WHERE rid = ?`, rid).Scan(&p.SSD, &p.TrainID, &p.TOC)
+    if errors.Is(err, sql.ErrNoRows) {
+        return p, false, nil
+    }
+    if err != nil {
+        return p, false, err
+    }
+    rows, err := archiveDB.Query(`SELECT tiploc, event, scheduled, actual, cancelled FROM stops WHERE rid = ? ORDER BY seq`, rid)
+    if err != nil {
+        return p, false, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var s Stop
+        var cancelled bool
+        if err := rows.Scan(&s.Station, &s.Event, &s.Scheduled, &s.Actual, &cancelled); err != nil {
+            return p, false, err
+        }
+        if cancelled {
+            s.Status = "Cancelled"
+        } else {
+            s.Status = stopStatus(s)
+        }
+        p.Stops = append(p.Stops, s)
+    }
+    return p, true, rows.Err()
+}
+
+// One day's outcome for a headcode. Delay is nil for days with no
+// arrival recorded.
+type DayDelay struct {
+    Date      string `json:"date"`
+    Delay     *int   `json:"delay"`
+    Cancelled bool   `json:"cancelled"`
+}
+
+// Terminal delay per day for a headcode over the last n days, oldest first.
+// If the headcode ran more than once on a day the worst run is used.
+func delayHistory(headcode string, days int, now time.Time) ([]DayDelay, error) {
+    today := now.In(ukLocation)
+    history := make([]DayDelay, days)
+    index := map[string]int{}
+    for i := range history {
+        date := today.AddDate(0, 0, i-days+1).Format("2006-01-02")
+        history[i].Date = date
+        index[date] = i
+    }
+    if archiveDB == nil {
+        return history, nil
+    }
+    rows, err := archiveDB.Query(`SELECT ssd, terminal_delay, cancelled FROM journeys
+        WHERE train_id = ? AND ssd >= ? AND ssd <= ?`, headcode, history[0].Date, history[days-1].Date)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var (
+            ssd       string
+            delay     sql.NullInt64
+            cancelled bool
+        )
+        if err := rows.Scan(&ssd, &delay, &cancelled); err != nil {
+            return nil, err
+        }
+        day := &history[index[ssd]]
+        day.Cancelled = day.Cancelled || cancelled
+        if delay.Valid && (day.Delay == nil || int(delay.Int64) > *day.Delay) {
+            d := int(delay.Int64)
+            day.Delay = &d
+        }
+    }
+    return history, rows.Err()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+)
+
+// POST /api/v1/trains:batchGet looks up many trains in one request, for
+// dashboards that would otherwise make one per train. The body names
+// them by RID, by headcode (today's current run) or both, up to
+// BATCH_GET_MAX (default 50) in all.
+type batchGetRequest struct {
+    RIDs      []string `json:"rids"`
+    Headcodes []string `json:"headcodes"`
+}
+
+// One train asked for, in the order asked. A train that can't be found
+// or loaded doesn't fail the rest.
+type BatchTrain struct {
+    RID      string         `json:"rid,omitempty"`
+    Headcode string         `json:"headcode,omitempty"`
+    Found    bool           `json:"found"`
+    Status   *TrainLocation `json:"status,omitempty"`
+    Error    string         `json:"error,omitempty"`
+}
+
+func batchGetTrain(rid, headcode string) BatchTrain {
+    b := BatchTrain{RID: rid, Headcode: headcode}
+    var (
+        p   TrainProgress
+        err error
+    )
+    if headcode != "" {
+        p, b.Found, err = currentRun(headcode)
+    } else {
+        p, b.Found, err = lookupProgress(rid)
+    }
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", rid+headcode, err)
+        b.Found, b.Error = false, "failed to load progress"
+        return b
+    }
+    if b.Found {
+        if headcode != "" {
+            p.Position = berthPosition(headcode)
+        }
+        loc := trainLocation(p)
+        b.Status = &loc
+    }
+    return b
+}
+
+func batchGetHandler(w http.ResponseWriter, r *http.Request) {
+    var req batchGetRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+        http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+        return
+    }
+    n, limit := len(req.RIDs)+len(req.Headcodes), max(1, envInt("BATCH_GET_MAX", 50))
+    if n == 0 || n > limit {
+        http.Error(w, fmt.Sprintf("ask for between 1 and %d trains", limit), http.StatusBadRequest)
+        return
+    }
+    trains := make([]BatchTrain, 0, n)
+    for _, rid := range req.RIDs {
+        trains = append(trains, batchGetTrain(strings.TrimSpace(rid), ""))
+    }
+    for _, h := range req.Headcodes {
+        trains = append(trains, batchGetTrain("", strings.ToUpper(strings.TrimSpace(h))))
+    }
+    writeJSON(w, struct {
+        Trains []BatchTrain `json:"trains"`
+    }{trains})
+}
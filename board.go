@@ -0,0 +1,631 @@
+package main
+
+import (
+    "cmp"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// One departure on a station board
+type BoardRow struct {
+    RID      string `json:"rid"`
+    TrainID  string `json:"train_id"`
+    Tiploc   string `json:"tiploc"` // which of the station's TIPLOCs it leaves from
+    Time     string `json:"time"`
+    Expected string `json:"expected,omitempty"`
+    Actual   string `json:"actual,omitempty"`
+    // Where Expected came from, and whether it's only a guess
+    ForecastSource     string `json:"forecast_source,omitempty"`
+    ForecastSourceInst string `json:"forecast_source_inst,omitempty"`
+    Delayed            bool   `json:"delayed"`
+    // Minutes until departure, negative once it's left, and the same as text
+    DueMins     *int   `json:"due_mins,omitempty"`
+    Relative    string `json:"relative,omitempty"`
+    Destination string `json:"destination"`
+    // With ?to=, when this train gets there (expected, else scheduled),
+    // and whether it's the first to
+    Arrival string `json:"arrival,omitempty"`
+    Fastest bool   `json:"fastest,omitempty"`
+    Platform    string `json:"platform,omitempty"`
+    // Confirmed by the signaller or station, not only planned or expected
+    PlatformConfirmed bool `json:"platform_confirmed"`
+    TOC         string `json:"toc"`
+    Status      string `json:"status,omitempty"`
+    Mode        string `json:"mode"`
+    Note        string `json:"note,omitempty"`
+    VSTP        bool   `json:"vstp"`
+    Charter     bool   `json:"charter"`
+
+    // Why it's late or cancelled, and coaches in the formation, if known
+    Reason string `json:"reason,omitempty"`
+    Length int    `json:"length,omitempty"`
+    // The units working it, from the allocations source
+    Allocation *Allocation `json:"allocation,omitempty"`
+    // Reason is in English; pages say it in their own language from this
+    Cause DelayReason `json:"-"`
+}
+
+// Departures sharing a platform or destination, or all of them when the
+// board isn't grouped
+type BoardGroup struct {
+    Key  string // platform number or destination CRS
+    Rows []BoardRow
+}
+
+type Board struct {
+    CRS     string
+    Group   string // station group slug, for boards covering several stations
+    Name    string // what the board is titled with: the CRS or group name
+    Tiplocs []string
+    GroupBy string // "", "platform" or "destination"
+    To      string // CRS chosen with ?to=, if any
+    Groups  []BoardGroup
+    Columns map[string]bool // optional columns shown, from ?cols=
+    // Show the units working each train
+    Enthusiast bool
+}
+
+// Ways a board can be grouped with ?group=
+var boardGroupings = map[string]func(BoardRow) string{
+    "platform":    func(r BoardRow) string { return r.Platform },
+    "destination": func(r BoardRow) string { return stationKey(r.Destination) },
+}
+
+// Every departure on the board, group by group
+func (b Board) allRows() []BoardRow {
+    var rows []BoardRow
+    for _, g := range b.Groups {
+        rows = append(rows, g.Rows...)
+    }
+    return rows
+}
+
+// How far ahead boards look, and how long departed trains linger
+const (
+    boardWindow     = 2 * time.Hour
+    boardLookBehind = 5 * time.Minute
+)
+
+// Build the departures board for a station from today's schedules.
+// Non-passenger services are left off unless opts.All is set.
+func buildBoard(crs string, opts boardOptions, now time.Time) Board {
+    markBoardViewed(crs)
+    return collectDepartures(Board{CRS: crs, Name: crs, Tiplocs: tiplocsForCRS(crs)}, opts, now)
+}
+
+// Fill in a board's departures from any of its TIPLOCs
+func collectDepartures(board Board, opts boardOptions, now time.Time) Board {
+    board.GroupBy, board.To, board.Enthusiast = opts.GroupBy, opts.To, opts.Enthusiast
+    board.Columns = map[string]bool{}
+    for _, c := range opts.Columns {
+        board.Columns[c] = true
+    }
+    toTiplocs := tiplocsForCRS(opts.To)
+    if len(board.Tiplocs) == 0 {
+        return board
+    }
+    nowHHMM := now.In(ukLocation).Format("15:04")
+    today := now.In(ukLocation).Format("2006-01-02")
+    // Trains that started yesterday can still be calling after midnight
+    yesterday := now.In(ukLocation).AddDate(0, 0, -1).Format("2006-01-02")
+
+    type row struct {
+        BoardRow
+        offset int // minutes from now until the scheduled departure
+        due    int // and until the expected one
+        // The call at the ?to= station, to find its live arrival
+        toTiploc, toTime string
+    }
+    var rows []row
+    nowMins, _ := parseRailTime(nowHHMM)
+    journeysMu.RLock()
+    calls := callsBetween(board.Tiplocs, nowMins-int(boardLookBehind.Minutes()), nowMins+int(boardWindow.Minutes()))
+    for j, points := range calls {
+        if (j.SSD != today && j.SSD != yesterday) || (!opts.All && !j.IsPublic()) {
+            continue
+        }
+        for _, i := range points {
+            p := j.Points[i]
+            dep, ok := boardDeparture(p, opts.All)
+            if !ok {
+                continue
+            }
+            if j.SSD == yesterday {
+                at, ok := journeyCallTime(j, dep)
+                if !ok || now.Sub(at) > boardLookBehind || at.Sub(now) > boardWindow {
+                    continue
+                }
+            }
+            offset, ok := minutesLate(nowHHMM, dep)
+            if !ok || offset < -int(boardLookBehind.Minutes()) || offset > int(boardWindow.Minutes()) {
+                continue
+            }
+            r := row{BoardRow: BoardRow{
+                RID:         j.RID,
+                TrainID:     j.TrainID,
+                Tiploc:      p.Tiploc,
+                Time:        dep,
+                Destination: j.Points[len(j.Points)-1].Tiploc,
+                Platform:    p.Plat,
+                TOC:         j.TOC,
+                Mode:        j.Mode(),
+                Note:        activityNote(p.Act),
+                VSTP:        j.VSTP,
+                Charter:     j.IsCharter,
+                Allocation:  allocationFor(j.RID, j.TrainID, j.SSD),
+            }, offset: offset}
+            if p.Cancelled {
+                r.Status, r.Cause = "Cancelled", j.CancelCause()
+                r.Reason = r.Cause.Text(defaultLang)
+            }
+            for _, later := range j.Points[i+1:] {
+                if slices.Contains(toTiplocs, later.Tiploc) && isPublicCall(later) && later.Pta != "" {
+                    r.toTiploc, r.toTime, r.Arrival = later.Tiploc, later.Pta, later.Pta
+                    break
+                }
+            }
+            // Calling at the same station twice only shows the first visit
+            rows = append(rows, r)
+            break
+        }
+    }
+    journeysMu.RUnlock()
+
+    for i := range rows {
+        r := &rows[i]
+        if p, ok := applyLiveProgress(&r.BoardRow); ok && r.toTiploc != "" {
+            applyLiveArrival(&r.BoardRow, r.toTiploc, r.toTime, p)
+        }
+        r.due = r.offset
+        if r.Status != "Cancelled" {
+            if mins, ok := relativeMinutes(r.Time, r.Expected, r.Actual, now); ok {
+                r.DueMins = &mins
+                r.due = mins
+            }
+        }
+        r.Relative = relativeTime(defaultLang, "dep", r.Time, r.Expected, r.Actual, r.Status, now)
+    }
+    // Expected departure, then scheduled, then train ID, so rows with the
+    // same times don't swap places between refreshes
+    pinned := boardPinnedStatuses()
+    sort.SliceStable(rows, func(i, j int) bool {
+        a, b := rows[i], rows[j]
+        if pa, pb := pinned[a.Status], pinned[b.Status]; pa != pb {
+            return pb
+        }
+        if a.due != b.due {
+            return a.due < b.due
+        }
+        if a.offset != b.offset {
+            return a.offset < b.offset
+        }
+        if a.TrainID != b.TrainID {
+            return a.TrainID < b.TrainID
+        }
+        return a.RID < b.RID
+    })
+    // The first arrival at ?to= among trains that haven't left yet
+    fastest, fastestAt := -1, 0
+    for i, r := range rows {
+        if r.Arrival == "" || r.Status == "Cancelled" || r.Actual != "" {
+            continue
+        }
+        if at, ok := minutesLate(nowHHMM, r.Arrival); ok && (fastest < 0 || at < fastestAt) {
+            fastest, fastestAt = i, at
+        }
+    }
+    if fastest >= 0 {
+        rows[fastest].Fastest = true
+    }
+    var flat []BoardRow
+    for _, r := range rows {
+        flat = append(flat, r.BoardRow)
+    }
+    board.Groups = groupRows(flat, opts.GroupBy)
+    return board
+}
+
+// Statuses listed in BOARD_PIN_TO_BOTTOM, e.g. "Cancelled", go after
+// every other row instead of in time order
+func boardPinnedStatuses() map[string]bool {
+    pinned := map[string]bool{}
+    for _, st := range strings.Split(os.Getenv("BOARD_PIN_TO_BOTTOM"), ",") {
+        if st = strings.TrimSpace(st); st != "" {
+            pinned[st] = true
+        }
+    }
+    return pinned
+}
+
+// When a journey calls at hhmm, taking times before it left its origin
+// to be after midnight
+func journeyCallTime(j *Journey, hhmm string) (time.Time, bool) {
+    m, ok := callMinutes(j.Points[0])
+    if !ok {
+        return time.Time{}, false
+    }
+    start, ok := railDateTime(j.SSD, fmt.Sprintf("%02d:%02d", m/60, m%60), time.Time{})
+    if !ok {
+        return time.Time{}, false
+    }
+    return railDateTime(j.SSD, hhmm, start)
+}
+
+// Departure time to show for a calling point. Passenger boards only list
+// public departures; with all set, stops non-passenger services make in
+// their working timetable are listed too.
+func boardDeparture(p CallingPoint, all bool) (string, bool) {
+    if isPublicDeparture(p) {
+        return p.Ptd, true
+    }
+    if all && p.Wtd != "" && len(p.Wtd) >= 5 {
+        return p.Wtd[:5], true
+    }
+    return "", false
+}
+
+// Split time-ordered rows into groups, keeping them in time order within
+// each group. Destinations are listed by their next departure; platforms
+// in number order, with rows not yet given a platform last.
+func groupRows(rows []BoardRow, groupBy string) []BoardGroup {
+    key, ok := boardGroupings[groupBy]
+    if !ok {
+        return []BoardGroup{{Rows: rows}}
+    }
+    var groups []BoardGroup
+    index := map[string]int{}
+    for _, r := range rows {
+        k := key(r)
+        i, ok := index[k]
+        if !ok {
+            i = len(groups)
+            index[k] = i
+            groups = append(groups, BoardGroup{Key: k})
+        }
+        groups[i].Rows = append(groups[i].Rows, r)
+    }
+    if groupBy == "platform" {
+        sort.SliceStable(groups, func(i, j int) bool { return platformLess(groups[i].Key, groups[j].Key) })
+    }
+    return groups
+}
+
+// Order platforms numerically where possible, so 2 comes before 10 and
+// 2A after 2
+func platformLess(a, b string) bool {
+    if a == "" || b == "" {
+        return b == "" && a != ""
+    }
+    na, ra := leadingNumber(a)
+    nb, rb := leadingNumber(b)
+    if na != nb {
+        return na < nb
+    }
+    return ra < rb
+}
+
+func leadingNumber(s string) (int, string) {
+    n, i := 0, 0
+    for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+        n = n*10 + int(s[i]-'0')
+        i++
+    }
+    if i == 0 {
+        // Lettered platforms go after numbered ones
+        return 1 << 30, s
+    }
+    return n, s[i:]
+}
+
+// Overlay live forecasts from the progress store onto a scheduled row,
+// returning the progress if there was any
+func applyLiveProgress(row *BoardRow) (TrainProgress, bool) {
+    p, ok, err := progressStore.Get(row.RID)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", row.RID, err)
+        return p, false
+    }
+    if !ok {
+        return p, false
+    }
+    for _, s := range p.Stops {
+        if s.Station != row.Tiploc || s.Scheduled != row.Time {
+            continue
+        }
+        row.Expected, row.Actual = s.Expected, s.Actual
+        row.ForecastSource, row.ForecastSourceInst, row.Delayed = s.ForecastSource, s.ForecastSourceInst, s.Delayed
+        if s.Actual != "" {
+            row.Expected = s.Actual
+        }
+        if s.Platform != "" {
+            row.Platform, row.PlatformConfirmed = s.Platform, s.PlatformConfirmed
+        }
+        if s.Status != "" {
+            row.Status = s.Status
+        }
+        if s.Reinstated && row.Status == "" {
+            row.Status = "Reinstated"
+        }
+        row.Length = s.Length
+        break
+    }
+    if row.Reason == "" && row.Status != "Cancelled" {
+        row.Cause = p.LateCause()
+        row.Reason = row.Cause.Text(defaultLang)
+    }
+    return p, true
+}
+
+// Replace a row's scheduled arrival at ?to= with the live one. A train
+// cancelled there doesn't get there at all.
+func applyLiveArrival(row *BoardRow, tiploc, scheduled string, p TrainProgress) {
+    for _, s := range p.Stops {
+        if s.Station != tiploc || s.Scheduled != scheduled {
+            continue
+        }
+        switch {
+        case s.Status == "Cancelled":
+            row.Arrival = ""
+        case s.Actual != "":
+            row.Arrival = s.Actual
+        case s.Expected != "":
+            row.Arrival = s.Expected
+        }
+        return
+    }
+}
+
+// Template for a station board page
+var boardPageTmpl = template.Must(template.New("boardPage").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "board_title" .Title}}</title>
+    {{meta .Meta}}
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+    {{with .BoardURL}}<link rel="alternate" type="application/json+oembed" href="/oembed?url={{.}}" title="{{T "board_title" $.Title}}">{{end}}
+    <script src="https://unpkg.com/htmx.org@1.9.10"></script>
+</head>
+<body>
+    <h1>{{T "board_title" .Title}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/stations">{{T "stations_title"}}</a>{{if .BoardURL}} | <a href="/disruptions?crs={{.Title}}">{{T "disruptions_title"}}</a> | <a href="/board/{{.Title}}/timetable">{{T "walkup_link"}}</a>{{end}} | <a href="{{.EnthusiastToggle}}">{{if .Options.Enthusiast}}{{T "enthusiast_off"}}{{else}}{{T "enthusiast_on"}}{{end}}</a></p>
+    <p>
+        <a href="?">{{T "group_none"}}</a> |
+        <a href="?group=platform">{{T "group_platform"}}</a> |
+        <a href="?group=destination">{{T "group_destination"}}</a> |
+        {{if .Options.All}}<a href="?group={{.Options.GroupBy}}">{{T "passenger_only"}}</a>{{else}}<a href="?all=true&group={{.Options.GroupBy}}">{{T "all_services"}}</a>{{end}}
+    </p>
+    <form method="get">
+        <input type="hidden" name="group" value="{{.Options.GroupBy}}">
+        {{if .Options.All}}<input type="hidden" name="all" value="true">{{end}}
+        <label>{{T "fastest_to_label"}} <input name="to" value="{{.Options.To}}" size="4" maxlength="3" placeholder="CRS"></label>
+        <button type="submit">{{T "stations_search"}}</button>
+    </form>
+    <form method="get">
+        <input type="hidden" name="group" value="{{.Options.GroupBy}}">
+        {{if .Options.All}}<input type="hidden" name="all" value="true">{{end}}
+        {{with .Options.To}}<input type="hidden" name="to" value="{{.}}">{{end}}
+        <input type="hidden" name="cols" value="">
+        {{T "board_columns"}}
+        {{range .Options.ColumnChoices}}<label><input type="checkbox" name="cols" value="{{.Name}}"{{if .On}} checked{{end}}> {{T .Label}}</label> {{end}}
+        <button type="submit">{{T "show_columns"}}</button>
+    </form>
+    {{range .PriorityMessages}}<div class="nrcc nrcc-priority" role="alert">{{nrcc .Text}}</div>{{end}}
+    {{with .InfoMessages}}<details class="nrcc-info"><summary>{{T "nrcc_more" (len .)}}</summary>{{range .}}<div class="nrcc">{{nrcc .Text}}</div>{{end}}</details>{{end}}
+    <div id="board" hx-get="{{.Path}}/departures{{.Query}}" hx-trigger="load, every 30s" hx-swap="innerHTML">
+        <p>{{T "loading"}}</p>
+    </div>
+</body>
+</html>
+`))
+
+// Template for the departures table (htmx partial)
+var boardTmpl = template.Must(template.New("board").Funcs(templateFuncs).Parse(`
+{{$cols := .ColumnCount}}
+<table>
+    <tr><th>{{T "time"}}</th>{{if .Columns.expected}}<th>{{T "expected"}}</th>{{end}}{{if .Group}}<th>{{T "departs_from"}}</th>{{end}}<th>{{T "destination"}}</th>{{if .Columns.platform}}<th>{{T "platform"}}</th>{{end}}{{if .Columns.length}}<th>{{T "length_col"}}</th>{{end}}{{if .Columns.operator}}<th>{{T "operator"}}</th>{{end}}{{if .Columns.reason}}<th>{{T "reason_col"}}</th>{{end}}<th>{{T "status"}}</th></tr>
+    {{range .Groups}}
+    {{if $.GroupBy}}
+        <tr class="group"><th colspan="{{$cols}}">{{if eq $.GroupBy "platform"}}{{if .Key}}{{T "platform"}} {{.Key}}{{else}}{{T "platform_unknown"}}{{end}}{{else}}{{station (index .Rows 0).Destination}}{{end}}</th></tr>
+    {{end}}
+    {{range .Rows}}
+        <tr class="toc-row toc-{{.TOC}}">
+            <td>{{hhmm .Time}}</td>
+            {{if $.Columns.expected}}<td>{{if .Delayed}}<span class="late" title="{{T "delay_unknown"}}">{{T "Delayed"}}</span>{{else}}<span{{with .ForecastSource}} title="{{T "forecast_source" .}}"{{end}}>{{hhmm .Expected}}</span> {{delay .Time .Expected}}{{with relative "dep" .Time .Expected .Actual .Status}} <span class="muted">{{.}}</span>{{end}}{{end}}</td>{{end}}
+            {{if $.Group}}<td>{{station .Tiploc}}</td>{{end}}
+            <td>{{modeBadge .Mode}}{{station .Destination}}{{with .Arrival}} <span class="muted">{{T "arrives_at" $.To .}}</span>{{end}}{{if .Fastest}} <span class="fastest">{{T "fastest_to" $.To}}</span>{{end}}{{if .VSTP}} <span class="muted">({{T "vstp"}})</span>{{end}}{{if .Charter}} <span class="muted">({{T "charter"}})</span>{{end}}{{with .Note}} <em>{{T .}}</em>{{end}}{{if $.Enthusiast}}{{with .Allocation}} <span class="muted">{{with .Class}}{{T "unit_class" .}}{{end}}{{with .Formation}} {{.}}{{end}}</span>{{end}}{{end}}</td>
+            {{if $.Columns.platform}}<td>{{platform .Platform .PlatformConfirmed}}</td>{{end}}
+            {{if $.Columns.length}}<td>{{with .Length}}{{T "coaches" .}}{{end}}</td>{{end}}
+            {{if $.Columns.operator}}<td>{{operator .TOC}}</td>{{end}}
+            {{if $.Columns.reason}}<td>{{reason .Cause}}</td>{{end}}
+            <td>{{T .Status}}{{if ne .Status "Cancelled"}} <form method="post" action="/watch" target="_top" style="display:inline"><input type="hidden" name="rid" value="{{.RID}}"><button type="submit">{{T "track_this"}}</button></form>{{end}}</td>
+        </tr>
+    {{else}}
+        <tr><td colspan="{{$cols}}">{{T "no_departures"}}</td></tr>
+    {{end}}
+    {{else}}
+        <tr><td colspan="{{$cols}}">{{T "no_departures"}}</td></tr>
+    {{end}}
+</table>
+`))
+
+// How a board is built, from ?group= and ?all=
+type boardOptions struct {
+    GroupBy string // "", or one of boardGroupings
+    // Include empty stock moves and other non-passenger services
+    All bool
+    To  string // CRS to find the fastest train to
+    // Optional columns to show, from ?cols= or the board_cols cookie
+    Columns []string
+    // Show unit allocations, from ?enthusiast= or its cookie
+    Enthusiast bool
+}
+
+func boardOptionsFor(r *http.Request) boardOptions {
+    q := r.URL.Query()
+    opts := boardOptions{All: q.Get("all") == "true", To: strings.ToUpper(strings.TrimSpace(q.Get("to"))), Columns: requestBoardColumns(r), Enthusiast: enthusiastMode(r)}
+    if g := q.Get("group"); boardGroupings[g] != nil {
+        opts.GroupBy = g
+    }
+    return opts
+}
+
+// Query string that asks for the same options again
+func (o boardOptions) query() string {
+    v := url.Values{}
+    if o.GroupBy != "" {
+        v.Set("group", o.GroupBy)
+    }
+    if o.All {
+        v.Set("all", "true")
+    }
+    if o.To != "" {
+        v.Set("to", o.To)
+    }
+    if !slices.Equal(o.Columns, defaultBoardColumns) {
+        v.Set("cols", cmp.Or(strings.Join(o.Columns, ","), "none"))
+    }
+    if len(v) == 0 {
+        return ""
+    }
+    return "?" + v.Encode()
+}
+
+type boardPageData struct {
+    Lang, OtherLang, Theme string
+    Title, Path, Query     string
+    BoardURL               string // for oEmbed discovery; empty if the board can't be embedded
+    Options                boardOptions
+    Meta                   pageMeta
+    Messages               []StationMessage // NRCC messages for the station
+    EnthusiastToggle       string
+}
+
+func (d boardPageData) PriorityMessages() []StationMessage {
+    priority, _ := splitMessages(d.Messages)
+    return priority
+}
+
+func (d boardPageData) InfoMessages() []StationMessage {
+    _, info := splitMessages(d.Messages)
+    return info
+}
+
+// GET /board/{crs}: the board page for browsers, or the departures
+// themselves as JSON or a plain-text table
+func boardPageHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    rememberBoardColumns(w, r)
+    rememberEnthusiast(w, r)
+    opts := boardOptionsFor(r)
+    crs := strings.ToUpper(r.PathValue("crs"))
+    board := func() Board { return buildBoard(crs, opts, clock.Now()) }
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(boardPageTmpl, lang)
+            if err != nil {
+                return err
+            }
+            executeTemplate(w, r, tmpl, boardPageData{lang, otherLang(lang), requestTheme(w, r), crs, "/board/" + crs, opts.query(), requestBaseURL(r) + "/board/" + crs, opts, boardMeta(r, crs, lang), messagesForStation(crs), enthusiastToggle(r)})
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                CRS        string     `json:"crs"`
+                Departures []BoardRow `json:"departures"`
+            }{crs, board().allRows()}
+        },
+        Text: func(w io.Writer) { writeBoardText(w, board(), lang) },
+    })
+}
+
+// The board as a table for terminals
+func writeBoardText(w io.Writer, board Board, lang string) {
+    fmt.Fprintf(w, "%s\n\n", translate(lang, "board_title", board.Name))
+    var rows [][]string
+    for _, row := range board.allRows() {
+        expected := row.Expected
+        if row.Delayed {
+            expected = translate(lang, "Delayed")
+        }
+        cols := []string{row.Time}
+        if board.Columns["expected"] {
+            cols = append(cols, expected)
+        }
+        if board.Group != "" {
+            cols = append(cols, stationDisplayName(row.Tiploc, lang))
+        }
+        cols = append(cols, stationDisplayName(row.Destination, lang))
+        if board.Columns["platform"] {
+            cols = append(cols, row.Platform)
+        }
+        if board.Columns["length"] {
+            length := ""
+            if row.Length > 0 {
+                length = strconv.Itoa(row.Length)
+            }
+            cols = append(cols, length)
+        }
+        if board.Columns["operator"] {
+            cols = append(cols, operatorName(row.TOC))
+        }
+        if board.Columns["reason"] {
+            cols = append(cols, row.Cause.Text(lang))
+        }
+        cols = append(cols, translate(lang, row.Status))
+        if board.To != "" {
+            arrival := row.Arrival
+            if row.Fastest {
+                arrival += " *"
+            }
+            cols = append(cols, arrival)
+        }
+        rows = append(rows, cols)
+    }
+    if len(rows) == 0 {
+        fmt.Fprintln(w, translate(lang, "no_departures"))
+        return
+    }
+    header := []string{translate(lang, "time")}
+    if board.Columns["expected"] {
+        header = append(header, translate(lang, "expected"))
+    }
+    if board.Group != "" {
+        header = append(header, translate(lang, "departs_from"))
+    }
+    header = append(header, translate(lang, "destination"))
+    // Expected has gone in already, before the destination
+    for _, c := range boardColumnNames[1:] {
+        if board.Columns[c] {
+            header = append(header, translate(lang, boardColumnLabels[c]))
+        }
+    }
+    header = append(header, translate(lang, "status"))
+    if board.To != "" {
+        header = append(header, translate(lang, "arrives_col", board.To))
+    }
+    writeTextTable(w, header, rows)
+}
+
+func boardHandler(w http.ResponseWriter, r *http.Request) {
+    crs := strings.ToUpper(r.PathValue("crs"))
+    log.Printf("Serving board for %s", crs)
+    board := buildBoard(crs, boardOptionsFor(r), clock.Now())
+    tmpl, err := localisedTemplate(boardTmpl, requestLang(w, r))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    executeTemplate(w, r, tmpl, board)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "net/http"
+    "slices"
+    "strings"
+    "time"
+)
+
+// Boards always show the time, destination and status; the other columns
+// are chosen with ?cols= (comma-separated or repeated), which is also
+// remembered in the board_cols cookie for next time
+var boardColumnNames = []string{"expected", "platform", "length", "operator", "reason"}
+
+var defaultBoardColumns = []string{"expected", "platform", "operator"}
+
+// Columns from a ?cols= or board_cols value, in display order. Reports
+// false if it names none that exist, unless it's deliberately empty.
+func parseBoardColumns(values []string) ([]string, bool) {
+    var cols []string
+    empty := true
+    for _, v := range values {
+        for _, c := range strings.Split(v, ",") {
+            if c = strings.TrimSpace(c); c == "" || c == "none" {
+                continue
+            }
+            empty = false
+            if slices.Contains(boardColumnNames, c) && !slices.Contains(cols, c) {
+                cols = append(cols, c)
+            }
+        }
+    }
+    if len(cols) == 0 && !empty {
+        return nil, false
+    }
+    slices.SortFunc(cols, func(a, b string) int { return slices.Index(boardColumnNames, a) - slices.Index(boardColumnNames, b) })
+    return cols, true
+}
+
+func requestBoardColumns(r *http.Request) []string {
+    if values, ok := r.URL.Query()["cols"]; ok {
+        if cols, ok := parseBoardColumns(values); ok {
+            return cols
+        }
+    }
+    if c, err := r.Cookie("board_cols"); err == nil {
+        if cols, ok := parseBoardColumns([]string{c.Value}); ok {
+            return cols
+        }
+    }
+    return defaultBoardColumns
+}
+
+// Keep a board page's ?cols= as the browser's choice of columns
+func rememberBoardColumns(w http.ResponseWriter, r *http.Request) {
+    if !r.URL.Query().Has("cols") {
+        return
+    }
+    value := strings.Join(requestBoardColumns(r), ",")
+    if value == "" {
+        value = "none"
+    }
+    http.SetCookie(w, &http.Cookie{Name: "board_cols", Value: value, Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds()), SameSite: http.SameSiteLaxMode})
+}
+
+// Translation keys for the columns' headings
+var boardColumnLabels = map[string]string{
+    "expected": "expected",
+    "platform": "platform",
+    "length":   "length_col",
+    "operator": "operator",
+    "reason":   "reason_col",
+}
+
+// A checkbox on the board page's column chooser
+type boardColumnChoice struct {
+    Name, Label string
+    On          bool
+}
+
+func (o boardOptions) ColumnChoices() []boardColumnChoice {
+    var choices []boardColumnChoice
+    for _, c := range boardColumnNames {
+        choices = append(choices, boardColumnChoice{c, boardColumnLabels[c], slices.Contains(o.Columns, c)})
+    }
+    return choices
+}
+
+// Columns in a board's table, for colspans
+func (b Board) ColumnCount() int {
+    n := 3 + len(b.Columns)
+    if b.Group != "" {
+        n++
+    }
+    return n
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// "minimaltrains board MAN": show a station's departures in the terminal,
+// redrawn every --interval. The server renders the table, so station and
+// operator names come from its reference data.
+func runBoard(args []string) int {
+    fs := flag.NewFlagSet("board", flag.ExitOnError)
+    base := fs.String("url", envOr("MINIMALTRAINS_URL", "http://localhost:8081"), "instance to fetch the board from")
+    token := fs.String("token", os.Getenv("MINIMALTRAINS_TOKEN"), "API token, if the instance needs one")
+    interval := fs.Duration("interval", 30*time.Second, "how often to refresh")
+    once := fs.Bool("once", false, "print the board once and exit")
+    all := fs.Bool("all", false, "include non-passenger services")
+    lang := fs.String("lang", defaultLang, "language: en or cy")
+    var crs string
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        crs, args = args[0], args[1:]
+    }
+    fs.Parse(args)
+    if crs == "" {
+        crs = fs.Arg(0)
+    }
+    if crs == "" {
+        fmt.Fprintln(os.Stderr, "usage: minimaltrains board <CRS> [--url URL] [--interval 30s] [--once] [--all] [--lang cy]")
+        return 2
+    }
+
+    q := url.Values{"format": {"text"}, "lang": {*lang}}
+    if *all {
+        q.Set("all", "true")
+    }
+    boardURL := strings.TrimSuffix(*base, "/") + "/board/" + url.PathEscape(strings.ToUpper(crs)) + "?" + q.Encode()
+    client := &http.Client{Timeout: 10 * time.Second}
+
+    for {
+        text, err := fetchBoardText(client, boardURL, *token)
+        if *once {
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Failed to fetch board: %v\n", err)
+                return 1
+            }
+            fmt.Print(text)
+            return 0
+        }
+        // Redraw in place so it sits happily in a tmux pane
+        fmt.Print("\033[H\033[2J")
+        if err != nil {
+            fmt.Printf("Failed to fetch board: %v\n", err)
+        } else {
+            fmt.Print(text)
+        }
+        fmt.Printf("\nUpdated %s, every %s. Ctrl-C to quit.\n", time.Now().Format("15:04:05"), *interval)
+        time.Sleep(*interval)
+    }
+}
+
+func fetchBoardText(client *http.Client, boardURL, token string) (string, error) {
+    req, err := http.NewRequest("GET", boardURL, nil)
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Accept", "text/plain")
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return "", err
+    }
+    if resp.StatusCode != http.StatusOK {
+        return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+    }
+    return string(body), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+// A run of consecutive cancelled stops, described by the stations either
+// side of the part of the journey that won't run
+type CancelledRange struct {
+    From string `json:"from"` // last stop served before the gap, or the first cancelled one
+    To   string `json:"to"`   // last cancelled stop
+}
+
+// Parts of a journey that are cancelled, and whether that's all of it
+func cancelledRanges(stops []Stop) ([]CancelledRange, bool) {
+    var ranges []CancelledRange
+    all := len(stops) > 0
+    for i := 0; i < len(stops); i++ {
+        if stops[i].Status != "Cancelled" {
+            all = false
+            continue
+        }
+        start := i
+        for i+1 < len(stops) && stops[i+1].Status == "Cancelled" {
+            i++
+        }
+        from := stops[start].Station
+        if start > 0 {
+            from = stops[start-1].Station
+        }
+        ranges = append(ranges, CancelledRange{From: from, To: stops[i].Station})
+    }
+    if all {
+        return nil, true
+    }
+    return ranges, false
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/png"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "golang.org/x/image/draw"
+    "golang.org/x/image/font"
+    "golang.org/x/image/font/basicfont"
+    "golang.org/x/image/math/fixed"
+)
+
+// A journey in a few lines, for share cards and link previews
+type TrainSummary struct {
+    Title  string // headcode and operator
+    Route  string // origin to destination
+    Status string // "On time", "12 min late", "Cancelled"
+    Class  string // on-time, late or cancelled, matching the theme variables
+    Detail string // where it last was, or what's cancelled
+}
+
+func summariseTrain(p TrainProgress, lang string) TrainSummary {
+    s := TrainSummary{Title: strings.TrimSpace(p.TrainID + " " + operatorName(p.TOC)), Class: "on-time"}
+    if len(p.Stops) == 0 {
+        return s
+    }
+    first, last := p.Stops[0], p.Stops[len(p.Stops)-1]
+    s.Route = stationDisplayName(first.Station, lang) + " - " + stationDisplayName(last.Station, lang)
+
+    ranges, all := cancelledRanges(p.Stops)
+    if all {
+        s.Status, s.Class = translate(lang, "Cancelled"), "cancelled"
+        return s
+    }
+
+    s.Detail = translate(lang, "card_due", stationDisplayName(first.Station, lang), first.Scheduled)
+    for _, st := range p.Stops {
+        if st.Actual != "" {
+            key := "card_departed"
+            if st.Event == "arr" {
+                key = "card_arrived"
+            }
+            s.Detail = translate(lang, key, stationDisplayName(st.Station, lang), st.Actual)
+        }
+    }
+    if delay, known := trainDelay(p); known && delay > 0 {
+        s.Status, s.Class = translate(lang, "card_late", delay), "late"
+    } else {
+        s.Status = translate(lang, "On time")
+    }
+    if len(ranges) > 0 {
+        r := ranges[0]
+        if r.From == r.To {
+            s.Detail = translate(lang, "cancelled_at", stationDisplayName(r.To, lang))
+        } else {
+            s.Detail = translate(lang, "cancelled_between", stationDisplayName(r.From, lang), stationDisplayName(r.To, lang))
+        }
+        s.Class = "cancelled"
+    }
+    return s
+}
+
+// How late a train is: at the last stop with an actual time, or else at
+// the first forecast one
+func trainDelay(p TrainProgress) (int, bool) {
+    delay, known := 0, false
+    for _, st := range p.Stops {
+        if st.Actual != "" {
+            delay, known = minutesLate(st.Scheduled, st.Actual)
+        }
+    }
+    if known {
+        return delay, true
+    }
+    for _, st := range p.Stops {
+        if st.Expected != "" && st.Status != "Cancelled" {
+            return minutesLate(st.Scheduled, st.Expected)
+        }
+    }
+    return 0, false
+}
+
+// Share cards are the usual 1.91:1 link preview size
+const (
+    cardWidth  = 1200
+    cardHeight = 630
+    cardMargin = 48
+)
+
+// GET /train/{rid}/card.png
+func trainCardHandler(w http.ResponseWriter, r *http.Request) {
+    p, ok := requestProgress(w, r)
+    if !ok {
+        return
+    }
+    // The bitmap font only covers Latin-1, so cards are always in English
+    img := drawTrainCard(summariseTrain(p, defaultLang), p.TOC)
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        log.Printf("Failed to encode card for %s: %v", p.RID, err)
+        http.Error(w, "failed to draw card", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Cache-Control", "public, max-age=60")
+    w.Write(buf.Bytes())
+}
+
+func drawTrainCard(s TrainSummary, toc string) *image.RGBA {
+    theme := themes["light"]
+    img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+    draw.Draw(img, img.Bounds(), image.NewUniform(hexColour(theme["bg"])), image.Point{}, draw.Src)
+
+    // Header band in the operator's colour
+    band := hexColour(theme["accent"])
+    if c, ok := operatorThemeVars()["toc-"+toc]; ok {
+        band = hexColour(c)
+    }
+    draw.Draw(img, image.Rect(0, 0, cardWidth, 130), image.NewUniform(band), image.Point{}, draw.Src)
+
+    white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+    drawCardText(img, cardMargin, 35, 5, s.Title, white)
+    drawCardText(img, cardMargin, 175, 4, s.Route, hexColour(theme["fg"]))
+    drawCardText(img, cardMargin, 290, 7, s.Status, hexColour(theme[s.Class]))
+    drawCardText(img, cardMargin, 420, 3, s.Detail, hexColour(theme["muted"]))
+    drawCardText(img, cardMargin, cardHeight-cardMargin-26, 2, "MinimalTrains", hexColour(theme["muted"]))
+    return img
+}
+
+// Draw text in the 7x13 bitmap font scaled up by a whole number, so it
+// stays crisp. Text too wide for the card is cut short.
+func drawCardText(dst *image.RGBA, x, y, scale int, text string, c color.Color) {
+    face := basicfont.Face7x13
+    maxChars := (cardWidth - x - cardMargin) / (face.Advance * scale)
+    if runes := []rune(text); len(runes) > maxChars {
+        text = string(runes[:maxChars-3]) + "..."
+    }
+    if text == "" {
+        return
+    }
+    small := image.NewRGBA(image.Rect(0, 0, face.Advance*len([]rune(text)), face.Height))
+    d := font.Drawer{Dst: small, Src: image.NewUniform(c), Face: face, Dot: fixed.P(0, face.Ascent)}
+    d.DrawString(text)
+    b := small.Bounds()
+    draw.NearestNeighbor.Scale(dst, image.Rect(x, y, x+b.Dx()*scale, y+b.Dy()*scale), small, b, draw.Over, nil)
+}
+
+// Parse a #rrggbb theme colour, falling back to black
+func hexColour(s string) color.RGBA {
+    v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+    if err != nil || len(s) != 7 {
+        return color.RGBA{0, 0, 0, 0xff}
+    }
+    return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
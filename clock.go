@@ -0,0 +1,77 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Source of "now" for anything that reasons about the railway day:
+// statuses, board windows, the digest schedule and the midnight
+// rollover. Swapping it lets tests and replays run at any time of day.
+type Clock interface {
+    Now() time.Time
+    // Channel that receives once d has passed on this clock
+    After(d time.Duration) <-chan time.Time
+}
+
+var clock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock that only moves when told to, e.g. to step through the hour
+// around midnight or a DST change
+type ManualClock struct {
+    mu      sync.Mutex
+    now     time.Time
+    waiters []manualWaiter
+}
+
+type manualWaiter struct {
+    at time.Time
+    ch chan time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+    return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.now
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    ch := make(chan time.Time, 1)
+    if d <= 0 {
+        ch <- c.now
+        return ch
+    }
+    c.waiters = append(c.waiters, manualWaiter{at: c.now.Add(d), ch: ch})
+    return ch
+}
+
+// Move the clock on, firing any After channels that come due
+func (c *ManualClock) Advance(d time.Duration) {
+    c.Set(c.Now().Add(d))
+}
+
+func (c *ManualClock) Set(now time.Time) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.now = now
+    pending := c.waiters[:0]
+    for _, w := range c.waiters {
+        if w.at.After(now) {
+            pending = append(pending, w)
+            continue
+        }
+        w.ch <- now
+    }
+    c.waiters = pending
+}
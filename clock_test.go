@@ -0,0 +1,141 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+// Swap in a manual clock for the length of a test
+func useManualClock(t *testing.T, now time.Time) *ManualClock {
+    t.Helper()
+    c := NewManualClock(now)
+    old := clock
+    clock = c
+    t.Cleanup(func() { clock = old })
+    return c
+}
+
+func fired(ch <-chan time.Time) (time.Time, bool) {
+    select {
+    case at := <-ch:
+        return at, true
+    default:
+        return time.Time{}, false
+    }
+}
+
+func TestManualClockAdvanceFiresDueAfters(t *testing.T) {
+    start := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+    c := NewManualClock(start)
+    soon, later := c.After(5*time.Minute), c.After(time.Hour)
+
+    c.Advance(4 * time.Minute)
+    if _, ok := fired(soon); ok {
+        t.Fatal("5 minute After fired after 4 minutes")
+    }
+    c.Advance(time.Minute)
+    at, ok := fired(soon)
+    if !ok {
+        t.Fatal("5 minute After didn't fire after 5 minutes")
+    }
+    if want := start.Add(5 * time.Minute); !at.Equal(want) {
+        t.Errorf("After fired with %s, want %s", at, want)
+    }
+    if _, ok := fired(later); ok {
+        t.Fatal("hour After fired after 5 minutes")
+    }
+    if got := c.Now(); !got.Equal(start.Add(5 * time.Minute)) {
+        t.Errorf("Now() = %s after advancing 5 minutes", got)
+    }
+}
+
+func TestManualClockSetFiresEverythingPassed(t *testing.T) {
+    start := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+    c := NewManualClock(start)
+    a, b, notYet := c.After(time.Minute), c.After(2*time.Hour), c.After(3*time.Hour)
+
+    jump := start.Add(2 * time.Hour)
+    c.Set(jump)
+    for name, ch := range map[string]<-chan time.Time{"1 minute": a, "2 hour": b} {
+        at, ok := fired(ch)
+        if !ok {
+            t.Errorf("%s After didn't fire on Set", name)
+        } else if !at.Equal(jump) {
+            t.Errorf("%s After fired with %s, want the time set, %s", name, at, jump)
+        }
+    }
+    if _, ok := fired(notYet); ok {
+        t.Error("3 hour After fired 2 hours on")
+    }
+    // Firing is once only
+    c.Advance(2 * time.Hour)
+    if _, ok := fired(a); ok {
+        t.Error("1 minute After fired twice")
+    }
+    if _, ok := fired(notYet); !ok {
+        t.Error("3 hour After didn't fire 4 hours on")
+    }
+}
+
+func TestManualClockAfterNowFiresAtOnce(t *testing.T) {
+    c := NewManualClock(time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC))
+    for _, d := range []time.Duration{0, -time.Minute} {
+        if _, ok := fired(c.After(d)); !ok {
+            t.Errorf("After(%s) didn't fire straight away", d)
+        }
+    }
+}
+
+func TestUKTodayAcrossMidnight(t *testing.T) {
+    // 23:59 BST is 22:59 UTC
+    c := useManualClock(t, time.Date(2026, 10, 13, 22, 59, 0, 0, time.UTC))
+    if got := ukToday(); got != "2026-10-13" {
+        t.Fatalf("ukToday() at 23:59 = %s, want 2026-10-13", got)
+    }
+    c.Advance(2 * time.Minute)
+    if got := ukToday(); got != "2026-10-14" {
+        t.Errorf("ukToday() at 00:01 = %s, want 2026-10-14", got)
+    }
+}
+
+func TestUKTodayAcrossClockChanges(t *testing.T) {
+    tests := []struct {
+        name string
+        at   time.Time
+        want string
+    }{
+        // Clocks go forward at 01:00 GMT on 29 March 2026
+        {"GMT before midnight", time.Date(2026, 3, 28, 23, 30, 0, 0, time.UTC), "2026-03-28"},
+        {"GMT after midnight", time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC), "2026-03-29"},
+        {"BST after the change", time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), "2026-03-29"},
+        // And back at 01:00 GMT on 25 October 2026, when UTC's still on
+        // the 24th at local midnight
+        {"BST just after midnight", time.Date(2026, 10, 24, 23, 30, 0, 0, time.UTC), "2026-10-25"},
+        {"GMT after the change", time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), "2026-10-25"},
+    }
+    c := useManualClock(t, tests[0].at)
+    for _, tt := range tests {
+        c.Set(tt.at)
+        if got := ukToday(); got != tt.want {
+            t.Errorf("%s: ukToday() at %s = %s, want %s", tt.name, tt.at.Format(time.RFC3339), got, tt.want)
+        }
+    }
+}
+
+func TestRelativeMinutesAcrossMidnightAndClockChange(t *testing.T) {
+    c := useManualClock(t, time.Date(2026, 10, 24, 21, 58, 0, 0, time.UTC)) // 22:58 BST
+    c.Advance(time.Hour)                                                    // 23:58 BST
+    if got, _ := relativeMinutes("00:03", "", "", clock.Now()); got != 5 {
+        t.Errorf("00:03 from 23:58 is %d min away, want 5", got)
+    }
+    // 01:30 comes round twice when the clocks go back; Darwin's times are
+    // local, so a 01:40 train is 10 minutes away both times
+    c.Set(time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC)) // 01:30 BST
+    if got, _ := relativeMinutes("01:40", "", "", clock.Now()); got != 10 {
+        t.Errorf("01:40 from 01:30 BST is %d min away, want 10", got)
+    }
+    c.Advance(time.Hour) // 01:30 GMT
+    if got, _ := relativeMinutes("01:40", "", "", clock.Now()); got != 10 {
+        t.Errorf("01:40 from 01:30 GMT is %d min away, want 10", got)
+    }
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+    "fmt"
+    "os"
+)
+
+// Subcommands run instead of the server, e.g. "minimaltrains timetable validate".
+// Reports false if args don't name one.
+func runCommand(args []string) (int, bool) {
+    if len(args) == 0 {
+        return 0, false
+    }
+    switch args[0] {
+    case "timetable":
+        if len(args) < 2 || args[1] != "validate" {
+            fmt.Fprintln(os.Stderr, "usage: minimaltrains timetable validate <file>")
+            return 2, true
+        }
+        return runTimetableValidate(args[2:]), true
+    case "token":
+        return runToken(args[1:]), true
+    case "board":
+        return runBoard(args[1:]), true
+    case "where":
+        return runWhere(args[1:]), true
+    case "export":
+        return runExport(args[1:]), true
+    }
+    return 0, false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// A saved commute as an iCalendar feed, one event per train on each of the
+// commute's days the timetable knows about. Calendar apps poll it, so the
+// events move with the trains' live expected times. As the feed's URL holds
+// the commute itself, subscribing doesn't need the cookie.
+
+// How often calendar apps are asked to fetch the feed again. Many poll
+// less often than they're asked to.
+const commuteCalendarRefresh = "PT15M"
+
+// webcal:// link to a commute's feed, which calendar apps open as a
+// subscription. html/template won't allow the scheme in an href by
+// itself, so it's marked as safe here.
+func commuteCalendarURL(r *http.Request, c Commute, lang string) template.URL {
+    base := strings.TrimPrefix(strings.TrimPrefix(requestBaseURL(r), "https://"), "http://")
+    return template.URL("webcal://" + base + "/commutes/" + url.PathEscape(c.cookieValue()) + "/calendar.ics?lang=" + url.QueryEscape(lang))
+}
+
+// Text values in iCalendar escape backslashes, commas, semicolons and
+// newlines
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// Write a content line, folded at 75 octets as RFC 5545 asks, without
+// splitting a UTF-8 character
+func writeICSLine(b *strings.Builder, line string) {
+    for len(line) > 75 {
+        cut := 75
+        for cut > 0 && line[cut]&0xC0 == 0x80 {
+            cut--
+        }
+        b.WriteString(line[:cut] + "\r\n ")
+        line = line[cut:]
+    }
+    b.WriteString(line + "\r\n")
+}
+
+func icsTime(t time.Time) string {
+    return t.UTC().Format("20060102T150405Z")
+}
+
+// The feed for a commute, covering its days from today to the horizon
+func commuteCalendar(c Commute, lang string, now time.Time) string {
+    var b strings.Builder
+    line := func(format string, args ...any) { writeICSLine(&b, fmt.Sprintf(format, args...)) }
+    from, to := crsName(c.From, lang), crsName(c.To, lang)
+
+    line("BEGIN:VCALENDAR")
+    line("VERSION:2.0")
+    line("PRODID:-//MinimalTrains//Commutes//EN")
+    line("CALSCALE:GREGORIAN")
+    line("METHOD:PUBLISH")
+    line("X-WR-CALNAME:%s", icsEscaper.Replace(translate(lang, "commute_heading", from, to, c.Time)))
+    line("REFRESH-INTERVAL;VALUE=DURATION:%s", commuteCalendarRefresh)
+    line("X-PUBLISHED-TTL:%s", commuteCalendarRefresh)
+    for _, date := range timetableDates(now) {
+        day, err := time.ParseInLocation("2006-01-02", date, ukLocation)
+        if err != nil || !commuteDays[c.Days](day.Weekday()) {
+            continue
+        }
+        for _, s := range commuteServices(c, date) {
+            departs := s.Time
+            if s.Expected != "" && !s.Delayed {
+                departs = s.Expected
+            }
+            start, ok := railDateTime(date, departs, time.Time{})
+            if !ok {
+                continue
+            }
+            end := start
+            if s.Arrival != "" {
+                if t, ok := railDateTime(date, s.Arrival, start); ok {
+                    end = t
+                }
+            }
+
+            details := []string{translate(lang, "time") + ": " + hhmm(s.Time)}
+            switch {
+            case s.Status == "Cancelled":
+                details = append(details, translate(lang, "Cancelled"))
+            case s.Delayed:
+                details = append(details, translate(lang, "Delayed"))
+            case s.Expected != "":
+                details = append(details, translate(lang, "expected")+": "+hhmm(s.Expected))
+            }
+            location := from
+            if s.Platform != "" {
+                location += ", " + translate(lang, "platform") + " " + s.Platform
+            }
+
+            line("BEGIN:VEVENT")
+            line("UID:%s-%s@minimaltrains", s.RID, c.From)
+            line("DTSTAMP:%s", icsTime(now))
+            line("DTSTART:%s", icsTime(start))
+            line("DTEND:%s", icsTime(end))
+            line("SUMMARY:%s", icsEscaper.Replace(translate(lang, "commute_event", hhmm(s.Time), from, to)))
+            line("LOCATION:%s", icsEscaper.Replace(location))
+            line("DESCRIPTION:%s", icsEscaper.Replace(strings.Join(details, "\n")))
+            if s.Status == "Cancelled" {
+                line("STATUS:CANCELLED")
+            } else {
+                line("STATUS:CONFIRMED")
+            }
+            line("TRANSP:OPAQUE")
+            line("END:VEVENT")
+        }
+    }
+    line("END:VCALENDAR")
+    return b.String()
+}
+
+// GET /commutes/{commute}/calendar.ics, where commute is as in the cookie
+func commuteCalendarHandler(w http.ResponseWriter, r *http.Request) {
+    c, ok := parseCommute(r.PathValue("commute"))
+    if !ok {
+        http.Error(w, "no such commute", http.StatusNotFound)
+        return
+    }
+    lang := requestLang(w, r)
+    w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+    w.Header().Set("Content-Disposition", `inline; filename="commute.ics"`)
+    w.Write([]byte(commuteCalendar(c, lang, clock.Now())))
+}
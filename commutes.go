@@ -0,0 +1,333 @@
+package main
+
+import (
+    "errors"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// A saved commute: trains from one station calling at another, leaving
+// around a time on some days of the week. Commutes are kept in the
+// browser's commutes cookie, like its language and theme, so the server
+// holds nothing about who travels where.
+type Commute struct {
+    From string `json:"from"`
+    To   string `json:"to"`
+    Time string `json:"time"` // HH:MM
+    Days string `json:"days"` // a key of commuteDays
+}
+
+var commuteDays = map[string]func(time.Weekday) bool{
+    "days_weekdays": func(d time.Weekday) bool { return d != time.Saturday && d != time.Sunday },
+    "days_weekends": func(d time.Weekday) bool { return d == time.Saturday || d == time.Sunday },
+    "days_daily":    func(time.Weekday) bool { return true },
+}
+
+const (
+    maxCommutes = 5
+    // How far either side of a commute's time trains are shown
+    commuteWindowMins = 30
+)
+
+// A commute as it's stored in the cookie, e.g. PRE-MAN-08:00-days_weekdays
+func (c Commute) cookieValue() string {
+    return c.From + "-" + c.To + "-" + c.Time + "-" + c.Days
+}
+
+// Tidy a commute from a form or the cookie and check it can match trains
+func (c *Commute) normalise() bool {
+    c.From = strings.ToUpper(strings.TrimSpace(c.From))
+    c.To = strings.ToUpper(strings.TrimSpace(c.To))
+    mins, ok := parseRailTime(strings.TrimSpace(c.Time))
+    if !ok || commuteDays[c.Days] == nil || c.From == c.To || len(tiplocsForCRS(c.From)) == 0 || len(tiplocsForCRS(c.To)) == 0 {
+        return false
+    }
+    c.Time = time.Date(0, 1, 1, mins/60, mins%60, 0, 0, time.UTC).Format("15:04")
+    return true
+}
+
+func requestCommutes(r *http.Request) []Commute {
+    c, err := r.Cookie("commutes")
+    if err != nil {
+        return nil
+    }
+    var commutes []Commute
+    for _, v := range strings.Split(c.Value, ".") {
+        if cm, ok := parseCommute(v); ok && len(commutes) < maxCommutes {
+            commutes = append(commutes, cm)
+        }
+    }
+    return commutes
+}
+
+// A commute from its cookieValue
+func parseCommute(v string) (Commute, bool) {
+    parts := strings.SplitN(v, "-", 4)
+    if len(parts) != 4 {
+        return Commute{}, false
+    }
+    c := Commute{parts[0], parts[1], parts[2], parts[3]}
+    return c, c.normalise()
+}
+
+func setCommutes(w http.ResponseWriter, commutes []Commute) {
+    var values []string
+    for _, c := range commutes {
+        values = append(values, c.cookieValue())
+    }
+    cookie := &http.Cookie{Name: "commutes", Value: strings.Join(values, "."), Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds()), SameSite: http.SameSiteLaxMode}
+    if len(values) == 0 {
+        cookie.MaxAge = -1
+    }
+    http.SetCookie(w, cookie)
+}
+
+// How a commute is doing today
+type CommuteStatus struct {
+    Commute
+    FromName string     `json:"from_name"`
+    ToName   string     `json:"to_name"`
+    Today    bool       `json:"today"` // whether the commute's days include today
+    Services []BoardRow `json:"services"`
+}
+
+// A day's public trains for a commute, with their live times if they have
+// any, in order of departure. Arrival is the time they get to the
+// commute's To. Days past the timetable horizon have none.
+func commuteServices(c Commute, date string) []BoardRow {
+    fromTiplocs, toTiplocs := tiplocsForCRS(c.From), tiplocsForCRS(c.To)
+    js, err := journeysOn(date, false)
+    if err != nil && !errors.Is(err, errOutsideHorizon) && !errors.Is(err, errDayNotCached) {
+        log.Printf("Failed to load the timetable for %s: %v", date, err)
+    }
+
+    type service struct {
+        BoardRow
+        offset int // minutes after the commute's time it's scheduled
+        // The call at To, to find the live arrival
+        toTiploc, toTime string
+    }
+    var services []service
+    for _, j := range js {
+        if !j.IsPublic() {
+            continue
+        }
+        for i, p := range j.Points {
+            if !slices.Contains(fromTiplocs, p.Tiploc) || !isPublicDeparture(p) {
+                continue
+            }
+            offset, ok := minutesLate(c.Time, p.Ptd)
+            if !ok || offset < -commuteWindowMins || offset > commuteWindowMins {
+                break
+            }
+            for _, later := range j.Points[i+1:] {
+                if !slices.Contains(toTiplocs, later.Tiploc) || !isPublicCall(later) || later.Pta == "" {
+                    continue
+                }
+                s := service{BoardRow: BoardRow{
+                    RID:         j.RID,
+                    TrainID:     j.TrainID,
+                    Tiploc:      p.Tiploc,
+                    Time:        p.Ptd,
+                    Destination: j.Points[len(j.Points)-1].Tiploc,
+                    Arrival:     later.Pta,
+                    Platform:    p.Plat,
+                    TOC:         j.TOC,
+                    Mode:        j.Mode(),
+                }, offset: offset, toTiploc: later.Tiploc, toTime: later.Pta}
+                if p.Cancelled {
+                    s.Status = "Cancelled"
+                }
+                services = append(services, s)
+                break
+            }
+            break
+        }
+    }
+
+    sort.Slice(services, func(i, k int) bool {
+        if services[i].offset != services[k].offset {
+            return services[i].offset < services[k].offset
+        }
+        return services[i].RID < services[k].RID
+    })
+    rows := []BoardRow{}
+    for _, s := range services {
+        if p, ok := applyLiveProgress(&s.BoardRow); ok {
+            applyLiveArrival(&s.BoardRow, s.toTiploc, s.toTime, p)
+        }
+        rows = append(rows, s.BoardRow)
+    }
+    return rows
+}
+
+func commuteStatuses(commutes []Commute, lang string, now time.Time) []CommuteStatus {
+    weekday := now.In(ukLocation).Weekday()
+    statuses := []CommuteStatus{}
+    for _, c := range commutes {
+        st := CommuteStatus{Commute: c, FromName: crsName(c.From, lang), ToName: crsName(c.To, lang), Today: commuteDays[c.Days](weekday)}
+        if st.Today {
+            st.Services = commuteServices(c, now.In(ukLocation).Format("2006-01-02"))
+        }
+        statuses = append(statuses, st)
+    }
+    return statuses
+}
+
+// Template for saved commutes' live status (htmx partial, also on the
+// home page)
+var commuteStatusTmpl = template.Must(template.New("commuteStatus").Funcs(templateFuncs).Parse(`
+{{range .}}
+<h3>{{T "commute_heading" .FromName .ToName .Time}}</h3>
+{{if not .Today}}
+    <p class="muted">{{T "commute_not_today" (T .Days)}}</p>
+{{else}}
+<table>
+    <tr><th>{{T "time"}}</th><th>{{T "expected"}}</th><th>{{T "platform"}}</th><th>{{T "arrives_col" .ToName}}</th><th>{{T "status"}}</th></tr>
+    {{range .Services}}
+    <tr class="toc-row toc-{{.TOC}}">
+        <td>{{hhmm .Time}}</td>
+        <td>{{if .Delayed}}<span class="late" title="{{T "delay_unknown"}}">{{T "Delayed"}}</span>{{else}}{{hhmm .Expected}} {{delay .Time .Expected}}{{end}}</td>
+        <td>{{platform .Platform .PlatformConfirmed}}</td>
+        <td>{{hhmm .Arrival}}</td>
+        <td>{{modeBadge .Mode}}{{T .Status}}</td>
+    </tr>
+    {{else}}
+    <tr><td colspan="5">{{T "commute_none" .Time}}</td></tr>
+    {{end}}
+</table>
+{{end}}
+{{end}}
+`))
+
+// Template for the page where commutes are saved and removed
+var commutesTmpl = template.Must(template.New("commutes").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "commutes_title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+    <script src="https://unpkg.com/htmx.org@1.9.10"></script>
+</head>
+<body>
+    <h1>{{T "commutes_title"}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/">{{T "title"}}</a> | <a href="/stations">{{T "stations_title"}}</a></p>
+    <ul>
+    {{range $i, $c := .Commutes}}
+        <li>{{T "commute_heading" (index $.Names $i 0) (index $.Names $i 1) .Time}}, {{T .Days}}
+            <a href="{{index $.Calendars $i}}">{{T "commute_calendar"}}</a>
+            <form method="post" action="/commutes/{{$i}}/delete" style="display:inline"><button type="submit">{{T "commute_remove"}}</button></form></li>
+    {{else}}
+        <li>{{T "commutes_empty"}}</li>
+    {{end}}
+    </ul>
+    {{if lt (len .Commutes) .Max}}
+    <form method="post" action="/commutes">
+        <label>{{T "departs_from"}} <input name="from" size="4" maxlength="3" placeholder="CRS" required></label>
+        <label>{{T "calling_at"}} <input name="to" size="4" maxlength="3" placeholder="CRS" required></label>
+        <label>{{T "commute_around"}} <input name="time" type="time" value="08:00" required></label>
+        <select name="days">
+            <option value="days_weekdays">{{T "days_weekdays"}}</option>
+            <option value="days_weekends">{{T "days_weekends"}}</option>
+            <option value="days_daily">{{T "days_daily"}}</option>
+        </select>
+        <button type="submit">{{T "commute_add"}}</button>
+    </form>
+    {{end}}
+    {{if .Commutes}}
+    <div id="commutes" hx-get="/commutes/status" hx-trigger="load, every 60s" hx-swap="innerHTML">
+        <p>{{T "loading"}}</p>
+    </div>
+    {{end}}
+</body>
+</html>
+`))
+
+// GET /commutes: the saved commutes, as a page to manage them or as their
+// live status in JSON or text
+func commutesHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    commutes := requestCommutes(r)
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(commutesTmpl, lang)
+            if err != nil {
+                return err
+            }
+            var names [][]string
+            var calendars []template.URL
+            for _, c := range commutes {
+                names = append(names, []string{crsName(c.From, lang), crsName(c.To, lang)})
+                calendars = append(calendars, commuteCalendarURL(r, c, lang))
+            }
+            data := struct {
+                Lang, OtherLang, Theme string
+                Commutes               []Commute
+                Names                  [][]string
+                Calendars              []template.URL
+                Max                    int
+            }{lang, otherLang(lang), requestTheme(w, r), commutes, names, calendars, maxCommutes}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any { return commuteStatuses(commutes, lang, clock.Now()) },
+        Text: func(w io.Writer) {
+            var rows [][]string
+            for _, st := range commuteStatuses(commutes, lang, clock.Now()) {
+                for _, s := range st.Services {
+                    rows = append(rows, []string{st.FromName, st.ToName, s.Time, s.Expected, s.Platform, s.Arrival, translate(lang, s.Status)})
+                }
+            }
+            writeTextTable(w, []string{translate(lang, "departs_from"), translate(lang, "calling_at"), translate(lang, "time"), translate(lang, "expected"), translate(lang, "platform"), translate(lang, "arrival_col"), translate(lang, "status")}, rows)
+        },
+    })
+}
+
+// GET /commutes/status
+func commuteStatusHandler(w http.ResponseWriter, r *http.Request) {
+    tmpl, err := localisedTemplate(commuteStatusTmpl, requestLang(w, r))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    executeTemplate(w, r, tmpl, commuteStatuses(requestCommutes(r), requestLang(w, r), clock.Now()))
+}
+
+// POST /commutes with from, to, time and days
+func addCommuteHandler(w http.ResponseWriter, r *http.Request) {
+    c := Commute{From: r.FormValue("from"), To: r.FormValue("to"), Time: r.FormValue("time"), Days: r.FormValue("days")}
+    if !c.normalise() {
+        http.Error(w, "from and to must be known stations, time HH:MM and days one of days_weekdays, days_weekends or days_daily", http.StatusBadRequest)
+        return
+    }
+    commutes := requestCommutes(r)
+    if len(commutes) >= maxCommutes {
+        http.Error(w, "at most 5 commutes can be saved", http.StatusBadRequest)
+        return
+    }
+    if !slices.Contains(commutes, c) {
+        commutes = append(commutes, c)
+    }
+    setCommutes(w, commutes)
+    http.Redirect(w, r, "/commutes", http.StatusSeeOther)
+}
+
+// POST /commutes/{n}/delete
+func deleteCommuteHandler(w http.ResponseWriter, r *http.Request) {
+    commutes := requestCommutes(r)
+    n, err := strconv.Atoi(r.PathValue("n"))
+    if err != nil || n < 0 || n >= len(commutes) {
+        http.Error(w, "no such commute", http.StatusNotFound)
+        return
+    }
+    setCommutes(w, slices.Delete(commutes, n, n+1))
+    http.Redirect(w, r, "/commutes", http.StatusSeeOther)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "slices"
+    "strings"
+)
+
+// GET /compare?trains=2B15,1M45 shows several trains' progress side by
+// side, say when choosing between two trains home. Each column is the
+// train page's own progress fragment, so they refresh independently.
+const maxCompareTrains = 4
+
+type compareColumn struct {
+    Headcode string
+    // The progress fragment of today's run of the headcode, when there's
+    // only one it could mean
+    ProgressURL string
+    Runs        int
+}
+
+var compareTmpl = template.Must(template.New("compare").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "compare_title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+    <script src="https://unpkg.com/htmx.org@1.9.10"></script>
+</head>
+<body>
+    <h1>{{T "compare_title"}}</h1>
+    <p><a href="?trains={{.Trains}}&lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/">{{T "title"}}</a> | <a href="/stations">{{T "stations_title"}}</a></p>
+    {{if not .Columns}}<p>{{T "compare_usage" .Max}}</p>{{end}}
+    <div style="display:grid; grid-template-columns:repeat(auto-fit, minmax(18em, 1fr)); gap:1em">
+    {{range .Columns}}
+        {{if .ProgressURL}}
+        <div class="train-progression" hx-get="{{.ProgressURL}}" hx-trigger="load" hx-swap="innerHTML">
+            <p>{{T "loading"}}</p>
+        </div>
+        {{else if .Runs}}
+        <div><h2>{{.Headcode}}</h2><p><a href="/train/{{.Headcode}}">{{T "choose_train" .Headcode}}</a></p></div>
+        {{else}}
+        <div><h2>{{.Headcode}}</h2><p>{{T "compare_none" .Headcode}}</p></div>
+        {{end}}
+    {{end}}
+    </div>
+</body>
+</html>
+`))
+
+// The headcodes in ?trains=, upper-cased, without repeats
+func compareHeadcodes(q string) []string {
+    var out []string
+    for _, h := range strings.Split(q, ",") {
+        h = strings.ToUpper(strings.TrimSpace(h))
+        if h != "" && !slices.Contains(out, h) {
+            out = append(out, h)
+        }
+    }
+    return out
+}
+
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    headcodes := compareHeadcodes(r.URL.Query().Get("trains"))
+    if len(headcodes) > maxCompareTrains {
+        http.Error(w, fmt.Sprintf("compare at most %d trains", maxCompareTrains), http.StatusBadRequest)
+        return
+    }
+    var (
+        cols []compareColumn
+        locs []TrainLocation
+    )
+    for _, h := range headcodes {
+        runs, err := todaysRuns(h)
+        if err != nil {
+            log.Printf("Failed to load runs of %s: %v", h, err)
+            http.Error(w, "failed to load progress", http.StatusInternalServerError)
+            return
+        }
+        c := compareColumn{Headcode: h, Runs: len(runs)}
+        if p, ok := chooseRun(runs, ""); ok {
+            c.ProgressURL = "/train/" + url.PathEscape(h) + "/progress?rid=" + url.QueryEscape(p.RID)
+            p.Position = berthPosition(h)
+            locs = append(locs, trainLocation(p))
+        }
+        cols = append(cols, c)
+    }
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(compareTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme, Trains string
+                Columns                        []compareColumn
+                Max                            int
+            }{lang, otherLang(lang), requestTheme(w, r), strings.Join(headcodes, ","), cols, maxCompareTrains}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                Trains []TrainLocation `json:"trains"`
+            }{locs}
+        },
+        Text: func(w io.Writer) {
+            for _, loc := range locs {
+                writeTrainLocation(w, loc, lang)
+                io.WriteString(w, "\n")
+            }
+        },
+    })
+}
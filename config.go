@@ -0,0 +1,80 @@
+package main
+
+import (
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/joho/godotenv"
+)
+
+// Read an environment variable, falling back to def when it isn't set
+func envOr(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
+// Read a whole number from the environment, falling back to def when it's
+// unset or invalid
+func envInt(key string, def int) int {
+    v := envOr(key, "")
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n < 0 {
+        log.Printf("Invalid %s %q; using %d", key, v, def)
+        return def
+    }
+    return n
+}
+
+// Settings can also come from a file of KEY=value lines, CONFIG_FILE
+// (default .env). Variables in the process's own environment take
+// precedence over it, and it can be read again while running; see
+// reloadConfig.
+var (
+    // Variables set before the file was first read, which it doesn't override
+    processEnv = map[string]bool{}
+    // Variables the file last set
+    configFileKeys = map[string]bool{}
+    configMu       sync.Mutex
+)
+
+func configFile() string {
+    return envOr("CONFIG_FILE", ".env")
+}
+
+// Read the config file into the environment. Variables it no longer sets
+// are unset again. A missing file leaves things as they are.
+func loadConfigFile() error {
+    configMu.Lock()
+    defer configMu.Unlock()
+    if len(processEnv) == 0 {
+        for _, kv := range os.Environ() {
+            processEnv[strings.SplitN(kv, "=", 2)[0]] = true
+        }
+    }
+    values, err := godotenv.Read(configFile())
+    if err != nil {
+        return err
+    }
+    for key := range configFileKeys {
+        if _, ok := values[key]; !ok {
+            os.Unsetenv(key)
+            delete(configFileKeys, key)
+        }
+    }
+    for key, value := range values {
+        if processEnv[key] {
+            continue
+        }
+        os.Setenv(key, value)
+        configFileKeys[key] = true
+    }
+    return nil
+}
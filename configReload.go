@@ -0,0 +1,65 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+)
+
+// The config file is read again on SIGHUP or POST
+// /api/v1/admin/reload-config, without a restart, so the Push Port
+// connection stays up. What reloading changes:
+//   - WATCHED_TRAINS, WATCHED_STATIONS, ALERT_TO and ALERT_MIN_DELAY
+//   - OPS_ALERT_TO, OPS_ALERT_ERROR_PCT and OPS_ALERT_TS_SILENCE
+//   - KB_REFRESH and SNAPSHOT_INTERVAL, from the next wait on
+//   - the SMTP_* server and sender, NOTIFY_MAX_PER_TRAIN_HOUR and
+//     NOTIFY_MIN_DELAY_CHANGE
+//
+// Anything else still needs a restart, as does turning email on or off.
+
+// Reload on SIGHUP
+func watchConfigReload() {
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+    for range hup {
+        log.Printf("SIGHUP received; reloading %s", configFile())
+        if err := reloadConfig(); err != nil {
+            log.Printf("Failed to reload config: %v", err)
+        }
+    }
+}
+
+// Read the config file again and apply the settings that can change while
+// running
+func reloadConfig() error {
+    if err := loadConfigFile(); err != nil {
+        return err
+    }
+    invalidateRuleCache()
+    if t, ok := alertNotifier.(*throttledNotifier); ok {
+        if smtp, ok := newSMTPNotifierFromEnv(); ok {
+            t.reconfigure(queueNotifications(smtp))
+        } else {
+            log.Printf("SMTP_ADDR is no longer set; email stays on until a restart")
+        }
+    } else if _, ok := newSMTPNotifierFromEnv(); ok {
+        log.Printf("SMTP_ADDR is now set; email starts after a restart")
+    }
+    log.Printf("Reloaded config from %s", configFile())
+    return nil
+}
+
+// POST /api/v1/admin/reload-config
+func reloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+    log.Printf("Config reload requested")
+    if err := reloadConfig(); err != nil {
+        log.Printf("Failed to reload config: %v", err)
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, struct {
+        Status string `json:"status"`
+    }{"reloaded"})
+}
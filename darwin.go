@@ -0,0 +1,442 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "log"
+    "sync"
+    "time"
+)
+
+// Darwin XML structs (only the parts we use)
+// https://wiki.openraildata.com/index.php/Darwin:Push_Port
+type DarwinPport struct {
+    XMLName  xml.Name               `xml:"Pport"`
+    Ts       string                 `xml:"ts,attr"`
+    TS       []DarwinTS             `xml:"uR>TS"`
+    Schedule []DarwinSchedule       `xml:"uR>schedule"`
+    OW       []DarwinStationMessage `xml:"uR>OW"`
+
+    // Snapshot files carry the same elements under sR
+    SnapshotTS       []DarwinTS             `xml:"sR>TS"`
+    SnapshotSchedule []DarwinSchedule       `xml:"sR>schedule"`
+    SnapshotOW       []DarwinStationMessage `xml:"sR>OW"`
+}
+type DarwinTS struct {
+    RID        string       `xml:"rid,attr"`
+    UID        string       `xml:"uid,attr"`
+    SSD        string       `xml:"ssd,attr"`
+    LateReason DarwinReason `xml:"LateReason"`
+    Locs       []DarwinLoc  `xml:"Location"`
+}
+type DarwinLoc struct {
+    Tiploc string          `xml:"tpl,attr"`
+    Pta    string          `xml:"pta,attr"`
+    Ptd    string          `xml:"ptd,attr"`
+    Arr    *DarwinForecast `xml:"arr"`
+    Dep    *DarwinForecast `xml:"dep"`
+    Plat   DarwinPlatform  `xml:"plat"`
+    Length int             `xml:"length"` // coaches in the formation, if known
+}
+
+// A late-running or cancellation reason code, optionally at or near a
+// location
+type DarwinReason struct {
+    Code   int    `xml:",chardata"`
+    Tiploc string `xml:"tiploc,attr"`
+    Near   bool   `xml:"near,attr"`
+}
+
+// A platform from a TS. Until it's confirmed, by the signaller setting the
+// route or by the station, it's only the platform the train is expected at.
+type DarwinPlatform struct {
+    Number    string `xml:",chardata"`
+    Confirmed bool   `xml:"conf,attr"`
+}
+type DarwinForecast struct {
+    Et      string `xml:"et,attr"`
+    At      string `xml:"at,attr"`
+    Src     string `xml:"src,attr"`     // e.g. "Darwin", "TD" or "CIS"
+    SrcInst string `xml:"srcInst,attr"` // which CIS, when Src is CIS
+    Delayed bool   `xml:"delayed,attr"` // delay is indeterminate; et is a guess
+}
+
+// Used for both live schedule messages and timetable snapshot Journeys
+type DarwinSchedule struct {
+    RID            string                `xml:"rid,attr"`
+    UID            string                `xml:"uid,attr"`
+    TrainID        string                `xml:"trainId,attr"`
+    SSD            string                `xml:"ssd,attr"`
+    TOC            string                `xml:"toc,attr"`
+    Status         string                `xml:"status,attr"`
+    TrainCat       string                `xml:"trainCat,attr"`
+    IsPassengerSvc string                `xml:"isPassengerSvc,attr"`
+    IsCharter      string                `xml:"isCharter,attr"`
+    Points         []DarwinSchedulePoint `xml:",any"`
+
+    // CIF codes, only in timetables converted from CIF
+    Catering     string `xml:"catering,attr"`
+    SeatingClass string `xml:"seatingClass,attr"`
+    Sleepers     string `xml:"sleepers,attr"`
+    STP          string `xml:"stpIndicator,attr"`
+
+    CancelReason DarwinReason `xml:"cancelReason"`
+}
+
+// One of OR, IP, PP, DT or their operational OPxx equivalents
+type DarwinSchedulePoint struct {
+    XMLName xml.Name
+    Tiploc  string `xml:"tpl,attr"`
+    Act     string `xml:"act,attr"`
+    Plat    string `xml:"plat,attr"`
+    Pta     string `xml:"pta,attr"`
+    Ptd     string `xml:"ptd,attr"`
+    Wta     string `xml:"wta,attr"`
+    Wtd     string `xml:"wtd,attr"`
+    Wtp     string `xml:"wtp,attr"`
+    Can     bool   `xml:"can,attr"`
+}
+
+var schedulePointTypes = map[string]bool{
+    "OR": true, "IP": true, "PP": true, "DT": true,
+    "OPOR": true, "OPIP": true, "OPDT": true,
+}
+
+const (
+    defaultDarwinAddr  = "darwin-dist-44ae45.nationalrail.co.uk:61613"
+    defaultDarwinTopic = "/topic/darwin.pushport-v16"
+)
+
+// RID of today's run of the tracked train, learned from its schedule
+var (
+    trackedRID   string
+    trackedRIDMu sync.RWMutex
+)
+
+func startDarwinFeed(username, password string) {
+    startDarwinIngest()
+    topic := envOr("DARWIN_TOPIC", defaultDarwinTopic)
+    ingestProgress.consuming(topic)
+    if off, ok := ingestProgress.savedOffset(topic); ok {
+        catchUpFromJournal(off)
+    }
+    consumeStompTopic("Darwin",
+        envOr("DARWIN_STOMP_ADDR", defaultDarwinAddr),
+        username,
+        password,
+        topic,
+        enqueueDarwinMessage,
+    )
+}
+
+// Start applying queued Push Port messages, however they arrive
+func startDarwinIngest() {
+    go pruneAppliedUpdates()
+    go pruneForecasts()
+    go startIngestPipeline()
+}
+
+// Ungzip and parse one Push Port message
+func decodeDarwinMessage(body []byte) (DarwinPport, error) {
+    var msg DarwinPport
+    // Older brokers deliver gzipped XML
+    if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+        gz, err := gzip.NewReader(bytes.NewReader(body))
+        if err != nil {
+            return msg, fmt.Errorf("ungzip: %w", err)
+        }
+        body, err = io.ReadAll(gz)
+        if err != nil {
+            return msg, fmt.Errorf("ungzip: %w", err)
+        }
+    }
+    if err := xml.Unmarshal(body, &msg); err != nil {
+        return msg, fmt.Errorf("parse: %w", err)
+    }
+    msg.TS = append(msg.TS, msg.SnapshotTS...)
+    msg.Schedule = append(msg.Schedule, msg.SnapshotSchedule...)
+    msg.OW = append(msg.OW, msg.SnapshotOW...)
+    msg.SnapshotTS, msg.SnapshotSchedule, msg.SnapshotOW = nil, nil, nil
+    return msg, nil
+}
+
+// Store a live schedule, refresh the train's progress with it, and follow
+// it if it's the tracked train
+func applySchedule(s DarwinSchedule) {
+    j, added, replaces := upsertLiveJourney(s)
+    if added && j.VSTP {
+        log.Printf("VSTP schedule %s (%s %s) added from live feed", j.RID, j.TrainID, j.TOC)
+    }
+    var previous TrainProgress
+    if replaces != "" {
+        linkRIDs(replaces, j.RID)
+        if p, ok, err := progressStore.Get(replaces); err == nil && ok {
+            previous = p
+        }
+    }
+    if pub, ok := progressStore.(schedulePublisher); ok {
+        pub.PublishSchedule(j)
+    }
+    err := progressStore.Update(j.RID, func(p *TrainProgress) {
+        fresh := len(p.Stops) == 0
+        progressFromJourney(j, p)
+        if fresh {
+            carryOverHistory(p, previous)
+        }
+        p.PreviousRIDs = previousRIDs(j.RID)
+        p.LastUpdated = clock.Now()
+    })
+    if err != nil {
+        log.Printf("Failed to update progress for %s: %v", j.RID, err)
+    }
+    followIfTracked(j)
+}
+
+func followIfTracked(j *Journey) {
+    if j.TrainID == trackedHeadcode && j.SSD == ukToday() {
+        setTrackedRID(j.RID)
+    }
+}
+
+func setTrackedRID(rid string) {
+    trackedRIDMu.Lock()
+    trackedRID = rid
+    trackedRIDMu.Unlock()
+}
+
+func currentTrackedRID() string {
+    trackedRIDMu.RLock()
+    defer trackedRIDMu.RUnlock()
+    return trackedRID
+}
+
+// Find today's run of the tracked train in a freshly loaded timetable
+func trackFromTimetable() {
+    journeysMu.RLock()
+    defer journeysMu.RUnlock()
+    for _, j := range journeys {
+        if j.TrainID == trackedHeadcode && j.SSD == ukToday() {
+            setTrackedRID(j.RID)
+            return
+        }
+    }
+}
+
+// Take a train's calling pattern from its schedule, keeping what we
+// already know about stops that are still in it
+func progressFromJourney(j *Journey, p *TrainProgress) {
+    var stops []Stop
+    var cancelled, reinstated []string
+    visits := map[string]int{}
+    for _, pt := range j.Points {
+        if !isPublicCall(pt) {
+            continue
+        }
+        stop := Stop{Station: pt.Tiploc, Scheduled: pt.Ptd, Event: "dep", Platform: pt.Plat, Note: activityNote(pt.Act)}
+        if pt.Ptd == "" {
+            stop.Scheduled, stop.Event = pt.Pta, "arr"
+        }
+        if pt.Cancelled {
+            stop.Status = "Cancelled"
+        }
+        // Match on the nth visit rather than the time, so a reissue that
+        // retimes the train keeps what's already happened
+        visits[stop.Station]++
+        if old := nthVisit(p.Stops, stop.Station, visits[stop.Station]); old != nil {
+            stop.Expected, stop.Actual = old.Expected, old.Actual
+            stop.TrustActual = old.TrustActual
+            stop.Reinstated = old.Reinstated && !pt.Cancelled
+            if old.Platform != "" {
+                stop.Platform, stop.PlatformConfirmed = old.Platform, old.PlatformConfirmed
+            }
+            stop.Length = old.Length
+            // Darwin reinstates a cancelled call by reissuing the schedule
+            // without its can flag
+            switch {
+            case old.Status == "Cancelled" && !pt.Cancelled:
+                stop.Reinstated = true
+                reinstated = append(reinstated, stop.Station)
+            case old.Status != "Cancelled" && pt.Cancelled:
+                cancelled = append(cancelled, stop.Station)
+            }
+            if stop.Status == "" && old.Status != "" {
+                stop.Status = stopStatus(stop)
+            }
+        }
+        stops = append(stops, stop)
+    }
+    now := clock.Now()
+    if len(cancelled) > 0 {
+        p.Events = append(p.Events, ServiceEvent{At: now, Kind: "cancelled", Tiplocs: cancelled})
+    }
+    if len(reinstated) > 0 {
+        p.Events = append(p.Events, ServiceEvent{At: now, Kind: "reinstated", Tiplocs: reinstated})
+    }
+    p.RID, p.TrainID, p.SSD, p.TOC = j.RID, j.TrainID, j.SSD, j.TOC
+    p.Mode = j.Mode()
+    p.Stops = stops
+    // Cancelling the last call finishes the journey, and reinstating it
+    // undoes that, so it isn't evicted or shown as over
+    switch finished := journeyFinished(*p); {
+    case finished && p.FinishedAt.IsZero():
+        p.FinishedAt = now
+    case !finished:
+        p.FinishedAt = time.Time{}
+    }
+}
+
+// The nth (from 1) stop at a station, for trains that call twice
+func nthVisit(stops []Stop, station string, n int) *Stop {
+    for i := range stops {
+        if stops[i].Station != station {
+            continue
+        }
+        if n--; n == 0 {
+            return &stops[i]
+        }
+    }
+    return nil
+}
+
+// What became of a TS update
+type tsOutcome int
+
+const (
+    tsApplied     tsOutcome = iota
+    tsUnknown               // no schedule for the train
+    tsQuarantined           // held back for anomalous actuals
+    tsFailed                // the store couldn't be updated
+)
+
+// Apply forecasts and actuals to a train we have a schedule for. Forecast
+// tracking only hears about an update once it's known not to be
+// quarantined.
+func applyTS(ts DarwinTS) tsOutcome {
+    j, ok := journeyByRID(ts.RID)
+    if !ok {
+        return tsUnknown
+    }
+    var (
+        updated   TrainProgress
+        anomalies []Anomaly
+        forecasts []func()
+    )
+    now := clock.Now()
+    err := progressStore.Update(ts.RID, func(p *TrainProgress) {
+        if len(p.Stops) == 0 {
+            progressFromJourney(j, p)
+        }
+        // The store may retry fn, so start afresh each time
+        before := cloneProgress(*p)
+        forecasts = nil
+        p.LastUpdated = now
+        if ts.LateReason.Code != 0 {
+            p.LateReason = ts.LateReason.Code
+            p.LateReasonAt, p.LateReasonNear = ts.LateReason.Tiploc, ts.LateReason.Near
+        }
+        for _, loc := range ts.Locs {
+            stop := findStop(p.Stops, loc)
+            if stop == nil {
+                continue
+            }
+            f := loc.Dep
+            if stop.Event == "arr" {
+                f = loc.Arr
+            }
+            if f != nil {
+                s := *stop
+                if f.At != "" {
+                    if stop.Actual == "" {
+                        at := f.At
+                        forecasts = append(forecasts, func() { scoreForecasts(ts.RID, &s, at, now) })
+                    }
+                    stop.Actual = f.At
+                } else if f.Et != "" {
+                    stop.Expected = f.Et
+                    et := f.Et
+                    forecasts = append(forecasts, func() { recordForecast(ts.RID, &s, et, now) })
+                }
+                stop.ForecastSource, stop.ForecastSourceInst = f.Src, f.SrcInst
+                stop.Delayed = f.Delayed && f.At == ""
+            }
+            if loc.Plat.Number != "" {
+                stop.Platform, stop.PlatformConfirmed = loc.Plat.Number, loc.Plat.Confirmed
+            }
+            if loc.Length > 0 {
+                stop.Length = loc.Length
+            }
+            stop.Status = stopStatus(*stop)
+        }
+        if p.FinishedAt.IsZero() && journeyFinished(*p) {
+            p.FinishedAt = now
+        }
+        if anomalies = newAnomalies(before, *p); len(anomalies) > 0 {
+            // The rejected progress is kept for the quarantine record
+            updated = cloneProgress(*p)
+            *p = before
+            return
+        }
+        updated = cloneProgress(*p)
+    })
+    if err != nil {
+        log.Printf("Failed to update progress for %s: %v", ts.RID, err)
+        return tsFailed
+    }
+    if len(anomalies) > 0 {
+        quarantineUpdate(updated, anomalies)
+        return tsQuarantined
+    }
+    for _, f := range forecasts {
+        f()
+    }
+    if journeyFinished(updated) {
+        archiveJourney(updated)
+    }
+    queueAlertCheck(updated)
+    return tsApplied
+}
+
+// Match a TS location to a stop by TIPLOC, using the public time to tell
+// apart repeat visits on circular routes
+func findStop(stops []Stop, loc DarwinLoc) *Stop {
+    var byTiploc *Stop
+    for i := range stops {
+        if stops[i].Station != loc.Tiploc {
+            continue
+        }
+        if stops[i].Scheduled == loc.Ptd || stops[i].Scheduled == loc.Pta {
+            return &stops[i]
+        }
+        if byTiploc == nil {
+            byTiploc = &stops[i]
+        }
+    }
+    return byTiploc
+}
+
+func stopStatus(s Stop) string {
+    if s.Status == "Cancelled" {
+        return s.Status
+    }
+    if s.Delayed {
+        return "Delayed"
+    }
+    t := s.Actual
+    if t == "" {
+        t = s.Expected
+    }
+    late, ok := minutesLate(s.Scheduled, t)
+    switch {
+    case !ok && s.Reinstated:
+        return "Reinstated"
+    case !ok:
+        return ""
+    case late > 0:
+        return "Late"
+    default:
+        return "On time"
+    }
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "maps"
+    "path"
+    "slices"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/service/s3"
+    "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Without Push Port credentials, live data can still come from the files
+// Darwin writes to S3: a snapshot of the current state and journal files
+// of the messages since. DARWIN_JOURNAL_BUCKET (default the timetable
+// bucket) is listed under DARWIN_JOURNAL_PREFIX (default pushport/) every
+// DARWIN_JOURNAL_POLL (default 1m), and new messages go through the same
+// ingest queue as STOMP ones. Updates are only as fresh as the files, so
+// /healthz reports the feed as degraded.
+
+var journalPolling atomic.Bool
+
+// Longest a journal message may be, in bytes
+const maxJournalMessage = 16 << 20
+
+type journalPoller struct {
+    client         *s3.Client
+    bucket, prefix string
+    // Messages already queued from each file, since journal files are
+    // rewritten as they grow
+    seen map[string]int
+    // Files last modified before this are done with
+    since time.Time
+}
+
+func journalPollInterval() time.Duration {
+    interval, err := time.ParseDuration(envOr("DARWIN_JOURNAL_POLL", "1m"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid DARWIN_JOURNAL_POLL; using 1m")
+        return time.Minute
+    }
+    return interval
+}
+
+func isJournalSnapshot(key string) bool {
+    return strings.Contains(strings.ToLower(path.Base(key)), "snapshot")
+}
+
+// Poll the journal in S3 for Push Port messages. Never returns.
+func startDarwinJournalPolling() {
+    startDarwinIngest()
+    journalPolling.Store(true)
+    markFeedStarted("Darwin")
+    p := newJournalPoller()
+    ingestProgress.consuming(p.topic())
+    if off, ok := ingestProgress.savedOffset(p.topic()); ok && !off.Since.IsZero() {
+        log.Printf("Resuming the Push Port journal from files modified %s", off.Since.Format(time.RFC3339))
+        p.since = off.Since
+        maps.Copy(p.seen, off.Journal)
+    }
+    log.Printf("Polling %s for Push Port messages", p.topic())
+    for {
+        before := queuedMessages.Load()
+        if err := p.poll(context.Background()); err != nil {
+            log.Printf("Failed to poll Push Port journal: %v", err)
+        } else {
+            noteFeedConnected("Darwin")
+        }
+        // Only new messages count, so a journal nobody writes to any more
+        // goes unhealthy
+        if queuedMessages.Load() > before {
+            markFeedMessage("Darwin")
+        }
+        time.Sleep(journalPollInterval())
+    }
+}
+
+func newJournalPoller() *journalPoller {
+    return &journalPoller{
+        bucket: envOr("DARWIN_JOURNAL_BUCKET", timetableBucket),
+        prefix: envOr("DARWIN_JOURNAL_PREFIX", "pushport/"),
+        seen:   map[string]int{},
+    }
+}
+
+// The journal's name for offsets
+func (p *journalPoller) topic() string {
+    return "s3://" + p.bucket + "/" + p.prefix
+}
+
+// Queue the messages added since the last poll. The first poll starts
+// from the newest snapshot, or the last hour of journal without one.
+func (p *journalPoller) poll(ctx context.Context) error {
+    if p.client == nil {
+        client, err := darwinS3Client(ctx)
+        if err != nil {
+            return err
+        }
+        p.client = client
+    }
+    objects, err := listS3Objects(ctx, p.client, p.bucket, p.prefix)
+    if err != nil {
+        return fmt.Errorf("list S3 objects: %w", err)
+    }
+
+    if p.since.IsZero() {
+        p.since = clock.Now().Add(-time.Hour)
+        if i := slices.IndexFunc(objects, func(o types.Object) bool { return isJournalSnapshot(*o.Key) }); i >= 0 {
+            snap := objects[i]
+            log.Printf("Loading Push Port snapshot %s", *snap.Key)
+            n, err := p.queueFile(ctx, *snap.Key, 0)
+            if err != nil {
+                return fmt.Errorf("snapshot %s: %w", *snap.Key, err)
+            }
+            p.since = *snap.LastModified
+            ingestProgress.journalAt(p.topic(), queuedMessages.Load(), p.seen, p.since)
+            log.Printf("Queued %d messages from Push Port snapshot", n)
+        }
+    }
+
+    // Oldest first, so messages are queued in the order Darwin sent them
+    listed := map[string]bool{}
+    latest := p.since
+    for _, obj := range slices.Backward(objects) {
+        key := *obj.Key
+        listed[key] = true
+        if isJournalSnapshot(key) || obj.LastModified.Before(p.since) {
+            continue
+        }
+        n, err := p.queueFile(ctx, key, p.seen[key])
+        if err != nil {
+            return fmt.Errorf("journal %s: %w", key, err)
+        }
+        p.seen[key] = n
+        ingestProgress.journalAt(p.topic(), queuedMessages.Load(), p.seen, p.since)
+        if obj.LastModified.After(latest) {
+            latest = *obj.LastModified
+        }
+    }
+    p.since = latest
+    for key := range p.seen {
+        if !listed[key] {
+            delete(p.seen, key)
+        }
+    }
+    ingestProgress.journalAt(p.topic(), queuedMessages.Load(), p.seen, p.since)
+    return nil
+}
+
+// Before a Push Port consumer restarting with a saved offset subscribes,
+// queue the messages the journal has from after it, so the time it was
+// stopped isn't lost. Anything also delivered over STOMP is dropped as a
+// repeat when it's applied.
+func catchUpFromJournal(off FeedOffset) {
+    if off.Sent.IsZero() {
+        return
+    }
+    p := newJournalPoller()
+    p.since = off.Sent
+    before := queuedMessages.Load()
+    if err := p.poll(context.Background()); err != nil {
+        log.Printf("Failed to catch up from the Push Port journal; messages sent since %s were missed while stopped: %v", off.Sent.Format(time.RFC3339), err)
+        return
+    }
+    log.Printf("Caught up %d Push Port messages from %s sent since %s", queuedMessages.Load()-before, p.topic(), off.Sent.Format(time.RFC3339))
+}
+
+// Queue a file's messages after the first skip, returning how many it has
+func (p *journalPoller) queueFile(ctx context.Context, key string, skip int) (int, error) {
+    n := 0
+    err := withS3Gzip(ctx, p.client, p.bucket, key, func(r io.Reader) error {
+        return splitPportMessages(r, func(msg []byte) {
+            n++
+            if n > skip {
+                enqueueDarwinMessage(msg)
+            }
+        })
+    })
+    return n, err
+}
+
+// Split a file of Pport documents into one message each. The journal has
+// one per line, but a document may also run over several.
+func splitPportMessages(r io.Reader, handle func([]byte)) error {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), maxJournalMessage)
+    var msg []byte
+    for scanner.Scan() {
+        line := bytes.TrimSpace(scanner.Bytes())
+        if len(line) == 0 {
+            continue
+        }
+        msg = append(append(msg, line...), '\n')
+        if bytes.HasSuffix(line, []byte("</Pport>")) {
+            handle(msg)
+            msg = nil
+        }
+    }
+    return scanner.Err()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+    "encoding/xml"
+    "testing"
+    "time"
+)
+
+func testSchedule(cancelled bool) DarwinSchedule {
+    point := func(kind, tpl, pta, ptd string) DarwinSchedulePoint {
+        return DarwinSchedulePoint{XMLName: xml.Name{Local: kind}, Tiploc: tpl, Pta: pta, Ptd: ptd, Can: cancelled}
+    }
+    return DarwinSchedule{
+        RID: "202610148000001", UID: "C12345", TrainID: "2B15", SSD: "2026-10-14", TOC: "NT",
+        Points: []DarwinSchedulePoint{point("OR", "MNCRPIC", "", "08:00"), point("DT", "STKP", "08:10", "")},
+    }
+}
+
+func TestReinstatementIsNotEvicted(t *testing.T) {
+    c := useManualClock(t, time.Date(2026, 10, 14, 6, 0, 0, 0, time.UTC))
+    oldStore := progressStore
+    progressStore = newMemoryStore()
+    t.Cleanup(func() { progressStore = oldStore })
+    setTimetable(map[string]*Journey{})
+    rid := testSchedule(false).RID
+
+    applySchedule(testSchedule(true))
+    p, _, _ := progressStore.Get(rid)
+    if p.FinishedAt.IsZero() {
+        t.Fatal("a cancelled train isn't finished")
+    }
+
+    c.Advance(time.Minute)
+    applySchedule(testSchedule(false))
+    p, _, _ = progressStore.Get(rid)
+    if !p.FinishedAt.IsZero() {
+        t.Fatalf("a reinstated train is still finished, at %s", p.FinishedAt)
+    }
+    if p.Stops[len(p.Stops)-1].Status == "Cancelled" {
+        t.Fatal("a reinstated train's last call is still cancelled")
+    }
+
+    c.Advance(time.Hour)
+    evictJourneys(c.Now(), c.Now())
+    if _, ok, _ := progressStore.Get(rid); !ok {
+        t.Error("the reinstated train's progress was evicted")
+    }
+    if _, ok := journeyByRID(rid); !ok {
+        t.Error("the reinstated train's schedule was evicted")
+    }
+}
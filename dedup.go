@@ -0,0 +1,93 @@
+package main
+
+import (
+    "encoding/xml"
+    "expvar"
+    "hash/fnv"
+    "maps"
+    "sync"
+    "time"
+)
+
+// The Push Port is at-least-once: reconnects and replays can redeliver
+// updates we've already applied, or deliver them out of order. Applying
+// those again makes statuses flap back to older forecasts, so we remember
+// the newest update applied per RID and drop anything older or identical.
+// The state snapshot saves them along with the feed offset, so the
+// messages replayed after a restart are dropped too.
+type appliedUpdate struct {
+    TS   time.Time
+    Hash uint64
+    At   time.Time
+}
+
+var (
+    appliedUpdates   = map[string]appliedUpdate{}
+    appliedUpdatesMu sync.Mutex
+
+    duplicateUpdates = expvar.NewInt("darwin_duplicate_updates")
+    staleUpdates     = expvar.NewInt("darwin_stale_updates")
+)
+
+// Forget RIDs we haven't heard about for this long
+const appliedUpdateTTL = 36 * time.Hour
+
+// Reports whether an update of the given kind ("TS", "schedule") for a RID,
+// sent by Darwin at ts, should be applied. A zero ts falls back to matching
+// on content alone.
+func freshUpdate(kind, rid string, ts time.Time, update any) bool {
+    h := fnv.New64a()
+    if data, err := xml.Marshal(update); err == nil {
+        h.Write(data)
+    }
+    sum := h.Sum64()
+
+    key := kind + "/" + rid
+    appliedUpdatesMu.Lock()
+    defer appliedUpdatesMu.Unlock()
+    last, ok := appliedUpdates[key]
+    if ok {
+        if last.Hash == sum && (ts.IsZero() || !ts.After(last.TS)) {
+            duplicateUpdates.Add(1)
+            return false
+        }
+        if !ts.IsZero() && ts.Before(last.TS) {
+            staleUpdates.Add(1)
+            return false
+        }
+    }
+    if ts.IsZero() {
+        ts = last.TS
+    }
+    appliedUpdates[key] = appliedUpdate{TS: ts, Hash: sum, At: time.Now()}
+    return true
+}
+
+func pruneAppliedUpdates() {
+    for range time.Tick(time.Hour) {
+        appliedUpdatesMu.Lock()
+        for key, u := range appliedUpdates {
+            if time.Since(u.At) > appliedUpdateTTL {
+                delete(appliedUpdates, key)
+            }
+        }
+        appliedUpdatesMu.Unlock()
+    }
+}
+
+func snapshotAppliedUpdates() map[string]appliedUpdate {
+    appliedUpdatesMu.Lock()
+    defer appliedUpdatesMu.Unlock()
+    return maps.Clone(appliedUpdates)
+}
+
+// Keep whichever of the restored and the already applied is newer
+func restoreAppliedUpdates(saved map[string]appliedUpdate) {
+    appliedUpdatesMu.Lock()
+    defer appliedUpdatesMu.Unlock()
+    for key, u := range saved {
+        if last, ok := appliedUpdates[key]; !ok || u.TS.After(last.TS) {
+            appliedUpdates[key] = u
+        }
+    }
+}
@@ -0,0 +1,101 @@
+package main
+
+import "fmt"
+
+// Darwin's forecasts can lag behind a late train, especially away from
+// train describer coverage. This predicts arrival at the destination from
+// how late the train was at its last actual time, assuming it runs to
+// its booked running times from there but recovers lost time where the
+// timetable lets it:
+//   - by cutting station stops down to minDwellMins
+//   - by the public time being later than the working one, which is how
+//     the timetable usually pads the run into a terminus
+
+// The shortest a late train is expected to stand at a station, in minutes
+const minDwellMins = 1
+
+type ArrivalPrediction struct {
+    Station   string `json:"station"`
+    Scheduled string `json:"scheduled"`
+    Predicted string `json:"predicted"`
+    Delay     int    `json:"delay"`
+    // Darwin's own expected time there, if it has one
+    Darwin string `json:"darwin,omitempty"`
+}
+
+// A working time, falling back on the public one for schedules without it
+func workingTime(working, public string) string {
+    if working != "" {
+        return working
+    }
+    return public
+}
+
+// The schedule point a stop came from
+func stopPoint(j *Journey, s Stop) int {
+    for i, pt := range j.Points {
+        if pt.Tiploc == s.Station && ((s.Event == "dep" && pt.Ptd == s.Scheduled) || (s.Event == "arr" && pt.Pta == s.Scheduled)) {
+            return i
+        }
+    }
+    return -1
+}
+
+// HH:MM some minutes after a time, wrapping at midnight
+func addRailMinutes(t string, mins int) string {
+    m, _ := parseRailTime(t)
+    m = ((m+mins)%(24*60) + 24*60) % (24 * 60)
+    return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+// Predicted arrival at the last stop the train still calls at, for a
+// train that's set off late and not yet got there
+func predictArrival(p TrainProgress) (ArrivalPrediction, bool) {
+    last, dest := -1, -1
+    for i, s := range p.Stops {
+        if s.Actual != "" {
+            last = i
+        }
+        if s.Status != "Cancelled" {
+            dest = i
+        }
+    }
+    if last < 0 || dest <= last || p.Stops[dest].Event != "arr" {
+        return ArrivalPrediction{}, false
+    }
+    j, ok := journeyByRID(p.RID)
+    if !ok {
+        return ArrivalPrediction{}, false
+    }
+    from, to := stopPoint(j, p.Stops[last]), stopPoint(j, p.Stops[dest])
+    if from < 0 || to <= from {
+        return ArrivalPrediction{}, false
+    }
+
+    // Lateness on the working timetable at the last actual time
+    pt := j.Points[from]
+    booked := workingTime(pt.Wtd, pt.Ptd)
+    if p.Stops[last].Event == "arr" {
+        booked = workingTime(pt.Wta, pt.Pta)
+    }
+    late, ok := minutesLate(booked, p.Stops[last].Actual)
+    if !ok || late <= 0 {
+        return ArrivalPrediction{}, false
+    }
+    for _, pt := range j.Points[from+1 : to] {
+        if pt.Cancelled || pt.Wta == "" || pt.Wtd == "" {
+            continue
+        }
+        if dwell, ok := minutesLate(pt.Wta, pt.Wtd); ok {
+            late = max(0, late-max(0, dwell-minDwellMins))
+        }
+    }
+
+    end := j.Points[to]
+    predicted := addRailMinutes(workingTime(end.Wta, end.Pta), late)
+    delay, ok := minutesLate(end.Pta, predicted)
+    if !ok || delay <= 0 {
+        return ArrivalPrediction{}, false
+    }
+    return ArrivalPrediction{end.Tiploc, end.Pta, predicted, delay, p.Stops[dest].Expected}, true
+}
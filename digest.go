@@ -0,0 +1,139 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "time"
+)
+
+// Punctuality of one headcode over a period, from the archive
+type PunctualitySummary struct {
+    Headcode     string
+    From, To     string
+    Runs         int
+    Cancelled    int
+    AverageDelay float64
+    WorstDate    string
+    WorstDelay   int
+}
+
+func punctualitySummary(headcode, from, to string) (PunctualitySummary, error) {
+    sum := PunctualitySummary{Headcode: headcode, From: from, To: to}
+    if archiveDB == nil {
+        return sum, nil
+    }
+    rows, err := archiveDB.Query(`SELECT ssd, terminal_delay, cancelled FROM journeys
+        WHERE train_id = ? AND ssd >= ? AND ssd <= ? ORDER BY ssd`, headcode, from, to)
+    if err != nil {
+        return sum, err
+    }
+    defer rows.Close()
+    total, arrived := 0, 0
+    for rows.Next() {
+        var (
+            ssd       string
+            delay     sql.NullInt64
+            cancelled bool
+        )
+        if err := rows.Scan(&ssd, &delay, &cancelled); err != nil {
+            return sum, err
+        }
+        sum.Runs++
+        if cancelled {
+            sum.Cancelled++
+            continue
+        }
+        if delay.Valid {
+            d := int(delay.Int64)
+            total += d
+            arrived++
+            if sum.WorstDate == "" || d > sum.WorstDelay {
+                sum.WorstDate, sum.WorstDelay = ssd, d
+            }
+        }
+    }
+    if arrived > 0 {
+        sum.AverageDelay = float64(total) / float64(arrived)
+    }
+    return sum, rows.Err()
+}
+
+func (s PunctualitySummary) String() string {
+    if s.Runs == 0 {
+        return fmt.Sprintf("%s: no runs recorded", s.Headcode)
+    }
+    out := fmt.Sprintf("%s: %d runs, average arrival %.1f min late, %d cancelled", s.Headcode, s.Runs, s.AverageDelay, s.Cancelled)
+    if s.WorstDate != "" {
+        out += fmt.Sprintf(", worst %s (%d min late)", s.WorstDate, s.WorstDelay)
+    }
+    return out
+}
+
+// Headcodes the digest covers, from WATCHED_TRAINS (default the tracked train)
+func watchedTrains() []string {
+    var trains []string
+    for _, h := range strings.Split(envOr("WATCHED_TRAINS", trackedHeadcode), ",") {
+        if h = strings.ToUpper(strings.TrimSpace(h)); h != "" {
+            trains = append(trains, h)
+        }
+    }
+    return trains
+}
+
+// Email DIGEST_TO a daily or weekly (DIGEST_PERIOD) punctuality digest of
+// the watched trains at DIGEST_AT (HH:MM, UK time). Weekly digests go out
+// on Mondays.
+func startDigest(n Notifier) {
+    to := os.Getenv("DIGEST_TO")
+    if to == "" {
+        return
+    }
+    period := envOr("DIGEST_PERIOD", "daily")
+    at, ok := parseRailTime(envOr("DIGEST_AT", "07:00"))
+    if !ok || (period != "daily" && period != "weekly") {
+        log.Printf("Invalid DIGEST_PERIOD %q or DIGEST_AT; digest disabled.", period)
+        return
+    }
+    log.Printf("Sending %s digest to %s", period, to)
+    for {
+        now := clock.Now().In(ukLocation)
+        next := time.Date(now.Year(), now.Month(), now.Day(), at/60, at%60, 0, 0, ukLocation)
+        if !next.After(now) {
+            next = next.AddDate(0, 0, 1)
+        }
+        for period == "weekly" && next.Weekday() != time.Monday {
+            next = next.AddDate(0, 0, 1)
+        }
+        <-clock.After(next.Sub(now))
+        if err := sendDigest(n, to, period, next); err != nil {
+            log.Printf("Failed to send %s digest: %v", period, err)
+        }
+    }
+}
+
+func sendDigest(n Notifier, to, period string, now time.Time) error {
+    days := 1
+    if period == "weekly" {
+        days = 7
+    }
+    end := now.AddDate(0, 0, -1).Format("2006-01-02")
+    start := now.AddDate(0, 0, -days).Format("2006-01-02")
+
+    var body strings.Builder
+    fmt.Fprintf(&body, "Punctuality of your watched trains, %s to %s\n\n", start, end)
+    for _, headcode := range watchedTrains() {
+        sum, err := punctualitySummary(headcode, start, end)
+        if err != nil {
+            return err
+        }
+        body.WriteString(sum.String() + "\n")
+    }
+    return n.Notify(Notification{
+        To:      to,
+        Subject: fmt.Sprintf("Your %s train digest", period),
+        Body:    body.String(),
+    })
+}
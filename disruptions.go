@@ -0,0 +1,213 @@
+package main
+
+import (
+    "html/template"
+    "io"
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Planned service at a station, or between two, on one day: what the
+// future timetable has running and any engineering work KB knows about
+type PlannedDay struct {
+    Date   string            `json:"date"`
+    Trains int               `json:"trains"`
+    Buses  int               `json:"buses"`
+    Status string            `json:"status"` // a planned_ translation key
+    Works  []EngineeringWork `json:"engineering_works"`
+}
+
+const disruptionDays = 7
+
+// Count the public services leaving from (and, if to is set, calling at to
+// afterwards) on each day of the next week the timetable covers. Days with
+// noticeably fewer trains than the busiest day of the same kind are flagged.
+func plannedDisruption(from, to string, days int, lang string, now time.Time) []PlannedDay {
+    fromTiplocs, toTiplocs := tiplocsForCRS(from), tiplocsForCRS(to)
+    names := []string{crsName(from, lang)}
+    if to != "" {
+        names = append(names, crsName(to, lang))
+    }
+
+    var out []PlannedDay
+    for _, date := range timetableDates(now)[:min(days, timetableHorizon+1)] {
+        day := PlannedDay{Date: date, Status: "planned_normal"}
+        start, _ := time.ParseInLocation("2006-01-02", date, ukLocation)
+        day.Works = engineeringWorksAffecting(names, start, start.AddDate(0, 0, 1))
+        js, err := journeysOn(date, false)
+        if err != nil {
+            day.Status = "planned_no_data"
+            out = append(out, day)
+            continue
+        }
+        for _, j := range js {
+            if !j.IsPublic() || !servesRoute(j, fromTiplocs, toTiplocs) {
+                continue
+            }
+            if j.Mode() == modeBus {
+                day.Buses++
+            } else {
+                day.Trains++
+            }
+        }
+        out = append(out, day)
+    }
+
+    // Weekdays are compared with weekdays, Saturdays with Saturdays and so on
+    kind := func(date string) time.Weekday {
+        t, _ := time.Parse("2006-01-02", date)
+        if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+            return wd
+        }
+        return time.Monday
+    }
+    busiest := map[time.Weekday]int{}
+    for _, d := range out {
+        busiest[kind(d.Date)] = max(busiest[kind(d.Date)], d.Trains)
+    }
+    for i, d := range out {
+        if d.Status == "planned_no_data" {
+            continue
+        }
+        switch {
+        case d.Trains == 0:
+            out[i].Status = "planned_closed"
+        case d.Buses > 0:
+            out[i].Status = "planned_buses"
+        case d.Trains*2 < busiest[kind(d.Date)]:
+            out[i].Status = "planned_reduced"
+        case len(d.Works) > 0:
+            out[i].Status = "planned_works"
+        }
+    }
+    return out
+}
+
+// Whether a journey picks up at one of from and, when to is given, calls
+// at one of to later on
+func servesRoute(j *Journey, from, to []string) bool {
+    boarded := false
+    for _, p := range j.Points {
+        if p.Cancelled {
+            continue
+        }
+        if !boarded && slices.Contains(from, p.Tiploc) && isPublicDeparture(p) {
+            if len(to) == 0 {
+                return true
+            }
+            boarded = true
+            continue
+        }
+        if boarded && slices.Contains(to, p.Tiploc) && isPublicCall(p) && p.Pta != "" {
+            return true
+        }
+    }
+    return false
+}
+
+// A station's name from its CRS, or the CRS if it isn't known
+func crsName(crs, lang string) string {
+    if tiplocs := tiplocsForCRS(crs); len(tiplocs) > 0 {
+        return stationDisplayName(tiplocs[0], lang)
+    }
+    return crs
+}
+
+var disruptionsTmpl = template.Must(template.New("disruptions").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Title}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/stations">{{T "stations_title"}}</a></p>
+    <form method="get" action="/disruptions">
+        <label>{{T "station_col"}} <input name="crs" value="{{.CRS}}" size="4" maxlength="3" placeholder="CRS" required></label>
+        <label>{{T "calling_at"}} <input name="to" value="{{.To}}" size="4" maxlength="3" placeholder="CRS"></label>
+        <button type="submit">{{T "stations_search"}}</button>
+    </form>
+    {{if .CRS}}
+    <table>
+        <tr><th>{{T "date_col"}}</th><th>{{T "trains_col"}}</th><th>{{T "buses_col"}}</th><th>{{T "status"}}</th></tr>
+        {{range .Days}}
+        <tr{{if ne .Status "planned_normal"}} class="late"{{end}}>
+            <td>{{.Date}}</td>
+            <td>{{.Trains}}</td>
+            <td>{{.Buses}}</td>
+            <td>{{T .Status}}
+                {{range .Works}}<p><strong>{{T "engineering_works"}}:</strong> {{with .Link}}<a href="{{.}}">{{end}}{{.Summary}}{{if .Link}}</a>{{end}}{{with .Routes}} <span class="muted">{{.}}</span>{{end}}</p>{{end}}
+            </td>
+        </tr>
+        {{end}}
+    </table>
+    {{end}}
+</body>
+</html>
+`))
+
+// GET /disruptions?crs=LDS&to=YRK&days=7: planned closures and engineering
+// work affecting a station or route over the coming week
+func disruptionsHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    crs := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("crs")))
+    to := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("to")))
+    days := disruptionDays
+    if v := r.URL.Query().Get("days"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > disruptionDays {
+            http.Error(w, "days must be between 1 and 7", http.StatusBadRequest)
+            return
+        }
+        days = n
+    }
+    var planned []PlannedDay
+    if crs != "" {
+        planned = plannedDisruption(crs, to, days, lang, clock.Now())
+    }
+    title := translate(lang, "disruptions_title")
+    switch {
+    case crs != "" && to != "":
+        title = translate(lang, "disruptions_route", crsName(crs, lang), crsName(to, lang))
+    case crs != "":
+        title = translate(lang, "planned_at", crsName(crs, lang))
+    }
+
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(disruptionsTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme, Title, CRS, To string
+                Days                                   []PlannedDay
+            }{lang, otherLang(lang), requestTheme(w, r), title, crs, to, planned}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                CRS  string       `json:"crs"`
+                To   string       `json:"to,omitempty"`
+                Days []PlannedDay `json:"days"`
+            }{crs, to, planned}
+        },
+        Text: func(w io.Writer) {
+            var rows [][]string
+            for _, d := range planned {
+                status := translate(lang, d.Status)
+                for _, wk := range d.Works {
+                    status += "; " + wk.Summary
+                }
+                rows = append(rows, []string{d.Date, strconv.Itoa(d.Trains), strconv.Itoa(d.Buses), status})
+            }
+            writeTextTable(w, []string{translate(lang, "date_col"), translate(lang, "trains_col"), translate(lang, "buses_col"), translate(lang, "status")}, rows)
+        },
+    })
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    mathrand "math/rand/v2"
+    "strconv"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/config"
+    "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+    "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Progress kept in DynamoDB, for serverless frontends (see lambda.go) and
+// ingesters and frontends spread over several hosts. The table,
+// DYNAMODB_TABLE (default minimaltrains-progress) in AWS_REGION (default
+// eu-west-1), needs a string partition key "rid", and TTL enabled on
+// "expires" so finished trains are deleted like they expire from Redis.
+// Credentials come from the usual AWS chain, so a Lambda's role is used.
+//
+// Each item carries a version that changes with every write, and Update
+// only writes if it's still the one it read, trying again if not, so
+// writers on different hosts don't overwrite each other's changes. That
+// holds within a region: a global table's replicas settle concurrent
+// writes in different regions by last writer wins, so keep the writers
+// in one region and read from the others.
+//
+// Only train progress is kept here. Forecast accuracy is per process and
+// alert rules live in the archive database; neither is behind a store
+// interface yet.
+const dynamoProgressTTL = 36 * time.Hour
+
+// Times Update reads and writes again when another writer got in first
+const dynamoUpdateAttempts = 10
+
+type dynamoStore struct {
+    client *dynamodb.Client
+    table  string
+}
+
+func newDynamoStore(table string) (*dynamoStore, error) {
+    ctx := context.Background()
+    cfg, err := config.LoadDefaultConfig(ctx,
+        config.WithRegion(envOr("AWS_REGION", "eu-west-1")),
+        config.WithHTTPClient(tracedHTTPClient()),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("load AWS config: %w", err)
+    }
+    s := &dynamoStore{client: dynamodb.NewFromConfig(cfg), table: table}
+    if _, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}); err != nil {
+        return nil, fmt.Errorf("describe table %s: %w", table, err)
+    }
+    return s, nil
+}
+
+func (s *dynamoStore) Get(rid string) (TrainProgress, bool, error) {
+    p, ok, _, err := s.get(rid)
+    return p, ok, err
+}
+
+// Get, with the version of the item read, "" if it hasn't one. An item
+// that has expired but not yet been deleted has a version though it's
+// not found.
+func (s *dynamoStore) get(rid string) (TrainProgress, bool, string, error) {
+    out, err := s.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+        TableName:      aws.String(s.table),
+        Key:            map[string]types.AttributeValue{"rid": &types.AttributeValueMemberS{Value: rid}},
+        ConsistentRead: aws.Bool(true),
+    })
+    if err != nil {
+        return TrainProgress{}, false, "", err
+    }
+    var version string
+    if v, ok := out.Item["version"].(*types.AttributeValueMemberS); ok {
+        version = v.Value
+    }
+    data, ok := out.Item["data"].(*types.AttributeValueMemberS)
+    if !ok {
+        return TrainProgress{}, false, version, nil
+    }
+    // DynamoDB deletes expired items some time after they expire
+    if exp, ok := out.Item["expires"].(*types.AttributeValueMemberN); ok {
+        if at, err := strconv.ParseInt(exp.Value, 10, 64); err == nil && at < clock.Now().Unix() {
+            return TrainProgress{}, false, version, nil
+        }
+    }
+    var p TrainProgress
+    if err := json.Unmarshal([]byte(data.Value), &p); err != nil {
+        return TrainProgress{}, false, version, err
+    }
+    return p, true, version, nil
+}
+
+func newDynamoVersion() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+func dynamoItem(p TrainProgress) (map[string]types.AttributeValue, error) {
+    data, err := json.Marshal(p)
+    if err != nil {
+        return nil, err
+    }
+    expires := clock.Now().Add(progressTTL(p, dynamoProgressTTL)).Unix()
+    return map[string]types.AttributeValue{
+        "rid":     &types.AttributeValueMemberS{Value: p.RID},
+        "data":    &types.AttributeValueMemberS{Value: string(data)},
+        "expires": &types.AttributeValueMemberN{Value: strconv.FormatInt(expires, 10)},
+        "version": &types.AttributeValueMemberS{Value: newDynamoVersion()},
+    }, nil
+}
+
+func (s *dynamoStore) Put(p TrainProgress) error {
+    item, err := dynamoItem(p)
+    if err != nil {
+        return err
+    }
+    _, err = s.client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item})
+    return err
+}
+
+// Write p only if the item's version is still version, or if it's "",
+// there's still no item or only one written before items had versions
+func (s *dynamoStore) putIfVersion(p TrainProgress, version string) error {
+    item, err := dynamoItem(p)
+    if err != nil {
+        return err
+    }
+    in := &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item}
+    if version == "" {
+        in.ConditionExpression = aws.String("attribute_not_exists(version)")
+    } else {
+        in.ConditionExpression = aws.String("version = :version")
+        in.ExpressionAttributeValues = map[string]types.AttributeValue{":version": &types.AttributeValueMemberS{Value: version}}
+    }
+    _, err = s.client.PutItem(context.Background(), in)
+    return err
+}
+
+// Read-modify-write with a conditional put, running fn again on the
+// newer progress when another writer got in between
+func (s *dynamoStore) Update(rid string, fn func(p *TrainProgress)) error {
+    for attempt := 1; ; attempt++ {
+        p, _, version, err := s.get(rid)
+        if err != nil {
+            return err
+        }
+        fn(&p)
+        p.RID = rid
+        err = s.putIfVersion(p, version)
+        var conflict *types.ConditionalCheckFailedException
+        if !errors.As(err, &conflict) {
+            return err
+        }
+        if attempt == dynamoUpdateAttempts {
+            return fmt.Errorf("progress for %s changed under %d updates in a row", rid, attempt)
+        }
+        time.Sleep(time.Duration(mathrand.IntN(20*attempt)) * time.Millisecond)
+    }
+}
+
+func (s *dynamoStore) Close() error { return nil }
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "bytes"
+    "html/template"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+)
+
+// Default size of the iframe oEmbed consumers are told to use
+const (
+    embedWidth  = 480
+    embedHeight = 360
+)
+
+// Self-contained board for iframes on other sites: styles are inlined and
+// it refreshes itself without scripts, so the host page can't affect it
+var embedTmpl = template.Must(template.New("embed").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta http-equiv="refresh" content="60">
+    <title>{{T "board_title" .CRS}}</title>
+    <style>{{.CSS}}
+body { margin: 0; padding: 4px; font-size: 14px; }
+table { width: 100%; border-collapse: collapse; }
+</style>
+</head>
+<body>
+    <strong><a href="{{.BoardURL}}" target="_blank" rel="noopener">{{T "board_title" .CRS}}</a></strong>
+    {{.Table}}
+</body>
+</html>
+`))
+
+// The snippet oEmbed consumers paste into their pages
+var oEmbedIframeTmpl = template.Must(template.New("oEmbedIframe").Parse(
+    `<iframe src="{{.Src}}" width="{{.Width}}" height="{{.Height}}" style="border:0" title="{{.Title}}"></iframe>`))
+
+// GET /embed/board/{crs}
+func embedBoardHandler(w http.ResponseWriter, r *http.Request) {
+    crs := strings.ToUpper(r.PathValue("crs"))
+    lang := requestLang(w, r)
+    theme := r.URL.Query().Get("theme")
+    if !validTheme(theme) {
+        theme = "light"
+    }
+
+    table, err := localisedTemplate(boardTmpl, lang)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    var buf bytes.Buffer
+    if err := table.Execute(&buf, buildBoard(crs, boardOptionsFor(r), clock.Now())); err != nil {
+        templateFailed(w, r, table.Name(), err)
+        return
+    }
+    page, err := localisedTemplate(embedTmpl, lang)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    data := struct {
+        Lang, CRS, BoardURL string
+        CSS                 template.CSS
+        Table               template.HTML
+    }{lang, crs, requestBaseURL(r) + "/board/" + crs, template.CSS(themeStylesheet(theme)), template.HTML(buf.String())}
+
+    // Any site may frame the widget
+    w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+    executeTemplate(w, r, page, data)
+}
+
+type oEmbedResponse struct {
+    Version      string `json:"version"`
+    Type         string `json:"type"`
+    Title        string `json:"title"`
+    ProviderName string `json:"provider_name"`
+    ProviderURL  string `json:"provider_url"`
+    HTML         string `json:"html"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+}
+
+// GET /oembed?url=https://host/board/CDF[&maxwidth=&maxheight=]
+// https://oembed.com/
+func oEmbedHandler(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    if f := q.Get("format"); f != "" && f != "json" {
+        http.Error(w, "only json is supported", http.StatusNotImplemented)
+        return
+    }
+    target, err := url.Parse(q.Get("url"))
+    if err != nil {
+        http.Error(w, "invalid url", http.StatusBadRequest)
+        return
+    }
+    crs, ok := strings.CutPrefix(strings.TrimSuffix(target.Path, "/"), "/board/")
+    if !ok || crs == "" || strings.Contains(crs, "/") {
+        http.Error(w, "only board URLs can be embedded", http.StatusNotFound)
+        return
+    }
+    crs = strings.ToUpper(crs)
+
+    width, height := embedWidth, embedHeight
+    if n, err := strconv.Atoi(q.Get("maxwidth")); err == nil && n > 0 && n < width {
+        width = n
+    }
+    if n, err := strconv.Atoi(q.Get("maxheight")); err == nil && n > 0 && n < height {
+        height = n
+    }
+    base := requestBaseURL(r)
+    src := base + "/embed/board/" + url.PathEscape(crs)
+    if target.RawQuery != "" {
+        src += "?" + target.RawQuery
+    }
+    log.Printf("Serving oEmbed for %s", crs)
+    writeJSON(w, oEmbedResponse{
+        Version:      "1.0",
+        Type:         "rich",
+        Title:        translate(defaultLang, "board_title", crs),
+        ProviderName: "MinimalTrains",
+        ProviderURL:  base,
+        HTML: string(htmlFragment(oEmbedIframeTmpl, struct {
+            Src, Title    string
+            Width, Height int
+        }{src, translate(defaultLang, "board_title", crs), width, height})),
+        Width:  width,
+        Height: height,
+    })
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "log"
+    "maps"
+    "os"
+    "slices"
+    "time"
+)
+
+// How long a journey's progress is kept after its final arrival, from
+// EVICT_FINISHED_AFTER (default 6h; "off" keeps everything)
+var evictFinishedAfter = 6 * time.Hour
+
+// Implemented by stores that need to be told to drop finished journeys.
+// Redis expires them itself; see progressTTL.
+type finishedEvicter interface {
+    // Remove progress for journeys that finished before the cutoff,
+    // returning their RIDs
+    EvictFinished(before time.Time) ([]string, error)
+}
+
+func initEviction() {
+    switch v := os.Getenv("EVICT_FINISHED_AFTER"); v {
+    case "":
+    case "off":
+        evictFinishedAfter = 0
+    default:
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 {
+            log.Printf("Invalid EVICT_FINISHED_AFTER %q; using %s", v, evictFinishedAfter)
+            return
+        }
+        evictFinishedAfter = d
+    }
+}
+
+// Expiry for a progress record in a store with TTLs: the usual TTL until
+// the journey finishes, then whatever is left of the eviction delay
+func progressTTL(p TrainProgress, ttl time.Duration) time.Duration {
+    if evictFinishedAfter == 0 || p.FinishedAt.IsZero() {
+        return ttl
+    }
+    return min(max(evictFinishedAfter-clock.Now().Sub(p.FinishedAt), time.Second), ttl)
+}
+
+// Periodically drop finished journeys, and schedules from days gone by
+func startEviction() {
+    for range time.Tick(10 * time.Minute) {
+        evictFinished(clock.Now())
+    }
+}
+
+func evictFinished(now time.Time) {
+    if evictFinishedAfter > 0 {
+        evictJourneys(now, now.Add(-evictFinishedAfter))
+    } else {
+        evictJourneys(now, time.Time{})
+    }
+}
+
+// Drop progress for journeys that finished before finishedBefore (none if
+// it's zero) and schedules from before yesterday
+func evictJourneys(now, finishedBefore time.Time) {
+    var evicted []string
+    if e, ok := progressStore.(finishedEvicter); ok && !finishedBefore.IsZero() {
+        rids, err := e.EvictFinished(finishedBefore)
+        if err != nil {
+            log.Printf("Failed to evict finished journeys: %v", err)
+        }
+        evicted = rids
+    }
+
+    // Trains that started yesterday can still be running after midnight
+    cutoff := now.In(ukLocation).AddDate(0, 0, -1).Format("2006-01-02")
+    tracked := currentTrackedRID()
+    journeysMu.Lock()
+    remove := map[string]bool{}
+    for _, rid := range evicted {
+        if _, ok := journeys[rid]; ok && rid != tracked {
+            remove[rid] = true
+        }
+    }
+    stale := 0
+    for rid, j := range journeys {
+        if j.SSD < cutoff && rid != tracked && !remove[rid] {
+            remove[rid] = true
+            stale++
+        }
+    }
+    removeJourneys(slices.Collect(maps.Keys(remove)))
+    journeysMu.Unlock()
+    if len(evicted) > 0 || stale > 0 {
+        log.Printf("Evicted %d finished journeys and %d old schedules", len(evicted), stale)
+    }
+}
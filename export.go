@@ -0,0 +1,113 @@
+package main
+
+import (
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strconv"
+    "time"
+)
+
+// Header of the export, one row per stop of every archived journey
+var exportColumns = []string{"ssd", "rid", "train_id", "toc", "origin", "destination", "seq", "tiploc", "event", "scheduled", "actual", "delay_mins", "cancelled"}
+
+// "minimaltrains export --date 2024-05-01 --format csv": every stop-level
+// scheduled and actual time the archive holds for a day's journeys, for
+// building performance datasets. Rows are ordered by journey and calling
+// point; delay_mins is empty until the stop has an actual time.
+func runExport(args []string) int {
+    fs := flag.NewFlagSet("export", flag.ExitOnError)
+    date := fs.String("date", ukToday(), "start date of the journeys to export, as YYYY-MM-DD")
+    format := fs.String("format", "csv", "output format: csv")
+    out := fs.String("out", "", "file to write; empty for standard output")
+    fs.Parse(args)
+    if _, err := time.Parse("2006-01-02", *date); err != nil {
+        fmt.Fprintln(os.Stderr, "usage: minimaltrains export --date YYYY-MM-DD [--format csv] [--out file]")
+        return 2
+    }
+    if *format != "csv" {
+        fmt.Fprintf(os.Stderr, "Unknown --format %q; only csv is supported\n", *format)
+        return 2
+    }
+
+    // Opening the archive would make an empty one, and export nothing
+    if path := envOr("ARCHIVE_PATH", "archive.db"); path != "off" {
+        if _, err := os.Stat(path); err != nil {
+            fmt.Fprintf(os.Stderr, "No archive to export: %v\n", err)
+            return 1
+        }
+    }
+    // The archive logs as it opens; that's noise in a pipe
+    log.SetOutput(io.Discard)
+    if err := initArchive(); err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to open archive: %v\n", err)
+        return 1
+    }
+    if archiveDB == nil {
+        fmt.Fprintln(os.Stderr, "The archive is disabled (ARCHIVE_PATH=off)")
+        return 1
+    }
+    defer archiveDB.Close()
+
+    w := io.Writer(os.Stdout)
+    if *out != "" {
+        f, err := os.Create(*out)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", *out, err)
+            return 1
+        }
+        defer f.Close()
+        w = f
+    }
+    n, err := exportDayCSV(w, *date)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to export %s: %v\n", *date, err)
+        return 1
+    }
+    fmt.Fprintf(os.Stderr, "Exported %d stops from %s\n", n, *date)
+    return 0
+}
+
+// Write a day's archived stops as CSV, returning how many rows there were
+func exportDayCSV(w io.Writer, ssd string) (int, error) {
+    rows, err := archiveDB.Query(`SELECT j.ssd, j.rid, j.train_id, j.toc, j.origin, j.destination,
+            s.seq, s.tiploc, s.event, s.scheduled, s.actual, s.cancelled
+        FROM journeys j JOIN stops s ON s.rid = j.rid
+        WHERE j.ssd = ?
+        ORDER BY j.rid, s.seq`, ssd)
+    if err != nil {
+        return 0, err
+    }
+    defer rows.Close()
+    cw := csv.NewWriter(w)
+    if err := cw.Write(exportColumns); err != nil {
+        return 0, err
+    }
+    n := 0
+    for rows.Next() {
+        var ssd, rid, trainID, toc, origin, destination, tiploc, event, scheduled, actual string
+        var seq int
+        var cancelled bool
+        if err := rows.Scan(&ssd, &rid, &trainID, &toc, &origin, &destination, &seq, &tiploc, &event, &scheduled, &actual, &cancelled); err != nil {
+            return n, err
+        }
+        delay := ""
+        if actual != "" {
+            if d, ok := minutesLate(scheduled, actual); ok {
+                delay = strconv.Itoa(d)
+            }
+        }
+        if err := cw.Write([]string{ssd, rid, trainID, toc, origin, destination, strconv.Itoa(seq), tiploc, event, scheduled, actual, delay, strconv.FormatBool(cancelled)}); err != nil {
+            return n, err
+        }
+        n++
+    }
+    if err := rows.Err(); err != nil {
+        return n, err
+    }
+    cw.Flush()
+    return n, cw.Error()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+    "encoding/xml"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Minutes late before a service appears in the disruption feed
+var significantDelayMins = 15
+
+func initFeed() {
+    if v := os.Getenv("SIGNIFICANT_DELAY_MINS"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            log.Printf("Ignoring invalid SIGNIFICANT_DELAY_MINS %q: %v", v, err)
+            return
+        }
+        significantDelayMins = n
+    }
+}
+
+type atomFeed struct {
+    XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+    ID      string      `xml:"id"`
+    Title   string      `xml:"title"`
+    Updated string      `xml:"updated"`
+    Link    atomLink    `xml:"link"`
+    Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+    Href string `xml:"href,attr"`
+    Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+    ID      string      `xml:"id"`
+    Title   string      `xml:"title"`
+    Updated string      `xml:"updated"`
+    Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+    Type string `xml:"type,attr"`
+    Body string `xml:",chardata"`
+}
+
+// Cancellations, significant delays and NRCC messages for a station
+func buildDisruptionFeed(crs, baseURL string, now time.Time) atomFeed {
+    uk := now.In(ukLocation)
+    today := uk.Format("2006-01-02")
+    feed := atomFeed{
+        ID:      fmt.Sprintf("tag:minimaltrains,%s:board/%s", today, crs),
+        Title:   translate(defaultLang, "feed_title", crs),
+        Updated: now.UTC().Format(time.RFC3339),
+        Link:    atomLink{Href: baseURL + "/board/" + crs},
+    }
+
+    for _, m := range messagesForStation(crs) {
+        feed.Entries = append(feed.Entries, atomEntry{
+            ID:      fmt.Sprintf("tag:minimaltrains,%s:message/%s", m.Received.In(ukLocation).Format("2006-01-02"), m.ID),
+            Title:   translate(defaultLang, "feed_message", m.Category),
+            Updated: m.Received.UTC().Format(time.RFC3339),
+            Content: atomContent{Type: "html", Body: string(sanitiseNRCC(m.Text))},
+        })
+    }
+
+    board := buildBoard(crs, boardOptions{}, now)
+    for _, row := range board.allRows() {
+        var title, kind string
+        if row.Status == "Cancelled" {
+            kind = "cancelled"
+            title = translate(defaultLang, "feed_cancelled", row.Time, stationDisplayName(row.Destination, defaultLang))
+        } else if late, ok := minutesLate(row.Time, row.Expected); ok && late >= significantDelayMins {
+            kind = "delayed"
+            title = translate(defaultLang, "feed_delayed", row.Time, stationDisplayName(row.Destination, defaultLang), late)
+        } else {
+            continue
+        }
+        // Stable across fetches so feed readers don't show entries twice
+        updated := uk
+        if mins, ok := parseRailTime(row.Time); ok {
+            updated = time.Date(uk.Year(), uk.Month(), uk.Day(), mins/60, mins%60, 0, 0, ukLocation)
+        }
+        feed.Entries = append(feed.Entries, atomEntry{
+            ID:      fmt.Sprintf("tag:minimaltrains,%s:%s/%s", today, row.RID, kind),
+            Title:   title,
+            Updated: updated.UTC().Format(time.RFC3339),
+            Content: atomContent{Type: "text", Body: title},
+        })
+    }
+    return feed
+}
+
+func requestBaseURL(r *http.Request) string {
+    scheme := "http"
+    if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+    return scheme + "://" + r.Host
+}
+
+// GET /board/{crs}/feed.atom
+func disruptionFeedHandler(w http.ResponseWriter, r *http.Request) {
+    crs := strings.ToUpper(r.PathValue("crs"))
+    feed := buildDisruptionFeed(crs, requestBaseURL(r), clock.Now())
+    w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+    w.Write([]byte(xml.Header))
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(feed); err != nil {
+        log.Printf("Failed to write feed for %s: %v", crs, err)
+    }
+}
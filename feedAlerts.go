@@ -0,0 +1,161 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "maps"
+    "net/http"
+    "slices"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Alerts for whoever runs the service, as opposed to passengers' train
+// alerts: the Push Port failing to decode too often, no TS updates for a
+// while, or a timetable snapshot failing to load. Only the ingester
+// raises them, so web replicas don't each send a copy. Each is sent to
+// OPS_ALERT_TO through the same notifier as delay alerts when it starts,
+// and again when it clears, rather than on every check. Without
+// OPS_ALERT_TO or SMTP they're only logged and shown on /admin/feed-alerts.
+const (
+    feedAlertErrorRate = "ingest_errors"
+    feedAlertTSSilence = "no_ts"
+    feedAlertTimetable = "timetable_load"
+)
+
+type FeedAlert struct {
+    Kind   string    `json:"kind"`
+    Detail string    `json:"detail"`
+    Since  time.Time `json:"since"`
+}
+
+var (
+    feedAlerts   = map[string]FeedAlert{}
+    feedAlertsMu sync.Mutex
+)
+
+// Push Port messages and decode failures since the last check, when the
+// last TS update arrived, and when the Push Port first connected, which
+// is when the wait for TS updates starts: it isn't connected until the
+// timetable has loaded, which can take a while
+var (
+    ingestMessages  atomic.Int64
+    ingestFailures  atomic.Int64
+    lastTSUpdate    atomic.Int64 // Unix nanoseconds, 0 before the first
+    darwinConnected atomic.Int64 // Unix nanoseconds, 0 until it is
+)
+
+// Fewer messages than this in a check say nothing about the error rate
+const feedAlertMinMessages = 20
+
+func noteIngestMessage(ok bool) {
+    ingestMessages.Add(1)
+    if !ok {
+        ingestFailures.Add(1)
+    }
+}
+
+func noteTSUpdate() {
+    lastTSUpdate.Store(clock.Now().UnixNano())
+}
+
+// Note that a feed is delivering, for the Push Port's TS silence check
+func noteFeedConnected(name string) {
+    if name == "Darwin" {
+        darwinConnected.CompareAndSwap(0, clock.Now().UnixNano())
+    }
+}
+
+// Raise an alert, notifying only if it wasn't already raised
+func raiseFeedAlert(kind, detail string) {
+    feedAlertsMu.Lock()
+    _, raised := feedAlerts[kind]
+    if !raised {
+        feedAlerts[kind] = FeedAlert{kind, detail, clock.Now()}
+    }
+    feedAlertsMu.Unlock()
+    if raised {
+        return
+    }
+    log.Printf("Feed alert %s: %s", kind, detail)
+    sendFeedAlert(fmt.Sprintf("MinimalTrains: %s", kind), detail)
+}
+
+func clearFeedAlert(kind string) {
+    feedAlertsMu.Lock()
+    a, raised := feedAlerts[kind]
+    delete(feedAlerts, kind)
+    feedAlertsMu.Unlock()
+    if !raised {
+        return
+    }
+    log.Printf("Feed alert %s cleared", kind)
+    sendFeedAlert(fmt.Sprintf("MinimalTrains: %s cleared", kind), fmt.Sprintf("Cleared after %s: %s", clock.Now().Sub(a.Since).Round(time.Second), a.Detail))
+}
+
+// OPS_ALERT_TO is read each time, so a config reload can change it
+func sendFeedAlert(subject, body string) {
+    to := envOr("OPS_ALERT_TO", "")
+    if to == "" || alertNotifier == nil {
+        return
+    }
+    if err := alertNotifier.Notify(Notification{To: to, Subject: subject, Body: body + "\n"}); err != nil {
+        log.Printf("Failed to send feed alert to %s: %v", to, err)
+    }
+}
+
+// Check the Push Port every OPS_ALERT_INTERVAL (default 1m). It alerts
+// when OPS_ALERT_ERROR_PCT (10) percent or more of the messages in an
+// interval fail to decode, or there's been no TS update for
+// OPS_ALERT_TS_SILENCE (10m) since the feed connected.
+func startFeedAlerts() {
+    interval, err := time.ParseDuration(envOr("OPS_ALERT_INTERVAL", "1m"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid OPS_ALERT_INTERVAL; using 1m")
+        interval = time.Minute
+    }
+    for range time.Tick(interval) {
+        checkFeedAlerts(clock.Now())
+    }
+}
+
+func checkFeedAlerts(now time.Time) {
+    total, failed := ingestMessages.Swap(0), ingestFailures.Swap(0)
+    if total >= feedAlertMinMessages {
+        pct := envInt("OPS_ALERT_ERROR_PCT", 10)
+        if failed*100 >= total*int64(pct) {
+            raiseFeedAlert(feedAlertErrorRate, fmt.Sprintf("%d of %d Push Port messages failed to decode", failed, total))
+        } else {
+            clearFeedAlert(feedAlertErrorRate)
+        }
+    }
+
+    silence, err := time.ParseDuration(envOr("OPS_ALERT_TS_SILENCE", "10m"))
+    if err != nil || silence <= 0 {
+        silence = 10 * time.Minute
+    }
+    last := darwinConnected.Load()
+    if n := lastTSUpdate.Load(); n != 0 {
+        last = n
+    }
+    if last == 0 {
+        return
+    }
+    if gap := now.Sub(time.Unix(0, last)); gap > silence {
+        raiseFeedAlert(feedAlertTSSilence, fmt.Sprintf("no TS update for %s", gap.Round(time.Second)))
+    } else {
+        clearFeedAlert(feedAlertTSSilence)
+    }
+}
+
+// GET /admin/feed-alerts: the alerts raised now, oldest first
+func feedAlertsHandler(w http.ResponseWriter, r *http.Request) {
+    feedAlertsMu.Lock()
+    alerts := slices.SortedFunc(maps.Values(feedAlerts), func(a, b FeedAlert) int { return a.Since.Compare(b.Since) })
+    feedAlertsMu.Unlock()
+    if alerts == nil {
+        alerts = []FeedAlert{}
+    }
+    writeJSON(w, alerts)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// How far ahead of each actual we score Darwin's forecasts, in minutes.
+// FORECAST_HORIZONS overrides this with a comma-separated list.
+var forecastHorizons = []int{5, 15, 30, 60}
+
+// Errors (actual minus forecast, in minutes) beyond these go in the end
+// buckets of the histogram
+const (
+    minForecastError = -5
+    maxForecastError = 15
+)
+
+// A forecast for a stop and when we received it
+type forecastObservation struct {
+    Et       string
+    Received time.Time
+}
+
+type accuracyHistogram struct {
+    counts      [maxForecastError - minForecastError + 1]int
+    totalAbsErr int
+    n           int
+}
+
+var (
+    // Forecasts seen for stops that haven't happened yet, keyed rid/tiploc/event
+    pendingForecasts = map[string][]forecastObservation{}
+    // Keyed by horizon
+    forecastAccuracy = map[int]*accuracyHistogram{}
+    forecastMu       sync.Mutex
+)
+
+// Don't keep forecasts for stops that never get an actual forever
+const pendingForecastTTL = 36 * time.Hour
+
+// Keep at most this many forecasts per stop; Darwin can revise a late
+// train's estimate every minute
+const maxForecastsPerStop = 120
+
+func initForecastAccuracy() {
+    v := os.Getenv("FORECAST_HORIZONS")
+    if v == "" {
+        return
+    }
+    var horizons []int
+    for _, part := range strings.Split(v, ",") {
+        n, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil || n <= 0 {
+            log.Printf("Invalid FORECAST_HORIZONS %q; using defaults", v)
+            return
+        }
+        horizons = append(horizons, n)
+    }
+    forecastHorizons = horizons
+}
+
+func forecastKey(rid string, s *Stop) string {
+    return rid + "/" + s.Station + "/" + s.Event
+}
+
+// Remember a forecast for a stop, ignoring repeats of the current one
+func recordForecast(rid string, s *Stop, et string, now time.Time) {
+    if memoryPressure.Load() {
+        return
+    }
+    key := forecastKey(rid, s)
+    forecastMu.Lock()
+    defer forecastMu.Unlock()
+    obs := pendingForecasts[key]
+    if len(obs) > 0 && obs[len(obs)-1].Et == et {
+        return
+    }
+    if len(obs) >= maxForecastsPerStop {
+        obs = obs[1:]
+    }
+    pendingForecasts[key] = append(obs, forecastObservation{Et: et, Received: now})
+}
+
+// Score the forecasts we saw for a stop against its actual time. For each
+// horizon the forecast that counts is the latest one we'd received that
+// many minutes before the actual.
+func scoreForecasts(rid string, s *Stop, at string, now time.Time) {
+    key := forecastKey(rid, s)
+    forecastMu.Lock()
+    defer forecastMu.Unlock()
+    obs, ok := pendingForecasts[key]
+    if !ok {
+        return
+    }
+    delete(pendingForecasts, key)
+
+    offset, ok := minutesLate(now.In(ukLocation).Format("15:04"), at)
+    if !ok {
+        return
+    }
+    actualAt := now.Add(time.Duration(offset) * time.Minute)
+    for _, h := range forecastHorizons {
+        cutoff := actualAt.Add(-time.Duration(h) * time.Minute)
+        var et string
+        for _, o := range obs {
+            if o.Received.After(cutoff) {
+                break
+            }
+            et = o.Et
+        }
+        if et == "" {
+            continue
+        }
+        errMins, ok := minutesLate(et, at)
+        if !ok {
+            continue
+        }
+        hist := forecastAccuracy[h]
+        if hist == nil {
+            hist = &accuracyHistogram{}
+            forecastAccuracy[h] = hist
+        }
+        hist.add(errMins)
+    }
+}
+
+// Forget forecasts still waiting for an actual, returning how many stops
+// they were for. Their stops go unscored.
+func dropPendingForecasts() int {
+    forecastMu.Lock()
+    defer forecastMu.Unlock()
+    n := len(pendingForecasts)
+    clear(pendingForecasts)
+    return n
+}
+
+func (h *accuracyHistogram) add(errMins int) {
+    bucket := min(max(errMins, minForecastError), maxForecastError)
+    h.counts[bucket-minForecastError]++
+    if errMins < 0 {
+        errMins = -errMins
+    }
+    h.totalAbsErr += errMins
+    h.n++
+}
+
+func pruneForecasts() {
+    for range time.Tick(time.Hour) {
+        forecastMu.Lock()
+        for key, obs := range pendingForecasts {
+            if clock.Now().Sub(obs[len(obs)-1].Received) > pendingForecastTTL {
+                delete(pendingForecasts, key)
+            }
+        }
+        forecastMu.Unlock()
+    }
+}
+
+type ForecastBucket struct {
+    // Actual minus forecast in minutes; the first and last buckets also
+    // hold everything beyond them
+    ErrorMins int `json:"error_mins"`
+    Count     int `json:"count"`
+}
+
+type HorizonAccuracy struct {
+    Minutes      int              `json:"minutes"`
+    Count        int              `json:"count"`
+    MeanAbsError float64          `json:"mean_abs_error"`
+    Histogram    []ForecastBucket `json:"histogram"`
+}
+
+func forecastAccuracyStats() []HorizonAccuracy {
+    forecastMu.Lock()
+    defer forecastMu.Unlock()
+    stats := []HorizonAccuracy{}
+    for _, h := range forecastHorizons {
+        a := HorizonAccuracy{Minutes: h, Histogram: []ForecastBucket{}}
+        if hist := forecastAccuracy[h]; hist != nil && hist.n > 0 {
+            a.Count = hist.n
+            a.MeanAbsError = float64(hist.totalAbsErr) / float64(hist.n)
+            for i, c := range hist.counts {
+                a.Histogram = append(a.Histogram, ForecastBucket{ErrorMins: i + minForecastError, Count: c})
+            }
+        }
+        stats = append(stats, a)
+    }
+    return stats
+}
+
+var horizonFields = listFields[HorizonAccuracy]{
+    "minutes": func(h HorizonAccuracy) string { return strconv.Itoa(h.Minutes) },
+    "count":   func(h HorizonAccuracy) string { return strconv.Itoa(h.Count) },
+}
+
+// GET /api/v1/stats/forecast-accuracy
+func forecastAccuracyHandler(w http.ResponseWriter, r *http.Request) {
+    q, err := parseListQuery(r, horizonFields)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    horizons := applyListQuery(w, r, forecastAccuracyStats(), horizonFields, q)
+    writeJSON(w, struct {
+        Horizons []HorizonAccuracy `json:"horizons"`
+    }{horizons})
+}
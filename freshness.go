@@ -0,0 +1,44 @@
+package main
+
+import "time"
+
+// A running train that's gone this long without a Darwin update is shown
+// as stale, as its forecasts may have been overtaken by events
+const staleAfter = 10 * time.Minute
+
+// How recent a train's information is, for consumers to judge it by
+type Freshness struct {
+    LastUpdated time.Time `json:"last_updated"`
+    MinsAgo     int       `json:"mins_ago"`
+    Stale       bool      `json:"stale"`
+}
+
+// Nil if no Darwin update has been applied to the train yet
+func (p TrainProgress) Freshness(now time.Time) *Freshness {
+    if p.LastUpdated.IsZero() {
+        return nil
+    }
+    age := max(now.Sub(p.LastUpdated), 0)
+    return &Freshness{
+        LastUpdated: p.LastUpdated,
+        MinsAgo:     int(age.Minutes()),
+        Stale:       age >= staleAfter && p.running(now),
+    }
+}
+
+// Whether the train is under way: it's recorded a time somewhere, or
+// should have left its origin by now, and hasn't finished. Schedules are
+// updated hours before a train leaves, and it's fine for those to go
+// quiet until it does.
+func (p TrainProgress) running(now time.Time) bool {
+    if !p.FinishedAt.IsZero() || len(p.Stops) == 0 {
+        return false
+    }
+    for _, s := range p.Stops {
+        if s.Actual != "" {
+            return true
+        }
+    }
+    departs, ok := railDateTime(p.SSD, p.Stops[0].Scheduled, time.Time{})
+    return ok && !now.Before(departs)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+    "html/template"
+    "io"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// A station as listed in the gazetteer: one entry per CRS however many
+// TIPLOCs it has
+type GazetteerEntry struct {
+    CRS    string `json:"crs"`
+    Name   string `json:"name"`
+    NameCy string `json:"name_cy,omitempty"`
+}
+
+const gazetteerPageSize = 50
+
+// Public stations from the reference data, by name, matching q if given
+func gazetteer(q string) []GazetteerEntry {
+    q = strings.ToLower(strings.TrimSpace(q))
+    byCRS := map[string]GazetteerEntry{}
+    stationsMu.RLock()
+    for _, s := range stations {
+        if s.CRS == "" || s.Name == "" {
+            continue
+        }
+        if e, ok := byCRS[s.CRS]; ok && len(e.Name) <= len(s.Name) {
+            // Prefer the plainest name, e.g. "Tamworth" over "Tamworth Low Level"
+            continue
+        }
+        byCRS[s.CRS] = GazetteerEntry{CRS: s.CRS, Name: s.Name, NameCy: s.NameCy}
+    }
+    stationsMu.RUnlock()
+
+    var entries []GazetteerEntry
+    for _, e := range byCRS {
+        if q == "" || strings.Contains(strings.ToLower(e.Name), q) || strings.Contains(strings.ToLower(e.NameCy), q) || strings.ToLower(e.CRS) == q {
+            entries = append(entries, e)
+        }
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+    return entries
+}
+
+var gazetteerTmpl = template.Must(template.New("stations").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "stations_title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{T "stations_title"}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/near">{{T "near_title"}}</a></p>
+    <form method="get" action="/stations">
+        <input type="search" name="q" value="{{.Query}}" placeholder="{{T "stations_search"}}" autofocus>
+        <button type="submit">{{T "stations_search"}}</button>
+    </form>
+    <p class="muted">{{T "stations_count" .Total}}</p>
+    <ul>
+    {{range .Entries}}
+        <li><a href="/board/{{.CRS}}">{{if and (eq $.Lang "cy") .NameCy}}{{.NameCy}}{{else}}{{.Name}}{{end}}</a> <span class="muted">{{.CRS}}</span></li>
+    {{else}}
+        <li>{{T "stations_none"}}</li>
+    {{end}}
+    </ul>
+    <p>
+        {{with .Prev}}<a href="{{.}}">{{T "page_prev"}}</a>{{end}}
+        {{with .Next}}<a href="{{.}}">{{T "page_next"}}</a>{{end}}
+    </p>
+</body>
+</html>
+`))
+
+// GET /stations?q=&page=
+func gazetteerHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    q := r.URL.Query().Get("q")
+    page, err := strconv.Atoi(r.URL.Query().Get("page"))
+    if err != nil || page < 1 {
+        page = 1
+    }
+    entries := gazetteer(q)
+    total := len(entries)
+    start := min((page-1)*gazetteerPageSize, total)
+    end := min(start+gazetteerPageSize, total)
+
+    pageURL := func(n int) string {
+        v := url.Values{}
+        if q != "" {
+            v.Set("q", q)
+        }
+        v.Set("page", strconv.Itoa(n))
+        return "/stations?" + v.Encode()
+    }
+    var prev, next string
+    if page > 1 {
+        prev = pageURL(page - 1)
+    }
+    if end < total {
+        next = pageURL(page + 1)
+    }
+
+    entries = entries[start:end]
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(gazetteerTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme, Query, Prev, Next string
+                Total                                   int
+                Entries                                 []GazetteerEntry
+            }{lang, otherLang(lang), requestTheme(w, r), q, prev, next, total, entries}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                Total    int              `json:"total"`
+                Stations []GazetteerEntry `json:"stations"`
+            }{total, entries}
+        },
+        Text: func(w io.Writer) {
+            var rows [][]string
+            for _, e := range entries {
+                rows = append(rows, []string{e.CRS, e.Name})
+            }
+            writeTextTable(w, []string{"CRS", translate(lang, "station_col")}, rows)
+        },
+    })
+}
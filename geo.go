@@ -0,0 +1,236 @@
+package main
+
+import (
+    "encoding/csv"
+    "io"
+    "log"
+    "math"
+    "net/http"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+type LatLon struct {
+    Lat float64 `json:"lat"`
+    Lon float64 `json:"lon"`
+}
+
+// Station coordinates from STATION_COORDS_FILE, a CSV of code,lat,lon
+// where code is a TIPLOC or a CRS. Darwin's reference data has none.
+var (
+    stationCoords   = map[string]LatLon{}
+    stationCoordsMu sync.RWMutex
+)
+
+func initStationCoords() {
+    path := os.Getenv("STATION_COORDS_FILE")
+    if path == "" {
+        return
+    }
+    n, err := loadStationCoords(path)
+    if err != nil {
+        log.Printf("Failed to load station coordinates from %s: %v", path, err)
+        return
+    }
+    log.Printf("Loaded coordinates for %d locations", n)
+}
+
+func loadStationCoords(path string) (int, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+    r := csv.NewReader(f)
+    r.FieldsPerRecord = -1
+    coords := map[string]LatLon{}
+    for {
+        rec, err := r.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return 0, err
+        }
+        if len(rec) < 3 {
+            continue
+        }
+        lat, errLat := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+        lon, errLon := strconv.ParseFloat(strings.TrimSpace(rec[2]), 64)
+        if errLat != nil || errLon != nil {
+            // Header row or junk
+            continue
+        }
+        coords[strings.ToUpper(strings.TrimSpace(rec[0]))] = LatLon{lat, lon}
+    }
+    stationCoordsMu.Lock()
+    stationCoords = coords
+    stationCoordsMu.Unlock()
+    return len(coords), nil
+}
+
+// Coordinates of a TIPLOC, falling back to those of its station's CRS
+func coordsFor(tiploc string) (LatLon, bool) {
+    stationCoordsMu.RLock()
+    defer stationCoordsMu.RUnlock()
+    if c, ok := stationCoords[tiploc]; ok {
+        return c, true
+    }
+    c, ok := stationCoords[stationKey(tiploc)]
+    return c, ok
+}
+
+// Great-circle distance in kilometres
+func haversineKm(a, b LatLon) float64 {
+    const earthRadiusKm = 6371
+    rad := math.Pi / 180
+    dLat := (b.Lat - a.Lat) * rad
+    dLon := (b.Lon - a.Lon) * rad
+    h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+    return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Where a train probably is: between the last stop it has an actual for
+// and the next one, in proportion to the time elapsed. Trains that
+// haven't started or have finished have no position.
+func estimatePosition(p TrainProgress, now time.Time) (pos LatLon, from, to string, ok bool) {
+    last := -1
+    for i, s := range p.Stops {
+        if s.Actual != "" {
+            last = i
+        }
+    }
+    if last < 0 || last == len(p.Stops)-1 || journeyFinished(p) {
+        return LatLon{}, "", "", false
+    }
+    prev, next := p.Stops[last], p.Stops[last+1]
+    a, ok := coordsFor(prev.Station)
+    if !ok {
+        return LatLon{}, "", "", false
+    }
+    b, ok := coordsFor(next.Station)
+    if !ok {
+        return a, prev.Station, next.Station, true
+    }
+    nextAt := next.Expected
+    if nextAt == "" {
+        nextAt = next.Scheduled
+    }
+    nowHHMM := now.In(ukLocation).Format("15:04")
+    elapsed, ok1 := minutesLate(prev.Actual, nowHHMM)
+    total, ok2 := minutesLate(prev.Actual, nextAt)
+    frac := 0.0
+    if ok1 && ok2 && total > 0 {
+        frac = math.Min(math.Max(float64(elapsed)/float64(total), 0), 1)
+    }
+    pos = LatLon{a.Lat + (b.Lat-a.Lat)*frac, a.Lon + (b.Lon-a.Lon)*frac}
+    return pos, prev.Station, next.Station, true
+}
+
+type NearbyTrain struct {
+    RID        string  `json:"rid"`
+    TrainID    string  `json:"train_id"`
+    TOC        string  `json:"toc"`
+    Position   LatLon  `json:"position"`
+    DistanceKm float64 `json:"distance_km"`
+    From       string  `json:"from"` // last stop it has called at
+    To         string  `json:"to"`   // next stop
+}
+
+// Whether a journey's public times span now, give or take, so only
+// trains that could be running are looked up in the progress store
+func mightBeRunning(j *Journey, nowMins int) bool {
+    var first, last string
+    for _, p := range j.Points {
+        t := p.Ptd
+        if t == "" {
+            t = p.Pta
+        }
+        if t == "" {
+            continue
+        }
+        if first == "" {
+            first = t
+        }
+        last = t
+    }
+    start, ok1 := parseRailTime(first)
+    end, ok2 := parseRailTime(last)
+    if !ok1 || !ok2 {
+        return false
+    }
+    if end < start {
+        end += 24 * 60
+    }
+    // Allow for trains running up to three hours late
+    return nowMins >= start-10 && nowMins <= end+180
+}
+
+// Trains estimated to be within radiusKm of a point, nearest first
+func trainsNear(at LatLon, radiusKm float64, now time.Time) []NearbyTrain {
+    local := now.In(ukLocation)
+    today := local.Format("2006-01-02")
+    nowMins := local.Hour()*60 + local.Minute()
+    var candidates []*Journey
+    journeysMu.RLock()
+    for _, j := range journeys {
+        if j.SSD == today && mightBeRunning(j, nowMins) {
+            candidates = append(candidates, j)
+        }
+    }
+    journeysMu.RUnlock()
+
+    nearby := []NearbyTrain{}
+    for _, j := range candidates {
+        p, ok, err := progressStore.Get(j.RID)
+        if err != nil {
+            log.Printf("Failed to load progress for %s: %v", j.RID, err)
+            continue
+        }
+        if !ok {
+            continue
+        }
+        pos, from, to, ok := estimatePosition(p, now)
+        if !ok {
+            continue
+        }
+        if d := haversineKm(at, pos); d <= radiusKm {
+            nearby = append(nearby, NearbyTrain{RID: j.RID, TrainID: j.TrainID, TOC: j.TOC, Position: pos, DistanceKm: math.Round(d*100) / 100, From: from, To: to})
+        }
+    }
+    sort.Slice(nearby, func(i, k int) bool { return nearby[i].DistanceKm < nearby[k].DistanceKm })
+    return nearby
+}
+
+const (
+    defaultNearRadiusKm = 5
+    maxNearRadiusKm     = 50
+)
+
+// GET /api/v1/trains/near?lat=51.47&lon=-3.18&radius=5
+func trainsNearHandler(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    at, ok := queryLatLon(q)
+    if !ok {
+        http.Error(w, "lat and lon are required", http.StatusBadRequest)
+        return
+    }
+    radius := float64(defaultNearRadiusKm)
+    if v := q.Get("radius"); v != "" {
+        n, err := strconv.ParseFloat(v, 64)
+        if err != nil || n <= 0 || n > maxNearRadiusKm {
+            http.Error(w, "radius must be between 0 and 50 km", http.StatusBadRequest)
+            return
+        }
+        radius = n
+    }
+    writeJSON(w, struct {
+        Position LatLon        `json:"position"`
+        RadiusKm float64       `json:"radius_km"`
+        Trains   []NearbyTrain `json:"trains"`
+    }{at, radius, trainsNear(at, radius, clock.Now())})
+}
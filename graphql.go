@@ -0,0 +1,457 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/graph-gophers/graphql-go"
+    "github.com/graph-gophers/graphql-go/relay"
+)
+
+// POST /graphql answers queries over services, locations, forecasts and
+// stats, so a dashboard can fetch exactly the fields it needs in one
+// request. Like the JSON API it's open unless API_AUTH=required.
+const graphQLSchema = `
+schema {
+    query: Query
+}
+
+type Query {
+    # A train by RID, from the live feed or else its schedule
+    service(rid: String!): Service
+    # Today's runs of a headcode, in order of departure
+    runs(headcode: String!): [Service!]!
+    # Public services on a day within the timetable horizon
+    timetable(date: String!, train: String, uid: String, crs: String): [ScheduledService!]!
+    # A station's departures over the next two hours
+    board(crs: String!, to: String, all: Boolean): [Departure!]!
+    location(crs: String!): Location
+    # Stations matching search, or nearest to lat and lon
+    locations(search: String, lat: Float, lon: Float, limit: Int): [Location!]!
+    # How far Darwin's forecasts were out, by how far ahead they were made
+    forecastAccuracy: [ForecastHorizon!]!
+    # Terminal delay per day for a headcode, oldest first
+    delayHistory(headcode: String!, days: Int): [DayDelay!]!
+}
+
+type Service {
+    rid: String!
+    trainId: String!
+    ssd: String!
+    toc: String!
+    mode: String!
+    stops: [Stop!]!
+    previousRids: [String!]!
+    # Minutes late at the last actual time, if it's reported one
+    delay: Int
+    lateReason: String
+    predictedArrival: ArrivalPrediction
+    attributes: [String!]!
+    # How recent the train's information is, once Darwin has updated it
+    freshness: Freshness
+}
+
+type Freshness {
+    lastUpdated: String!
+    minsAgo: Int!
+    stale: Boolean!
+}
+
+type Stop {
+    station: String!
+    scheduled: String!
+    expected: String!
+    actual: String!
+    status: String!
+    platform: String!
+    platformConfirmed: Boolean!
+    event: String!
+    note: String!
+    delayed: Boolean!
+    length: Int!
+}
+
+type ArrivalPrediction {
+    station: String!
+    scheduled: String!
+    predicted: String!
+    delay: Int!
+    darwin: String!
+}
+
+type Departure {
+    rid: String!
+    trainId: String!
+    time: String!
+    expected: String!
+    actual: String!
+    dueMins: Int
+    destination: String!
+    platform: String!
+    platformConfirmed: Boolean!
+    toc: String!
+    status: String!
+    mode: String!
+    reason: String!
+    length: Int!
+    service: Service
+}
+
+type ScheduledService {
+    rid: String!
+    trainId: String!
+    ssd: String!
+    toc: String!
+    origin: String!
+    destination: String!
+    departs: String!
+    platform: String!
+    # The variant that runs, for timetables with STP indicators
+    stp: String!
+    cancelled: Boolean!
+    service: Service
+}
+
+type Location {
+    crs: String!
+    name: String!
+    nameCy: String!
+    tiplocs: [String!]!
+    lat: Float
+    lon: Float
+    # From the lat and lon searched for
+    distanceKm: Float
+    departures(to: String, all: Boolean): [Departure!]!
+}
+
+type ForecastHorizon {
+    minutes: Int!
+    count: Int!
+    meanAbsError: Float!
+    histogram: [ForecastBucket!]!
+}
+
+type ForecastBucket {
+    errorMins: Int!
+    count: Int!
+}
+
+type DayDelay {
+    date: String!
+    delay: Int
+    cancelled: Boolean!
+}
+`
+
+// Deepest a query may nest: as deep as the schema goes, e.g.
+// locations { departures { service { stops { station } } } }, so nothing
+// added to it later can be walked further without raising this
+const maxGraphQLDepth = 5
+
+var graphQLHandler = &relay.Handler{Schema: graphql.MustParseSchema(graphQLSchema, &graphQLResolver{},
+    graphql.UseFieldResolvers(),
+    graphql.MaxDepth(maxGraphQLDepth),
+)}
+
+type graphQLResolver struct{}
+
+// Resolvers wrap the API's own types, adding what GraphQL needs: int32s
+// and the links between them
+type (
+    gqlService    struct{ TrainProgress }
+    gqlStop       struct{ Stop }
+    gqlPrediction struct{ ArrivalPrediction }
+    gqlDeparture  struct{ BoardRow }
+    gqlScheduled  struct{ ScheduledService }
+    gqlLocation   struct {
+        GazetteerEntry
+        DistanceKm *float64
+    }
+    gqlHorizon   struct{ HorizonAccuracy }
+    gqlBucket    struct{ ForecastBucket }
+    gqlDayDelay  struct{ DayDelay }
+    gqlFreshness struct{ Freshness }
+)
+
+func optionalInt32(v int, ok bool) *int32 {
+    if !ok {
+        return nil
+    }
+    n := int32(v)
+    return &n
+}
+
+func (graphQLResolver) Service(args struct{ Rid string }) (*gqlService, error) {
+    return gqlServiceByRID(args.Rid)
+}
+
+func gqlServiceByRID(rid string) (*gqlService, error) {
+    p, ok, err := lookupProgress(rid)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", rid, err)
+        return nil, errors.New("failed to load progress")
+    }
+    if !ok {
+        return nil, nil
+    }
+    return &gqlService{p}, nil
+}
+
+func (graphQLResolver) Runs(args struct{ Headcode string }) ([]*gqlService, error) {
+    runs, err := todaysRuns(strings.ToUpper(args.Headcode))
+    if err != nil {
+        log.Printf("Failed to load runs of %s: %v", args.Headcode, err)
+        return nil, errors.New("failed to load progress")
+    }
+    out := []*gqlService{}
+    for _, p := range runs {
+        out = append(out, &gqlService{p})
+    }
+    return out, nil
+}
+
+func (graphQLResolver) Timetable(args struct {
+    Date            string
+    Train, Uid, Crs *string
+}) ([]gqlScheduled, error) {
+    var headcode, uid, crs string
+    if args.Train != nil {
+        headcode = strings.ToUpper(*args.Train)
+    }
+    if args.Uid != nil {
+        uid = strings.ToUpper(*args.Uid)
+    }
+    if args.Crs != nil {
+        crs = strings.ToUpper(*args.Crs)
+    }
+    services, err := searchTimetable(args.Date, headcode, uid, crs)
+    if errors.Is(err, errOutsideHorizon) {
+        dates := timetableDates(clock.Now())
+        return nil, fmt.Errorf("date must be between %s and %s", dates[0], dates[len(dates)-1])
+    }
+    if errors.Is(err, errDayNotCached) {
+        return nil, err
+    }
+    if err != nil {
+        log.Printf("Failed to load the %s timetable: %v", args.Date, err)
+        return nil, errors.New("failed to load timetable")
+    }
+    out := []gqlScheduled{}
+    for _, s := range services {
+        out = append(out, gqlScheduled{s})
+    }
+    return out, nil
+}
+
+type gqlBoardArgs struct {
+    To  *string
+    All *bool
+}
+
+func gqlBoard(crs string, args gqlBoardArgs) []gqlDeparture {
+    opts := boardOptions{All: args.All != nil && *args.All}
+    if args.To != nil {
+        opts.To = strings.ToUpper(strings.TrimSpace(*args.To))
+    }
+    out := []gqlDeparture{}
+    for _, r := range buildBoard(strings.ToUpper(crs), opts, clock.Now()).allRows() {
+        out = append(out, gqlDeparture{r})
+    }
+    return out
+}
+
+func (graphQLResolver) Board(args struct {
+    Crs string
+    gqlBoardArgs
+}) []gqlDeparture {
+    return gqlBoard(args.Crs, args.gqlBoardArgs)
+}
+
+func (graphQLResolver) Location(args struct{ Crs string }) *gqlLocation {
+    crs := strings.ToUpper(args.Crs)
+    for _, e := range gazetteer(crs) {
+        if e.CRS == crs {
+            return &gqlLocation{GazetteerEntry: e}
+        }
+    }
+    return nil
+}
+
+func (graphQLResolver) Locations(args struct {
+    Search   *string
+    Lat, Lon *float64
+    Limit    *int32
+}) ([]gqlLocation, error) {
+    out := []gqlLocation{}
+    limit := gazetteerPageSize
+    if args.Limit != nil {
+        limit = min(max(0, int(*args.Limit)), gazetteerPageSize)
+    }
+    if args.Lat != nil || args.Lon != nil {
+        if args.Lat == nil || args.Lon == nil || *args.Lat < -90 || *args.Lat > 90 || *args.Lon < -180 || *args.Lon > 180 {
+            return nil, errors.New("lat and lon must both be given, in range")
+        }
+        for _, s := range stationsNear(LatLon{*args.Lat, *args.Lon}, min(limit, maxNearStations)) {
+            out = append(out, gqlLocation{GazetteerEntry{s.CRS, s.Name, s.NameCy}, &s.DistanceKm})
+        }
+        return out, nil
+    }
+    var q string
+    if args.Search != nil {
+        q = *args.Search
+    }
+    entries := gazetteer(q)
+    for _, e := range entries[:min(limit, len(entries))] {
+        out = append(out, gqlLocation{GazetteerEntry: e})
+    }
+    return out, nil
+}
+
+func (graphQLResolver) ForecastAccuracy() []gqlHorizon {
+    var out []gqlHorizon
+    for _, h := range forecastAccuracyStats() {
+        out = append(out, gqlHorizon{h})
+    }
+    return out
+}
+
+func (graphQLResolver) DelayHistory(args struct {
+    Headcode string
+    Days     *int32
+}) ([]gqlDayDelay, error) {
+    days := 30
+    if args.Days != nil {
+        days = int(*args.Days)
+    }
+    if days < 1 || days > maxDelayHistoryDays {
+        return nil, fmt.Errorf("days must be between 1 and %d", maxDelayHistoryDays)
+    }
+    headcode := strings.ToUpper(args.Headcode)
+    history, err := delayHistory(headcode, days, clock.Now())
+    if err != nil {
+        log.Printf("Failed to read delay history for %s: %v", headcode, err)
+        return nil, errors.New("failed to read delay history")
+    }
+    out := []gqlDayDelay{}
+    for _, d := range history {
+        out = append(out, gqlDayDelay{d})
+    }
+    return out, nil
+}
+
+func (s gqlService) Stops() []gqlStop {
+    out := []gqlStop{}
+    for _, st := range s.TrainProgress.Stops {
+        out = append(out, gqlStop{st})
+    }
+    return out
+}
+
+func (s gqlService) Delay() *int32 {
+    return optionalInt32(trainDelay(s.TrainProgress))
+}
+
+func (s gqlService) LateReason() *string {
+    reason := s.TrainProgress.LateCause().Text(defaultLang)
+    if reason == "" {
+        return nil
+    }
+    return &reason
+}
+
+func (s gqlService) PredictedArrival() *gqlPrediction {
+    if p, ok := predictArrival(s.TrainProgress); ok {
+        return &gqlPrediction{p}
+    }
+    return nil
+}
+
+func (s gqlService) Attributes() []string {
+    return append([]string{}, progressAttributes(s.TrainProgress)...)
+}
+
+func (s gqlService) Freshness() *gqlFreshness {
+    if f := s.TrainProgress.Freshness(clock.Now()); f != nil {
+        return &gqlFreshness{*f}
+    }
+    return nil
+}
+
+func (f gqlFreshness) LastUpdated() string { return f.Freshness.LastUpdated.Format(time.RFC3339) }
+
+func (f gqlFreshness) MinsAgo() int32 { return int32(f.Freshness.MinsAgo) }
+
+func (s gqlStop) Length() int32 { return int32(s.Stop.Length) }
+
+func (p gqlPrediction) Delay() int32 { return int32(p.ArrivalPrediction.Delay) }
+
+func (d gqlDeparture) Length() int32 { return int32(d.BoardRow.Length) }
+
+func (d gqlDeparture) DueMins() *int32 {
+    if d.BoardRow.DueMins == nil {
+        return nil
+    }
+    return optionalInt32(*d.BoardRow.DueMins, true)
+}
+
+func (d gqlDeparture) Service() (*gqlService, error) { return gqlServiceByRID(d.RID) }
+
+func (s gqlScheduled) Service() (*gqlService, error) { return gqlServiceByRID(s.RID) }
+
+func (l gqlLocation) Tiplocs() []string {
+    return append([]string{}, tiplocsForCRS(l.CRS)...)
+}
+
+// Coordinates of the first of the station's TIPLOCs that has them
+func (l gqlLocation) coords() (LatLon, bool) {
+    for _, tiploc := range tiplocsForCRS(l.CRS) {
+        if c, ok := coordsFor(tiploc); ok {
+            return c, true
+        }
+    }
+    return LatLon{}, false
+}
+
+func (l gqlLocation) Lat() *float64 {
+    if c, ok := l.coords(); ok {
+        return &c.Lat
+    }
+    return nil
+}
+
+func (l gqlLocation) Lon() *float64 {
+    if c, ok := l.coords(); ok {
+        return &c.Lon
+    }
+    return nil
+}
+
+func (l gqlLocation) Departures(args gqlBoardArgs) []gqlDeparture {
+    return gqlBoard(l.CRS, args)
+}
+
+func (h gqlHorizon) Minutes() int32 { return int32(h.HorizonAccuracy.Minutes) }
+
+func (h gqlHorizon) Count() int32 { return int32(h.HorizonAccuracy.Count) }
+
+func (h gqlHorizon) Histogram() []gqlBucket {
+    out := []gqlBucket{}
+    for _, b := range h.HorizonAccuracy.Histogram {
+        out = append(out, gqlBucket{b})
+    }
+    return out
+}
+
+func (b gqlBucket) ErrorMins() int32 { return int32(b.ForecastBucket.ErrorMins) }
+
+func (b gqlBucket) Count() int32 { return int32(b.ForecastBucket.Count) }
+
+func (d gqlDayDelay) Delay() *int32 {
+    if d.DayDelay.Delay == nil {
+        return nil
+    }
+    return optionalInt32(*d.DayDelay.Delay, true)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+    "cmp"
+    "context"
+    "errors"
+    "log"
+    "net"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/jashcroft123/MinimalTrains/trainspb"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/proto"
+)
+
+// The gRPC API serves the same trains, boards and timetable as the JSON
+// one, for integrations that want typed RPC; see trainspb. It listens on
+// GRPC_ADDR (e.g. :9090) if that's set, and like the JSON API needs a
+// bearer token with the read scope in the authorization metadata when
+// API_AUTH=required.
+
+type trainsServer struct {
+    trainspb.UnimplementedTrainsServer
+}
+
+// Boards are streamed no more often than this
+const minBoardStreamInterval = 10 * time.Second
+
+func startGRPC(addr string) {
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        log.Fatalf("Failed to listen on %s for gRPC: %v", addr, err)
+    }
+    s := grpc.NewServer(
+        grpc.ChainUnaryInterceptor(timeUnaryRPC, authoriseUnaryRPC),
+        grpc.ChainStreamInterceptor(authoriseStreamRPC),
+    )
+    trainspb.RegisterTrainsServer(s, trainsServer{})
+    log.Printf("gRPC API listening on %s", addr)
+    log.Fatal(s.Serve(ln))
+}
+
+func timeUnaryRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+    defer observeCall("handler", info.FullMethod, info.FullMethod, time.Now())
+    return handler(ctx, req)
+}
+
+func authoriseUnaryRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+    if err := authoriseRPC(ctx); err != nil {
+        return nil, err
+    }
+    return handler(ctx, req)
+}
+
+func authoriseStreamRPC(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    if err := authoriseRPC(ss.Context()); err != nil {
+        return err
+    }
+    return handler(srv, ss)
+}
+
+// The read-scope check readAPI makes, for RPCs
+func authoriseRPC(ctx context.Context) error {
+    if os.Getenv("API_AUTH") != "required" {
+        return nil
+    }
+    if archiveDB == nil {
+        return status.Error(codes.Unavailable, "API tokens need the archive database (ARCHIVE_PATH)")
+    }
+    md, _ := metadata.FromIncomingContext(ctx)
+    var token string
+    for _, v := range md.Get("authorization") {
+        if t, ok := strings.CutPrefix(v, "Bearer "); ok {
+            token = strings.TrimSpace(t)
+        }
+    }
+    if token == "" {
+        return status.Error(codes.Unauthenticated, "missing bearer token")
+    }
+    t, ok, err := lookupToken(archiveDB, token)
+    if err != nil {
+        log.Printf("Failed to look up API token: %v", err)
+        return status.Error(codes.Internal, "failed to check token")
+    }
+    if !ok {
+        return status.Error(codes.Unauthenticated, "invalid or revoked token")
+    }
+    if !t.allows("read") {
+        return status.Error(codes.PermissionDenied, "token lacks the read scope")
+    }
+    return nil
+}
+
+func (trainsServer) GetTrain(ctx context.Context, req *trainspb.GetTrainRequest) (*trainspb.Train, error) {
+    p, ok, err := lookupProgress(req.Rid)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", req.Rid, err)
+        return nil, status.Error(codes.Internal, "failed to load progress")
+    }
+    if !ok {
+        return nil, status.Error(codes.NotFound, "unknown RID")
+    }
+    return trainMessage(p), nil
+}
+
+func (trainsServer) StreamBoard(req *trainspb.StreamBoardRequest, stream grpc.ServerStreamingServer[trainspb.Board]) error {
+    crs := strings.ToUpper(strings.TrimSpace(req.Crs))
+    if crs == "" {
+        return status.Error(codes.InvalidArgument, "crs is required")
+    }
+    opts := boardOptions{All: req.All, To: strings.ToUpper(strings.TrimSpace(req.To))}
+    interval := max(minBoardStreamInterval, time.Duration(cmp.Or(req.IntervalSeconds, 30))*time.Second)
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    var last *trainspb.Board
+    for {
+        board := boardMessage(buildBoard(crs, opts, clock.Now()))
+        if !proto.Equal(board, last) {
+            if err := stream.Send(board); err != nil {
+                return err
+            }
+            last = board
+        }
+        select {
+        case <-stream.Context().Done():
+            return nil
+        case <-ticker.C:
+        }
+    }
+}
+
+func (trainsServer) SearchTimetable(ctx context.Context, req *trainspb.SearchTimetableRequest) (*trainspb.SearchTimetableResponse, error) {
+    services, err := searchTimetable(req.Date, strings.ToUpper(req.TrainId), "", strings.ToUpper(req.Crs))
+    if errors.Is(err, errOutsideHorizon) {
+        dates := timetableDates(clock.Now())
+        return nil, status.Errorf(codes.NotFound, "date must be between %s and %s", dates[0], dates[len(dates)-1])
+    }
+    if errors.Is(err, errDayNotCached) {
+        return nil, status.Error(codes.Unavailable, err.Error())
+    }
+    if err != nil {
+        log.Printf("Failed to load the %s timetable: %v", req.Date, err)
+        return nil, status.Error(codes.Unavailable, "failed to load timetable")
+    }
+    resp := &trainspb.SearchTimetableResponse{Date: req.Date}
+    for _, s := range services {
+        resp.Services = append(resp.Services, &trainspb.ScheduledService{
+            Rid:         s.RID,
+            TrainId:     s.TrainID,
+            Ssd:         s.SSD,
+            Toc:         s.TOC,
+            Origin:      s.Origin,
+            Destination: s.Destination,
+            Departs:     s.Departs,
+            Platform:    s.Platform,
+        })
+    }
+    return resp, nil
+}
+
+func trainMessage(p TrainProgress) *trainspb.Train {
+    t := &trainspb.Train{
+        Rid:          p.RID,
+        TrainId:      p.TrainID,
+        Ssd:          p.SSD,
+        Toc:          p.TOC,
+        Mode:         p.Mode,
+        PreviousRids: p.PreviousRIDs,
+        LateReason:   int32(p.LateReason),
+    }
+    for _, s := range p.Stops {
+        t.Stops = append(t.Stops, &trainspb.Stop{
+            Station:           s.Station,
+            Scheduled:         s.Scheduled,
+            Expected:          s.Expected,
+            Actual:            s.Actual,
+            Status:            s.Status,
+            Platform:          s.Platform,
+            PlatformConfirmed: s.PlatformConfirmed,
+            Event:             s.Event,
+            Note:              s.Note,
+            Delayed:           s.Delayed,
+            Length:            int32(s.Length),
+        })
+    }
+    return t
+}
+
+func boardMessage(b Board) *trainspb.Board {
+    msg := &trainspb.Board{Crs: b.CRS}
+    for _, r := range b.allRows() {
+        msg.Departures = append(msg.Departures, &trainspb.Departure{
+            Rid:               r.RID,
+            TrainId:           r.TrainID,
+            Time:              r.Time,
+            Expected:          r.Expected,
+            Actual:            r.Actual,
+            Destination:       r.Destination,
+            Platform:          r.Platform,
+            PlatformConfirmed: r.PlatformConfirmed,
+            Toc:               r.TOC,
+            Status:            r.Status,
+            Mode:              r.Mode,
+            Reason:            r.Reason,
+            Length:            int32(r.Length),
+        })
+    }
+    return msg
+}
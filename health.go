@@ -0,0 +1,111 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// When each STOMP feed last delivered a message, so a feed that's
+// connected but silent can be spotted
+var (
+    feedStarted     = map[string]time.Time{}
+    feedLastMessage = map[string]time.Time{}
+    feedHealthMu    sync.RWMutex
+)
+
+// Longest a feed may go without a message before it counts as stalled
+var feedStallTimeout = 5 * time.Minute
+
+func initHealth() {
+    v := envOr("FEED_STALL_TIMEOUT", "5m")
+    d, err := time.ParseDuration(v)
+    if err != nil || d <= 0 {
+        log.Printf("Invalid FEED_STALL_TIMEOUT %q; using 5m", v)
+        return
+    }
+    feedStallTimeout = d
+}
+
+func markFeedStarted(name string) {
+    feedHealthMu.Lock()
+    if _, ok := feedStarted[name]; !ok {
+        feedStarted[name] = time.Now()
+    }
+    feedHealthMu.Unlock()
+}
+
+func markFeedMessage(name string) {
+    feedHealthMu.Lock()
+    feedLastMessage[name] = time.Now()
+    feedHealthMu.Unlock()
+}
+
+// Whether a feed has had a message recently. Feeds get one stall timeout
+// after starting to deliver their first message.
+func feedHealthy(name string, now time.Time) bool {
+    feedHealthMu.RLock()
+    defer feedHealthMu.RUnlock()
+    started, ok := feedStarted[name]
+    if !ok {
+        return true
+    }
+    last := feedLastMessage[name]
+    if last.IsZero() {
+        last = started
+    }
+    return now.Sub(last) <= feedStallTimeout
+}
+
+// Whether the Push Port consumer is keeping up, if this process runs one
+func ingestHealthy() bool {
+    return feedHealthy("Darwin", time.Now())
+}
+
+type FeedHealth struct {
+    LastMessage *time.Time `json:"last_message,omitempty"`
+    Healthy     bool       `json:"healthy"`
+}
+
+// GET /healthz: 200 if every running feed is delivering, otherwise 503
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+    now := time.Now()
+    feedHealthMu.RLock()
+    var names []string
+    for name := range feedStarted {
+        names = append(names, name)
+    }
+    feedHealthMu.RUnlock()
+
+    feeds := map[string]FeedHealth{}
+    healthy := true
+    for _, name := range names {
+        h := FeedHealth{Healthy: feedHealthy(name, now)}
+        feedHealthMu.RLock()
+        if last, ok := feedLastMessage[name]; ok {
+            h.LastMessage = &last
+        }
+        feedHealthMu.RUnlock()
+        healthy = healthy && h.Healthy
+        feeds[name] = h
+    }
+    if !healthy {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    var offset *FeedOffset
+    if off, ok := ingestProgress.offset(); ok {
+        offset = &off
+    }
+    // Shedding, polling the journal and memory pressure keep the service
+    // up, so they're reported but aren't unhealthy
+    writeJSON(w, struct {
+        Healthy  bool                  `json:"healthy"`
+        Degraded bool                  `json:"degraded"`
+        Feeds    map[string]FeedHealth `json:"feeds"`
+        Shedding ShedState             `json:"shedding"`
+        Memory   MemoryState           `json:"memory"`
+        Offset   *FeedOffset           `json:"ingest_offset,omitempty"`
+    }{healthy, shedding.Load() || journalPolling.Load() || memoryPressure.Load(), feeds, shedState(), memoryState(), offset})
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "net/http"
+    "strings"
+    "time"
+)
+
+const defaultLang = "en"
+
+// UI string bundles, keyed by language then message key.
+// Missing keys fall back to English, then to the key itself.
+var translations = map[string]map[string]string{
+    "en": {
+        "title":             "Train Route Progression",
+        "loading":           "Loading train route...",
+        "train_progress":    "Train %s Progress",
+        "scheduled":         "Scheduled",
+        "actual":            "Actual",
+        "status":            "Status",
+        "switch_lang":       "Cymraeg",
+        "between_signals":   "Between signals %s and %s",
+        "at_signal":         "At signal %s",
+        "trust_discrepancy": "TRUST reports %s",
+        "board_title":       "Departures from %s",
+        "time":              "Time",
+        "expected":          "Expected",
+        "destination":       "Destination",
+        "platform":          "Platform",
+        "operator":          "Operator",
+        "vstp":              "Short-term plan",
+        "charter":           "Charter",
+        "no_departures":     "No departures in the next two hours",
+        "bus":               "Bus",
+        "ship":              "Ferry",
+        "reissued":          "Darwin has reissued this train's schedule; earlier times are kept.",
+        "feed_title":        "Disruption at %s",
+        "feed_message":      "Station message: %s",
+        "feed_cancelled":    "The %s to %s is cancelled",
+        "feed_delayed":      "The %s to %s is %d minutes late",
+        "group_none":        "All departures",
+        "group_platform":    "By platform",
+        "group_destination": "By destination",
+        "platform_unknown":  "Platform not yet known",
+        "plat_expected":     "Expected platform, not yet confirmed",
+        "plat_confirmed":    "Platform confirmed",
+        "rel_due_in":        "due in %d min",
+        "rel_due_now":       "due now",
+        "rel_overdue":       "was due %d min ago",
+        "rel_left_ago":      "left %d min ago",
+        "rel_just_left":     "just left",
+        "rel_arrived_ago":   "arrived %d min ago",
+        "rel_just_arrived":  "just arrived",
+        "all_services":      "Show all services",
+        "passenger_only":    "Passenger services only",
+        "segment_miles":     "%.1f miles",
+        "segment_mph":       "averaging %.0f mph",
+        "train_cancelled":   "This train is cancelled",
+        "cancelled_between": "Cancelled between %s and %s",
+        "cancelled_at":      "Will not call at %s",
+        "stations_title":    "Stations",
+        "stations_search":   "Search",
+        "stations_count":    "%d stations",
+        "stations_none":     "No stations match",
+        "near_title":        "Nearest station",
+        "near_locating":     "Finding your location…",
+        "near_denied":       "Couldn't get your location. Pick a station from the list instead.",
+        "near_none":         "No stations within %d km",
+        "page_prev":         "Previous",
+        "page_next":         "Next",
+        "station_col":       "Station",
+        "departs_from":      "From",
+        "card_late":         "%d min late",
+        "card_departed":     "Departed %s at %s",
+        "card_arrived":      "Arrived at %s at %s",
+        "card_due":          "Due to leave %s at %s",
+        "meta_next_dep":     "Next departure %s to %s: %s",
+        "meta_expected":     "expected %s",
+        "delay_unknown":     "Delay not yet known; estimate uncertain",
+        "forecast_source":   "estimate from %s",
+        "arrives_at":        "at %s %s",
+        "fastest_to":        "Fastest to %s",
+        "fastest_to_label":  "Fastest train to",
+        "arrives_col":       "At %s",
+        "disruptions_title": "Planned disruption",
+        "planned_at":        "Planned disruption at %s",
+        "disruptions_route": "Planned disruption between %s and %s",
+        "calling_at":        "Calling at",
+        "date_col":          "Date",
+        "trains_col":        "Trains",
+        "buses_col":         "Replacement buses",
+        "engineering_works": "Engineering work",
+        "planned_normal":    "Normal service",
+        "planned_works":     "Engineering work planned",
+        "planned_reduced":   "Fewer trains than usual",
+        "planned_buses":     "Buses replace some trains",
+        "planned_closed":    "No trains",
+        "planned_no_data":   "Timetable not yet available",
+        "where_next":        "Next stop %s at %s",
+        "choose_train":      "Trains running as %s today",
+        "choose_prompt":     "More than one train runs as %s today. Which do you mean?",
+        "commutes_title":    "My commutes",
+        "commutes_empty":    "No saved commutes yet",
+        "commute_heading":   "%s to %s around %s",
+        "commute_around":    "Around",
+        "commute_add":       "Save commute",
+        "commute_remove":    "Remove",
+        "stats_title":       "Operator league table",
+        "stats_note":        "Today's services so far. Punctuality is the share of calls made less than 5 minutes late.",
+        "stats_services":    "Services",
+        "stats_punctuality": "Punctuality",
+        "stats_avg_delay":   "Average delay",
+        "stats_cancelled":   "Cancelled",
+        "stats_full_part":   "%d in full, %d in part",
+        "stats_running":     "Running now",
+        "stats_mins":        "%.1f min",
+        "stats_none":        "No services have run yet today",
+        "walkup_title":      "Timetable from %s",
+        "walkup_link":       "Timetable",
+        "walkup_none":       "No departures are planned on this day",
+        "walkup_live":       "Live departures",
+        "share_link":        "Short link:",
+        "make_short_link":   "Make a short link",
+        "unit_class":        "Class %s",
+        "unit_formation":    "units %s",
+        "unit_built":        "built %d (%d years old)",
+        "enthusiast_on":     "Show units",
+        "enthusiast_off":    "Hide units",
+        "last_report":       "last report %d min ago",
+        "last_report_now":   "last report just now",
+        "stale":             "Stale",
+        "nrcc_more":         "%d more station messages",
+        "track_this":        "Track this",
+        "stop_watching":     "Stop following this train",
+        "compare_title":     "Compare trains",
+        "compare_usage":     "Name up to %d trains to compare by headcode, e.g. /compare?trains=2B15,1M45",
+        "compare_none":      "No train runs as %s today",
+        "reason_delayed":    "This train is delayed by %s",
+        "reason_cancelled":  "This train has been cancelled because of %s",
+        "reason_at":         " at %s",
+        "reason_near":       " near %s",
+        "page_error":        "Sorry, something went wrong showing this page. Please try again in a moment.",
+        "page_error_home":   "Back to the start",
+        "short_platform":    "Short platform: travel in the front %d coaches",
+        "data_loading":      "Loading timetable data…",
+        "loading_refresh":   "This page will refresh in a few seconds.",
+        "length_col":        "Coaches",
+        "coaches":           "%d coaches",
+        "reason_col":        "Reason",
+        "board_columns":     "Columns:",
+        "show_columns":      "Show",
+        "cat_OO":            "Stopping service",
+        "cat_XX":            "Express service",
+        "cat_XZ":            "Sleeper service",
+        "first_class":       "First class available",
+        "standard_only":     "Standard class only",
+        "cater_C":           "Buffet",
+        "cater_F":           "Restaurant car for first class",
+        "cater_H":           "Hot food",
+        "cater_M":           "Meals for first class",
+        "cater_R":           "Restaurant",
+        "cater_T":           "Trolley service",
+        "sleeper_B":         "Sleeper berths, first and standard",
+        "sleeper_F":         "Sleeper berths, first class",
+        "sleeper_S":         "Sleeper berths, standard class",
+        "predicted_arr":     "Predicted arrival at %s: %s",
+        "darwin_expects":    "Darwin expects %s",
+        "commute_calendar":  "Add to calendar",
+        "commute_event":     "%s %s to %s",
+        "commute_none":      "No trains around %s today",
+        "commute_not_today": "Not today: this commute is for %s",
+        "days_weekdays":     "Weekdays",
+        "days_weekends":     "Weekends",
+        "days_daily":        "Every day",
+        "arrival_col":       "Arrives",
+    },
+    "cy": {
+        "title":             "Cynnydd Llwybr y Trên",
+        "loading":           "Yn llwytho llwybr y trên...",
+        "train_progress":    "Cynnydd Trên %s",
+        "scheduled":         "Wedi'i drefnu",
+        "actual":            "Gwirioneddol",
+        "status":            "Statws",
+        "switch_lang":       "English",
+        "between_signals":   "Rhwng signalau %s a %s",
+        "at_signal":         "Wrth signal %s",
+        "trust_discrepancy": "Mae TRUST yn adrodd %s",
+        "board_title":       "Ymadawiadau o %s",
+        "time":              "Amser",
+        "expected":          "Disgwylir",
+        "destination":       "Cyrchfan",
+        "platform":          "Platfform",
+        "operator":          "Gweithredwr",
+        "vstp":              "Cynllun tymor byr",
+        "charter":           "Siartr",
+        "no_departures":     "Dim ymadawiadau yn ystod y ddwy awr nesaf",
+        "bus":               "Bws",
+        "ship":              "Fferi",
+        "reissued":          "Mae Darwin wedi ailgyhoeddi amserlen y trên hwn; cedwir yr amseroedd cynharach.",
+        "feed_title":        "Tarfu yn %s",
+        "feed_message":      "Neges gorsaf: %s",
+        "feed_cancelled":    "Mae'r %s i %s wedi'i ganslo",
+        "feed_delayed":      "Mae'r %s i %s %d munud yn hwyr",
+        "group_none":        "Pob ymadawiad",
+        "group_platform":    "Yn ôl platfform",
+        "group_destination": "Yn ôl cyrchfan",
+        "platform_unknown":  "Platfform heb ei gyhoeddi eto",
+        "plat_expected":     "Platfform disgwyliedig, heb ei gadarnhau eto",
+        "plat_confirmed":    "Platfform wedi'i gadarnhau",
+        "rel_due_in":        "yn ddyledus ymhen %d munud",
+        "rel_due_now":       "yn ddyledus nawr",
+        "rel_overdue":       "yn ddyledus %d munud yn ôl",
+        "rel_left_ago":      "gadawodd %d munud yn ôl",
+        "rel_just_left":     "newydd adael",
+        "rel_arrived_ago":   "cyrhaeddodd %d munud yn ôl",
+        "rel_just_arrived":  "newydd gyrraedd",
+        "all_services":      "Dangos pob gwasanaeth",
+        "passenger_only":    "Gwasanaethau teithwyr yn unig",
+        "segment_miles":     "%.1f milltir",
+        "segment_mph":       "cyfartaledd o %.0f mya",
+        "train_cancelled":   "Mae'r trên hwn wedi'i ganslo",
+        "cancelled_between": "Wedi'i ganslo rhwng %s a %s",
+        "cancelled_at":      "Ni fydd yn galw yn %s",
+        "stations_title":    "Gorsafoedd",
+        "stations_search":   "Chwilio",
+        "stations_count":    "%d gorsaf",
+        "stations_none":     "Dim gorsafoedd yn cyfateb",
+        "near_title":        "Gorsaf agosaf",
+        "near_locating":     "Yn dod o hyd i'ch lleoliad…",
+        "near_denied":       "Methu cael eich lleoliad. Dewiswch orsaf o'r rhestr yn lle hynny.",
+        "near_none":         "Dim gorsafoedd o fewn %d km",
+        "page_prev":         "Blaenorol",
+        "page_next":         "Nesaf",
+        "station_col":       "Gorsaf",
+        "departs_from":      "O",
+        "card_late":         "%d munud yn hwyr",
+        "card_departed":     "Gadawodd %s am %s",
+        "card_arrived":      "Cyrhaeddodd %s am %s",
+        "card_due":          "I fod i adael %s am %s",
+        "meta_next_dep":     "Yr ymadawiad nesaf %s i %s: %s",
+        "meta_expected":     "disgwylir %s",
+        "delay_unknown":     "Oedi heb ei benderfynu eto; amcangyfrif ansicr",
+        "forecast_source":   "amcangyfrif gan %s",
+        "arrives_at":        "yn %s %s",
+        "fastest_to":        "Cyflymaf i %s",
+        "fastest_to_label":  "Trên cyflymaf i",
+        "arrives_col":       "Yn %s",
+        "disruptions_title": "Tarfu wedi'i gynllunio",
+        "planned_at":        "Tarfu wedi'i gynllunio yn %s",
+        "disruptions_route": "Tarfu wedi'i gynllunio rhwng %s a %s",
+        "calling_at":        "Yn galw yn",
+        "date_col":          "Dyddiad",
+        "trains_col":        "Trenau",
+        "buses_col":         "Bysiau yn lle trenau",
+        "engineering_works": "Gwaith peirianneg",
+        "planned_normal":    "Gwasanaeth arferol",
+        "planned_works":     "Gwaith peirianneg wedi'i gynllunio",
+        "planned_reduced":   "Llai o drenau nag arfer",
+        "planned_buses":     "Bysiau yn lle rhai trenau",
+        "planned_closed":    "Dim trenau",
+        "planned_no_data":   "Amserlen ddim ar gael eto",
+        "where_next":        "Yr arhosfan nesaf %s am %s",
+        "choose_train":      "Trenau'n rhedeg fel %s heddiw",
+        "choose_prompt":     "Mae mwy nag un trên yn rhedeg fel %s heddiw. Pa un ydych chi'n ei olygu?",
+        "commutes_title":    "Fy nheithiau cymudo",
+        "commutes_empty":    "Dim teithiau cymudo wedi'u cadw eto",
+        "commute_heading":   "%s i %s tua %s",
+        "commute_around":    "Tua",
+        "commute_add":       "Cadw taith",
+        "commute_remove":    "Dileu",
+        "stats_title":       "Tabl cynghrair gweithredwyr",
+        "stats_note":        "Gwasanaethau heddiw hyd yma. Prydlondeb yw cyfran y galwadau a wnaed lai na 5 munud yn hwyr.",
+        "stats_services":    "Gwasanaethau",
+        "stats_punctuality": "Prydlondeb",
+        "stats_avg_delay":   "Oedi cyfartalog",
+        "stats_cancelled":   "Wedi'u canslo",
+        "stats_full_part":   "%d yn llawn, %d yn rhannol",
+        "stats_running":     "Yn rhedeg nawr",
+        "stats_mins":        "%.1f munud",
+        "stats_none":        "Nid oes unrhyw wasanaethau wedi rhedeg eto heddiw",
+        "walkup_title":      "Amserlen o %s",
+        "walkup_link":       "Amserlen",
+        "walkup_none":       "Does dim ymadawiadau wedi'u cynllunio ar y diwrnod hwn",
+        "walkup_live":       "Ymadawiadau byw",
+        "share_link":        "Dolen fer:",
+        "make_short_link":   "Gwneud dolen fer",
+        "unit_class":        "Dosbarth %s",
+        "unit_formation":    "unedau %s",
+        "unit_built":        "adeiladwyd %d (%d oed)",
+        "enthusiast_on":     "Dangos unedau",
+        "enthusiast_off":    "Cuddio unedau",
+        "last_report":       "adroddiad diwethaf %d munud yn ôl",
+        "last_report_now":   "adroddiad diwethaf newydd ddod",
+        "stale":             "Hen",
+        "nrcc_more":         "%d neges arall am yr orsaf",
+        "track_this":        "Dilyn hwn",
+        "stop_watching":     "Peidio â dilyn y trên hwn",
+        "compare_title":     "Cymharu trenau",
+        "compare_usage":     "Enwch hyd at %d trên i'w cymharu yn ôl eu cod, e.e. /compare?trains=2B15,1M45",
+        "compare_none":      "Does dim trên yn rhedeg fel %s heddiw",
+        "reason_delayed":    "Mae'r trên hwn wedi'i oedi oherwydd %s",
+        "reason_cancelled":  "Mae'r trên hwn wedi'i ganslo oherwydd %s",
+        "reason_at":         " yn ardal %s",
+        "reason_near":       " ger %s",
+        "page_error":        "Mae'n ddrwg gennym, aeth rhywbeth o'i le wrth ddangos y dudalen hon. Rhowch gynnig arall arni mewn munud.",
+        "page_error_home":   "Yn ôl i'r dechrau",
+        "short_platform":    "Platfform byr: teithiwch yn y %d cerbyd blaen",
+        "data_loading":      "Wrthi'n llwytho data'r amserlen…",
+        "loading_refresh":   "Bydd y dudalen hon yn adnewyddu mewn ychydig eiliadau.",
+        "length_col":        "Cerbydau",
+        "coaches":           "%d cerbyd",
+        "reason_col":        "Rheswm",
+        "board_columns":     "Colofnau:",
+        "show_columns":      "Dangos",
+        "cat_OO":            "Gwasanaeth sy'n stopio",
+        "cat_XX":            "Gwasanaeth cyflym",
+        "cat_XZ":            "Gwasanaeth cysgu",
+        "first_class":       "Dosbarth cyntaf ar gael",
+        "standard_only":     "Dosbarth safonol yn unig",
+        "cater_C":           "Bwffe",
+        "cater_F":           "Cerbyd bwyta i'r dosbarth cyntaf",
+        "cater_H":           "Bwyd poeth",
+        "cater_M":           "Prydau i'r dosbarth cyntaf",
+        "cater_R":           "Bwyty",
+        "cater_T":           "Gwasanaeth troli",
+        "sleeper_B":         "Gwelyau cysgu, dosbarth cyntaf a safonol",
+        "sleeper_F":         "Gwelyau cysgu, dosbarth cyntaf",
+        "sleeper_S":         "Gwelyau cysgu, dosbarth safonol",
+        "predicted_arr":     "Amser cyrraedd %s a ragwelir: %s",
+        "darwin_expects":    "Mae Darwin yn disgwyl %s",
+        "commute_calendar":  "Ychwanegu at galendr",
+        "commute_event":     "%s %s i %s",
+        "commute_none":      "Dim trenau tua %s heddiw",
+        "commute_not_today": "Dim heddiw: mae'r daith hon ar gyfer %s",
+        "days_weekdays":     "Dyddiau'r wythnos",
+        "days_weekends":     "Penwythnosau",
+        "days_daily":        "Bob dydd",
+        "arrival_col":       "Cyrraedd",
+        "On time":           "Ar amser",
+        "Late":              "Hwyr",
+        "Cancelled":         "Wedi'i ganslo",
+        "Arrived":           "Wedi cyrraedd",
+        "Departed":          "Wedi gadael",
+        "Reinstated":        "Wedi'i adfer",
+        "Delayed":           "Oedi",
+        "Request stop":      "Arhosfa ar gais",
+        "Set down only":     "Gollwng yn unig",
+        "Pick up only":      "Codi yn unig",
+    },
+}
+
+// Look up a UI string, formatting any args into it
+func translate(lang, key string, args ...any) string {
+    s, ok := translations[lang][key]
+    if !ok {
+        s, ok = translations[defaultLang][key]
+    }
+    if !ok {
+        s = key
+    }
+    if len(args) > 0 {
+        return fmt.Sprintf(s, args...)
+    }
+    return s
+}
+
+// The other supported language, for the switcher link
+func otherLang(lang string) string {
+    if lang == "cy" {
+        return "en"
+    }
+    return "cy"
+}
+
+// Pick the language for a request: ?lang= (remembered in a cookie), then
+// the lang cookie, then Accept-Language, then English
+func requestLang(w http.ResponseWriter, r *http.Request) string {
+    if lang := r.URL.Query().Get("lang"); lang != "" {
+        if _, ok := translations[lang]; ok {
+            http.SetCookie(w, &http.Cookie{Name: "lang", Value: lang, Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds())})
+            return lang
+        }
+    }
+    if c, err := r.Cookie("lang"); err == nil {
+        if _, ok := translations[c.Value]; ok {
+            return c.Value
+        }
+    }
+    for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+        tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+        base := strings.SplitN(tag, "-", 2)[0]
+        if _, ok := translations[base]; ok {
+            return base
+        }
+    }
+    return defaultLang
+}
+
+// Placeholder funcs so templates can be parsed; the real ones are bound
+// per request by localisedTemplate
+var templateFuncs = template.FuncMap{
+    "T":         func(key string, args ...any) string { return translate(defaultLang, key, args...) },
+    "station":   func(tiploc string) string { return stationDisplayName(tiploc, defaultLang) },
+    "modeBadge": func(mode string) template.HTML { return modeBadge(mode, defaultLang) },
+    "operator":  operatorBadge,
+    "meta":      metaTags,
+    "hhmm":      hhmm,
+    "nrcc":      sanitiseNRCC,
+    "delay":     func(scheduled, expected string) template.HTML { return delayBadge(defaultLang, scheduled, expected) },
+    "platform": func(number string, confirmed bool) template.HTML {
+        return platformBadge(defaultLang, number, confirmed)
+    },
+    "relative": func(event, scheduled, expected, actual, status string) string {
+        return relativeTime(defaultLang, event, scheduled, expected, actual, status, clock.Now())
+    },
+    "shortPlatform": shortPlatformFits,
+    "reason":        func(d DelayReason) string { return d.Text(defaultLang) },
+}
+
+// Clone a template with its T, station, delay and platform funcs bound to a
+// language, and relative times to the time of the request
+func localisedTemplate(t *template.Template, lang string) (*template.Template, error) {
+    now := clock.Now()
+    c, err := t.Clone()
+    if err != nil {
+        return nil, err
+    }
+    return c.Funcs(template.FuncMap{
+        "T":         func(key string, args ...any) string { return translate(lang, key, args...) },
+        "station":   func(tiploc string) string { return stationDisplayName(tiploc, lang) },
+        "modeBadge": func(mode string) template.HTML { return modeBadge(mode, lang) },
+        "delay":     func(scheduled, expected string) template.HTML { return delayBadge(lang, scheduled, expected) },
+        "platform":  func(number string, confirmed bool) template.HTML { return platformBadge(lang, number, confirmed) },
+        "relative": func(event, scheduled, expected, actual, status string) string {
+            return relativeTime(lang, event, scheduled, expected, actual, status, now)
+        },
+        "reason": func(d DelayReason) string { return d.Text(lang) },
+    }), nil
+}
+
+// Icon and label for services that aren't trains. Trains get no badge.
+func modeBadge(mode, lang string) template.HTML {
+    icon := map[string]string{modeBus: "🚌", modeShip: "⛴"}[mode]
+    if icon == "" {
+        return ""
+    }
+    return htmlFragment(modeBadgeTmpl, struct{ Mode, Icon, Label string }{mode, icon, translate(lang, mode)})
+}
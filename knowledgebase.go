@@ -0,0 +1,149 @@
+package main
+
+import (
+    "encoding/xml"
+    "html"
+    "io"
+    "log"
+    "regexp"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Incidents from the National Rail Knowledgebase, which carries planned
+// engineering work ahead of time. KB_INCIDENTS_SOURCE is a path or URL of
+// the incidents XML; it's fetched again every KB_REFRESH.
+type kbIncidents struct {
+    Incidents []struct {
+        Number   string `xml:"IncidentNumber"`
+        Planned  bool   `xml:"Planned"`
+        Summary  string `xml:"Summary"`
+        Validity []struct {
+            Start string `xml:"StartTime"`
+            End   string `xml:"EndTime"`
+        } `xml:"ValidityPeriod"`
+        Affects struct {
+            Operators []string `xml:"Operators>AffectedOperator>OperatorRef"`
+            Routes    string   `xml:"RoutesAffected"`
+        } `xml:"Affects"`
+        Links []string `xml:"InfoLinks>InfoLink>Uri"`
+    } `xml:"PtIncident"`
+}
+
+type EngineeringWork struct {
+    ID        string    `json:"id"`
+    Summary   string    `json:"summary"`
+    Routes    string    `json:"routes"` // plain text
+    Operators []string  `json:"operators,omitempty"`
+    Start     time.Time `json:"start"`
+    End       time.Time `json:"end"`
+    Link      string    `json:"link,omitempty"`
+}
+
+var (
+    engineeringWorks   []EngineeringWork
+    engineeringWorksMu sync.RWMutex
+)
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// Strip the HTML KB wraps its text in
+func kbText(s string) string {
+    s = html.UnescapeString(htmlTags.ReplaceAllString(s, " "))
+    return strings.Join(strings.Fields(s), " ")
+}
+
+// Parse the planned incidents out of a KB incidents document, one entry
+// per validity period
+func parseEngineeringWorks(r io.Reader) ([]EngineeringWork, error) {
+    var doc kbIncidents
+    if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+        return nil, err
+    }
+    var works []EngineeringWork
+    for _, inc := range doc.Incidents {
+        if !inc.Planned {
+            continue
+        }
+        for _, v := range inc.Validity {
+            start, err := time.Parse(time.RFC3339, strings.TrimSpace(v.Start))
+            if err != nil {
+                continue
+            }
+            // Open-ended work runs until further notice
+            end, err := time.Parse(time.RFC3339, strings.TrimSpace(v.End))
+            if err != nil {
+                end = start.AddDate(1, 0, 0)
+            }
+            w := EngineeringWork{
+                ID:        inc.Number,
+                Summary:   kbText(inc.Summary),
+                Routes:    kbText(inc.Affects.Routes),
+                Operators: inc.Affects.Operators,
+                Start:     start,
+                End:       end,
+            }
+            if len(inc.Links) > 0 {
+                w.Link = strings.TrimSpace(inc.Links[0])
+            }
+            works = append(works, w)
+        }
+    }
+    slices.SortFunc(works, func(a, b EngineeringWork) int { return a.Start.Compare(b.Start) })
+    return works, nil
+}
+
+// KB_REFRESH, read each time so a config reload can change it
+func knowledgebaseRefresh() time.Duration {
+    interval, err := time.ParseDuration(envOr("KB_REFRESH", "30m"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid KB_REFRESH; using 30m")
+        return 30 * time.Minute
+    }
+    return interval
+}
+
+// Fetch the KB incidents now and then every KB_REFRESH, if a source is set
+func startKnowledgebase() {
+    source := envOr("KB_INCIDENTS_SOURCE", "")
+    if source == "" {
+        return
+    }
+    for {
+        err := withSource(source, func(r io.Reader) error {
+            works, err := parseEngineeringWorks(r)
+            if err != nil {
+                return err
+            }
+            engineeringWorksMu.Lock()
+            engineeringWorks = works
+            engineeringWorksMu.Unlock()
+            log.Printf("Loaded %d planned engineering works from the Knowledgebase", len(works))
+            return nil
+        })
+        if err != nil {
+            log.Printf("Failed to load Knowledgebase incidents: %v", err)
+        }
+        time.Sleep(knowledgebaseRefresh())
+    }
+}
+
+// Planned works overlapping [from, to) that mention any of the named
+// places in their summary or affected routes
+func engineeringWorksAffecting(names []string, from, to time.Time) []EngineeringWork {
+    engineeringWorksMu.RLock()
+    defer engineeringWorksMu.RUnlock()
+    var out []EngineeringWork
+    for _, w := range engineeringWorks {
+        if !w.Start.Before(to) || !w.End.After(from) {
+            continue
+        }
+        text := strings.ToLower(w.Summary + " " + w.Routes)
+        if slices.ContainsFunc(names, func(n string) bool { return n != "" && strings.Contains(text, strings.ToLower(n)) }) {
+            out = append(out, w)
+        }
+    }
+    return out
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// The binary can run as a read-only frontend on AWS Lambda behind API
+// Gateway: build it for the provided.al2023 runtime as bootstrap, e.g.
+//
+//	GOOS=linux GOARCH=arm64 go build -o bootstrap .
+//
+// Lambda sets AWS_LAMBDA_RUNTIME_API, and with it the process serves
+// invocations from the runtime API instead of listening on :8081. Only
+// the web role runs: progress comes from the shared PROGRESS_STORE
+// (dynamodb suits) that an ingester elsewhere writes, and the timetable
+// from S3 as usual. The timetable loads in the background, never inside
+// an invocation, as a parse takes longer than API Gateway waits; until
+// it's in, pages show the loading placeholder. The environment is frozen
+// between invocations, so a load only moves on while they're served.
+// Only GET and HEAD requests, and the read-only POST APIs, are served.
+//
+// Both API Gateway payload formats are understood: 2.0 from HTTP APIs and
+// 1.0 from REST APIs and older integrations.
+
+func lambdaRuntimeAPI() (string, bool) {
+    api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+    return api, api != ""
+}
+
+func onLambda() bool {
+    _, ok := lambdaRuntimeAPI()
+    return ok
+}
+
+// POSTs served on Lambda, as they only read
+var lambdaReadOnlyPosts = map[string]bool{"/graphql": true, "/api/v1/trains:batchGet": true}
+
+func readOnly(h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodGet || r.Method == http.MethodHead || (r.Method == http.MethodPost && lambdaReadOnlyPosts[r.URL.Path]) {
+            h.ServeHTTP(w, r)
+            return
+        }
+        w.Header().Set("Allow", "GET, HEAD")
+        http.Error(w, "this frontend is read-only", http.StatusMethodNotAllowed)
+    })
+}
+
+// An API Gateway proxy event, in either payload format
+type apiGatewayRequest struct {
+    Version string `json:"version"`
+
+    // 2.0
+    RawPath        string   `json:"rawPath"`
+    RawQueryString string   `json:"rawQueryString"`
+    Cookies        []string `json:"cookies"`
+    RequestContext struct {
+        HTTP struct {
+            Method   string `json:"method"`
+            SourceIP string `json:"sourceIp"`
+        } `json:"http"`
+        Identity struct {
+            SourceIP string `json:"sourceIp"`
+        } `json:"identity"`
+    } `json:"requestContext"`
+
+    // 1.0
+    HTTPMethod                      string              `json:"httpMethod"`
+    Path                            string              `json:"path"`
+    MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+    QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+    MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+
+    Headers         map[string]string `json:"headers"`
+    Body            string            `json:"body"`
+    IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+type apiGatewayResponse struct {
+    StatusCode        int                 `json:"statusCode"`
+    Headers           map[string]string   `json:"headers,omitempty"`
+    MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+    Cookies           []string            `json:"cookies,omitempty"`
+    Body              string              `json:"body"`
+    IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+func (e apiGatewayRequest) v2() bool { return e.Version == "2.0" }
+
+// The event as an HTTP request to serve
+func (e apiGatewayRequest) request(ctx context.Context) (*http.Request, error) {
+    method, path, query, remote := e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString, e.RequestContext.HTTP.SourceIP
+    if !e.v2() {
+        method, path, remote = e.HTTPMethod, e.Path, e.RequestContext.Identity.SourceIP
+        q := url.Values{}
+        for k, v := range e.QueryStringParameters {
+            q.Set(k, v)
+        }
+        for k, vs := range e.MultiValueQueryStringParameters {
+            q[k] = vs
+        }
+        query = q.Encode()
+    }
+    body := []byte(e.Body)
+    if e.IsBase64Encoded {
+        var err error
+        if body, err = base64.StdEncoding.DecodeString(e.Body); err != nil {
+            return nil, fmt.Errorf("decode body: %w", err)
+        }
+    }
+    u := &url.URL{Path: path, RawQuery: query}
+    r, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    for k, v := range e.Headers {
+        r.Header.Set(k, v)
+    }
+    for k, vs := range e.MultiValueHeaders {
+        r.Header[http.CanonicalHeaderKey(k)] = vs
+    }
+    if len(e.Cookies) > 0 {
+        r.Header.Set("Cookie", strings.Join(e.Cookies, "; "))
+    }
+    r.Host, r.RemoteAddr = r.Header.Get("Host"), remote
+    return r, nil
+}
+
+// Collects a handler's response for the runtime API
+type lambdaResponseWriter struct {
+    header http.Header
+    code   int
+    body   bytes.Buffer
+}
+
+func (w *lambdaResponseWriter) Header() http.Header { return w.header }
+
+func (w *lambdaResponseWriter) WriteHeader(code int) {
+    if w.code == 0 {
+        w.code = code
+    }
+}
+
+func (w *lambdaResponseWriter) Write(b []byte) (int, error) {
+    w.WriteHeader(http.StatusOK)
+    return w.body.Write(b)
+}
+
+// Whether API Gateway can pass a body of this type through as text
+func textual(contentType string) bool {
+    mt, _, _ := mime.ParseMediaType(contentType)
+    return mt == "" || strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "json") || strings.HasSuffix(mt, "xml") || mt == "application/javascript"
+}
+
+func (w *lambdaResponseWriter) response(v2 bool) apiGatewayResponse {
+    resp := apiGatewayResponse{StatusCode: w.code}
+    if resp.StatusCode == 0 {
+        resp.StatusCode = http.StatusOK
+    }
+    if textual(w.header.Get("Content-Type")) {
+        resp.Body = w.body.String()
+    } else {
+        resp.Body, resp.IsBase64Encoded = base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+    }
+    if !v2 {
+        resp.MultiValueHeaders = w.header
+        return resp
+    }
+    resp.Headers = map[string]string{}
+    for k, vs := range w.header {
+        if k == "Set-Cookie" {
+            resp.Cookies = vs
+            continue
+        }
+        resp.Headers[k] = strings.Join(vs, ",")
+    }
+    return resp
+}
+
+// Take invocations from the runtime API and serve them with h. Never
+// returns.
+func serveLambda(api string, h http.Handler) {
+    base := "http://" + api + "/2018-06-01/runtime/invocation/"
+    log.Printf("Serving Lambda invocations from %s", api)
+    for {
+        if err := serveInvocation(base, h); err != nil {
+            log.Printf("Failed to serve Lambda invocation: %v", err)
+            time.Sleep(time.Second)
+        }
+    }
+}
+
+func serveInvocation(base string, h http.Handler) error {
+    next, err := http.Get(base + "next")
+    if err != nil {
+        return fmt.Errorf("fetch next invocation: %w", err)
+    }
+    defer next.Body.Close()
+    id := next.Header.Get("Lambda-Runtime-Aws-Request-Id")
+    if next.StatusCode != http.StatusOK || id == "" {
+        return fmt.Errorf("fetch next invocation: %s", next.Status)
+    }
+    ctx := context.Background()
+    if ms, err := strconv.ParseInt(next.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithDeadline(ctx, time.UnixMilli(ms))
+        defer cancel()
+    }
+
+    var event apiGatewayRequest
+    if err := json.NewDecoder(next.Body).Decode(&event); err != nil {
+        return postLambdaError(base+id+"/error", fmt.Errorf("decode event: %w", err))
+    }
+    r, err := event.request(ctx)
+    if err != nil {
+        return postLambdaError(base+id+"/error", err)
+    }
+    refreshLambdaTimetable()
+    w := &lambdaResponseWriter{header: http.Header{}}
+    h.ServeHTTP(w, r)
+    out, err := json.Marshal(w.response(event.v2()))
+    if err != nil {
+        return postLambdaError(base+id+"/error", fmt.Errorf("encode response: %w", err))
+    }
+    return postLambda(base+id+"/response", out)
+}
+
+// Until today's timetable is in, invocations start a load this long
+// after the last, doubling up to the maximum, so they don't hammer a
+// source that's down or hasn't published today's yet. Invocations are
+// served one at a time, so these aren't locked.
+const (
+    lambdaRetryMin = 5 * time.Second
+    lambdaRetryMax = 5 * time.Minute
+)
+
+var (
+    lambdaRetryAt    time.Time
+    lambdaRetryDelay time.Duration
+)
+
+// The day the loaded timetable is for. Darwin's IDs start with it, e.g.
+// 20261014020508; a timetable from elsewhere is taken to be for the day
+// it was loaded.
+func loadedTimetableDate() (string, bool) {
+    loadedVersionsMu.RLock()
+    id, at := loadedTimetableID, timetableLoadedAt
+    loadedVersionsMu.RUnlock()
+    if at.IsZero() {
+        return "", false
+    }
+    if len(id) >= 8 {
+        if d, err := time.Parse("20060102", id[:8]); err == nil {
+            return d.Format("2006-01-02"), true
+        }
+    }
+    return at.In(ukLocation).Format("2006-01-02"), true
+}
+
+// Start loading today's timetable in the background if none is loaded or
+// the one held is for a day gone by. Between midnight and Darwin
+// publishing, the newest in S3 is still yesterday's, so this keeps trying
+// until today's arrives.
+func refreshLambdaTimetable() {
+    date, loaded := loadedTimetableDate()
+    if loaded && date == ukToday() {
+        lambdaRetryAt, lambdaRetryDelay = time.Time{}, 0
+        return
+    }
+    now := clock.Now()
+    if now.Before(lambdaRetryAt) {
+        return
+    }
+    lambdaRetryDelay = min(lambdaRetryMax, max(lambdaRetryMin, 2*lambdaRetryDelay))
+    lambdaRetryAt = now.Add(lambdaRetryDelay)
+    if loaded {
+        log.Printf("Timetable loaded is for %s, not today; loading again, and after %s if it's still not today's", date, lambdaRetryDelay)
+    } else {
+        log.Printf("No timetable loaded; loading it, and again after %s if that fails", lambdaRetryDelay)
+    }
+    go loadTimetable()
+}
+
+func postLambdaError(url string, failure error) error {
+    body, _ := json.Marshal(struct {
+        ErrorMessage string `json:"errorMessage"`
+        ErrorType    string `json:"errorType"`
+    }{failure.Error(), "InvalidEvent"})
+    if err := postLambda(url, body); err != nil {
+        return err
+    }
+    return failure
+}
+
+func postLambda(url string, body []byte) error {
+    resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, resp.Body)
+    if resp.StatusCode != http.StatusAccepted {
+        return fmt.Errorf("runtime API answered %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// Fields of a list item that ?sort= and field filters can refer to
+type listFields[T any] map[string]func(T) string
+
+// ?limit=, ?offset= and ?sort= as parsed for a list endpoint. Any other
+// parameter named after one of the list's fields filters on it.
+type listQuery struct {
+    limit   int
+    offset  int
+    sort    string
+    desc    bool
+    filters map[string]string
+}
+
+const (
+    defaultListLimit = 50
+    maxListLimit     = 500
+)
+
+func parseListQuery[T any](r *http.Request, fields listFields[T]) (listQuery, error) {
+    q := listQuery{limit: defaultListLimit, filters: map[string]string{}}
+    values := r.URL.Query()
+    if v := values.Get("limit"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > maxListLimit {
+            return q, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+        }
+        q.limit = n
+    }
+    if v := values.Get("offset"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 0 {
+            return q, fmt.Errorf("offset must be a non-negative number")
+        }
+        q.offset = n
+    }
+    if v := values.Get("sort"); v != "" {
+        q.sort, q.desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+        if _, ok := fields[q.sort]; !ok {
+            return q, fmt.Errorf("can't sort by %q; expected one of %s", q.sort, strings.Join(fieldNames(fields), ", "))
+        }
+    }
+    for name := range fields {
+        if v := values.Get(name); v != "" {
+            q.filters[name] = v
+        }
+    }
+    return q, nil
+}
+
+func fieldNames[T any](fields listFields[T]) []string {
+    names := make([]string, 0, len(fields))
+    for name := range fields {
+        names = append(names, name)
+    }
+    slices.Sort(names)
+    return names
+}
+
+// Filter, sort and page a list, setting X-Total-Count and a Link header
+// with first/prev/next/last pages
+func applyListQuery[T any](w http.ResponseWriter, r *http.Request, items []T, fields listFields[T], q listQuery) []T {
+    var matched []T
+    for _, item := range items {
+        ok := true
+        for name, want := range q.filters {
+            if !strings.EqualFold(fields[name](item), want) {
+                ok = false
+                break
+            }
+        }
+        if ok {
+            matched = append(matched, item)
+        }
+    }
+    if q.sort != "" {
+        key := fields[q.sort]
+        slices.SortStableFunc(matched, func(a, b T) int {
+            c := compareFieldValues(key(a), key(b))
+            if q.desc {
+                return -c
+            }
+            return c
+        })
+    }
+
+    total := len(matched)
+    w.Header().Set("X-Total-Count", strconv.Itoa(total))
+    if links := pageLinks(r, q, total); len(links) > 0 {
+        w.Header().Set("Link", strings.Join(links, ", "))
+    }
+    start := min(q.offset, total)
+    end := min(start+q.limit, total)
+    page := matched[start:end]
+    if page == nil {
+        page = []T{}
+    }
+    return page
+}
+
+// Compare numerically when both values are numbers, so delays of 5 and
+// 10 minutes sort the right way round
+func compareFieldValues(a, b string) int {
+    na, errA := strconv.Atoi(a)
+    nb, errB := strconv.Atoi(b)
+    if errA == nil && errB == nil {
+        return na - nb
+    }
+    return strings.Compare(a, b)
+}
+
+func pageLinks(r *http.Request, q listQuery, total int) []string {
+    link := func(offset int, rel string) string {
+        values := r.URL.Query()
+        values.Set("offset", strconv.Itoa(offset))
+        values.Set("limit", strconv.Itoa(q.limit))
+        u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+        return fmt.Sprintf("<%s%s>; rel=%q", requestBaseURL(r), u.String(), rel)
+    }
+    var links []string
+    if q.offset > 0 {
+        links = append(links, link(0, "first"), link(max(q.offset-q.limit, 0), "prev"))
+    }
+    if q.offset+q.limit < total {
+        // Keep the last page aligned with the pages before it
+        last := q.offset + (total-1-q.offset)/q.limit*q.limit
+        links = append(links, link(q.offset+q.limit, "next"), link(last, "last"))
+    }
+    return links
+}
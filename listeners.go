@@ -0,0 +1,86 @@
+package main
+
+import (
+    "expvar"
+    "log"
+    "net"
+    "net/http"
+    "net/http/pprof"
+    "strings"
+)
+
+// The public listener (--addr, HTTP_ADDR, default :8081) serves the
+// boards, pages and API. With --admin-addr or ADMIN_ADDR set, a second
+// listener serves what's only for whoever runs the service: admin pages
+// and API, metrics, health checks, expvar and pprof. Those paths then 404
+// on the public listener, so it can be exposed on its own. Without an
+// admin listener the rest stay on the public one as before, but /debug/
+// (expvar and pprof) is never served publicly.
+var adminPaths = []string{"/healthz", "/readyz", "/metrics", "/admin/", "/api/v1/admin/", "/debug/"}
+
+// Whether the admin paths have a listener of their own
+var adminListener bool
+
+func isAdminPath(path string) bool {
+    for _, p := range adminPaths {
+        if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+            return true
+        }
+    }
+    return false
+}
+
+// h without the paths the public listener doesn't serve
+func publicOnly(h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if (adminListener && isAdminPath(r.URL.Path)) || strings.HasPrefix(r.URL.Path, "/debug/") {
+            http.NotFound(w, r)
+            return
+        }
+        h.ServeHTTP(w, r)
+    })
+}
+
+// Handlers for the admin listener. Health, metrics and debugging are
+// registered here so an ingest-only process serves them too; the admin
+// pages and API are the ones on the default mux.
+func adminHandler() http.Handler {
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /healthz", healthzHandler)
+    mux.HandleFunc("GET /readyz", readyzHandler)
+    if metricsHandler != nil {
+        mux.Handle("GET /metrics", metricsHandler)
+    }
+    mux.Handle("GET /debug/vars", expvar.Handler())
+    mux.HandleFunc("/debug/pprof/", pprof.Index)
+    mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+    mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+    mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+    mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+    mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !isAdminPath(r.URL.Path) {
+            http.NotFound(w, r)
+            return
+        }
+        http.DefaultServeMux.ServeHTTP(w, r)
+    }))
+    return mux
+}
+
+// Start the admin listener if there's an address for one
+func startAdminListener(addr string) {
+    if addr == "" {
+        return
+    }
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        log.Fatalf("Failed to listen on %s: %v", addr, err)
+    }
+    adminListener = true
+    log.Printf("Admin server started at %s", addr)
+    go func() {
+        if err := http.Serve(ln, tracedHandler(adminHandler())); err != nil {
+            log.Printf("Failed to serve admin listener: %v", err)
+        }
+    }()
+}
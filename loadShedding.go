@@ -0,0 +1,159 @@
+package main
+
+import (
+    "log"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Darwin messages waiting to be applied. The STOMP consumer only queues
+// them, so the queue's depth shows how far behind ingestion is.
+var ingestQueue = make(chan []byte, 5000)
+
+// Above shedAbove queued messages, TS updates for trains and stations
+// nobody is watching are dropped until the queue is back under half that.
+// Schedules and station messages are always applied.
+var (
+    shedAbove = 2500
+    shedding  atomic.Bool
+    shedCount atomic.Int64
+)
+
+// How long a board counts as watched after someone last loaded it
+const boardWatchTTL = 30 * time.Minute
+
+var (
+    boardViews   = map[string]time.Time{}
+    boardViewsMu sync.Mutex
+)
+
+// Read INGEST_QUEUE_SIZE and INGEST_SHED_THRESHOLD (default half the queue)
+func initLoadShedding() {
+    size := 5000
+    v := envOr("INGEST_QUEUE_SIZE", "5000")
+    if n, err := strconv.Atoi(v); err != nil || n < 1 {
+        log.Printf("Invalid INGEST_QUEUE_SIZE %q; using 5000", v)
+    } else {
+        size = n
+    }
+    ingestQueue = make(chan []byte, size)
+    shedAbove = size / 2
+    if v := envOr("INGEST_SHED_THRESHOLD", ""); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 {
+            log.Printf("Invalid INGEST_SHED_THRESHOLD %q; using %d", v, shedAbove)
+        } else {
+            shedAbove = n
+        }
+    }
+}
+
+// Queue a message from the broker, blocking once the queue is full
+func enqueueDarwinMessage(body []byte) {
+    ingestQueue <- body
+    queuedMessages.Add(1)
+}
+
+// Switch shedding on and off as the queue grows and drains
+func updateShedding() {
+    depth := len(ingestQueue)
+    if depth > shedAbove && !shedding.Load() {
+        shedding.Store(true)
+        log.Printf("Darwin ingest is %d messages behind; shedding updates for unwatched trains", depth)
+    } else if depth < shedAbove/2 && shedding.Load() {
+        shedding.Store(false)
+        log.Printf("Darwin ingest caught up; stopped shedding after dropping %d updates", shedCount.Load())
+        shedCount.Store(0)
+    }
+}
+
+// Whether a TS update should be applied while shedding: the tracked
+// train, WATCHED_TRAINS, or a train calling at a watched station
+func tsWanted(ts DarwinTS) bool {
+    if ts.RID == currentTrackedRID() {
+        return true
+    }
+    if j, ok := journeyByRID(ts.RID); ok && slices.Contains(watchedTrains(), j.TrainID) {
+        return true
+    }
+    watched := watchedTiplocs()
+    for _, loc := range ts.Locs {
+        if watched[loc.Tiploc] {
+            return true
+        }
+    }
+    return false
+}
+
+// Note that a station's board is being looked at. Only real stations are
+// kept, so the map is no bigger than the station list, and ones not
+// looked at for boardWatchTTL are swept out each time another is added.
+func markBoardViewed(crs string) {
+    if memoryPressure.Load() || len(tiplocsForCRS(crs)) == 0 {
+        return
+    }
+    now := time.Now()
+    boardViewsMu.Lock()
+    defer boardViewsMu.Unlock()
+    if _, ok := boardViews[crs]; !ok {
+        pruneBoardViews(now)
+    }
+    boardViews[crs] = now
+}
+
+// Caller must hold boardViewsMu.
+func pruneBoardViews(now time.Time) {
+    for crs, at := range boardViews {
+        if now.Sub(at) > boardWatchTTL {
+            delete(boardViews, crs)
+        }
+    }
+}
+
+// Forget which boards have been viewed, returning how many
+func dropBoardViews() int {
+    boardViewsMu.Lock()
+    defer boardViewsMu.Unlock()
+    n := len(boardViews)
+    clear(boardViews)
+    return n
+}
+
+// TIPLOCs of WATCHED_STATIONS and of boards viewed recently
+func watchedTiplocs() map[string]bool {
+    var crss []string
+    for _, c := range strings.Split(envOr("WATCHED_STATIONS", ""), ",") {
+        if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+            crss = append(crss, c)
+        }
+    }
+    boardViewsMu.Lock()
+    pruneBoardViews(time.Now())
+    for crs := range boardViews {
+        crss = append(crss, crs)
+    }
+    boardViewsMu.Unlock()
+
+    tiplocs := map[string]bool{}
+    for _, crs := range crss {
+        for _, t := range tiplocsForCRS(crs) {
+            tiplocs[t] = true
+        }
+    }
+    return tiplocs
+}
+
+type ShedState struct {
+    Active     bool  `json:"active"`
+    QueueDepth int   `json:"queue_depth"`
+    Threshold  int   `json:"threshold"`
+    Dropped    int64 `json:"dropped"`
+}
+
+func shedState() ShedState {
+    return ShedState{shedding.Load(), len(ingestQueue), shedAbove, shedCount.Load()}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+    "slices"
+    "sort"
+)
+
+// Boards used to scan every journey in the store for calls at their
+// TIPLOCs. The location index lists each TIPLOC's calls sorted by time,
+// so a board binary-searches to its window and looks at nothing else.
+//
+// Trade-offs: an entry is 24 bytes per calling point, so a day's
+// snapshot of around 25,000 services and 600,000 calling points costs
+// roughly 15 MB on top of the journeys themselves. The whole index is
+// rebuilt, with a sort per location, when a timetable is loaded: a
+// fraction of a second next to the minutes a snapshot takes to parse.
+// Live schedules update it in place, which costs a pass over the slice
+// of each location the journey calls at; eviction, which removes many at
+// once, rebuilds it. Like the journeys map it's guarded by journeysMu.
+// The memory guard drops it under memory pressure (it's nil meanwhile),
+// and boards fall back to scanning every journey.
+type locationCall struct {
+    mins    int // minutes after midnight, see callMinutes
+    point   int // index into the journey's Points
+    journey *Journey
+}
+
+var locationIndex = map[string][]locationCall{}
+
+// Calls are indexed by the time boards show, public if there is one, else
+// working. Boards allow indexSlack either side, so a call whose public and
+// working times differ a little is still found.
+const indexSlack = 5
+
+func callMinutes(p CallingPoint) (int, bool) {
+    for _, t := range []string{p.Ptd, p.Wtd, p.Pta, p.Wta, p.Wtp} {
+        if m, ok := parseRailTime(t); ok {
+            return m, true
+        }
+    }
+    return 0, false
+}
+
+// Rebuild the index from scratch, unless it's been dropped to save
+// memory. Caller must hold journeysMu.
+func rebuildLocationIndex() {
+    if memoryPressure.Load() {
+        locationIndex = nil
+        return
+    }
+    index := map[string][]locationCall{}
+    for _, j := range journeys {
+        for i, p := range j.Points {
+            if m, ok := callMinutes(p); ok {
+                index[p.Tiploc] = append(index[p.Tiploc], locationCall{m, i, j})
+            }
+        }
+    }
+    for _, calls := range index {
+        sort.Slice(calls, func(a, b int) bool { return calls[a].mins < calls[b].mins })
+    }
+    locationIndex = index
+}
+
+// Add a journey's calls. Caller must hold journeysMu.
+func indexJourney(j *Journey) {
+    if locationIndex == nil {
+        return
+    }
+    for i, p := range j.Points {
+        m, ok := callMinutes(p)
+        if !ok {
+            continue
+        }
+        calls := locationIndex[p.Tiploc]
+        at := sort.Search(len(calls), func(k int) bool { return calls[k].mins > m })
+        locationIndex[p.Tiploc] = slices.Insert(calls, at, locationCall{m, i, j})
+    }
+}
+
+// Remove a journey's calls. Caller must hold journeysMu.
+func unindexJourney(j *Journey) {
+    for _, p := range j.Points {
+        calls, ok := locationIndex[p.Tiploc]
+        if !ok {
+            continue
+        }
+        calls = slices.DeleteFunc(calls, func(c locationCall) bool { return c.journey == j })
+        if len(calls) == 0 {
+            delete(locationIndex, p.Tiploc)
+        } else {
+            locationIndex[p.Tiploc] = calls
+        }
+    }
+}
+
+// Replace a journey in the store and the index. Caller must hold
+// journeysMu.
+func storeJourney(j *Journey) {
+    if old, ok := journeys[j.RID]; ok {
+        unindexJourney(old)
+        unindexService(old)
+    }
+    journeys[j.RID] = j
+    indexJourney(j)
+    indexService(j)
+}
+
+// Drop a journey from the store and the index. Caller must hold
+// journeysMu.
+func removeJourney(rid string) {
+    if j, ok := journeys[rid]; ok {
+        unindexJourney(j)
+        unindexService(j)
+        delete(journeys, rid)
+    }
+}
+
+// Past this many, removing journeys one by one, with a pass over each
+// of their locations' calls, costs more than rebuilding the index
+const bulkRemoveAt = 32
+
+// Drop many journeys at once, as eviction does, rebuilding the index
+// once rather than for each. Caller must hold journeysMu.
+func removeJourneys(rids []string) {
+    if len(rids) < bulkRemoveAt {
+        for _, rid := range rids {
+            removeJourney(rid)
+        }
+        return
+    }
+    for _, rid := range rids {
+        if j, ok := journeys[rid]; ok {
+            unindexService(j)
+            delete(journeys, rid)
+        }
+    }
+    rebuildLocationIndex()
+}
+
+// Calls at any of tiplocs within indexSlack of the minutes from..to after
+// midnight, wrapping past midnight if to is before from, grouped by
+// journey with each journey's points in calling order. Caller must hold
+// journeysMu.
+func callsBetween(tiplocs []string, from, to int) map[*Journey][]int {
+    from, to = from-indexSlack, to+indexSlack
+    var ranges [][2]int
+    switch {
+    case to-from >= 24*60:
+        ranges = [][2]int{{0, 24 * 60}}
+    case from < 0:
+        ranges = [][2]int{{from + 24*60, 24 * 60}, {0, to}}
+    case to >= 24*60:
+        ranges = [][2]int{{from, 24 * 60}, {0, to - 24*60}}
+    default:
+        ranges = [][2]int{{from, to}}
+    }
+    found := map[*Journey][]int{}
+    if locationIndex == nil {
+        scanCallsBetween(found, tiplocs, ranges)
+        return found
+    }
+    for _, tiploc := range tiplocs {
+        calls := locationIndex[tiploc]
+        for _, r := range ranges {
+            i := sort.Search(len(calls), func(k int) bool { return calls[k].mins >= r[0] })
+            for ; i < len(calls) && calls[i].mins <= r[1]; i++ {
+                found[calls[i].journey] = append(found[calls[i].journey], calls[i].point)
+            }
+        }
+    }
+    for j, points := range found {
+        slices.Sort(points)
+        found[j] = slices.Compact(points)
+    }
+    return found
+}
+
+// callsBetween without the index, looking at every journey's calls
+func scanCallsBetween(found map[*Journey][]int, tiplocs []string, ranges [][2]int) {
+    for _, j := range journeys {
+        for i, p := range j.Points {
+            if !slices.Contains(tiplocs, p.Tiploc) {
+                continue
+            }
+            m, ok := callMinutes(p)
+            if !ok {
+                continue
+            }
+            for _, r := range ranges {
+                if m >= r[0] && m <= r[1] {
+                    found[j] = append(found[j], i)
+                    break
+                }
+            }
+        }
+    }
+}
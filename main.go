@@ -4,199 +4,605 @@ package main
 
 import (
     "context"
-    "encoding/xml"
+    "flag"
+    "fmt"
     "html/template"
     "log"
+    "net"
     "net/http"
     "os"
-    "sync"
     "github.com/aws/aws-sdk-go-v2/config"
     "github.com/aws/aws-sdk-go-v2/credentials"
     "github.com/aws/aws-sdk-go-v2/service/s3"
+    "github.com/aws/aws-sdk-go-v2/service/s3/types"
     "io"
     "sort"
-    "github.com/joho/godotenv"
+    "strings"
+    "time"
     "compress/gzip"
 )
 
 
-// Download the latest timetable XML from S3 and print the first 500 bytes
-func downloadLatestTimetableFromS3() {
-    bucket := "darwin.xmltimetable"
-    prefix := "PPTimetable/"
-    region := "eu-west-1"
-	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-	log.Printf("Using AWS_ACCESS_KEY_ID: %s", accessKey)
-
+// Download and load the latest timetable and reference data from S3
+func loadTimetableFromS3(ctx context.Context) {
+    client, err := darwinS3Client(ctx)
+    if err != nil {
+        log.Printf("Failed to set up S3 client: %v", err)
+        return
+    }
+    objects, err := listTimetableObjects(ctx, client)
+    if err != nil {
+        log.Printf("Failed to list S3 objects: %v", err)
+        return
+    }
+    if len(objects) == 0 {
+        log.Println("No timetable files found in S3 bucket.")
+        return
+    }
 
+    var timetableKey, refKey string
+    for _, obj := range objects {
+        switch {
+        case timetableKey == "" && strings.HasSuffix(*obj.Key, "_v8.xml.gz"):
+            timetableKey = *obj.Key
+        case refKey == "" && strings.HasSuffix(*obj.Key, "_ref_v3.xml.gz"):
+            refKey = *obj.Key
+        }
+    }
 
-    if accessKey == "" || secretKey == "" {
-        log.Println("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set in environment.")
+    if refKey != "" {
+        log.Printf("Downloading latest reference data: %s", refKey)
+        if err := withS3Gzip(ctx, client, timetableBucket, refKey, parseReference); err != nil {
+            log.Printf("Failed to load reference data: %v", err)
+        } else {
+            noteReferenceLoaded(dataID(refKey))
+        }
+    }
+    if timetableKey == "" {
+        log.Println("No timetable files found in S3 bucket.")
         return
     }
+    log.Printf("Downloading latest timetable: %s", timetableKey)
+    parse := beginParse(timetableKey)
+    err = withS3Gzip(withParse(ctx, parse), client, timetableBucket, timetableKey, func(r io.Reader) error {
+        parsed, err := parseTimetableCounted(r, parse)
+        if err != nil {
+            return err
+        }
+        setTimetable(parsed)
+        noteTimetableLoaded(dataID(timetableKey))
+        return nil
+    })
+    parse.finish(err)
+}
 
-    ctx := context.Background()
+// The Darwin timetable bucket, which publishes a snapshot for each day
+const (
+    timetableBucket = "darwin.xmltimetable"
+    timetablePrefix = "PPTimetable/"
+)
+
+func darwinS3Client(ctx context.Context) (*s3.Client, error) {
+    region := "eu-west-1"
+    accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+    secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+    log.Printf("Using AWS_ACCESS_KEY_ID: %s", accessKey)
+
+    if accessKey == "" || secretKey == "" {
+        return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set in environment")
+    }
     cfg, err := config.LoadDefaultConfig(ctx,
         config.WithRegion(region),
         config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+        config.WithHTTPClient(tracedHTTPClient()),
     )
     if err != nil {
-        log.Printf("Failed to load AWS config: %v", err)
-        return
+        return nil, fmt.Errorf("load AWS config: %w", err)
     }
-    client := s3.NewFromConfig(cfg)
+    return s3.NewFromConfig(cfg), nil
+}
+
+// Everything under the timetable prefix, newest first
+func listTimetableObjects(ctx context.Context, client *s3.Client) ([]types.Object, error) {
+    return listS3Objects(ctx, client, timetableBucket, timetablePrefix)
+}
 
-    // List objects with the prefix
-    listOut, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+// Everything under a prefix of a bucket, newest first
+func listS3Objects(ctx context.Context, client *s3.Client, bucket, prefix string) (objects []types.Object, err error) {
+    ctx, span := tracer.Start(ctx, "s3.list", s3Attributes(bucket, prefix))
+    defer func() { endSpan(span, err) }()
+    defer observeCall("s3", "list", prefix, time.Now())
+    pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
         Bucket: &bucket,
         Prefix: &prefix,
     })
-    if err != nil {
-        log.Printf("Failed to list S3 objects: %v", err)
-        return
-    }
-    if len(listOut.Contents) == 0 {
-        log.Println("No timetable files found in S3 bucket.")
-        return
+    for pages.HasMorePages() {
+        page, err := pages.NextPage(ctx)
+        if err != nil {
+            return nil, err
+        }
+        objects = append(objects, page.Contents...)
     }
-
-    // Find the latest file by LastModified
-    sort.Slice(listOut.Contents, func(i, j int) bool {
-        return listOut.Contents[i].LastModified.After(*listOut.Contents[j].LastModified)
+    sort.Slice(objects, func(i, j int) bool {
+        return objects[i].LastModified.After(*objects[j].LastModified)
     })
-    latest := listOut.Contents[0]
-    log.Printf("Downloading latest timetable: %s", *latest.Key)
+    return objects, nil
+}
 
+// Download a gzipped S3 object and pass the ungzipped stream to read
+func withS3Gzip(ctx context.Context, client *s3.Client, bucket, key string, read func(io.Reader) error) (err error) {
+    ctx, span := tracer.Start(ctx, "s3.download", s3Attributes(bucket, key))
+    defer func() { endSpan(span, err) }()
+    defer observeCall("s3", "download", key, time.Now())
     getOut, err := client.GetObject(ctx, &s3.GetObjectInput{
         Bucket: &bucket,
-        Key:    latest.Key,
+        Key:    &key,
     })
     if err != nil {
-        log.Printf("Failed to download S3 object: %v", err)
-        return
+        return fmt.Errorf("download S3 object: %w", err)
     }
     defer getOut.Body.Close()
 
-    gz, err := gzip.NewReader(getOut.Body)
+    var length int64
+    if getOut.ContentLength != nil {
+        length = *getOut.ContentLength
+    }
+    gz, err := gzip.NewReader(parseFrom(ctx).counting(getOut.Body, length))
     if err != nil {
-        log.Printf("Failed to ungzip S3 object: %v", err)
-        return
+        return fmt.Errorf("ungzip S3 object: %w", err)
     }
     defer gz.Close()
-
-    buf := make([]byte, 1000)
-    n, err := gz.Read(buf)
-    if err != nil && err != io.EOF {
-        log.Printf("Failed to read ungzipped S3 object: %v", err)
-        return
-    }
-    log.Printf("First 1000 bytes of ungzipped timetable file:\n%s", string(buf[:n]))
+    return read(gz)
 }
 
 // Template for the main page
-var pageTmpl = template.Must(template.New("page").Parse(`
+var pageTmpl = template.Must(template.New("page").Funcs(templateFuncs).Parse(`
 <!DOCTYPE html>
-<html lang="en">
+<html lang="{{.Lang}}">
 <head>
     <meta charset="UTF-8">
-    <title>Train Route Progression</title>
+    <title>{{T "title"}}</title>
+    {{meta .Meta}}
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
     <script src="https://unpkg.com/htmx.org@1.9.10"></script>
 </head>
 <body>
-    <h1>Train Route Progression</h1>
-    <div id="train-progression" hx-get="/progress" hx-trigger="load, every 30s" hx-swap="innerHTML">
-        <p>Loading train route...</p>
+    <h1>{{T "title"}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/stations">{{T "stations_title"}}</a> | <a href="/near">{{T "near_title"}}</a> | <a href="/disruptions">{{T "disruptions_title"}}</a> | <a href="/commutes">{{T "commutes_title"}}</a> | <a href="/stats">{{T "stats_title"}}</a> | <a href="{{.EnthusiastToggle}}">{{if .Enthusiast}}{{T "enthusiast_off"}}{{else}}{{T "enthusiast_on"}}{{end}}</a></p>
+    {{with .ShortLink}}<p class="muted">{{T "share_link"}} <a href="{{.}}">{{.}}</a></p>{{else}}{{with .Share}}
+    <form method="post" action="/t"><input type="hidden" name="rid" value="{{.RID}}"><input type="hidden" name="train" value="{{.TrainID}}"><input type="hidden" name="date" value="{{.Date}}"><button type="submit">{{T "make_short_link"}}</button></form>
+    {{end}}{{end}}
+    {{if .Watching}}
+    <form method="post" action="/watch/stop"><button type="submit">{{T "stop_watching"}}</button></form>
+    {{end}}
+    {{if .Commutes}}
+    <div id="commutes" hx-get="/commutes/status" hx-trigger="load, every 60s" hx-swap="innerHTML"></div>
+    {{end}}
+    <div id="train-progression" class="train-progression" hx-get="{{.ProgressURL}}" hx-trigger="load" hx-swap="innerHTML">
+        <p>{{T "loading"}}</p>
     </div>
 </body>
 </html>
 `))
 
 // Template for the train progress (htmx partial)
-var progressTmpl = template.Must(template.New("progress").Parse(`
-<h2>Train 2B15 Progress</h2>
+var progressTmpl = template.Must(template.New("progress").Funcs(templateFuncs).Parse(`
+<h2>{{modeBadge .Mode}}{{T "train_progress" .Headcode}} {{operator .TOC}}</h2>
+{{with .Attributes}}
+    <p>{{range $i, $a := .}}{{if $i}} · {{end}}{{T $a}}{{end}}</p>
+{{end}}
+{{if .PreviousRIDs}}
+    <p class="muted">{{T "reissued"}}</p>
+{{end}}
+{{with .Allocation}}
+    <p class="muted">{{with .Class}}{{T "unit_class" .}}{{end}}{{with .Formation}} · {{T "unit_formation" .}}{{end}}{{with .Built}} · {{T "unit_built" . $.Allocation.Age}}{{end}}</p>
+{{end}}
+{{with .Freshness}}
+    <p class="{{if .Stale}}late{{else}}muted{{end}}">{{if .Stale}}<span class="stale">{{T "stale"}}</span> {{end}}{{if .MinsAgo}}{{T "last_report" .MinsAgo}}{{else}}{{T "last_report_now"}}{{end}}</p>
+{{end}}
+{{if .FullyCancelled}}
+    <p class="cancelled"><strong>{{T "train_cancelled"}}</strong></p>
+{{end}}
+{{range .CancelledRanges}}
+    <p class="cancelled"><strong>{{if eq .From .To}}{{T "cancelled_at" (station .To)}}{{else}}{{T "cancelled_between" (station .From) (station .To)}}{{end}}</strong></p>
+{{end}}
+{{with .Prediction}}
+    <p>{{T "predicted_arr" (station .Station) (hhmm .Predicted)}} {{delay .Scheduled .Predicted}}{{with .Darwin}} <span class="muted">({{T "darwin_expects" (hhmm .)}})</span>{{end}}</p>
+{{end}}
+{{with reason .LateCause}}
+    <p class="late">{{.}}</p>
+{{end}}
+{{with .Position}}
+    <p>{{if .From}}{{T "between_signals" .From .To}}{{else}}{{T "at_signal" .To}}{{end}} ({{.Area}})</p>
+{{end}}
 <ul>
-    {{range .Stops}}
+    {{range $i, $_ := .Stops}}
+        {{with index $.Segments $i}}{{if .Known}}
+        <li class="muted">{{T "segment_miles" .Miles}}{{if .MPH}}, {{T "segment_mph" .MPH}}{{end}}</li>
+        {{end}}{{end}}
         <li>
-            <strong>{{.Station}}</strong>: 
-            Scheduled {{.Scheduled}} | Actual {{.Actual}} | Status: {{.Status}}
+            <strong>{{station .Station}}</strong>{{with .Note}} <em>({{T .}})</em>{{end}}: 
+            {{T "scheduled"}} {{hhmm .Scheduled}} | {{T "actual"}} {{hhmm .Actual}} {{delay .Scheduled .Actual}}{{if .Platform}} | {{T "platform"}} {{platform .Platform .PlatformConfirmed}}{{end}} | {{T "status"}}: {{T .Status}}{{if and .Reinstated (ne .Status "Cancelled") (ne .Status "Reinstated")}} ({{T "Reinstated"}}){{end}}
+            {{with relative .Event .Scheduled .Expected .Actual .Status}}<span class="muted">({{.}})</span>{{end}}
+            {{if and .Expected (not .Actual)}}{{if .Delayed}}<span class="late">{{T "delay_unknown"}}</span>{{else if .ForecastSource}}<span class="muted">{{T "forecast_source" .ForecastSource}}</span>{{end}}{{end}}
+            {{if .Discrepancy}}<span class="late">{{T "trust_discrepancy" .TrustActual}}</span>{{end}}
+            {{with shortPlatform .}}<span class="late">{{T "short_platform" .}}</span>{{end}}
         </li>
     {{end}}
 </ul>
+{{if .Poll}}
+    <span hx-get="{{.URL}}" hx-trigger="load delay:{{.Poll}}s" hx-target="closest .train-progression" hx-swap="innerHTML"></span>
+{{end}}
 `))
 
 // Data structures for train progress
 type Stop struct {
-    Station   string
-    Scheduled string
-    Actual    string
-    Status    string
+    Station     string
+    Scheduled   string
+    Expected    string
+    Actual      string
+    Status      string
+    Platform    string
+    // Whether Platform is confirmed rather than only expected
+    PlatformConfirmed bool
+    Event       string // "arr" or "dep", which of the stop's times Scheduled is
+    TrustActual string
+    Discrepancy bool   // Darwin and TRUST actuals disagree
+    Note        string // e.g. "Request stop", from the activity codes
+    Reinstated  bool   // cancelled earlier, then reinstated by Darwin
+    // Where the latest forecast came from, and whether Darwin flagged the
+    // delay as indeterminate, in which case Expected is only a guess
+    ForecastSource     string
+    ForecastSourceInst string
+    Delayed            bool
+    Length             int // coaches in the formation, if Darwin says
 }
 type TrainProgress struct {
-    Stops []Stop
+    RID      string
+    TrainID  string
+    SSD      string
+    TOC      string
+    Mode     string
+    Stops    []Stop
+    Position *BerthPosition
+    // RIDs this service ran under before Darwin reissued its schedule
+    PreviousRIDs []string
+    // When we saw the final arrival or cancellation; finished journeys
+    // are evicted a while after this
+    FinishedAt time.Time
+    // Cancellations and reinstatements, oldest first
+    Events []ServiceEvent
+    // Darwin's latest late-running reason code, 0 if none given
+    LateReason int
+    // and where its cause was, at or near a TIPLOC
+    LateReasonAt   string
+    LateReasonNear bool
+    // When a Darwin update for the train was last applied; zero if none
+    // has been yet
+    LastUpdated time.Time
 }
 
+// A change to a service's calling pattern, e.g. stops cancelled
+type ServiceEvent struct {
+    At      time.Time
+    Kind    string // "cancelled" or "reinstated"
+    Tiplocs []string
+}
 
-// Shared cache for train progress (real data)
-var (
-    train2B15Cache = TrainProgress{}
-    train2B15Mu    sync.RWMutex
-)
 
-// Darwin XML structs (simplified for TS)
-type DarwinPport struct {
-    XMLName xml.Name   `xml:"Pport"`
-    TS      []DarwinTS `xml:"TS"`
-}
-type DarwinTS struct {
-    RID     string        `xml:"rid,attr"`
-    UID     string        `xml:"uid,attr"`
-    TrainID string        `xml:"trainid,attr"`
-    Locs    []DarwinLoc   `xml:"Location"`
+// Headcode of the train we follow
+const trackedHeadcode = "2B15"
+
+// Progress of today's run of the tracked train
+func fetchTrackedProgress() TrainProgress {
+    var progress TrainProgress
+    if rid := currentTrackedRID(); rid != "" {
+        p, ok, err := progressStore.Get(rid)
+        if err != nil {
+            log.Printf("Failed to load progress for %s: %v", rid, err)
+        }
+        if ok {
+            progress = p
+        } else if j, ok := journeyByRID(rid); ok {
+            progressFromJourney(j, &progress)
+        }
+    }
+    reconcileWithTrust(&progress)
+    progress.Position = berthPosition(trackedHeadcode)
+    return progress
 }
-type DarwinLoc struct {
-    Tiploc string `xml:"tpl,attr"`
-    Pta    string `xml:"pta,attr"`
-    Ata    string `xml:"ata,attr"`
-    Act    string `xml:"act,attr"`
+
+// A train's calling points as a table for terminals
+func writeProgressText(w io.Writer, p TrainProgress, headcode, lang string) {
+    fmt.Fprintf(w, "%s\n\n", translate(lang, "train_progress", headcode))
+    var rows [][]string
+    for _, s := range p.Stops {
+        rows = append(rows, []string{stationDisplayName(s.Station, lang), s.Scheduled, s.Expected, s.Actual, s.Platform, translate(lang, s.Status)})
+    }
+    writeTextTable(w, []string{translate(lang, "station_col"), translate(lang, "scheduled"), translate(lang, "expected"), translate(lang, "actual"), translate(lang, "platform"), translate(lang, "status")}, rows)
 }
 
-func fetchTrain2B15Progress() TrainProgress {
-    train2B15Mu.RLock()
-    defer train2B15Mu.RUnlock()
-    return train2B15Cache
+// A train's progress as the htmx fragment, JSON or text. url is where the
+// fragment fetches its next refresh from.
+func serveProgress(w http.ResponseWriter, r *http.Request, progress TrainProgress, headcode, url string) {
+    lang := requestLang(w, r)
+    ranges, all := cancelledRanges(progress.Stops)
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(progressTmpl, lang)
+            if err != nil {
+                return err
+            }
+            // The fragment schedules its own next refresh, so idle pages back off
+            poll := pollInterval(progress, clock.Now())
+            var prediction *ArrivalPrediction
+            if pr, ok := predictArrival(progress); ok {
+                prediction = &pr
+            }
+            data := struct {
+                TrainProgress
+                Headcode, URL   string
+                Poll            int
+                Segments        []SegmentSpeed
+                CancelledRanges []CancelledRange
+                FullyCancelled  bool
+                Prediction      *ArrivalPrediction
+                Attributes      []string
+                Freshness       *Freshness
+                Allocation      *Allocation // only in enthusiast mode
+            }{progress, headcode, url, int(poll.Seconds()), segmentSpeeds(progress), ranges, all, prediction, progressAttributes(progress), progress.Freshness(clock.Now()), nil}
+            if enthusiastMode(r) {
+                data.Allocation = allocationFor(progress.RID, headcode, progress.SSD)
+            }
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any { return journeyResponse(progress) },
+        Text: func(w io.Writer) { writeProgressText(w, progress, headcode, lang) },
+    })
 }
 
 func main() {
-    // Load environment variables from .env file
-    _ = godotenv.Load()
+    // Load environment variables from the config file, if there is one
+    _ = loadConfigFile()
+
+    if code, ok := runCommand(os.Args[1:]); ok {
+        os.Exit(code)
+    }
+
+    // The Push Port consumer and the HTTP frontend can run as separate
+    // processes sharing a progress store
+    role := flag.String("role", "all", "components to run: ingest (Push Port consumer), web (HTTP frontend) or all")
+    timetable := flag.String("timetable", envOr("TIMETABLE_SOURCE", "s3"), "timetable source: s3, a file path, file:// URL or http(s):// URL")
+    reference := flag.String("reference", os.Getenv("REFERENCE_SOURCE"), "reference data path or URL when not loading from s3")
+    templatesDir := flag.String("templates-dir", os.Getenv("TEMPLATES_DIR"), "directory of page templates to use over the built-in ones")
+    addr := flag.String("addr", envOr("HTTP_ADDR", ":8081"), "address for the public boards, pages and API")
+    adminAddr := flag.String("admin-addr", os.Getenv("ADMIN_ADDR"), "address for admin, metrics, health and pprof, off the public listener")
+    flag.Parse()
+    ingest := *role == "all" || *role == "ingest"
+    web := *role == "all" || *role == "web"
+    if !ingest && !web {
+        log.Fatalf("Unknown --role %q; expected ingest, web or all", *role)
+    }
+    // On Lambda only the frontend runs, reading the shared progress store
+    if onLambda() {
+        ingest, web = false, true
+    }
+    runsIngest, runsWeb = ingest, web
 
 	log.Println(CancellationReasons[100]) // Example usage of the imported package
 
-    // Download and print the latest timetable XML from S3 at startup
-    downloadLatestTimetableFromS3()
+    initThemes()
+    initTemplateOverrides(*templatesDir)
+    initStationGroups()
+    initStationCoords()
+    initPlatformLengths()
+    if err := initProgressStore(); err != nil {
+        log.Fatalf("Failed to open progress store: %v", err)
+    }
+    if err := initArchive(); err != nil {
+        log.Fatalf("Failed to open archive: %v", err)
+    }
+    loadRIDLinks()
+    initForecastAccuracy()
+    initHealth()
+    initTracing()
+    initMetrics()
+    initSlowCalls()
+    initLoadShedding()
+    initEviction()
+    initTimetableDays()
+    if *role != "all" && envOr("PROGRESS_STORE", "memory") == "memory" {
+        log.Printf("Running --role=%s with the in-memory store; set PROGRESS_STORE so ingest and web share progress.", *role)
+    }
+
+    timetableSource, referenceSource = *timetable, *reference
+    go startEviction()
+    initMemoryGuard()
+
+    // Email for delay alerts and digests, throttled so fluctuating
+    // estimates don't flood inboxes, and queued so outages don't lose them
+    if smtp, ok := newSMTPNotifierFromEnv(); ok {
+        alertNotifier = newThrottledNotifier(queueNotifications(smtp))
+    }
+    go watchConfigReload()
 
-    // Use environment variables for Darwin credentials
-    username := os.Getenv("DARWIN_USERNAME")
-    password := os.Getenv("DARWIN_TOKEN")
-    if username == "" || password == "" {
-        log.Fatal("Please set DARWIN_USERNAME and DARWIN_TOKEN environment variables.")
+    var feed func()
+    if ingest {
+        // Use environment variables for Darwin credentials
+        username := os.Getenv("DARWIN_USERNAME")
+        password := os.Getenv("DARWIN_TOKEN")
+        feed = func() { startDarwinFeed(username, password) }
+        if username == "" || password == "" {
+            // Without Push Port credentials, fall back on polling the
+            // journal in S3 if its credentials are set
+            if os.Getenv("AWS_ACCESS_KEY_ID") == "" || os.Getenv("AWS_SECRET_ACCESS_KEY") == "" {
+                log.Fatal("Please set DARWIN_USERNAME and DARWIN_TOKEN environment variables.")
+            }
+            log.Println("DARWIN_USERNAME and DARWIN_TOKEN not set; polling the Push Port journal in S3 instead")
+            feed = startDarwinJournalPolling
+        }
+        go startWatchdog()
+        go startFeedAlerts()
+        // Punctuality digests of the watched trains by email, sent by the
+        // ingester so web replicas don't each send a copy
+        if alertNotifier != nil {
+            go startDigest(alertNotifier)
+        }
     }
-    
+    go announceReady(ingest, web)
+    startAdminListener(*adminAddr)
+
+    // Load the latest timetable, from S3 unless told otherwise, unless a
+    // snapshot from earlier today already has it, and only then start the
+    // Push Port feed so its updates have schedules to apply to. The web
+    // server doesn't wait for this; it shows a placeholder until ready.
+    startup := func() {
+        if loadSnapshot() {
+            trackFromTimetable()
+        } else {
+            loadTimetable()
+        }
+        timetableAttempted.Store(true)
+        go startSnapshots()
+        if feed != nil {
+            feed()
+        }
+    }
+    if !web {
+        startup()
+        return
+    }
+    // On Lambda too: an invocation mustn't wait for the timetable
+    go startup()
+
+    // TRUST and TD are overlaid on progress when pages are rendered, so
+    // they run alongside the web server rather than the ingester
+
+    // Optional second source of actuals from Network Rail TRUST
+    initReconcile()
+    initFeed()
+    go startKnowledgebase()
+    go startTimetablePrefetch()
+    go startAllocations()
+    if os.Getenv("TRUST_ENABLED") == "true" {
+        if os.Getenv("NR_USERNAME") == "" || os.Getenv("NR_PASSWORD") == "" {
+            log.Println("TRUST_ENABLED is set but NR_USERNAME and NR_PASSWORD are not; TRUST feed disabled.")
+        } else {
+            go startTrustFeed()
+        }
+    }
+
+    // Optional berth-level positions from the Network Rail TD feed
+    if os.Getenv("TD_ENABLED") == "true" {
+        if os.Getenv("NR_USERNAME") == "" || os.Getenv("NR_PASSWORD") == "" {
+            log.Println("TD_ENABLED is set but NR_USERNAME and NR_PASSWORD are not; TD feed disabled.")
+        } else {
+            go startTrainDescriber()
+        }
+    }
+
     http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-        if err := pageTmpl.Execute(w, nil); err != nil {
+        lang := requestLang(w, r)
+        tmpl, err := localisedTemplate(pageTmpl, lang)
+        if err != nil {
             http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
         }
+        rememberEnthusiast(w, r)
+        progress, _, watching := homeProgress(r)
+        data := struct {
+            Lang, OtherLang, Theme string
+            Meta                   pageMeta
+            Commutes               bool
+            ProgressURL            string
+            Watching               bool
+            Enthusiast             bool
+            EnthusiastToggle       string
+            ShortLink              string
+            Share                  *shareForm
+        }{lang, otherLang(lang), requestTheme(w, r), trainMeta(r, progress, lang), len(requestCommutes(r)) > 0, "/progress", watching, enthusiastMode(r), enthusiastToggle(r), "", nil}
+        executeTemplate(w, r, tmpl, data)
     })
 
+    http.HandleFunc("/theme.css", themeCSSHandler)
+    http.HandleFunc("GET /healthz", healthzHandler)
+    http.HandleFunc("GET /readyz", readyzHandler)
+    http.HandleFunc("GET /version", versionHandler)
+    http.HandleFunc("GET /stations", gazetteerHandler)
+    http.HandleFunc("GET /disruptions", disruptionsHandler)
+    http.HandleFunc("GET /near", nearHandler)
+    http.HandleFunc("GET /commutes", commutesHandler)
+    http.HandleFunc("GET /commutes/status", commuteStatusHandler)
+    http.HandleFunc("POST /commutes", sameOrigin(addCommuteHandler))
+    http.HandleFunc("POST /commutes/{n}/delete", sameOrigin(deleteCommuteHandler))
+    http.HandleFunc("GET /commutes/{commute}/calendar.ics", commuteCalendarHandler)
+    http.HandleFunc("GET /train/{rid}/card.png", trainCardHandler)
+    http.HandleFunc("GET /train/{headcode}", trainPageHandler)
+    http.HandleFunc("GET /t/{code}", shortLinkHandler)
+    http.HandleFunc("POST /t", sameOrigin(createShortLinkHandler))
+    http.HandleFunc("GET /train/{headcode}/progress", trainProgressHandler)
+    http.HandleFunc("GET /compare", compareHandler)
+    http.HandleFunc("POST /watch", sameOrigin(watchHandler))
+    http.HandleFunc("POST /watch/stop", sameOrigin(unwatchHandler))
+    http.HandleFunc("GET /board/{crs}", boardPageHandler)
+    http.HandleFunc("GET /board/{crs}/departures", boardHandler)
+    http.HandleFunc("GET /board/{crs}/timetable", walkUpHandler)
+    http.HandleFunc("GET /stats", statsPageHandler)
+    http.HandleFunc("GET /stats/table", statsTableHandler)
+    http.HandleFunc("GET /board/{crs}/feed.atom", disruptionFeedHandler)
+    http.HandleFunc("GET /board/{prefix}/{name}", groupBoardPageHandler)
+    http.HandleFunc("GET /board/group/{name}/departures", groupBoardHandler)
+    http.HandleFunc("GET /embed/board/{crs}", embedBoardHandler)
+    http.HandleFunc("GET /oembed", oEmbedHandler)
+    http.HandleFunc("GET /api/v1/train/{headcode}/delay-history", readAPI(delayHistoryHandler))
+    http.HandleFunc("GET /api/v1/train/{headcode}/where", readAPI(whereHandler))
+    http.HandleFunc("GET /api/v1/stats/forecast-accuracy", readAPI(forecastAccuracyHandler))
+    http.HandleFunc("GET /api/v1/board/{crs}", readAPI(boardAPIHandler))
+    http.HandleFunc("GET /api/v1/trains/near", readAPI(trainsNearHandler))
+    http.HandleFunc("GET /api/v1/stations/near", readAPI(stationsNearHandler))
+    http.HandleFunc("GET /api/v1/journey/{rid}", readAPI(journeyAPIHandler))
+    http.HandleFunc("POST /api/v1/trains:batchGet", readAPI(batchGetHandler))
+    http.HandleFunc("GET /api/v1/timetable/{date}", readAPI(timetableSearchHandler))
+    http.HandleFunc("POST /graphql", readAPI(graphQLHandler.ServeHTTP))
+    http.HandleFunc("GET /api/v1/rules", requireScope("notify", listRulesHandler))
+    http.HandleFunc("POST /api/v1/rules", requireScope("notify", createRuleHandler))
+    http.HandleFunc("GET /api/v1/rules/{id}", requireScope("notify", getRuleHandler))
+    http.HandleFunc("PUT /api/v1/rules/{id}", requireScope("notify", updateRuleHandler))
+    http.HandleFunc("DELETE /api/v1/rules/{id}", requireScope("notify", deleteRuleHandler))
+    http.HandleFunc("POST /api/v1/admin/reload-timetable", requireScope("admin", reloadTimetableHandler))
+    http.HandleFunc("POST /api/v1/admin/reload-config", requireScope("admin", reloadConfigHandler))
+    http.HandleFunc("GET /admin/login", adminLoginPageHandler)
+    http.HandleFunc("POST /admin/login", adminLoginHandler)
+    http.HandleFunc("POST /admin/logout", adminLogoutHandler)
+    http.HandleFunc("GET /admin/snapshots", requireScope("admin", snapshotsHandler))
+    http.HandleFunc("POST /admin/snapshots/load", requireScope("admin", loadSnapshotHandler))
+    http.HandleFunc("POST /admin/snapshots/unload", requireScope("admin", unloadSnapshotHandler))
+    http.HandleFunc("GET /admin/outbox", requireScope("admin", outboxHandler))
+    http.HandleFunc("GET /admin/anomalies", requireScope("admin", anomaliesHandler))
+    http.HandleFunc("GET /admin/feed-alerts", requireScope("admin", feedAlertsHandler))
+    http.HandleFunc("POST /admin/outbox/{id}/retry", requireScope("admin", retryOutboxHandler))
+
     http.HandleFunc("/progress", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("Serving /progress")
-        progress := fetchTrain2B15Progress()
-        if err := progressTmpl.Execute(w, progress); err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-        }
+        progress, headcode, _ := homeProgress(r)
+        serveProgress(w, r, progress, headcode, "/progress")
     })
 
-    log.Println("Server started at http://localhost:8081")
-    log.Fatal(http.ListenAndServe(":8081", nil))
+    if addr := envOr("GRPC_ADDR", ""); addr != "" {
+        go startGRPC(addr)
+    }
+
+    if api, ok := lambdaRuntimeAPI(); ok {
+        serverListening.Store(true)
+        serveLambda(api, tracedHandler(whileLoading(readOnly(publicOnly(http.DefaultServeMux)))))
+    }
+
+    ln, err := net.Listen("tcp", *addr)
+    if err != nil {
+        log.Fatalf("Failed to listen on %s: %v", *addr, err)
+    }
+    serverListening.Store(true)
+    log.Printf("Server started at %s", *addr)
+    log.Fatal(http.Serve(ln, tracedHandler(whileLoading(publicOnly(http.DefaultServeMux)))))
 }
\ No newline at end of file
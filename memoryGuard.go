@@ -0,0 +1,114 @@
+package main
+
+import (
+    "log"
+    "runtime"
+    "runtime/debug"
+    "sync/atomic"
+    "time"
+)
+
+// A big disruption day means more forecasts, more cancellations and more
+// progress to hold. With MEMORY_CEILING_MB set, the heap is checked every
+// MEMORY_CHECK_INTERVAL (default 15s), and each check that finds it over
+// the ceiling sheds one more stage, cheapest to do without first:
+//
+//  1. the optional indexes: TD berth positions, which boards have been
+//     viewed, and the location index, boards scanning every journey
+//     instead
+//  2. finished journeys, evicted straight away, and forecasts awaiting
+//     their actual times, with accuracy tracking stopped
+//  3. cached future timetable days, fetched again when asked for
+//
+// Nothing is rebuilt until the heap is back under 80% of the ceiling, so
+// a heap hovering around it doesn't shed and rebuild by turns. The
+// process reports itself degraded meanwhile.
+var (
+    memoryCeiling  uint64
+    memoryPressure atomic.Bool
+    shedStage      int
+    // The heap as of the last check, as reading it stops the world
+    lastHeap atomic.Uint64
+)
+
+const lastShedStage = 3
+
+func initMemoryGuard() {
+    memoryCeiling = uint64(envInt("MEMORY_CEILING_MB", 0)) << 20
+    interval, err := time.ParseDuration(envOr("MEMORY_CHECK_INTERVAL", "15s"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid MEMORY_CHECK_INTERVAL; using 15s")
+        interval = 15 * time.Second
+    }
+    lastHeap.Store(heapInUse())
+    go func() {
+        for range time.Tick(interval) {
+            lastHeap.Store(heapInUse())
+            if memoryCeiling > 0 {
+                checkMemory(lastHeap.Load())
+            }
+        }
+    }()
+}
+
+func heapInUse() uint64 {
+    var m runtime.MemStats
+    runtime.ReadMemStats(&m)
+    return m.HeapAlloc
+}
+
+func checkMemory(heap uint64) {
+    switch {
+    case heap > memoryCeiling && shedStage < lastShedStage:
+        if !memoryPressure.Swap(true) {
+            log.Printf("Heap is %d MB, over MEMORY_CEILING_MB (%d); shedding memory", heap>>20, memoryCeiling>>20)
+        }
+        shedStage++
+        shedMemory(shedStage)
+        debug.FreeOSMemory()
+        heap = heapInUse()
+        lastHeap.Store(heap)
+        log.Printf("Heap is %d MB after shedding memory", heap>>20)
+    case heap < memoryCeiling/10*8 && memoryPressure.Load():
+        memoryPressure.Store(false)
+        shedStage = 0
+        restoreMemory()
+        log.Printf("Heap is back down to %d MB; no longer shedding memory", heap>>20)
+    }
+}
+
+// Drop what a stage can do without or fetch again
+func shedMemory(stage int) {
+    switch stage {
+    case 1:
+        berths := dropBerthPositions()
+        views := dropBoardViews()
+        journeysMu.Lock()
+        locationIndex = nil
+        journeysMu.Unlock()
+        log.Printf("Dropped %d berth positions, %d board views and the location index", berths, views)
+    case 2:
+        now := clock.Now()
+        evictJourneys(now, now)
+        log.Printf("Dropped %d pending forecasts", dropPendingForecasts())
+    case 3:
+        log.Printf("Dropped %d cached timetable days", dropTimetableDays())
+    }
+}
+
+// Rebuild what shedMemory dropped that doesn't come back by itself
+func restoreMemory() {
+    journeysMu.Lock()
+    rebuildLocationIndex()
+    journeysMu.Unlock()
+}
+
+type MemoryState struct {
+    Pressure  bool   `json:"pressure"`
+    HeapMB    uint64 `json:"heap_mb"`
+    CeilingMB uint64 `json:"ceiling_mb,omitempty"`
+}
+
+func memoryState() MemoryState {
+    return MemoryState{memoryPressure.Load(), lastHeap.Load() >> 20, memoryCeiling >> 20}
+}
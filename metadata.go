@@ -0,0 +1,150 @@
+package main
+
+import (
+    "html/template"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Link preview and structured data for a page's <head>
+type pageMeta struct {
+    Title       string
+    Description string
+    URL         string
+    Image       string // absolute URL, or empty for no image
+    JSONLD      any    // a schema.org object, or nil
+}
+
+// Open Graph tags and JSON-LD from a pageMeta. The template marshals the
+// JSON-LD and escapes it for the script element, so the data can't close it.
+var metaTagsTmpl = template.Must(template.New("meta").Parse(`
+{{- range .Props}}{{if .Content}}<meta property="{{.Name}}" content="{{.Content}}">
+{{end}}{{end -}}
+{{if .Image}}<meta name="twitter:card" content="summary_large_image">
+{{end -}}
+{{with .Description}}<meta name="description" content="{{.}}">
+{{end -}}
+{{with .JSONLD}}<script type="application/ld+json">{{.}}</script>
+{{end -}}
+`))
+
+func metaTags(m pageMeta) template.HTML {
+    if m.Title == "" {
+        return ""
+    }
+    type prop struct{ Name, Content string }
+    return htmlFragment(metaTagsTmpl, struct {
+        pageMeta
+        Props []prop
+    }{m, []prop{
+        {"og:type", "website"},
+        {"og:site_name", "MinimalTrains"},
+        {"og:title", m.Title},
+        {"og:description", m.Description},
+        {"og:url", m.URL},
+        {"og:image", m.Image},
+    }})
+}
+
+// Date and time of a call, from the schedule's SSD. Times earlier than
+// after are taken to be past midnight.
+func railDateTime(ssd, hhmm string, after time.Time) (time.Time, bool) {
+    if len(hhmm) > 5 {
+        hhmm = hhmm[:5]
+    }
+    t, err := time.ParseInLocation("2006-01-02 15:04", ssd+" "+hhmm, ukLocation)
+    if err != nil {
+        return time.Time{}, false
+    }
+    if t.Before(after) {
+        t = t.AddDate(0, 0, 1)
+    }
+    return t, true
+}
+
+// schema.org TrainStation for a location
+func stationLD(tiploc, lang string) map[string]any {
+    ld := map[string]any{"@type": "TrainStation", "name": stationDisplayName(tiploc, lang)}
+    if crs := stationKey(tiploc); crs != tiploc {
+        ld["identifier"] = crs
+    }
+    if c, ok := coordsFor(tiploc); ok {
+        ld["geo"] = map[string]any{"@type": "GeoCoordinates", "latitude": c.Lat, "longitude": c.Lon}
+    }
+    return ld
+}
+
+// Link preview for a train's page, with its share card as the image
+func trainMeta(r *http.Request, p TrainProgress, lang string) pageMeta {
+    base := requestBaseURL(r)
+    s := summariseTrain(p, lang)
+    m := pageMeta{
+        Title:       strings.TrimSpace(s.Title + " " + s.Route),
+        Description: strings.Join(nonEmpty(s.Status, s.Detail), ". "),
+        URL:         base + r.URL.Path,
+    }
+    if p.RID == "" || len(p.Stops) == 0 {
+        return m
+    }
+    m.Image = base + "/train/" + p.RID + "/card.png"
+    first, last := p.Stops[0], p.Stops[len(p.Stops)-1]
+    trip := map[string]any{
+        "@context":         "https://schema.org",
+        "@type":            "TrainTrip",
+        "trainNumber":      p.TrainID,
+        "departureStation": stationLD(first.Station, lang),
+        "arrivalStation":   stationLD(last.Station, lang),
+    }
+    if p.TOC != "" {
+        trip["provider"] = map[string]any{"@type": "Organization", "name": operatorName(p.TOC)}
+    }
+    var dep time.Time
+    if t, ok := railDateTime(p.SSD, first.Scheduled, time.Time{}); ok {
+        dep = t
+        trip["departureTime"] = t.Format(time.RFC3339)
+    }
+    if t, ok := railDateTime(p.SSD, last.Scheduled, dep); ok && !dep.IsZero() {
+        trip["arrivalTime"] = t.Format(time.RFC3339)
+    }
+    m.JSONLD = trip
+    return m
+}
+
+// Link preview for a station board, describing the next departure
+func boardMeta(r *http.Request, crs, lang string) pageMeta {
+    m := pageMeta{Title: translate(lang, "board_title", crs), URL: requestBaseURL(r) + "/board/" + crs}
+    tiplocs := tiplocsForCRS(crs)
+    if len(tiplocs) == 0 {
+        return m
+    }
+    m.Title = translate(lang, "board_title", stationDisplayName(tiplocs[0], lang))
+    if rows := buildBoard(crs, boardOptions{}, clock.Now()).allRows(); len(rows) > 0 {
+        next := rows[0]
+        status := translate(lang, next.Status)
+        switch {
+        case next.Expected != "" && next.Expected != next.Time:
+            status = translate(lang, "meta_expected", next.Expected)
+        case status == "":
+            status = translate(lang, "On time")
+        }
+        m.Description = translate(lang, "meta_next_dep", next.Time, stationDisplayName(next.Destination, lang), status)
+    } else {
+        m.Description = translate(lang, "no_departures")
+    }
+    ld := stationLD(tiplocs[0], lang)
+    ld["@context"] = "https://schema.org"
+    ld["url"] = m.URL
+    m.JSONLD = ld
+    return m
+}
+
+func nonEmpty(ss ...string) []string {
+    var out []string
+    for _, s := range ss {
+        if s != "" {
+            out = append(out, s)
+        }
+    }
+    return out
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics about the railway rather than the app: how today's
+// trains are doing by operator and by station, so dashboards can chart
+// network health. They're served on the admin listener, and also on
+// METRICS_ADDR (e.g. ":9464") when it's set, so they needn't be public.
+
+// Calls made within this many minutes of schedule count as punctual,
+// as for the industry's public performance measure
+const punctualMins = 5
+
+// Working the figures out reads every train's progress, so scrapes closer
+// together than this share them
+const metricsMaxAge = 30 * time.Second
+
+// GET /metrics, for whichever listeners serve it
+var metricsHandler http.Handler
+
+func initMetrics() {
+    reg := prometheus.NewRegistry()
+    reg.MustRegister(railwayFigures, callDuration, slowCalls, templateErrors, quarantinedUpdates, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+    reg.MustRegister(parseMetrics...)
+    metricsHandler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+    addr := envOr("METRICS_ADDR", "")
+    if addr == "" {
+        return
+    }
+    mux := http.NewServeMux()
+    mux.Handle("GET /metrics", metricsHandler)
+    go func() {
+        log.Printf("Serving railway metrics on %s/metrics", addr)
+        if err := http.ListenAndServe(addr, mux); err != nil {
+            log.Printf("Failed to serve metrics: %v", err)
+        }
+    }()
+}
+
+// Today's figures, by TOC and by station CRS
+type railwayStats struct {
+    services, running        map[string]int
+    cancelled, partCancelled map[string]int
+    delaySum, delayed        map[string]int
+    stationCalls, punctual   map[string]int
+    // Calls with an actual time, and punctual ones, by TOC
+    tocCalls, tocPunctual map[string]int
+}
+
+func collectRailwayStats(now time.Time) railwayStats {
+    st := railwayStats{
+        services: map[string]int{}, running: map[string]int{},
+        cancelled: map[string]int{}, partCancelled: map[string]int{},
+        delaySum: map[string]int{}, delayed: map[string]int{},
+        stationCalls: map[string]int{}, punctual: map[string]int{},
+        tocCalls: map[string]int{}, tocPunctual: map[string]int{},
+    }
+    today := now.In(ukLocation).Format("2006-01-02")
+    var todays []*Journey
+    journeysMu.RLock()
+    for _, j := range journeys {
+        if j.SSD == today && j.IsPublic() {
+            todays = append(todays, j)
+        }
+    }
+    journeysMu.RUnlock()
+
+    for _, j := range todays {
+        st.services[j.TOC]++
+        p, ok, err := progressStore.Get(j.RID)
+        if err != nil {
+            log.Printf("Failed to load progress for %s: %v", j.RID, err)
+            continue
+        }
+        if !ok {
+            continue
+        }
+        ranges, all := cancelledRanges(p.Stops)
+        switch {
+        case all:
+            st.cancelled[j.TOC]++
+            continue
+        case len(ranges) > 0:
+            st.partCancelled[j.TOC]++
+        }
+        started := false
+        for _, s := range p.Stops {
+            if s.Actual == "" {
+                continue
+            }
+            started = true
+            if late, ok := minutesLate(s.Scheduled, s.Actual); ok {
+                crs := stationKey(s.Station)
+                st.stationCalls[crs]++
+                st.tocCalls[j.TOC]++
+                if late < punctualMins {
+                    st.punctual[crs]++
+                    st.tocPunctual[j.TOC]++
+                }
+            }
+        }
+        if !started {
+            continue
+        }
+        if !journeyFinished(p) {
+            st.running[j.TOC]++
+        }
+        if d, ok := trainDelay(p); ok {
+            st.delaySum[j.TOC] += max(0, d)
+            st.delayed[j.TOC]++
+        }
+    }
+    return st
+}
+
+var (
+    servicesDesc = prometheus.NewDesc("minimaltrains_services_today",
+        "Public services timetabled to run today.", []string{"toc"}, nil)
+    runningDesc = prometheus.NewDesc("minimaltrains_services_running",
+        "Services that have set off and not yet finished.", []string{"toc"}, nil)
+    cancelledDesc = prometheus.NewDesc("minimaltrains_cancelled_services_today",
+        "Services cancelled today, wholly or for part of their journey.", []string{"toc", "kind"}, nil)
+    averageDelayDesc = prometheus.NewDesc("minimaltrains_average_delay_minutes",
+        "Average lateness of today's services that have set off, counting early running as on time.", []string{"toc"}, nil)
+    stationCallsDesc = prometheus.NewDesc("minimaltrains_station_calls_today",
+        "Calls at a station today with an actual time.", []string{"crs"}, nil)
+    punctualityDesc = prometheus.NewDesc("minimaltrains_station_punctuality_ratio",
+        "Share of today's calls at a station made less than five minutes late.", []string{"crs"}, nil)
+)
+
+type railwayCollector struct {
+    mu    sync.Mutex
+    at    time.Time
+    stats railwayStats
+}
+
+// Shared by the metrics and the /stats page
+var railwayFigures = &railwayCollector{}
+
+// Today's figures, worked out again if they're more than metricsMaxAge old
+func (c *railwayCollector) current() railwayStats {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if now := clock.Now(); now.Sub(c.at) > metricsMaxAge {
+        c.stats, c.at = collectRailwayStats(now), now
+    }
+    return c.stats
+}
+
+func (c *railwayCollector) Describe(ch chan<- *prometheus.Desc) {
+    for _, d := range []*prometheus.Desc{servicesDesc, runningDesc, cancelledDesc, averageDelayDesc, stationCallsDesc, punctualityDesc} {
+        ch <- d
+    }
+}
+
+func (c *railwayCollector) Collect(ch chan<- prometheus.Metric) {
+    st := c.current()
+    gauge := func(d *prometheus.Desc, v float64, labels ...string) {
+        ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+    }
+    for toc, n := range st.services {
+        gauge(servicesDesc, float64(n), toc)
+        gauge(runningDesc, float64(st.running[toc]), toc)
+        gauge(cancelledDesc, float64(st.cancelled[toc]), toc, "full")
+        gauge(cancelledDesc, float64(st.partCancelled[toc]), toc, "part")
+        if st.delayed[toc] > 0 {
+            gauge(averageDelayDesc, float64(st.delaySum[toc])/float64(st.delayed[toc]), toc)
+        }
+    }
+    for crs, n := range st.stationCalls {
+        gauge(stationCallsDesc, float64(n), crs)
+        gauge(punctualityDesc, float64(st.punctual[crs])/float64(n), crs)
+    }
+}
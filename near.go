@@ -0,0 +1,137 @@
+package main
+
+import (
+    "html/template"
+    "math"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+)
+
+type NearbyStation struct {
+    CRS        string  `json:"crs"`
+    Name       string  `json:"name"`
+    NameCy     string  `json:"name_cy,omitempty"`
+    DistanceKm float64 `json:"distance_km"`
+}
+
+const (
+    defaultNearStations = 5
+    maxNearStations     = 20
+)
+
+// Public stations with known coordinates, nearest first, up to limit of
+// them within maxNearRadiusKm
+func stationsNear(at LatLon, limit int) []NearbyStation {
+    near := []NearbyStation{}
+    for _, e := range gazetteer("") {
+        for _, tiploc := range tiplocsForCRS(e.CRS) {
+            c, ok := coordsFor(tiploc)
+            if !ok {
+                continue
+            }
+            if d := haversineKm(at, c); d <= maxNearRadiusKm {
+                near = append(near, NearbyStation{e.CRS, e.Name, e.NameCy, math.Round(d*100) / 100})
+            }
+            break
+        }
+    }
+    sort.Slice(near, func(i, k int) bool { return near[i].DistanceKm < near[k].DistanceKm })
+    return near[:min(limit, len(near))]
+}
+
+// lat and lon from a query string, if both are there and in range
+func queryLatLon(q url.Values) (LatLon, bool) {
+    lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+    lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+    if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+        return LatLon{}, false
+    }
+    return LatLon{lat, lon}, true
+}
+
+// GET /api/v1/stations/near?lat=53.79&lon=-1.55&limit=5
+func stationsNearHandler(w http.ResponseWriter, r *http.Request) {
+    at, ok := queryLatLon(r.URL.Query())
+    if !ok {
+        http.Error(w, "lat and lon are required", http.StatusBadRequest)
+        return
+    }
+    limit := defaultNearStations
+    if v := r.URL.Query().Get("limit"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > maxNearStations {
+            http.Error(w, "limit must be between 1 and 20", http.StatusBadRequest)
+            return
+        }
+        limit = n
+    }
+    writeJSON(w, struct {
+        Position LatLon          `json:"position"`
+        Stations []NearbyStation `json:"stations"`
+    }{at, stationsNear(at, limit)})
+}
+
+// The browser is asked where it is and comes back with ?lat=&lon=, which
+// the server turns into a redirect to the nearest board. Coordinates are
+// only used for that lookup and aren't logged.
+var nearTmpl = template.Must(template.New("near").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "near_title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{T "near_title"}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/stations">{{T "stations_title"}}</a></p>
+    {{if .Located}}
+    <p>{{T "near_none" .Radius}}</p>
+    {{else}}
+    <p id="near-status">{{T "near_locating"}}</p>
+    <noscript><p>{{T "near_denied"}}</p></noscript>
+    <script>
+    (function () {
+        var status = document.getElementById("near-status");
+        var failed = function () { status.textContent = {{T "near_denied"}}; };
+        if (!navigator.geolocation) {
+            failed();
+            return;
+        }
+        navigator.geolocation.getCurrentPosition(function (pos) {
+            location.replace("/near?lat=" + pos.coords.latitude.toFixed(4) + "&lon=" + pos.coords.longitude.toFixed(4));
+        }, failed, {timeout: 10000, maximumAge: 300000});
+    })();
+    </script>
+    {{end}}
+</body>
+</html>
+`))
+
+// GET /near: find the browser's nearest station and go to its board
+func nearHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    at, located := queryLatLon(r.URL.Query())
+    if located {
+        if near := stationsNear(at, 1); len(near) > 0 {
+            http.Redirect(w, r, "/board/"+near[0].CRS, http.StatusFound)
+            return
+        }
+    }
+    tmpl, err := localisedTemplate(nearTmpl, lang)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    data := struct {
+        Lang, OtherLang, Theme string
+        Located                bool
+        Radius                 int
+    }{lang, otherLang(lang), requestTheme(w, r), located, maxNearRadiusKm}
+    if located {
+        w = &statusWriter{ResponseWriter: w, code: http.StatusNotFound}
+    }
+    executeTemplate(w, r, tmpl, data)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+    "fmt"
+    "mime"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "os"
+    "strings"
+    "time"
+)
+
+// A message for a person, delivered by one of the notifier channels
+type Notification struct {
+    To      string
+    Subject string
+    Body    string
+    // For alerts about a train: its RID and how late it is, so repeats
+    // can be throttled
+    Train     string
+    Delay     int
+    Cancelled bool
+}
+
+type Notifier interface {
+    Notify(n Notification) error
+}
+
+// Sends notifications as plain-text email
+type smtpNotifier struct {
+    addr     string
+    username string
+    password string
+    from     string
+}
+
+// SMTP notifier from SMTP_ADDR (host:port), SMTP_USERNAME, SMTP_PASSWORD
+// and SMTP_FROM. Reports false if SMTP isn't configured.
+func newSMTPNotifierFromEnv() (*smtpNotifier, bool) {
+    addr := os.Getenv("SMTP_ADDR")
+    if addr == "" {
+        return nil, false
+    }
+    return &smtpNotifier{
+        addr:     addr,
+        username: os.Getenv("SMTP_USERNAME"),
+        password: os.Getenv("SMTP_PASSWORD"),
+        from:     envOr("SMTP_FROM", "minimaltrains@localhost"),
+    }, true
+}
+
+func (s *smtpNotifier) Notify(n Notification) error {
+    var auth smtp.Auth
+    if s.username != "" {
+        host, _, err := net.SplitHostPort(s.addr)
+        if err != nil {
+            return err
+        }
+        auth = smtp.PlainAuth("", s.username, s.password, host)
+    }
+    // A line break in a header would let it add others
+    for _, v := range []string{s.from, n.To, n.Subject} {
+        if strings.ContainsAny(v, "\r\n") {
+            return fmt.Errorf("line break in email header %q", v)
+        }
+    }
+    from, err := mail.ParseAddress(s.from)
+    if err != nil {
+        return fmt.Errorf("invalid SMTP_FROM: %w", err)
+    }
+    to, err := mail.ParseAddressList(n.To)
+    if err != nil {
+        return fmt.Errorf("invalid recipients %q: %w", n.To, err)
+    }
+    var headerTo, rcpt []string
+    for _, a := range to {
+        headerTo = append(headerTo, a.String())
+        rcpt = append(rcpt, a.Address)
+    }
+    var msg strings.Builder
+    fmt.Fprintf(&msg, "From: %s\r\n", from)
+    fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(headerTo, ", "))
+    fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+    fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+    msg.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+    return smtp.SendMail(s.addr, auth, from.Address, rcpt, []byte(msg.String()))
+}
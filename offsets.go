@@ -0,0 +1,169 @@
+package main
+
+import (
+    "log"
+    "maps"
+    "slices"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// How far Push Port ingest had got, saved with the state snapshot so a
+// restart resumes from there instead of replaying hours of messages. A
+// message counts as processed once every update in it has been applied,
+// and the offset is read before the rest of the snapshot is taken, so
+// resuming may see a few messages twice but never skips one. The updates
+// already applied are saved in the snapshot too (see dedup.go), so the
+// apply stage drops the repeats rather than alerting on them again.
+//
+// The S3 journal resumes at the file and message it had reached. STOMP
+// can't be rewound, so a Push Port consumer that restarts with a saved
+// offset first catches up from the journal files written since, in
+// DARWIN_JOURNAL_BUCKET (default the timetable bucket).
+type FeedOffset struct {
+    Topic string `json:"topic"`
+    // When Darwin sent the last message processed
+    Sent time.Time `json:"sent"`
+    // Journal resume point: messages taken from each file, and the
+    // modification time files before which are done with
+    Journal map[string]int `json:"journal,omitempty"`
+    Since   time.Time      `json:"since,omitempty"`
+}
+
+// Where each message in the ingest pipeline has got to. Messages are
+// numbered in the order they were queued.
+type ingestTracker struct {
+    mu         sync.Mutex
+    topic      string
+    dispatched uint64 // messages handed on so far
+    lastSent   time.Time
+    // Messages with updates still being applied, by number
+    inflight map[uint64]*inflightMessage
+    // Journal positions, by how many messages had been queued when the
+    // poller reached them, oldest first
+    checkpoints []journalCheckpoint
+    // Offsets read from the snapshot, for consumers starting up
+    saved map[string]FeedOffset
+}
+
+type inflightMessage struct {
+    updates int
+    before  time.Time // when the message before it was sent
+}
+
+type journalCheckpoint struct {
+    queued uint64
+    seen   map[string]int
+    since  time.Time
+}
+
+// Messages queued for ingest so far, which numbers them
+var queuedMessages atomic.Uint64
+
+var ingestProgress = &ingestTracker{inflight: map[uint64]*inflightMessage{}, saved: map[string]FeedOffset{}}
+
+// Name the source messages are coming from, once when its consumer starts
+func (t *ingestTracker) consuming(topic string) {
+    t.mu.Lock()
+    t.topic = topic
+    t.mu.Unlock()
+}
+
+// A message was handed to the apply workers as updates updates, or
+// dropped or applied in place if updates is 0
+func (t *ingestTracker) dispatch(seq uint64, sent time.Time, updates int) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if updates > 0 {
+        t.inflight[seq] = &inflightMessage{updates, t.lastSent}
+    }
+    t.dispatched = seq + 1
+    if !sent.IsZero() {
+        t.lastSent = sent
+    }
+}
+
+// An update from message seq has been applied
+func (t *ingestTracker) applied(seq uint64) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if m, ok := t.inflight[seq]; ok {
+        if m.updates--; m.updates == 0 {
+            delete(t.inflight, seq)
+        }
+    }
+}
+
+// The journal poller for topic had queued queued messages in all when it
+// reached this position. A STOMP consumer catching up from the journal
+// doesn't resume from it next time, so isn't recorded.
+func (t *ingestTracker) journalAt(topic string, queued uint64, seen map[string]int, since time.Time) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if topic == t.topic {
+        t.checkpoints = append(t.checkpoints, journalCheckpoint{queued, maps.Clone(seen), since})
+    }
+}
+
+// The offset up to which everything has been processed, if ingest has
+// started
+func (t *ingestTracker) offset() (FeedOffset, bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.topic == "" {
+        return FeedOffset{}, false
+    }
+    done, sent := t.dispatched, t.lastSent
+    if len(t.inflight) > 0 {
+        done = slices.Min(slices.Collect(maps.Keys(t.inflight)))
+        sent = t.inflight[done].before
+    }
+    off := FeedOffset{Topic: t.topic, Sent: sent}
+    // The newest journal position with all its messages processed. Older
+    // ones aren't needed again.
+    n := 0
+    for i, c := range t.checkpoints {
+        if c.queued > done {
+            break
+        }
+        off.Journal, off.Since, n = c.seen, c.since, i
+    }
+    t.checkpoints = t.checkpoints[n:]
+    if prev, ok := t.saved[t.topic]; ok && off.Journal == nil {
+        // Nothing processed yet since resuming
+        off.Journal, off.Since = prev.Journal, prev.Since
+        if off.Sent.IsZero() {
+            off.Sent = prev.Sent
+        }
+    }
+    return off, true
+}
+
+func (t *ingestTracker) snapshotOffsets() map[string]FeedOffset {
+    offsets := map[string]FeedOffset{}
+    t.mu.Lock()
+    maps.Copy(offsets, t.saved)
+    t.mu.Unlock()
+    if off, ok := t.offset(); ok {
+        offsets[off.Topic] = off
+    }
+    return offsets
+}
+
+func (t *ingestTracker) restore(offsets map[string]FeedOffset) {
+    t.mu.Lock()
+    maps.Copy(t.saved, offsets)
+    t.mu.Unlock()
+    for _, off := range offsets {
+        log.Printf("Saved Push Port offset for %s: last message sent %s", off.Topic, off.Sent.Format(time.RFC3339))
+    }
+}
+
+// The offset saved for a topic by the last run, if there was one
+func (t *ingestTracker) savedOffset(topic string) (FeedOffset, bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    off, ok := t.saved[topic]
+    return off, ok
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+    "html/template"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Train operating companies, keyed by their two-letter Darwin code
+type Operator struct {
+    TOC    string
+    Name   string
+    Colour string
+}
+
+var (
+    operators   = map[string]Operator{}
+    operatorsMu sync.RWMutex
+)
+
+// Brand colours for the operators Darwin reports. A theme can override
+// any of these by setting a "toc-XX" variable in THEME_FILE.
+var OperatorColours = map[string]string{
+    "AW": "#ff0000", // Transport for Wales
+    "C2": "#b7007c", // c2c
+    "CH": "#00bfff", // Chiltern Railways
+    "CS": "#1d2e35", // Caledonian Sleeper
+    "EM": "#713563", // East Midlands Railway
+    "GC": "#1d1d1b", // Grand Central
+    "GN": "#0099ff", // Great Northern
+    "GR": "#ce0e2d", // LNER
+    "GW": "#0a493e", // Great Western Railway
+    "GX": "#eb1e2d", // Gatwick Express
+    "HT": "#de005c", // Hull Trains
+    "HX": "#532e63", // Heathrow Express
+    "IL": "#1e90ff", // Island Line
+    "LD": "#2b6ef5", // Lumo
+    "LE": "#d70428", // Greater Anglia
+    "LM": "#ff8300", // West Midlands Railway
+    "LN": "#00bf6f", // London Northwestern Railway
+    "LO": "#ee7c0e", // London Overground
+    "ME": "#fff200", // Merseyrail
+    "NT": "#262262", // Northern
+    "SE": "#389cff", // Southeastern
+    "SN": "#8cc63e", // Southern
+    "SR": "#1c4074", // ScotRail
+    "SW": "#24398c", // South Western Railway
+    "TL": "#e9438d", // Thameslink
+    "TP": "#09a4ec", // TransPennine Express
+    "VT": "#004354", // Avanti West Coast
+    "XC": "#660f21", // CrossCountry
+    "XR": "#6950a1", // Elizabeth line
+}
+
+// Register an operator from reference data, filling in its brand colour
+func addOperator(o Operator) {
+    if o.Colour == "" {
+        o.Colour = OperatorColours[o.TOC]
+    }
+    operatorsMu.Lock()
+    operators[o.TOC] = o
+    operatorsMu.Unlock()
+}
+
+// Name of an operator, or its code if reference data hasn't named it
+func operatorName(toc string) string {
+    operatorsMu.RLock()
+    o, ok := operators[toc]
+    operatorsMu.RUnlock()
+    if !ok || o.Name == "" {
+        return toc
+    }
+    return o.Name
+}
+
+// Theme variables for every operator with a colour, so themes can be
+// layered over them
+func operatorThemeVars() Theme {
+    vars := Theme{}
+    for toc, colour := range OperatorColours {
+        vars["toc-"+toc] = colour
+    }
+    operatorsMu.RLock()
+    for toc, o := range operators {
+        if o.Colour != "" {
+            vars["toc-"+toc] = o.Colour
+        }
+    }
+    operatorsMu.RUnlock()
+    return vars
+}
+
+// CSS rules mapping each operator's class onto its colour variable
+func operatorCSS(vars Theme) string {
+    var tocs []string
+    for k := range vars {
+        if toc, ok := strings.CutPrefix(k, "toc-"); ok {
+            tocs = append(tocs, toc)
+        }
+    }
+    sort.Strings(tocs)
+    var b strings.Builder
+    for _, toc := range tocs {
+        b.WriteString(".toc-" + toc + " { --toc: var(--toc-" + toc + "); }\n")
+    }
+    return b.String()
+}
+
+// Operator name in its brand colour
+func operatorBadge(toc string) template.HTML {
+    if toc == "" {
+        return ""
+    }
+    return htmlFragment(operatorBadgeTmpl, struct{ TOC, Name string }{toc, operatorName(toc)})
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Alerts and digests are written to an outbox in the archive database
+// and delivered from there, so a mail server outage delays them instead
+// of dropping them. A failed delivery is retried with backoff, doubling
+// from a minute up to an hour, and after OUTBOX_MAX_ATTEMPTS (default 8)
+// the message is dead-lettered: kept for /admin/outbox, where it can be
+// sent again. Without the archive, notifications go straight out.
+//
+// Several processes can share the archive, so each claims a message for
+// outboxLease before sending it, and the others leave it alone until it's
+// sent, rescheduled or the lease runs out.
+const outboxTableSQL = `
+    CREATE TABLE IF NOT EXISTS outbox (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        recipient TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        body TEXT NOT NULL,
+        train TEXT NOT NULL,
+        delay INTEGER NOT NULL,
+        cancelled BOOLEAN NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        next_attempt DATETIME NOT NULL,
+        last_error TEXT NOT NULL DEFAULT '',
+        dead BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at DATETIME NOT NULL,
+        claimed_by TEXT,
+        claimed_until DATETIME
+    );
+    CREATE INDEX IF NOT EXISTS outbox_next_attempt ON outbox (dead, next_attempt);`
+
+const (
+    outboxBatch      = 20
+    outboxMinBackoff = time.Minute
+    outboxMaxBackoff = time.Hour
+    outboxLease      = 5 * time.Minute
+)
+
+// Who this process is when claiming messages
+var outboxInstance = func() string {
+    host, _ := os.Hostname()
+    return host + "-" + randomHex(4)
+}()
+
+// The claim columns were added after the table; older archives won't
+// have them yet
+func migrateOutbox(db *sql.DB) error {
+    for _, col := range []string{"claimed_by TEXT", "claimed_until DATETIME"} {
+        if _, err := db.Exec(`ALTER TABLE outbox ADD COLUMN ` + col); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+            return err
+        }
+    }
+    return nil
+}
+
+// A notification waiting in the outbox
+type OutboxEntry struct {
+    ID          int64     `json:"id"`
+    To          string    `json:"to"`
+    Subject     string    `json:"subject"`
+    Train       string    `json:"train,omitempty"`
+    Attempts    int       `json:"attempts"`
+    NextAttempt time.Time `json:"next_attempt"`
+    LastError   string    `json:"last_error,omitempty"`
+    Dead        bool      `json:"dead"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// Queues notifications for the channel behind it
+type outboxNotifier struct {
+    db *sql.DB
+
+    mu     sync.Mutex
+    sender Notifier
+    wake   chan struct{}
+}
+
+var outbox *outboxNotifier
+
+// Put the outbox in front of sender, or hand sender back if there's no
+// archive to queue in. Called again on a config reload with the new
+// sender, which takes over the messages already queued.
+func queueNotifications(sender Notifier) Notifier {
+    if archiveDB == nil {
+        return sender
+    }
+    if outbox == nil {
+        outbox = &outboxNotifier{db: archiveDB, wake: make(chan struct{}, 1)}
+        go outbox.deliver()
+    }
+    outbox.mu.Lock()
+    outbox.sender = sender
+    outbox.mu.Unlock()
+    return outbox
+}
+
+func (o *outboxNotifier) Notify(n Notification) error {
+    now := clock.Now().UTC()
+    _, err := o.db.Exec(`INSERT INTO outbox (recipient, subject, body, train, delay, cancelled, next_attempt, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+        n.To, n.Subject, n.Body, n.Train, n.Delay, n.Cancelled, now, now)
+    if err != nil {
+        return fmt.Errorf("queue notification: %w", err)
+    }
+    o.kick()
+    return nil
+}
+
+func (o *outboxNotifier) kick() {
+    select {
+    case o.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Send whatever's due, then wait for the next message or retry
+func (o *outboxNotifier) deliver() {
+    for {
+        if err := o.sendDue(); err != nil {
+            log.Printf("Failed to read the outbox: %v", err)
+        }
+        select {
+        case <-o.wake:
+        case <-clock.After(30 * time.Second):
+        }
+    }
+}
+
+func (o *outboxNotifier) sendDue() error {
+    for {
+        now := clock.Now().UTC()
+        rows, err := o.db.Query(`SELECT id, recipient, subject, body, train, delay, cancelled, attempts FROM outbox
+            WHERE NOT dead AND next_attempt <= ? AND (claimed_until IS NULL OR claimed_until < ?)
+            ORDER BY next_attempt LIMIT ?`, now, now, outboxBatch)
+        if err != nil {
+            return err
+        }
+        type due struct {
+            id       int64
+            n        Notification
+            attempts int
+        }
+        var batch []due
+        for rows.Next() {
+            var d due
+            if err := rows.Scan(&d.id, &d.n.To, &d.n.Subject, &d.n.Body, &d.n.Train, &d.n.Delay, &d.n.Cancelled, &d.attempts); err != nil {
+                rows.Close()
+                return err
+            }
+            batch = append(batch, d)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return err
+        }
+        o.mu.Lock()
+        sender := o.sender
+        o.mu.Unlock()
+        for _, d := range batch {
+            if o.claim(d.id) {
+                o.attempt(sender, d.id, d.n, d.attempts+1)
+            }
+        }
+        if len(batch) < outboxBatch {
+            return nil
+        }
+    }
+}
+
+// Take a message for this process to send, unless another has it
+func (o *outboxNotifier) claim(id int64) bool {
+    now := clock.Now().UTC()
+    res, err := o.db.Exec(`UPDATE outbox SET claimed_by = ?, claimed_until = ?
+        WHERE id = ? AND NOT dead AND (claimed_until IS NULL OR claimed_until < ?)`, outboxInstance, now.Add(outboxLease), id, now)
+    if err != nil {
+        log.Printf("Failed to claim notification %d: %v", id, err)
+        return false
+    }
+    n, _ := res.RowsAffected()
+    return n == 1
+}
+
+// Try one delivery, and either clear it from the outbox or schedule the
+// next try, giving up the claim
+func (o *outboxNotifier) attempt(sender Notifier, id int64, n Notification, attempts int) {
+    sendErr := sender.Notify(n)
+    var err error
+    switch {
+    case sendErr == nil:
+        _, err = o.db.Exec(`DELETE FROM outbox WHERE id = ?`, id)
+    case attempts >= max(1, envInt("OUTBOX_MAX_ATTEMPTS", 8)):
+        log.Printf("Giving up on notification %d to %s after %d attempts: %v", id, n.To, attempts, sendErr)
+        _, err = o.db.Exec(`UPDATE outbox SET attempts = ?, last_error = ?, dead = TRUE, claimed_by = NULL, claimed_until = NULL WHERE id = ?`, attempts, sendErr.Error(), id)
+    default:
+        backoff := min(outboxMaxBackoff, outboxMinBackoff<<min(attempts-1, 20))
+        log.Printf("Failed to send notification %d to %s (attempt %d), retrying in %s: %v", id, n.To, attempts, backoff, sendErr)
+        _, err = o.db.Exec(`UPDATE outbox SET attempts = ?, last_error = ?, next_attempt = ?, claimed_by = NULL, claimed_until = NULL WHERE id = ?`,
+            attempts, sendErr.Error(), clock.Now().Add(backoff).UTC(), id)
+    }
+    if err != nil {
+        log.Printf("Failed to update notification %d in the outbox: %v", id, err)
+    }
+}
+
+func listOutbox(db *sql.DB) ([]OutboxEntry, error) {
+    rows, err := db.Query(`SELECT id, recipient, subject, train, attempts, next_attempt, last_error, dead, created_at FROM outbox ORDER BY dead DESC, next_attempt`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var out []OutboxEntry
+    for rows.Next() {
+        var e OutboxEntry
+        if err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Train, &e.Attempts, &e.NextAttempt, &e.LastError, &e.Dead, &e.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, e)
+    }
+    return out, rows.Err()
+}
+
+var outboxTmpl = template.Must(template.New("outbox").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Outbound notifications</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>Outbound notifications</h1>
+    {{if .Error}}<p class="late">{{.Error}}</p>{{end}}
+    <table>
+        <tr><th>Queued</th><th>To</th><th>Subject</th><th>Attempts</th><th>Next attempt</th><th>Last error</th><th></th></tr>
+        {{range .Entries}}
+        <tr{{if .Dead}} class="cancelled"{{end}}>
+            <td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td>
+            <td>{{.To}}</td>
+            <td>{{.Subject}}</td>
+            <td>{{.Attempts}}</td>
+            <td>{{if .Dead}}Dead-lettered{{else}}{{.NextAttempt.Format "15:04:05"}}{{end}}</td>
+            <td>{{.LastError}}</td>
+            <td>{{if .Dead}}<form method="post" action="/admin/outbox/{{.ID}}/retry"><input type="hidden" name="csrf" value="{{$.CSRF}}"><button type="submit">Retry</button></form>{{end}}</td>
+        </tr>
+        {{else}}
+        <tr><td colspan="7">Nothing waiting to be sent</td></tr>
+        {{end}}
+    </table>
+</body>
+</html>
+`))
+
+// GET /admin/outbox: what's waiting to be sent and what's been given up on
+func outboxHandler(w http.ResponseWriter, r *http.Request) {
+    if archiveDB == nil {
+        http.Error(w, "notifications aren't queued without the archive", http.StatusNotFound)
+        return
+    }
+    entries, err := listOutbox(archiveDB)
+    if err != nil {
+        log.Printf("Failed to list the outbox: %v", err)
+    }
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            data := struct {
+                Theme   string
+                Entries []OutboxEntry
+                Error   error
+                CSRF    string
+            }{requestTheme(w, r), entries, err, csrfToken(r)}
+            executeTemplate(w, r, outboxTmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                Entries []OutboxEntry `json:"entries"`
+            }{entries}
+        },
+        Text: func(w io.Writer) {
+            var rows [][]string
+            for _, e := range entries {
+                next := e.NextAttempt.Format("15:04:05")
+                if e.Dead {
+                    next = "dead"
+                }
+                rows = append(rows, []string{strconv.FormatInt(e.ID, 10), e.To, e.Subject, strconv.Itoa(e.Attempts), next, e.LastError})
+            }
+            writeTextTable(w, []string{"ID", "To", "Subject", "Attempts", "Next", "Last error"}, rows)
+        },
+    })
+}
+
+// POST /admin/outbox/{id}/retry: send a dead-lettered notification again
+func retryOutboxHandler(w http.ResponseWriter, r *http.Request) {
+    if archiveDB == nil {
+        http.Error(w, "notifications aren't queued without the archive", http.StatusNotFound)
+        return
+    }
+    res, err := archiveDB.Exec(`UPDATE outbox SET dead = FALSE, attempts = 0, next_attempt = ?, claimed_by = NULL, claimed_until = NULL WHERE id = ? AND dead`, clock.Now().UTC(), r.PathValue("id"))
+    if err != nil {
+        log.Printf("Failed to retry notification %s: %v", r.PathValue("id"), err)
+        http.Error(w, "failed to retry notification", http.StatusInternalServerError)
+        return
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        http.Error(w, "no such dead-lettered notification", http.StatusNotFound)
+        return
+    }
+    if outbox != nil {
+        outbox.kick()
+    }
+    http.Redirect(w, r, "/admin/outbox", http.StatusSeeOther)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+    "context"
+    "io"
+    "log"
+    "sync/atomic"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// The day's timetable is hundreds of megabytes of XML and can take
+// minutes to parse. Progress through it is logged every 15 seconds,
+// exported as metrics and shown on /admin/snapshots, so a slow parse can
+// be told from a hung one. Bytes are counted as downloaded, before
+// ungzipping, so they compare with the size of the file.
+type timetableParse struct {
+    source   string
+    started  time.Time
+    total    atomic.Int64 // 0 if the size isn't known
+    read     atomic.Int64
+    journeys atomic.Int64
+    finished atomic.Pointer[time.Time]
+    failure  atomic.Pointer[string] // why it failed, if it did
+}
+
+// The parse under way, or the last one. timetableLoading stops two
+// overlapping.
+var currentParse atomic.Pointer[timetableParse]
+
+type ParseProgress struct {
+    Source     string     `json:"source"`
+    Started    time.Time  `json:"started"`
+    Finished   *time.Time `json:"finished,omitempty"`
+    BytesRead  int64      `json:"bytes_read"`
+    BytesTotal int64      `json:"bytes_total,omitempty"`
+    Journeys   int64      `json:"journeys"`
+    Error      string     `json:"error,omitempty"`
+    // Estimated from the rate so far, once there is one
+    ETA *time.Time `json:"eta,omitempty"`
+}
+
+func beginParse(source string) *timetableParse {
+    p := &timetableParse{source: source, started: time.Now()}
+    currentParse.Store(p)
+    go func() {
+        for range time.Tick(15 * time.Second) {
+            if p.finished.Load() != nil || currentParse.Load() != p {
+                return
+            }
+            logParseProgress(p.progress())
+        }
+    }()
+    return p
+}
+
+type parseKey struct{}
+
+// A context whose S3 downloads count towards p
+func withParse(ctx context.Context, p *timetableParse) context.Context {
+    return context.WithValue(ctx, parseKey{}, p)
+}
+
+func parseFrom(ctx context.Context) *timetableParse {
+    p, _ := ctx.Value(parseKey{}).(*timetableParse)
+    return p
+}
+
+// End the parse, logging how it went, with the error that stopped it if
+// there was one
+func (p *timetableParse) finish(err error) {
+    now := time.Now()
+    if err != nil {
+        msg := err.Error()
+        p.failure.Store(&msg)
+    }
+    p.finished.Store(&now)
+    if err != nil {
+        log.Printf("Failed to load timetable from %s after %d journeys in %s: %v", p.source, p.journeys.Load(), now.Sub(p.started).Round(time.Second), err)
+        return
+    }
+    log.Printf("Parsed %d journeys from %s in %s", p.journeys.Load(), p.source, now.Sub(p.started).Round(time.Second))
+}
+
+// Count what's read through r towards the parse, whose file is total
+// bytes long (0 if unknown)
+func (p *timetableParse) counting(r io.Reader, total int64) io.Reader {
+    if p == nil {
+        return r
+    }
+    p.total.Store(max(total, 0))
+    return parseCounter{r, p}
+}
+
+type parseCounter struct {
+    r io.Reader
+    p *timetableParse
+}
+
+func (c parseCounter) Read(b []byte) (int, error) {
+    n, err := c.r.Read(b)
+    c.p.read.Add(int64(n))
+    return n, err
+}
+
+func (p *timetableParse) progress() ParseProgress {
+    pp := ParseProgress{
+        Source:     p.source,
+        Started:    p.started,
+        Finished:   p.finished.Load(),
+        BytesRead:  p.read.Load(),
+        BytesTotal: p.total.Load(),
+        Journeys:   p.journeys.Load(),
+    }
+    if msg := p.failure.Load(); msg != nil {
+        pp.Error = *msg
+    }
+    if pp.Finished == nil && pp.BytesTotal > 0 && pp.BytesRead > 0 {
+        elapsed := time.Since(p.started)
+        left := time.Duration(float64(elapsed) * float64(pp.BytesTotal-pp.BytesRead) / float64(pp.BytesRead))
+        eta := time.Now().Add(left)
+        pp.ETA = &eta
+    }
+    return pp
+}
+
+func logParseProgress(pp ParseProgress) {
+    if pp.BytesTotal == 0 {
+        log.Printf("Parsing %s: %d journeys, %d MB read", pp.Source, pp.Journeys, pp.BytesRead>>20)
+        return
+    }
+    eta := "unknown"
+    if pp.ETA != nil {
+        eta = time.Until(*pp.ETA).Round(time.Second).String()
+    }
+    log.Printf("Parsing %s: %d journeys, %d of %d MB read (%d%%), about %s to go",
+        pp.Source, pp.Journeys, pp.BytesRead>>20, pp.BytesTotal>>20, pp.BytesRead*100/pp.BytesTotal, eta)
+}
+
+// The current or last parse, if there's been one
+func parseProgress() *ParseProgress {
+    p := currentParse.Load()
+    if p == nil {
+        return nil
+    }
+    pp := p.progress()
+    return &pp
+}
+
+func parseGauge(name, help string, value func(p *timetableParse) int64) prometheus.GaugeFunc {
+    return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+        if p := currentParse.Load(); p != nil {
+            return float64(value(p))
+        }
+        return 0
+    })
+}
+
+var parseMetrics = []prometheus.Collector{
+    parseGauge("minimaltrains_timetable_parse_bytes_read", "Bytes of the timetable file read by the current or last parse.",
+        func(p *timetableParse) int64 { return p.read.Load() }),
+    parseGauge("minimaltrains_timetable_parse_bytes_total", "Size of the timetable file being parsed, 0 if unknown.",
+        func(p *timetableParse) int64 { return p.total.Load() }),
+    parseGauge("minimaltrains_timetable_parse_journeys", "Journeys parsed so far by the current or last parse.",
+        func(p *timetableParse) int64 { return p.journeys.Load() }),
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+    "context"
+    "expvar"
+    "log"
+    "runtime"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// Push Port messages go through three stages joined by bounded channels.
+// Decode workers ungzip and parse them; a dispatcher puts them back in
+// arrival order and hands each update to the apply worker its RID hashes
+// to, so one train's updates apply in order while different trains apply
+// in parallel; notify workers check updated trains against the alert
+// rules. A full channel holds up the stage before it, and ultimately
+// ingestQueue, whose depth drives load shedding.
+//
+// Worker counts come from INGEST_DECODE_WORKERS (default one per CPU),
+// INGEST_APPLY_WORKERS (4) and INGEST_NOTIFY_WORKERS (2), and each
+// channel holds INGEST_STAGE_QUEUE_SIZE (1000) items.
+
+type rawMessage struct {
+    seq  uint64
+    body []byte
+}
+
+type decodedMessage struct {
+    seq uint64
+    ctx context.Context
+    msg DarwinPport
+    ok  bool
+}
+
+// One schedule or TS from a message, for an apply worker
+type applyItem struct {
+    seq      uint64
+    ctx      context.Context
+    sent     time.Time
+    schedule *DarwinSchedule
+    ts       *DarwinTS
+}
+
+// Trains whose progress changed, waiting for their alert rules to be
+// checked. Nil until the pipeline starts.
+var notifyQueue chan TrainProgress
+
+// Alert checks dropped because the notify stage was full. Train updates
+// aren't held up for alerts.
+var droppedAlertChecks = expvar.NewInt("darwin_dropped_alert_checks")
+
+func startIngestPipeline() {
+    decoders := max(1, envInt("INGEST_DECODE_WORKERS", runtime.NumCPU()))
+    appliers := max(1, envInt("INGEST_APPLY_WORKERS", 4))
+    notifiers := max(1, envInt("INGEST_NOTIFY_WORKERS", 2))
+    size := max(1, envInt("INGEST_STAGE_QUEUE_SIZE", 1000))
+    log.Printf("Ingest pipeline: %d decode, %d apply and %d notify workers", decoders, appliers, notifiers)
+
+    notifyQueue = make(chan TrainProgress, size)
+    for range notifiers {
+        go notifyWorker(notifyQueue)
+    }
+    raw := make(chan rawMessage, size)
+    decoded := make(chan decodedMessage, size)
+    apply := make([]chan applyItem, appliers)
+    for i := range apply {
+        apply[i] = make(chan applyItem, size)
+        go applyWorker(apply[i])
+    }
+    for range decoders {
+        go decodeWorker(raw, decoded)
+    }
+    go dispatchDecoded(decoded, apply)
+
+    var seq uint64
+    for body := range ingestQueue {
+        updateShedding()
+        raw <- rawMessage{seq, body}
+        seq++
+    }
+}
+
+func decodeWorker(in <-chan rawMessage, out chan<- decodedMessage) {
+    for m := range in {
+        ctx, span := tracer.Start(context.Background(), "darwin.message", trace.WithAttributes(attribute.Int("messaging.message.body.size", len(m.body))))
+        _, decode := tracer.Start(ctx, "darwin.decode")
+        msg, err := decodeDarwinMessage(m.body)
+        endSpan(decode, err)
+        noteIngestMessage(err == nil)
+        if err != nil {
+            log.Printf("Failed to decode Darwin message: %v", err)
+        }
+        span.SetAttributes(
+            attribute.Int("darwin.schedules", len(msg.Schedule)),
+            attribute.Int("darwin.ts", len(msg.TS)),
+            attribute.Int("darwin.station_messages", len(msg.OW)),
+        )
+        span.End()
+        out <- decodedMessage{m.seq, ctx, msg, err == nil}
+    }
+}
+
+// Take decoded messages back into the order they arrived in, then route
+// their updates. Station messages are cheap and not per train, so they're
+// applied here.
+func dispatchDecoded(in <-chan decodedMessage, apply []chan applyItem) {
+    pending := map[uint64]decodedMessage{}
+    var next uint64
+    for m := range in {
+        pending[m.seq] = m
+        for {
+            m, ok := pending[next]
+            if !ok {
+                break
+            }
+            delete(pending, next)
+            next++
+            if !m.ok {
+                ingestProgress.dispatch(m.seq, time.Time{}, 0)
+                continue
+            }
+            sent, _ := time.Parse(time.RFC3339Nano, m.msg.Ts)
+            if len(m.msg.TS) > 0 {
+                noteTSUpdate()
+            }
+            var items []applyItem
+            for i := range m.msg.Schedule {
+                items = append(items, applyItem{seq: m.seq, ctx: m.ctx, sent: sent, schedule: &m.msg.Schedule[i]})
+            }
+            for i := range m.msg.TS {
+                ts := &m.msg.TS[i]
+                if shedding.Load() && !tsWanted(*ts) {
+                    shedCount.Add(1)
+                    continue
+                }
+                items = append(items, applyItem{seq: m.seq, ctx: m.ctx, sent: sent, ts: ts})
+            }
+            for _, ow := range m.msg.OW {
+                applyStationMessage(ow)
+            }
+            // Counted before any can be applied
+            ingestProgress.dispatch(m.seq, sent, len(items))
+            for _, item := range items {
+                apply[shardIndex(item.rid(), len(apply))] <- item
+            }
+        }
+    }
+}
+
+func (item applyItem) rid() string {
+    if item.schedule != nil {
+        return item.schedule.RID
+    }
+    return item.ts.RID
+}
+
+func applyWorker(in <-chan applyItem) {
+    for item := range in {
+        switch {
+        case item.schedule != nil:
+            s := *item.schedule
+            _, span := tracer.Start(item.ctx, "darwin.apply_schedule", trace.WithAttributes(attribute.String("darwin.rid", s.RID)))
+            start := time.Now()
+            if freshUpdate("schedule", s.RID, item.sent, s) {
+                applySchedule(s)
+            }
+            observeCall("apply", "schedule", s.RID, start)
+            span.End()
+        case item.ts != nil:
+            ts := *item.ts
+            _, span := tracer.Start(item.ctx, "darwin.apply_ts", trace.WithAttributes(attribute.String("darwin.rid", ts.RID)))
+            start := time.Now()
+            if freshUpdate("TS", ts.RID, item.sent, ts) && applyTS(ts) == tsQuarantined {
+                span.SetAttributes(attribute.Bool("darwin.quarantined", true))
+            }
+            observeCall("apply", "TS", ts.RID, start)
+            span.End()
+        }
+        ingestProgress.applied(item.seq)
+    }
+}
+
+// Hand a train's new progress to the notify stage, unless nobody could be
+// alerted about it or the stage is full
+func queueAlertCheck(p TrainProgress) {
+    if alertNotifier == nil {
+        return
+    }
+    select {
+    case notifyQueue <- p:
+    default:
+        droppedAlertChecks.Add(1)
+    }
+}
+
+func notifyWorker(in <-chan TrainProgress) {
+    for p := range in {
+        checkAlerts(p)
+    }
+}
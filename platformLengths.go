@@ -0,0 +1,111 @@
+package main
+
+import (
+    "encoding/csv"
+    "io"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Platform lengths in coaches from PLATFORM_LENGTHS_FILE, a CSV of
+// code,platform,coaches where code is a TIPLOC or a CRS. A train whose
+// formation is longer than the platform it's using is flagged, so
+// passengers know to travel in the front coaches.
+var (
+    platformLengths   = map[string]int{}
+    platformLengthsMu sync.RWMutex
+)
+
+func initPlatformLengths() {
+    path := os.Getenv("PLATFORM_LENGTHS_FILE")
+    if path == "" {
+        return
+    }
+    n, err := loadPlatformLengths(path)
+    if err != nil {
+        log.Printf("Failed to load platform lengths from %s: %v", path, err)
+        return
+    }
+    log.Printf("Loaded lengths for %d platforms", n)
+}
+
+func platformLengthKey(code, platform string) string {
+    return strings.ToUpper(strings.TrimSpace(code)) + "/" + strings.ToUpper(strings.TrimSpace(platform))
+}
+
+func loadPlatformLengths(path string) (int, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+    r := csv.NewReader(f)
+    r.FieldsPerRecord = -1
+    lengths := map[string]int{}
+    for {
+        rec, err := r.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return 0, err
+        }
+        if len(rec) < 3 {
+            continue
+        }
+        coaches, err := strconv.Atoi(strings.TrimSpace(rec[2]))
+        if err != nil || coaches < 1 {
+            // Header row or junk
+            continue
+        }
+        lengths[platformLengthKey(rec[0], rec[1])] = coaches
+    }
+    platformLengthsMu.Lock()
+    platformLengths = lengths
+    platformLengthsMu.Unlock()
+    return len(lengths), nil
+}
+
+// Coaches a platform takes, by its TIPLOC, falling back to its station's CRS
+func platformLength(tiploc, platform string) (int, bool) {
+    platformLengthsMu.RLock()
+    defer platformLengthsMu.RUnlock()
+    if n, ok := platformLengths[platformLengthKey(tiploc, platform)]; ok {
+        return n, true
+    }
+    n, ok := platformLengths[platformLengthKey(stationKey(tiploc), platform)]
+    return n, ok
+}
+
+// How many coaches will be at the platform if the train is too long for
+// it, else 0
+func shortPlatformFits(s Stop) int {
+    if s.Length == 0 || s.Platform == "" || s.Status == "Cancelled" {
+        return 0
+    }
+    if n, ok := platformLength(s.Station, s.Platform); ok && s.Length > n {
+        return n
+    }
+    return 0
+}
+
+// A stop where the train is longer than the platform
+type ShortPlatform struct {
+    Station  string `json:"station"`
+    Platform string `json:"platform"`
+    Length   int    `json:"length"`
+    Fits     int    `json:"fits"`
+}
+
+func shortPlatforms(p TrainProgress) []ShortPlatform {
+    var out []ShortPlatform
+    for _, s := range p.Stops {
+        if n := shortPlatformFits(s); n > 0 {
+            out = append(out, ShortPlatform{s.Station, s.Platform, s.Length, n})
+        }
+    }
+    return out
+}
@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// How often the progress fragment refreshes itself
+const (
+    livePollInterval    = 10 * time.Second
+    waitingPollInterval = 5 * time.Minute
+    // Start live polling this long before the train is due to leave
+    departureLeadTime = 20 * time.Minute
+)
+
+// Refresh interval for a train's progress: quick while it's running,
+// slow while it's a while off departing, and none once it's arrived.
+// Pages with nothing to show poll slowly in case a schedule turns up.
+func pollInterval(p TrainProgress, now time.Time) time.Duration {
+    if len(p.Stops) == 0 {
+        return waitingPollInterval
+    }
+    if journeyFinished(p) {
+        return 0
+    }
+    local := now.In(ukLocation)
+    today := local.Format("2006-01-02")
+    if p.SSD > today {
+        return waitingPollInterval
+    }
+    first := p.Stops[0]
+    if p.SSD < today || first.Actual != "" {
+        return livePollInterval
+    }
+    until, ok := minutesLate(local.Format("15:04"), first.Scheduled)
+    if ok && time.Duration(until)*time.Minute > departureLeadTime {
+        return waitingPollInterval
+    }
+    return livePollInterval
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "strconv"
+    "strings"
+    "time"
+    _ "time/tzdata"
+)
+
+// Darwin times are UK local time
+var ukLocation, _ = time.LoadLocation("Europe/London")
+
+// Today's date in the UK, in Darwin's ssd format
+func ukToday() string {
+    return clock.Now().In(ukLocation).Format("2006-01-02")
+}
+
+// Parse a Darwin "HH:MM" or working "HH:MM:SS" time into minutes past midnight
+func parseRailTime(s string) (int, bool) {
+    parts := strings.Split(s, ":")
+    if len(parts) < 2 || len(parts) > 3 {
+        return 0, false
+    }
+    h, err := strconv.Atoi(parts[0])
+    if err != nil || h < 0 || h > 23 {
+        return 0, false
+    }
+    m, err := strconv.Atoi(parts[1])
+    if err != nil || m < 0 || m > 59 {
+        return 0, false
+    }
+    return h*60 + m, true
+}
+
+// Minutes between a scheduled and a later (or earlier) time, allowing for
+// services that run past midnight
+func minutesLate(scheduled, actual string) (int, bool) {
+    s, ok := parseRailTime(scheduled)
+    if !ok {
+        return 0, false
+    }
+    a, ok := parseRailTime(actual)
+    if !ok {
+        return 0, false
+    }
+    diff := a - s
+    if diff < -12*60 {
+        diff += 24 * 60
+    } else if diff > 12*60 {
+        diff -= 24 * 60
+    }
+    return diff, true
+}
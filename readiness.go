@@ -0,0 +1,165 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+// The web server starts listening before the timetable is in, so a slow
+// S3 download doesn't leave the site unreachable. Until the process is
+// ready, pages answer 503 with a "data loading" placeholder, /readyz
+// answers 503 and systemd isn't sent READY=1. Ready means a timetable
+// has loaded, the server is listening if this process runs one, and the
+// Push Port feed has delivered if it runs that. Pages stop showing the
+// placeholder once the first load has finished, even if it failed, but
+// the process isn't ready until a load succeeds.
+var (
+    timetableAttempted atomic.Bool
+    serverListening    atomic.Bool
+)
+
+// The components this process runs, from --role
+var runsIngest, runsWeb bool
+
+// Paths answered as normal while loading: health checks and the admin
+// endpoints that might be needed to get a stuck load going
+var loadingExempt = []string{"/healthz", "/readyz", "/version", "/theme.css", "/admin/", "/api/v1/admin/"}
+
+type Readiness struct {
+    Ready     bool `json:"ready"`
+    Timetable bool `json:"timetable"`
+    Listening bool `json:"listening"`
+    PushPort  bool `json:"push_port"`
+    // Why it isn't ready, if it isn't
+    Reasons []string `json:"reasons,omitempty"`
+}
+
+func feedDelivered(name string) bool {
+    feedHealthMu.RLock()
+    defer feedHealthMu.RUnlock()
+    return !feedLastMessage[name].IsZero()
+}
+
+func readiness(ingest, web bool) Readiness {
+    loadedVersionsMu.RLock()
+    loaded := !timetableLoadedAt.IsZero()
+    loadedVersionsMu.RUnlock()
+    r := Readiness{
+        Timetable: loaded,
+        Listening: !web || serverListening.Load(),
+        PushPort:  !ingest || feedDelivered("Darwin"),
+    }
+    switch {
+    case !r.Timetable && timetableAttempted.Load():
+        r.Reasons = append(r.Reasons, fmt.Sprintf("the timetable failed to load from %s", timetableSource))
+    case !r.Timetable:
+        r.Reasons = append(r.Reasons, "the timetable is loading")
+    }
+    if !r.Listening {
+        r.Reasons = append(r.Reasons, "the web server isn't listening yet")
+    }
+    if !r.PushPort {
+        r.Reasons = append(r.Reasons, "no Push Port message has arrived yet")
+    }
+    r.Ready = r.Timetable && r.Listening && r.PushPort
+    return r
+}
+
+// Tell systemd once the process is ready
+func announceReady(ingest, web bool) {
+    start := time.Now()
+    for !readiness(ingest, web).Ready {
+        time.Sleep(250 * time.Millisecond)
+    }
+    log.Printf("Ready after %s", time.Since(start).Round(time.Millisecond))
+    sdNotify("READY=1")
+}
+
+// GET /readyz: 200 once the process is ready, otherwise 503 with the
+// reasons it isn't
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+    ready := readiness(runsIngest, runsWeb)
+    if !ready.Ready {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    writeJSON(w, ready)
+}
+
+// A ResponseWriter that answers with code whatever the handler sets
+type statusWriter struct {
+    http.ResponseWriter
+    code  int
+    wrote bool
+}
+
+func (s *statusWriter) WriteHeader(int) {
+    if !s.wrote {
+        s.wrote = true
+        s.ResponseWriter.WriteHeader(s.code)
+    }
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+    s.WriteHeader(s.code)
+    return s.ResponseWriter.Write(b)
+}
+
+var loadingTmpl = template.Must(template.New("loading").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta http-equiv="refresh" content="5">
+    <title>{{T "title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{T "title"}}</h1>
+    <p>{{T "data_loading"}}</p>
+    <p>{{T "loading_refresh"}}</p>
+</body>
+</html>
+`))
+
+// Serve the placeholder in place of h until the timetable has loaded
+func whileLoading(h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if timetableAttempted.Load() {
+            h.ServeHTTP(w, r)
+            return
+        }
+        for _, p := range loadingExempt {
+            if r.URL.Path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(r.URL.Path, p)) {
+                h.ServeHTTP(w, r)
+                return
+            }
+        }
+        w.Header().Set("Retry-After", "5")
+        lang := requestLang(w, r)
+        render(&statusWriter{ResponseWriter: w, code: http.StatusServiceUnavailable}, r, rendering{
+            HTML: func(w http.ResponseWriter) error {
+                tmpl, err := localisedTemplate(loadingTmpl, lang)
+                if err != nil {
+                    return err
+                }
+                data := struct{ Lang, Theme string }{lang, requestTheme(w, r)}
+                w.Header().Set("Content-Type", "text/html; charset=utf-8")
+                executeTemplate(w, r, tmpl, data)
+                return nil
+            },
+            JSON: func() any {
+                return struct {
+                    Status string `json:"status"`
+                }{"loading"}
+            },
+            Text: func(w io.Writer) { fmt.Fprintln(w, translate(lang, "data_loading")) },
+        })
+    })
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+    "maps"
+    "strings"
+    "sync"
+)
+
+// The reason codes in delayReason.go are the ones known when it was
+// written. Darwin's reference data carries the current lists, so whenever
+// it's loaded with the timetable its codes are merged over them: new
+// codes get their text rather than showing blank, and changed wording
+// follows Darwin's. Codes only in the built-in lists are kept. The maps
+// are replaced whole, never changed in place, so readers take the current
+// one under reasonsMu.
+type darwinReason struct {
+    Code int    `xml:"code,attr"`
+    Text string `xml:"reasontext,attr"`
+}
+
+var reasonsMu sync.RWMutex
+
+func lateRunningReason(code int) string {
+    reasonsMu.RLock()
+    defer reasonsMu.RUnlock()
+    return LateRunningReasons[code]
+}
+
+func cancellationReason(code int) string {
+    reasonsMu.RLock()
+    defer reasonsMu.RUnlock()
+    return CancellationReasons[code]
+}
+
+// Merge reasons from the reference data in, returning how many codes
+// weren't known before
+func updateReasonCodes(late, cancelled []darwinReason) int {
+    merge := func(current map[int]string, reasons []darwinReason) (map[int]string, int) {
+        if len(reasons) == 0 {
+            return current, 0
+        }
+        next, added := maps.Clone(current), 0
+        for _, r := range reasons {
+            text := strings.TrimSpace(r.Text)
+            if r.Code == 0 || text == "" {
+                continue
+            }
+            if _, ok := next[r.Code]; !ok {
+                added++
+            }
+            next[r.Code] = text
+        }
+        return next, added
+    }
+    reasonsMu.Lock()
+    defer reasonsMu.Unlock()
+    var addedLate, addedCancelled int
+    LateRunningReasons, addedLate = merge(LateRunningReasons, late)
+    CancellationReasons, addedCancelled = merge(CancellationReasons, cancelled)
+    return addedLate + addedCancelled
+}
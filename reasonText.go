@@ -0,0 +1,142 @@
+package main
+
+import "strings"
+
+// Darwin's reasons are one fixed sentence per code, in English, and
+// carry where the cause was separately. These turn them into sentences
+// for the page's language, naming the place from the station reference
+// data.
+type DelayReason struct {
+    Code      int
+    Tiploc    string // where the cause was, if Darwin said
+    Near      bool   // near Tiploc rather than at it
+    Cancelled bool
+}
+
+// Causes put more plainly than Darwin's own text. Codes not here keep
+// Darwin's wording.
+var friendlyCauses = map[int]string{
+    108: "a shortage of train crew",
+    110: "a passenger who was taken ill earlier",
+    114: "a problem that is still being investigated",
+    117: "a fault with equipment beside the railway",
+    123: "someone trespassing on the railway",
+    134: "an earlier fault with equipment beside the railway",
+    137: "someone trespassing on the railway earlier",
+    141: "more passengers than usual",
+    142: "more passengers than usual earlier",
+    151: "earlier problems with the overhead electric wires",
+    152: "engineering works that overran earlier",
+}
+
+var reasonCausesCy = map[int]string{
+    100: "trên wedi torri i lawr",
+    101: "oedi ar daith flaenorol",
+    102: "trên wedi dod oddi ar y cledrau",
+    104: "tân mewn gorsaf",
+    105: "tân mewn gorsaf yn gynharach",
+    106: "tirlithriad",
+    107: "tân ger y rheilffordd",
+    108: "prinder criw trên",
+    109: "teithiwr yn sâl",
+    110: "teithiwr a aeth yn sâl yn gynharach",
+    111: "person wedi'i daro gan drên",
+    112: "person a gafodd ei daro gan drên yn gynharach",
+    113: "problem ar groesfan reilffordd",
+    114: "problem sy'n dal i gael ei hymchwilio",
+    115: "problem ger y rheilffordd",
+    116: "problem gyda phont dros afon",
+    117: "nam ar offer ger y rheilffordd",
+    118: "rhybudd diogelwch",
+    119: "trên a ddaeth oddi ar y cledrau yn gynharach",
+    120: "nam ar drên",
+    121: "trên yn hwyr yn gadael y depo",
+    122: "trên a oedd yn hwyr yn gadael y depo yn gynharach",
+    123: "rhywun yn tresmasu ar y rheilffordd",
+    124: "cerbyd yn taro pont",
+    125: "cerbyd a darodd bont yn gynharach",
+    126: "trên a dorrodd i lawr yn gynharach",
+    128: "tirlithriad cynharach",
+    129: "tân cynharach ger y rheilffordd",
+    130: "digwyddiad gweithredol cynharach",
+    131: "problem gynharach ar groesfan reilffordd",
+    132: "problem gynharach ger y rheilffordd",
+    133: "problem gynharach gyda phont dros afon",
+    134: "nam cynharach ar offer ger y rheilffordd",
+    135: "rhybudd diogelwch cynharach",
+    136: "nam cynharach ar drên",
+    137: "rhywun yn tresmasu ar y rheilffordd yn gynharach",
+    138: "rhwystr ar y lein",
+    139: "rhwystr ar y lein yn gynharach",
+    140: "digwyddiad gweithredol",
+    141: "mwy o deithwyr nag arfer",
+    142: "mwy o deithwyr nag arfer yn gynharach",
+    143: "anifeiliaid ar y lein",
+    144: "anifeiliaid ar y lein yn gynharach",
+    145: "tagfeydd oherwydd oedi cynharach",
+    146: "teithwyr aflonyddgar",
+    147: "teithwyr aflonyddgar yn gynharach",
+    148: "problemau cynharach gyda'r cyflenwad trydan",
+    149: "gwaith peirianneg brys cynharach",
+    150: "gweithredu diwydiannol cynharach",
+    151: "problemau cynharach gyda'r gwifrau trydan uwchben",
+    152: "gwaith peirianneg a aeth dros amser yn gynharach",
+}
+
+// The cause on its own, e.g. "a train fault", taken from Darwin's
+// sentence unless there's a plainer one
+func reasonCause(lang string, code int, cancelled bool) (string, bool) {
+    if lang == "cy" {
+        if c, ok := reasonCausesCy[code]; ok {
+            return c, true
+        }
+    }
+    if c, ok := friendlyCauses[code]; ok {
+        return c, true
+    }
+    text, prefix := lateRunningReason(code), "This train has been delayed by "
+    if cancelled {
+        text, prefix = cancellationReason(code), "This train has been cancelled because of "
+    }
+    if !strings.HasPrefix(text, prefix) {
+        return "", false
+    }
+    return strings.TrimPrefix(text, prefix), true
+}
+
+// The reason as a sentence in lang, e.g. "This train is delayed by a
+// train fault near Reading", or "" if there's no code or it's unknown
+func (d DelayReason) Text(lang string) string {
+    if d.Code == 0 {
+        return ""
+    }
+    cause, ok := reasonCause(lang, d.Code, d.Cancelled)
+    if !ok {
+        return ""
+    }
+    if _, welsh := reasonCausesCy[d.Code]; lang == "cy" && !welsh {
+        // A cause we've no Welsh for reads better as a whole English sentence
+        lang = defaultLang
+    }
+    if d.Tiploc != "" {
+        place := "reason_at"
+        if d.Near {
+            place = "reason_near"
+        }
+        cause += translate(lang, place, stationDisplayName(d.Tiploc, lang))
+    }
+    if d.Cancelled {
+        return translate(lang, "reason_cancelled", cause)
+    }
+    return translate(lang, "reason_delayed", cause)
+}
+
+// Darwin's latest late-running reason for a service
+func (p TrainProgress) LateCause() DelayReason {
+    return DelayReason{Code: p.LateReason, Tiploc: p.LateReasonAt, Near: p.LateReasonNear}
+}
+
+// Why a scheduled service is cancelled, when Darwin gave a reason
+func (j *Journey) CancelCause() DelayReason {
+    return DelayReason{Code: j.CancelReason, Tiploc: j.CancelReasonAt, Near: j.CancelReasonNear, Cancelled: true}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "log"
+    "os"
+    "strconv"
+)
+
+// How far apart Darwin and TRUST actuals can be before we flag it
+var trustDiscrepancyMins = 2
+
+func initReconcile() {
+    if v := os.Getenv("TRUST_DISCREPANCY_MINS"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            log.Printf("Ignoring invalid TRUST_DISCREPANCY_MINS %q: %v", v, err)
+            return
+        }
+        trustDiscrepancyMins = n
+    }
+}
+
+// Merge TRUST actuals into Darwin's view of a train. TRUST fills in
+// actuals Darwin hasn't reported yet, and stops where the two sources
+// disagree are flagged. Stops must not be shared with the store.
+func reconcileWithTrust(p *TrainProgress) {
+    for i := range p.Stops {
+        s := &p.Stops[i]
+        t, ok := trustActualFor(s.Station, s.Event)
+        if !ok {
+            continue
+        }
+        s.TrustActual = t
+        if s.Actual == "" {
+            s.Actual = t
+            s.Status = stopStatus(*s)
+            continue
+        }
+        if diff, ok := minutesLate(s.Actual, t); ok && (diff >= trustDiscrepancyMins || -diff >= trustDiscrepancyMins) {
+            s.Discrepancy = true
+        }
+    }
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "log"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Progress entries expire a day and a half after their last update
+const redisProgressTTL = 36 * time.Hour
+
+// Pub/sub channels fanning updates out from the ingester to web instances
+const (
+    redisProgressChannel = "minimaltrains:progress"
+    redisScheduleChannel = "minimaltrains:schedules"
+)
+
+// Web instances serve boards from a local copy of progress, dropped when
+// the ingester announces a change. The TTL bounds staleness if a
+// notification is missed while pub/sub reconnects.
+const redisLocalCacheTTL = 30 * time.Second
+
+type cachedProgress struct {
+    progress TrainProgress
+    at       time.Time
+}
+
+// Progress kept in Redis, so several processes can share it
+type redisStore struct {
+    client   *redis.Client
+    locks    ridLocks
+    instance string
+    cache    *shardedMap[cachedProgress]
+}
+
+// Live schedule change published by the ingester
+type scheduleMessage struct {
+    Instance string
+    Journey  *Journey
+}
+
+func newRedisStore(url string) (*redisStore, error) {
+    opts, err := redis.ParseURL(url)
+    if err != nil {
+        return nil, err
+    }
+    client := redis.NewClient(opts)
+    if err := client.Ping(context.Background()).Err(); err != nil {
+        client.Close()
+        return nil, err
+    }
+    id := make([]byte, 8)
+    rand.Read(id)
+    s := &redisStore{client: client, instance: hex.EncodeToString(id), cache: newShardedMap[cachedProgress]()}
+    go s.subscribe()
+    return s, nil
+}
+
+func redisProgressKey(rid string) string { return "progress:" + rid }
+
+func (s *redisStore) Get(rid string) (TrainProgress, bool, error) {
+    c, ok := s.cache.load(rid)
+    if ok && time.Since(c.at) < redisLocalCacheTTL {
+        return cloneProgress(c.progress), true, nil
+    }
+
+    p, ok, err := s.load(rid)
+    if err != nil || !ok {
+        return p, ok, err
+    }
+    s.cache.store(rid, cachedProgress{progress: cloneProgress(p), at: time.Now()})
+    return p, true, nil
+}
+
+func (s *redisStore) load(rid string) (TrainProgress, bool, error) {
+    data, err := s.client.Get(context.Background(), redisProgressKey(rid)).Bytes()
+    if err == redis.Nil {
+        return TrainProgress{}, false, nil
+    }
+    if err != nil {
+        return TrainProgress{}, false, err
+    }
+    var p TrainProgress
+    if err := json.Unmarshal(data, &p); err != nil {
+        return TrainProgress{}, false, err
+    }
+    return p, true, nil
+}
+
+func (s *redisStore) Put(p TrainProgress) error {
+    data, err := json.Marshal(p)
+    if err != nil {
+        return err
+    }
+    ctx := context.Background()
+    if err := s.client.Set(ctx, redisProgressKey(p.RID), data, progressTTL(p, redisProgressTTL)).Err(); err != nil {
+        return err
+    }
+    return s.client.Publish(ctx, redisProgressChannel, p.RID).Err()
+}
+
+// Reads go straight to Redis so we never build on a stale local copy
+func (s *redisStore) Update(rid string, fn func(p *TrainProgress)) error {
+    defer s.locks.lock(rid)()
+    p, _, err := s.load(rid)
+    if err != nil {
+        return err
+    }
+    fn(&p)
+    p.RID = rid
+    return s.Put(p)
+}
+
+// Share a live schedule change with the other instances
+func (s *redisStore) PublishSchedule(j *Journey) {
+    data, err := json.Marshal(scheduleMessage{Instance: s.instance, Journey: j})
+    if err != nil {
+        log.Printf("Failed to encode schedule %s: %v", j.RID, err)
+        return
+    }
+    if err := s.client.Publish(context.Background(), redisScheduleChannel, data).Err(); err != nil {
+        log.Printf("Failed to publish schedule %s: %v", j.RID, err)
+    }
+}
+
+func (s *redisStore) subscribe() {
+    sub := s.client.Subscribe(context.Background(), redisProgressChannel, redisScheduleChannel)
+    defer sub.Close()
+    for msg := range sub.Channel() {
+        switch msg.Channel {
+        case redisProgressChannel:
+            s.cache.delete(msg.Payload)
+        case redisScheduleChannel:
+            var m scheduleMessage
+            if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+                log.Printf("Failed to decode published schedule: %v", err)
+                continue
+            }
+            if m.Instance != s.instance && m.Journey != nil {
+                applyPublishedJourney(m.Journey)
+            }
+        }
+    }
+}
+
+func (s *redisStore) Close() error { return s.client.Close() }
@@ -0,0 +1,52 @@
+package main
+
+import "time"
+
+// Countdowns further away than this, either way, are left to the
+// absolute times
+const relativeTimeWindow = 90
+
+// Minutes from now until a stop's best-known time: its actual if it has
+// one, else its forecast, else its scheduled time. Negative once passed.
+func relativeMinutes(scheduled, expected, actual string, now time.Time) (int, bool) {
+    nowHHMM := now.In(ukLocation).Format("15:04")
+    for _, t := range []string{actual, expected, scheduled} {
+        if mins, ok := minutesLate(nowHHMM, t); ok {
+            return mins, true
+        }
+    }
+    return 0, false
+}
+
+// Countdown text such as "due in 4 min" or "left 2 min ago". Cancelled
+// stops get none, since there's nothing to count down to.
+func relativeTime(lang, event, scheduled, expected, actual, status string, now time.Time) string {
+    if status == "Cancelled" {
+        return ""
+    }
+    mins, ok := relativeMinutes(scheduled, expected, actual, now)
+    if !ok || mins > relativeTimeWindow || mins < -relativeTimeWindow {
+        return ""
+    }
+    if actual != "" {
+        ago := max(-mins, 0)
+        switch {
+        case event == "arr" && ago == 0:
+            return translate(lang, "rel_just_arrived")
+        case event == "arr":
+            return translate(lang, "rel_arrived_ago", ago)
+        case ago == 0:
+            return translate(lang, "rel_just_left")
+        default:
+            return translate(lang, "rel_left_ago", ago)
+        }
+    }
+    switch {
+    case mins > 0:
+        return translate(lang, "rel_due_in", mins)
+    case mins == 0:
+        return translate(lang, "rel_due_now")
+    default:
+        return translate(lang, "rel_overdue", -mins)
+    }
+}
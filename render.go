@@ -0,0 +1,157 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// The ways one resource can be written out. Handlers fill in the formats
+// they support and render picks one from the request.
+type rendering struct {
+    HTML func(w http.ResponseWriter) error
+    JSON func() any
+    Text func(w io.Writer)
+}
+
+// Media types we can serve, by the format name ?format= takes
+var renderFormats = map[string]string{
+    "html": "text/html",
+    "json": "application/json",
+    "text": "text/plain",
+}
+
+// Pick html, json or text for a request: ?format= wins, then the best
+// match in Accept. Clients that accept anything get HTML, except curl and
+// wget, which get a plain-text table.
+func negotiateFormat(r *http.Request) string {
+    if f := r.URL.Query().Get("format"); renderFormats[f] != "" {
+        return f
+    }
+    best, bestQ := "", 0.0
+    for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+        mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+        q := 1.0
+        if v, ok := params["q"]; ok {
+            if q, err = strconv.ParseFloat(v, 64); err != nil {
+                continue
+            }
+        }
+        for f, t := range renderFormats {
+            if mediaType == t && q > bestQ {
+                best, bestQ = f, q
+            }
+        }
+    }
+    if best != "" {
+        return best
+    }
+    ua := strings.ToLower(r.UserAgent())
+    if strings.HasPrefix(ua, "curl/") || strings.HasPrefix(ua, "wget/") {
+        return "text"
+    }
+    return "html"
+}
+
+// Write a resource in the negotiated format, falling back to HTML if the
+// handler doesn't offer it. JSON is the API by another URL, so it goes
+// through readAPI like /api/v1 does.
+func render(w http.ResponseWriter, r *http.Request, rd rendering) {
+    w.Header().Add("Vary", "Accept")
+    switch format := negotiateFormat(r); {
+    case format == "json" && rd.JSON != nil:
+        readAPI(func(w http.ResponseWriter, r *http.Request) {
+            writeJSON(w, rd.JSON())
+        })(w, r)
+        return
+    case format == "text" && rd.Text != nil:
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        rd.Text(w)
+        return
+    case rd.HTML == nil:
+        http.Error(w, "not acceptable", http.StatusNotAcceptable)
+        return
+    }
+    if err := rd.HTML(w); err != nil {
+        templateFailed(w, r, "page", err)
+    }
+}
+
+var templateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "minimaltrains_template_errors_total",
+    Help: "Pages whose template failed to render, by template.",
+}, []string{"template"})
+
+var errorFragmentTmpl = template.Must(template.New("error").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "title"}}</title>
+</head>
+<body>
+    <h1>{{T "title"}}</h1>
+    <p class="error">{{T "page_error"}}</p>
+    <p><a href="/">{{T "page_error_home"}}</a></p>
+</body>
+</html>
+`))
+
+// Execute a page template into a buffer first, so a template that fails
+// part way gives the error page rather than half a page with an error
+// tacked on the end
+func executeTemplate(w http.ResponseWriter, r *http.Request, t *template.Template, data any) {
+    var buf bytes.Buffer
+    if err := t.Execute(&buf, data); err != nil {
+        templateFailed(w, r, t.Name(), err)
+        return
+    }
+    if w.Header().Get("Content-Type") == "" {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    }
+    if _, err := buf.WriteTo(w); err != nil {
+        log.Printf("Failed to write %s page: %v", t.Name(), err)
+    }
+}
+
+// Log and count a page that couldn't be rendered, and tell the user
+// something went wrong without showing them the error
+func templateFailed(w http.ResponseWriter, r *http.Request, name string, err error) {
+    log.Printf("Failed to render %s for %s: %v", name, r.URL.Path, err)
+    templateErrors.WithLabelValues(name).Inc()
+    lang := requestLang(w, r)
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(http.StatusInternalServerError)
+    tmpl, err := localisedTemplate(errorFragmentTmpl, lang)
+    if err == nil {
+        err = tmpl.Execute(w, lang)
+    }
+    if err != nil {
+        log.Printf("Failed to render the error page: %v", err)
+        fmt.Fprintln(w, translate(lang, "page_error"))
+    }
+}
+
+// Align rows into columns for terminals
+func writeTextTable(w io.Writer, header []string, rows [][]string) {
+    tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, strings.Join(header, "\t"))
+    for _, row := range rows {
+        fmt.Fprintln(tw, strings.Join(row, "\t"))
+    }
+    if err := tw.Flush(); err != nil {
+        log.Printf("Failed to write text response: %v", err)
+    }
+}
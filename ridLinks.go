@@ -0,0 +1,203 @@
+package main
+
+import (
+    "log"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// When Darwin reissues a service mid-day (reinstatements, schedule
+// replacements) it can arrive under a new RID. We link the old RID to its
+// replacement so pages can follow the service without losing history.
+var (
+    ridSuccessor    = map[string]string{}
+    ridPredecessors = map[string][]string{}
+    ridLinksMu      sync.RWMutex
+)
+
+// RIDs in the journeys map by UID and day, so a reissued service finds
+// the schedules it replaces without a scan. Like the journeys map it's
+// guarded by journeysMu.
+type serviceKey struct{ uid, ssd string }
+
+var serviceRIDs = map[serviceKey][]string{}
+
+// Caller must hold journeysMu.
+func indexService(j *Journey) {
+    k := serviceKey{j.UID, j.SSD}
+    if !slices.Contains(serviceRIDs[k], j.RID) {
+        serviceRIDs[k] = append(serviceRIDs[k], j.RID)
+    }
+}
+
+// Caller must hold journeysMu.
+func unindexService(j *Journey) {
+    k := serviceKey{j.UID, j.SSD}
+    rids := slices.DeleteFunc(serviceRIDs[k], func(rid string) bool { return rid == j.RID })
+    if len(rids) == 0 {
+        delete(serviceRIDs, k)
+    } else {
+        serviceRIDs[k] = rids
+    }
+}
+
+// Rebuild the index from scratch. Caller must hold journeysMu.
+func rebuildServiceIndex() {
+    index := map[serviceKey][]string{}
+    for rid, j := range journeys {
+        k := serviceKey{j.UID, j.SSD}
+        index[k] = append(index[k], rid)
+    }
+    serviceRIDs = index
+}
+
+// Remove the schedules a new RID for the same UID and day supersedes,
+// returning the one it replaces. Darwin runs a service under one RID a
+// day, but a timetable converted from CIF can hold several variants, so
+// the one replaced is the one that was running: not already replaced
+// itself, with the strongest STP indicator, live before snapshot, then
+// the lowest RID so it's the same each time. The replacement supersedes
+// all of them, so none is left on boards beside it. Caller must hold
+// journeysMu.
+func supersedeService(j *Journey) *Journey {
+    var candidates []*Journey
+    for _, rid := range serviceRIDs[serviceKey{j.UID, j.SSD}] {
+        if old, ok := journeys[rid]; ok && rid != j.RID {
+            candidates = append(candidates, old)
+        }
+    }
+    if len(candidates) == 0 || j.UID == "" {
+        return nil
+    }
+    rank := func(c *Journey) int {
+        r := stpRank(c) * 2
+        if latestRID(c.RID) == c.RID {
+            r += 100
+        }
+        if c.Live {
+            r++
+        }
+        return r
+    }
+    replaced := slices.MinFunc(candidates, func(a, b *Journey) int {
+        if d := rank(b) - rank(a); d != 0 {
+            return d
+        }
+        return strings.Compare(a.RID, b.RID)
+    })
+    if len(candidates) > 1 {
+        log.Printf("RID %s supersedes %d schedules for %s on %s; linking it to %s", j.RID, len(candidates), j.UID, j.SSD, replaced.RID)
+    }
+    for _, c := range candidates {
+        removeJourney(c.RID)
+    }
+    return replaced
+}
+
+func linkRIDs(oldRID, newRID string) {
+    ridLinksMu.Lock()
+    if ridSuccessor[oldRID] == newRID {
+        ridLinksMu.Unlock()
+        return
+    }
+    ridSuccessor[oldRID] = newRID
+    ridPredecessors[newRID] = append(ridPredecessors[newRID], oldRID)
+    ridLinksMu.Unlock()
+    log.Printf("Linked RID %s to its replacement %s", oldRID, newRID)
+
+    if archiveDB != nil {
+        _, err := archiveDB.Exec(`INSERT OR REPLACE INTO rid_links (old_rid, new_rid, linked_at) VALUES (?, ?, ?)`, oldRID, newRID, clock.Now().UTC())
+        if err != nil {
+            log.Printf("Failed to archive RID link %s -> %s: %v", oldRID, newRID, err)
+        }
+    }
+}
+
+// Follow replacements to the current RID for a service
+func latestRID(rid string) string {
+    ridLinksMu.RLock()
+    defer ridLinksMu.RUnlock()
+    for i := 0; i < 10; i++ {
+        next, ok := ridSuccessor[rid]
+        if !ok {
+            break
+        }
+        rid = next
+    }
+    return rid
+}
+
+// Every RID a service ran under before this one, oldest first
+func previousRIDs(rid string) []string {
+    ridLinksMu.RLock()
+    defer ridLinksMu.RUnlock()
+    var out []string
+    seen := map[string]bool{rid: true}
+    queue := append([]string(nil), ridPredecessors[rid]...)
+    for len(queue) > 0 {
+        r := queue[0]
+        queue = queue[1:]
+        if seen[r] {
+            continue
+        }
+        seen[r] = true
+        out = append([]string{r}, out...)
+        queue = append(queue, ridPredecessors[r]...)
+    }
+    return out
+}
+
+// Reload links recorded by earlier runs
+func loadRIDLinks() {
+    if archiveDB == nil {
+        return
+    }
+    rows, err := archiveDB.Query(`SELECT old_rid, new_rid FROM rid_links WHERE linked_at > ?`, clock.Now().Add(-48*time.Hour).UTC())
+    if err != nil {
+        log.Printf("Failed to load RID links: %v", err)
+        return
+    }
+    defer rows.Close()
+    ridLinksMu.Lock()
+    defer ridLinksMu.Unlock()
+    for rows.Next() {
+        var oldRID, newRID string
+        if err := rows.Scan(&oldRID, &newRID); err != nil {
+            log.Printf("Failed to load RID links: %v", err)
+            return
+        }
+        ridSuccessor[oldRID] = newRID
+        ridPredecessors[newRID] = append(ridPredecessors[newRID], oldRID)
+    }
+}
+
+// Carry over stops the replacement schedule no longer covers, so the
+// part of the journey already run stays visible
+func carryOverHistory(p *TrainProgress, old TrainProgress) {
+    if len(old.Stops) == 0 {
+        return
+    }
+    var before []Stop
+    for _, s := range old.Stops {
+        if len(p.Stops) > 0 && s.Station == p.Stops[0].Station {
+            break
+        }
+        if s.Actual != "" || s.Status == "Cancelled" {
+            before = append(before, s)
+        }
+    }
+    p.Stops = append(before, p.Stops...)
+    visits := map[string]int{}
+    for i := range p.Stops {
+        visits[p.Stops[i].Station]++
+        o := nthVisit(old.Stops, p.Stops[i].Station, visits[p.Stops[i].Station])
+        if o != nil && p.Stops[i].Actual == "" {
+            p.Stops[i].Actual = o.Actual
+            if p.Stops[i].Expected == "" {
+                p.Stops[i].Expected = o.Expected
+            }
+        }
+    }
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "net/mail"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Who to alert about which trains. A rule watches a headcode, every train
+// calling at a station, or a headcode at a station.
+type AlertRule struct {
+    ID       string `json:"id"`
+    To       string `json:"to"`
+    Headcode string `json:"headcode,omitempty"`
+    CRS      string `json:"crs,omitempty"`
+    // Alert once the train is this many minutes late
+    MinDelay      int       `json:"min_delay"`
+    Cancellations bool      `json:"cancellations"`
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+const ruleTableSQL = `
+    CREATE TABLE IF NOT EXISTS alert_rules (
+        id TEXT PRIMARY KEY,
+        recipient TEXT NOT NULL,
+        headcode TEXT NOT NULL,
+        crs TEXT NOT NULL,
+        min_delay INTEGER NOT NULL,
+        cancellations BOOLEAN NOT NULL,
+        created_at DATETIME NOT NULL
+    );`
+
+// Whether a rule covers a train
+func (a AlertRule) matches(p TrainProgress) bool {
+    if a.Headcode != "" && a.Headcode != p.TrainID {
+        return false
+    }
+    if a.CRS == "" {
+        return true
+    }
+    for _, st := range p.Stops {
+        if stationKey(st.Station) == a.CRS {
+            return true
+        }
+    }
+    return false
+}
+
+// Tidy a rule from the API and check it can ever match
+func (a *AlertRule) normalise() error {
+    a.To = strings.TrimSpace(a.To)
+    a.Headcode = strings.ToUpper(strings.TrimSpace(a.Headcode))
+    a.CRS = strings.ToUpper(strings.TrimSpace(a.CRS))
+    if a.To == "" {
+        return errors.New("to is required")
+    }
+    // To ends up in an email header, so a line break could add headers
+    if strings.ContainsAny(a.To, "\r\n") {
+        return errors.New("to can't contain line breaks")
+    }
+    addrs, err := mail.ParseAddressList(a.To)
+    if err != nil {
+        return fmt.Errorf("to must be a list of email addresses: %v", err)
+    }
+    var to []string
+    for _, addr := range addrs {
+        to = append(to, addr.Address)
+    }
+    a.To = strings.Join(to, ",")
+    switch {
+    case a.Headcode == "" && a.CRS == "":
+        return errors.New("a rule needs a headcode, a crs or both")
+    case a.MinDelay < 0:
+        return errors.New("min_delay can't be negative")
+    }
+    return nil
+}
+
+func createRule(db *sql.DB, a AlertRule) (AlertRule, error) {
+    a.ID, a.CreatedAt = randomHex(4), time.Now().UTC()
+    _, err := db.Exec(`INSERT INTO alert_rules (id, recipient, headcode, crs, min_delay, cancellations, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+        a.ID, a.To, a.Headcode, a.CRS, a.MinDelay, a.Cancellations, a.CreatedAt)
+    return a, err
+}
+
+func listRules(db *sql.DB) ([]AlertRule, error) {
+    rows, err := db.Query(`SELECT id, recipient, headcode, crs, min_delay, cancellations, created_at FROM alert_rules ORDER BY created_at`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var rules []AlertRule
+    for rows.Next() {
+        var a AlertRule
+        if err := rows.Scan(&a.ID, &a.To, &a.Headcode, &a.CRS, &a.MinDelay, &a.Cancellations, &a.CreatedAt); err != nil {
+            return nil, err
+        }
+        rules = append(rules, a)
+    }
+    return rules, rows.Err()
+}
+
+func getRule(db *sql.DB, id string) (AlertRule, bool, error) {
+    var a AlertRule
+    err := db.QueryRow(`SELECT id, recipient, headcode, crs, min_delay, cancellations, created_at FROM alert_rules WHERE id = ?`, id).
+        Scan(&a.ID, &a.To, &a.Headcode, &a.CRS, &a.MinDelay, &a.Cancellations, &a.CreatedAt)
+    if errors.Is(err, sql.ErrNoRows) {
+        return a, false, nil
+    }
+    return a, err == nil, err
+}
+
+func updateRule(db *sql.DB, a AlertRule) (bool, error) {
+    res, err := db.Exec(`UPDATE alert_rules SET recipient = ?, headcode = ?, crs = ?, min_delay = ?, cancellations = ? WHERE id = ?`,
+        a.To, a.Headcode, a.CRS, a.MinDelay, a.Cancellations, a.ID)
+    if err != nil {
+        return false, err
+    }
+    n, _ := res.RowsAffected()
+    return n > 0, nil
+}
+
+func deleteRule(db *sql.DB, id string) (bool, error) {
+    res, err := db.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+    if err != nil {
+        return false, err
+    }
+    n, _ := res.RowsAffected()
+    return n > 0, nil
+}
+
+// Rules are checked on every TS message, so they're cached. The cache is
+// refreshed each minute so an ingester picks up rules changed through
+// another instance's API.
+var (
+    ruleCache   []AlertRule
+    ruleCacheAt time.Time
+    ruleCacheMu sync.Mutex
+)
+
+const ruleCacheTTL = time.Minute
+
+// Stored rules plus one for ALERT_TO and WATCHED_TRAINS, if set
+func currentAlertRules() []AlertRule {
+    ruleCacheMu.Lock()
+    defer ruleCacheMu.Unlock()
+    if time.Since(ruleCacheAt) > ruleCacheTTL {
+        ruleCache = nil
+        if to := envOr("ALERT_TO", ""); to != "" {
+            for _, h := range watchedTrains() {
+                ruleCache = append(ruleCache, AlertRule{ID: "env", To: to, Headcode: h, MinDelay: envInt("ALERT_MIN_DELAY", 5), Cancellations: true})
+            }
+        }
+        if archiveDB != nil {
+            stored, err := listRules(archiveDB)
+            if err != nil {
+                log.Printf("Failed to load alert rules: %v", err)
+            }
+            ruleCache = append(ruleCache, stored...)
+        }
+        ruleCacheAt = time.Now()
+    }
+    return ruleCache
+}
+
+// Make the next currentAlertRules read the store again
+func invalidateRuleCache() {
+    ruleCacheMu.Lock()
+    ruleCacheAt = time.Time{}
+    ruleCacheMu.Unlock()
+}
+
+var ruleFields = listFields[AlertRule]{
+    "to":        func(a AlertRule) string { return a.To },
+    "headcode":  func(a AlertRule) string { return a.Headcode },
+    "crs":       func(a AlertRule) string { return a.CRS },
+    "min_delay": func(a AlertRule) string { return strconv.Itoa(a.MinDelay) },
+}
+
+// GET /api/v1/rules
+func listRulesHandler(w http.ResponseWriter, r *http.Request) {
+    q, err := parseListQuery(r, ruleFields)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    rules, err := listRules(archiveDB)
+    if err != nil {
+        log.Printf("Failed to list alert rules: %v", err)
+        http.Error(w, "failed to list rules", http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, struct {
+        Rules []AlertRule `json:"rules"`
+    }{applyListQuery(w, r, rules, ruleFields, q)})
+}
+
+// Decode a rule from a request body. Fields left out keep the defaults:
+// alert at 5 minutes late and on cancellations.
+func decodeRule(w http.ResponseWriter, r *http.Request) (AlertRule, error) {
+    a := AlertRule{MinDelay: 5, Cancellations: true}
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&a); err != nil {
+        return a, fmt.Errorf("invalid rule: %v", err)
+    }
+    return a, a.normalise()
+}
+
+// POST /api/v1/rules
+func createRuleHandler(w http.ResponseWriter, r *http.Request) {
+    a, err := decodeRule(w, r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    a, err = createRule(archiveDB, a)
+    if err != nil {
+        log.Printf("Failed to create alert rule: %v", err)
+        http.Error(w, "failed to create rule", http.StatusInternalServerError)
+        return
+    }
+    invalidateRuleCache()
+    w.Header().Set("Location", "/api/v1/rules/"+a.ID)
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    writeJSON(w, a)
+}
+
+// GET /api/v1/rules/{id}
+func getRuleHandler(w http.ResponseWriter, r *http.Request) {
+    a, ok, err := getRule(archiveDB, r.PathValue("id"))
+    if err != nil {
+        log.Printf("Failed to load alert rule: %v", err)
+        http.Error(w, "failed to load rule", http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        http.Error(w, "unknown rule", http.StatusNotFound)
+        return
+    }
+    writeJSON(w, a)
+}
+
+// PUT /api/v1/rules/{id} replaces a rule
+func updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+    a, err := decodeRule(w, r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    a.ID = r.PathValue("id")
+    ok, err := updateRule(archiveDB, a)
+    if err != nil {
+        log.Printf("Failed to update alert rule %s: %v", a.ID, err)
+        http.Error(w, "failed to update rule", http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        http.Error(w, "unknown rule", http.StatusNotFound)
+        return
+    }
+    invalidateRuleCache()
+    getRuleHandler(w, r)
+}
+
+// DELETE /api/v1/rules/{id}
+func deleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+    ok, err := deleteRule(archiveDB, r.PathValue("id"))
+    if err != nil {
+        log.Printf("Failed to delete alert rule: %v", err)
+        http.Error(w, "failed to delete rule", http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        http.Error(w, "unknown rule", http.StatusNotFound)
+        return
+    }
+    invalidateRuleCache()
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+    "net/http"
+    "net/url"
+)
+
+// Public forms that change what a browser is shown (saved commutes, the
+// tracked train, short links) work off cookies or nothing at all, with
+// no session to hang a CSRF token on. Instead a post must come from a
+// page on this site: browsers say where a request came from in
+// Sec-Fetch-Site, or failing that Origin. Requests with neither aren't
+// from a browser, so can't be forged by another site, and go ahead.
+func sameOrigin(h http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if !fromThisSite(r) {
+            http.Error(w, "cross-site form posts aren't allowed", http.StatusForbidden)
+            return
+        }
+        h(w, r)
+    }
+}
+
+func fromThisSite(r *http.Request) bool {
+    switch r.Header.Get("Sec-Fetch-Site") {
+    case "same-origin", "none":
+        return true
+    case "":
+    default:
+        return false
+    }
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return true
+    }
+    u, err := url.Parse(origin)
+    return err == nil && u.Host == r.Host
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+    "html/template"
+    "net/url"
+    "strings"
+
+    "golang.org/x/net/html"
+)
+
+// Markup NRCC messages may keep. Anything else is dropped, keeping its
+// text, except scripts and styles, which go entirely.
+var nrccTags = map[string]bool{"p": true, "br": true, "strong": true, "b": true, "em": true, "i": true, "a": true}
+
+// NRCC station message HTML reduced to the allowed tags, with links only
+// to absolute http, https or mailto URLs. Text is re-escaped and anything
+// left open is closed, so the result is safe to put straight in a page.
+func sanitiseNRCC(s string) template.HTML {
+    z := html.NewTokenizer(strings.NewReader(s))
+    var b strings.Builder
+    var open []string
+    skip := 0 // depth inside script and style elements
+    for {
+        tt := z.Next()
+        switch tt {
+        case html.ErrorToken:
+            for i := len(open) - 1; i >= 0; i-- {
+                b.WriteString("</" + open[i] + ">")
+            }
+            return template.HTML(b.String())
+        case html.TextToken:
+            if skip == 0 {
+                b.WriteString(html.EscapeString(string(z.Text())))
+            }
+        case html.StartTagToken, html.SelfClosingTagToken:
+            tok := z.Token()
+            if tok.Data == "script" || tok.Data == "style" {
+                if tt == html.StartTagToken {
+                    skip++
+                }
+                continue
+            }
+            if skip > 0 || !nrccTags[tok.Data] {
+                continue
+            }
+            switch tok.Data {
+            case "br":
+                b.WriteString("<br>")
+                continue
+            case "a":
+                href := safeLink(tok)
+                if href == "" {
+                    continue
+                }
+                b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="noopener nofollow">`)
+            default:
+                b.WriteString("<" + tok.Data + ">")
+            }
+            // Only br may close itself; <a href="..."/> is an empty link,
+            // not one spanning the rest of the message
+            if tt == html.StartTagToken {
+                open = append(open, tok.Data)
+            } else {
+                b.WriteString("</" + tok.Data + ">")
+            }
+        case html.EndTagToken:
+            tok := z.Token()
+            if tok.Data == "script" || tok.Data == "style" {
+                skip = max(0, skip-1)
+                continue
+            }
+            // Close the element if it's open, and anything left open inside it
+            for i := len(open) - 1; i >= 0; i-- {
+                if open[i] == tok.Data {
+                    for j := len(open) - 1; j >= i; j-- {
+                        b.WriteString("</" + open[j] + ">")
+                    }
+                    open = open[:i]
+                    break
+                }
+            }
+        }
+    }
+}
+
+// The href of a link if it's one readers can safely follow
+func safeLink(tok html.Token) string {
+    for _, a := range tok.Attr {
+        if a.Key != "href" {
+            continue
+        }
+        u, err := url.Parse(strings.TrimSpace(a.Val))
+        if err != nil {
+            return ""
+        }
+        switch u.Scheme {
+        case "http", "https", "mailto":
+            return u.String()
+        }
+        return ""
+    }
+    return ""
+}
@@ -0,0 +1,65 @@
+package main
+
+import "strings"
+
+// What a train offers passengers, from its schedule: its category and,
+// where the timetable carries them, the CIF catering, seating class and
+// sleeper codes. Darwin's own timetable doesn't include the CIF ones, but
+// timetables converted from CIF do.
+// https://wiki.openraildata.com/index.php/CIF_Codes
+
+// Categories worth telling passengers about; the rest are plain trains or
+// are shown by the mode badge
+var categoryKeys = map[string]string{
+    "OO": "cat_OO",
+    "XX": "cat_XX",
+    "XZ": "cat_XZ",
+}
+
+var cateringKeys = map[rune]string{
+    'C': "cater_C",
+    'F': "cater_F",
+    'H': "cater_H",
+    'M': "cater_M",
+    'R': "cater_R",
+    'T': "cater_T",
+}
+
+var sleeperKeys = map[string]string{
+    "B": "sleeper_B",
+    "F": "sleeper_F",
+    "S": "sleeper_S",
+}
+
+// A journey's attributes as translation keys, in the order they're shown
+func scheduleAttributes(j *Journey) []string {
+    var keys []string
+    if k, ok := categoryKeys[j.TrainCat]; ok {
+        keys = append(keys, k)
+    }
+    // CIF leaves the seating class blank for first and standard as well,
+    // but so does a timetable without it, so only B says so
+    switch strings.TrimSpace(j.SeatingClass) {
+    case "B":
+        keys = append(keys, "first_class")
+    case "S":
+        keys = append(keys, "standard_only")
+    }
+    for _, c := range j.Catering {
+        if k, ok := cateringKeys[c]; ok {
+            keys = append(keys, k)
+        }
+    }
+    if k, ok := sleeperKeys[strings.TrimSpace(j.Sleepers)]; ok {
+        keys = append(keys, k)
+    }
+    return keys
+}
+
+// Attributes of the journey behind some progress, if it's known
+func progressAttributes(p TrainProgress) []string {
+    if j, ok := journeyByRID(p.RID); ok {
+        return scheduleAttributes(j)
+    }
+    return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import "slices"
+
+// Timetables converted from CIF can hold several schedules for one UID on
+// the same day: the permanent one, an overlay changing it for a while, a
+// new short-term one, or a cancellation for the day. Only the one with the
+// strongest STP indicator runs. Darwin's own timetable has already done
+// this, so its schedules don't carry one.
+var stpPrecedence = map[string]int{"P": 1, "O": 2, "N": 3, "C": 4}
+
+func stpRank(j *Journey) int {
+    return max(1, stpPrecedence[j.STP])
+}
+
+// The schedules out of js that run, dropping any a stronger variant of
+// the same UID and day overrides. A cancellation keeps the calling points
+// of the schedule it cancels, all marked cancelled.
+func resolveVariants(js []*Journey) []*Journey {
+    type key struct{ uid, ssd string }
+    groups := map[key][]*Journey{}
+    for _, j := range js {
+        if j.UID != "" {
+            k := key{j.UID, j.SSD}
+            groups[k] = append(groups[k], j)
+        }
+    }
+    drop := map[*Journey]bool{}
+    replace := map[*Journey]*Journey{}
+    for _, variants := range groups {
+        if len(variants) < 2 {
+            continue
+        }
+        best := slices.MaxFunc(variants, func(a, b *Journey) int { return stpRank(a) - stpRank(b) })
+        for _, j := range variants {
+            if stpRank(j) < stpRank(best) {
+                drop[j] = true
+            }
+        }
+        if best.STP == "C" && len(best.Points) == 0 {
+            replace[best] = cancelledVariant(best, variants)
+        }
+    }
+    if len(drop) == 0 && len(replace) == 0 {
+        return js
+    }
+    out := make([]*Journey, 0, len(js)-len(drop))
+    for _, j := range js {
+        if drop[j] {
+            continue
+        }
+        if r, ok := replace[j]; ok {
+            j = r
+        }
+        out = append(out, j)
+    }
+    return out
+}
+
+// The strongest variant a cancellation overrides, cancelled
+func cancelledVariant(c *Journey, variants []*Journey) *Journey {
+    var base *Journey
+    for _, j := range variants {
+        if j != c && len(j.Points) > 0 && (base == nil || stpRank(j) > stpRank(base)) {
+            base = j
+        }
+    }
+    if base == nil {
+        return c
+    }
+    out := *base
+    out.STP = "C"
+    out.Points = make([]CallingPoint, len(base.Points))
+    for i, p := range base.Points {
+        p.Cancelled = true
+        out.Points[i] = p
+    }
+    return &out
+}
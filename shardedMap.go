@@ -0,0 +1,112 @@
+package main
+
+import (
+    "hash/fnv"
+    "sync"
+)
+
+// Per-train state is split over this many independently locked shards, so
+// thousands of trains updating at once don't queue on a single lock
+const stateShards = 64
+
+func shardIndex(key string, n int) int {
+    h := fnv.New32a()
+    h.Write([]byte(key))
+    return int(h.Sum32() % uint32(n))
+}
+
+type mapShard[V any] struct {
+    mu sync.RWMutex
+    m  map[string]V
+}
+
+// A map keyed by RID whose entries are locked a shard at a time
+type shardedMap[V any] struct {
+    shards [stateShards]mapShard[V]
+}
+
+func newShardedMap[V any]() *shardedMap[V] {
+    s := &shardedMap[V]{}
+    for i := range s.shards {
+        s.shards[i].m = map[string]V{}
+    }
+    return s
+}
+
+func (s *shardedMap[V]) shard(key string) *mapShard[V] {
+    return &s.shards[shardIndex(key, stateShards)]
+}
+
+func (s *shardedMap[V]) load(key string) (V, bool) {
+    sh := s.shard(key)
+    sh.mu.RLock()
+    defer sh.mu.RUnlock()
+    v, ok := sh.m[key]
+    return v, ok
+}
+
+func (s *shardedMap[V]) store(key string, v V) {
+    sh := s.shard(key)
+    sh.mu.Lock()
+    sh.m[key] = v
+    sh.mu.Unlock()
+}
+
+func (s *shardedMap[V]) delete(key string) {
+    sh := s.shard(key)
+    sh.mu.Lock()
+    delete(sh.m, key)
+    sh.mu.Unlock()
+}
+
+// Replace an entry with what fn makes of it, holding its shard's lock
+// throughout. ok is false if there was no entry.
+func (s *shardedMap[V]) update(key string, fn func(v V, ok bool) V) {
+    sh := s.shard(key)
+    sh.mu.Lock()
+    defer sh.mu.Unlock()
+    v, ok := sh.m[key]
+    sh.m[key] = fn(v, ok)
+}
+
+// Remove and return the keys of every entry del picks
+func (s *shardedMap[V]) deleteFunc(del func(key string, v V) bool) []string {
+    var keys []string
+    for i := range s.shards {
+        sh := &s.shards[i]
+        sh.mu.Lock()
+        for k, v := range sh.m {
+            if del(k, v) {
+                delete(sh.m, k)
+                keys = append(keys, k)
+            }
+        }
+        sh.mu.Unlock()
+    }
+    return keys
+}
+
+// Call fn for every entry, a shard at a time, so it's not a consistent
+// snapshot of the whole map
+func (s *shardedMap[V]) each(fn func(key string, v V)) {
+    for i := range s.shards {
+        sh := &s.shards[i]
+        sh.mu.RLock()
+        for k, v := range sh.m {
+            fn(k, v)
+        }
+        sh.mu.RUnlock()
+    }
+}
+
+// Mutexes by RID, for read-modify-write on stores that can't do it
+// natively: updates to one train are serialised, different trains aren't
+type ridLocks struct {
+    shards [stateShards]sync.Mutex
+}
+
+func (l *ridLocks) lock(rid string) (unlock func()) {
+    mu := &l.shards[shardIndex(rid, stateShards)]
+    mu.Lock()
+    return mu.Unlock
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "errors"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Short links to train pages, e.g. /t/aB3xK9mQ, for sharing in chats. A
+// code stands for a service's RID and start date, and is resolved through
+// the RID links table when it's followed, so a link shared before Darwin
+// reissued the service still finds it, on any day. Codes are only made
+// when someone asks for one from the train page, and are forgotten
+// SHORT_LINK_DAYS (default 30) after the day the train ran. They live in
+// the archive database; without one the pages simply don't offer them.
+const shortLinkTableSQL = `
+    CREATE TABLE IF NOT EXISTS short_links (
+        code TEXT PRIMARY KEY,
+        rid TEXT NOT NULL UNIQUE,
+        train_id TEXT NOT NULL,
+        ssd TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS short_links_ssd ON short_links (ssd);`
+
+const (
+    shortLinkAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+    shortLinkLength   = 8
+)
+
+// A random code, each character equally likely: bytes past the last whole
+// multiple of the alphabet's length are thrown away rather than wrapped
+func newShortCode() string {
+    limit := byte(256 - 256%len(shortLinkAlphabet))
+    code := make([]byte, 0, shortLinkLength)
+    b := make([]byte, shortLinkLength)
+    for len(code) < shortLinkLength {
+        rand.Read(b)
+        for _, c := range b {
+            if c < limit && len(code) < shortLinkLength {
+                code = append(code, shortLinkAlphabet[int(c)%len(shortLinkAlphabet)])
+            }
+        }
+    }
+    return string(code)
+}
+
+// The code already made for a run of a train, if there is one
+func existingShortLink(rid string) (string, bool, error) {
+    var code string
+    err := archiveDB.QueryRow(`SELECT code FROM short_links WHERE rid = ?`, rid).Scan(&code)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", false, nil
+    }
+    return code, err == nil, err
+}
+
+// The code for a run of a train, made if there isn't one yet
+func shortLinkCode(rid, trainID, ssd string) (string, error) {
+    if archiveDB == nil {
+        return "", errors.New("short links need the archive")
+    }
+    if code, ok, err := existingShortLink(rid); ok || err != nil {
+        return code, err
+    }
+    // Eight characters hardly ever clash; one that does just tries again
+    for range 5 {
+        code := newShortCode()
+        res, err := archiveDB.Exec(`INSERT OR IGNORE INTO short_links (code, rid, train_id, ssd, created_at) VALUES (?, ?, ?, ?, ?)`,
+            code, rid, trainID, ssd, clock.Now().UTC())
+        if err != nil {
+            return "", err
+        }
+        if n, _ := res.RowsAffected(); n == 1 {
+            return code, nil
+        }
+        // Another request may have made one for the same RID meanwhile
+        if code, ok, err := existingShortLink(rid); ok || err != nil {
+            return code, err
+        }
+    }
+    return "", errors.New("no free short link code")
+}
+
+// Forget links to trains that ran more than SHORT_LINK_DAYS ago
+func pruneShortLinks(now time.Time) {
+    cutoff := now.In(ukLocation).AddDate(0, 0, -envInt("SHORT_LINK_DAYS", 30)).Format("2006-01-02")
+    res, err := archiveDB.Exec(`DELETE FROM short_links WHERE ssd < ?`, cutoff)
+    if err != nil {
+        log.Printf("Failed to prune short links: %v", err)
+        return
+    }
+    if n, _ := res.RowsAffected(); n > 0 {
+        log.Printf("Pruned %d short links to trains that ran before %s", n, cutoff)
+    }
+}
+
+// The full short link for a train page, or "" if none has been made
+func shortLinkFor(r *http.Request, p TrainProgress) string {
+    if p.RID == "" || archiveDB == nil {
+        return ""
+    }
+    code, ok, err := existingShortLink(p.RID)
+    if err != nil {
+        log.Printf("Failed to look up the short link for %s: %v", p.RID, err)
+    }
+    if !ok {
+        return ""
+    }
+    return requestBaseURL(r) + "/t/" + code
+}
+
+// What the train page's button for making a short link posts
+type shareForm struct {
+    RID, TrainID, Date string
+}
+
+func shareFormFor(p TrainProgress) *shareForm {
+    if p.RID == "" || archiveDB == nil {
+        return nil
+    }
+    return &shareForm{p.RID, p.TrainID, p.SSD}
+}
+
+// The train page for a run, under the service's current RID
+func trainPageURL(trainID, rid, ssd string) string {
+    return "/train/" + url.PathEscape(trainID) + "?rid=" + url.QueryEscape(rid) + "&date=" + url.QueryEscape(ssd)
+}
+
+// POST /t with rid, train and date: make the short link for a run of a
+// train and go back to its page, which then shows it
+func createShortLinkHandler(w http.ResponseWriter, r *http.Request) {
+    if archiveDB == nil {
+        http.NotFound(w, r)
+        return
+    }
+    rid, trainID, date := r.FormValue("rid"), strings.ToUpper(r.FormValue("train")), r.FormValue("date")
+    runs, err := runsOn(trainID, date, rid)
+    if err != nil {
+        log.Printf("Failed to look up %s for a short link: %v", rid, err)
+        http.Error(w, "failed to look up train", http.StatusInternalServerError)
+        return
+    }
+    if _, ok := chooseRun(runs, rid); !ok || rid == "" {
+        http.Error(w, "no such train", http.StatusNotFound)
+        return
+    }
+    if _, err := shortLinkCode(rid, trainID, date); err != nil {
+        log.Printf("Failed to make a short link for %s: %v", rid, err)
+        http.Error(w, "failed to make link", http.StatusInternalServerError)
+        return
+    }
+    pruneShortLinks(clock.Now())
+    http.Redirect(w, r, trainPageURL(trainID, rid, date), http.StatusSeeOther)
+}
+
+// GET /t/{code}: redirect to the train page the code stands for, under
+// the service's current RID, on the day it ran
+func shortLinkHandler(w http.ResponseWriter, r *http.Request) {
+    if archiveDB == nil {
+        http.NotFound(w, r)
+        return
+    }
+    var rid, trainID, ssd string
+    err := archiveDB.QueryRow(`SELECT rid, train_id, ssd FROM short_links WHERE code = ?`, r.PathValue("code")).Scan(&rid, &trainID, &ssd)
+    if errors.Is(err, sql.ErrNoRows) {
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil {
+        log.Printf("Failed to look up short link %s: %v", r.PathValue("code"), err)
+        http.Error(w, "failed to look up link", http.StatusInternalServerError)
+        return
+    }
+    rid = latestRID(rid)
+    if j, ok := journeyByRID(rid); ok && j.TrainID != "" {
+        trainID = j.TrainID
+    }
+    http.Redirect(w, r, trainPageURL(trainID, rid, ssd), http.StatusFound)
+}
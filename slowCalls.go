@@ -0,0 +1,48 @@
+package main
+
+import (
+    "log"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP handlers, S3 calls and applying Push Port updates are timed, and
+// those taking longer than their threshold are logged and counted, to
+// show where production time goes. Thresholds are SLOW_HANDLER_MS
+// (default 1000), SLOW_S3_MS (5000) and SLOW_APPLY_MS (250); 0 stops
+// logging that kind. Durations go to the metrics either way.
+var slowThresholds = map[string]time.Duration{
+    "handler": time.Second,
+    "s3":      5 * time.Second,
+    "apply":   250 * time.Millisecond,
+}
+
+var (
+    callDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "minimaltrains_call_duration_seconds",
+        Help:    "Time taken by HTTP handlers, S3 calls and Push Port applies.",
+        Buckets: []float64{.001, .005, .025, .1, .25, 1, 5, 30},
+    }, []string{"kind", "name"})
+    slowCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "minimaltrains_slow_calls_total",
+        Help: "Calls that took longer than their SLOW_*_MS threshold.",
+    }, []string{"kind", "name"})
+)
+
+func initSlowCalls() {
+    for kind, key := range map[string]string{"handler": "SLOW_HANDLER_MS", "s3": "SLOW_S3_MS", "apply": "SLOW_APPLY_MS"} {
+        slowThresholds[kind] = time.Duration(envInt(key, int(slowThresholds[kind].Milliseconds()))) * time.Millisecond
+    }
+}
+
+// Record how long a call that began at start took. name is the route,
+// S3 operation or update type; detail says which one, for the log.
+func observeCall(kind, name, detail string, start time.Time) {
+    took := time.Since(start)
+    callDuration.WithLabelValues(kind, name).Observe(took.Seconds())
+    if limit := slowThresholds[kind]; limit > 0 && took > limit {
+        slowCalls.WithLabelValues(kind, name).Inc()
+        log.Printf("Slow %s %s (%s) took %s, over %s", kind, name, detail, took.Round(time.Millisecond), limit)
+    }
+}
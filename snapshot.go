@@ -0,0 +1,181 @@
+package main
+
+import (
+    "compress/gzip"
+    "encoding/gob"
+    "log"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// In-memory state written to disk periodically, so a restart can pick up
+// where it left off instead of downloading the timetable again and
+// waiting for the live feed to fill in progress
+type stateSnapshot struct {
+    TakenAt    time.Time
+    Journeys   map[string]*Journey
+    Stations   map[string]Station
+    Operators  map[string]Operator
+    TrackedRID string
+    // Only set when progress is held in memory; the other stores persist it
+    Progress map[string]TrainProgress
+    // Where Journeys and Stations came from, for /version
+    TimetableID, ReferenceID string
+    TimetableLoadedAt        time.Time
+    // How far Push Port ingest had got, by topic, and the updates applied,
+    // so ones replayed from before the offset are dropped
+    Offsets map[string]FeedOffset
+    Applied map[string]appliedUpdate
+}
+
+func snapshotPath() string {
+    return envOr("SNAPSHOT_PATH", "minimaltrains.snapshot")
+}
+
+func takeSnapshot() stateSnapshot {
+    // Offsets first, so the state saved is at least as new as them
+    s := stateSnapshot{TakenAt: clock.Now(), Offsets: ingestProgress.snapshotOffsets()}
+    s.Applied = snapshotAppliedUpdates()
+    s.TrackedRID = currentTrackedRID()
+    journeysMu.RLock()
+    if timetableLoaded {
+        s.Journeys = make(map[string]*Journey, len(journeys))
+        for rid, j := range journeys {
+            s.Journeys[rid] = j
+        }
+    }
+    journeysMu.RUnlock()
+    stationsMu.RLock()
+    s.Stations = make(map[string]Station, len(stations))
+    for k, v := range stations {
+        s.Stations[k] = v
+    }
+    stationsMu.RUnlock()
+    operatorsMu.RLock()
+    s.Operators = make(map[string]Operator, len(operators))
+    for k, v := range operators {
+        s.Operators[k] = v
+    }
+    operatorsMu.RUnlock()
+    if m, ok := progressStore.(*memoryStore); ok {
+        s.Progress = m.all()
+    }
+    loadedVersionsMu.RLock()
+    s.TimetableID, s.ReferenceID, s.TimetableLoadedAt = loadedTimetableID, loadedReferenceID, timetableLoadedAt
+    loadedVersionsMu.RUnlock()
+    return s
+}
+
+// Write the snapshot to a temporary file then rename it into place, so a
+// crash mid-write never leaves a truncated snapshot behind
+func writeSnapshot(path string) error {
+    s := takeSnapshot()
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    gz := gzip.NewWriter(tmp)
+    if err := gob.NewEncoder(gz).Encode(s); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := gz.Close(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// Restore state from the snapshot if there is one. Reports whether it
+// held today's timetable, in which case there's no need to load it again.
+func loadSnapshot() bool {
+    path := snapshotPath()
+    if path == "off" {
+        return false
+    }
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return false
+    }
+    if err != nil {
+        log.Printf("Failed to open snapshot %s: %v", path, err)
+        return false
+    }
+    defer f.Close()
+    gz, err := gzip.NewReader(f)
+    if err != nil {
+        log.Printf("Failed to read snapshot %s: %v", path, err)
+        return false
+    }
+    var s stateSnapshot
+    if err := gob.NewDecoder(gz).Decode(&s); err != nil {
+        log.Printf("Failed to read snapshot %s: %v", path, err)
+        return false
+    }
+    // Yesterday's live progress and schedules are no use today
+    if s.TakenAt.In(ukLocation).Format("2006-01-02") != ukToday() {
+        log.Printf("Ignoring snapshot from %s", s.TakenAt.Format(time.RFC3339))
+        return false
+    }
+    for _, st := range s.Stations {
+        addStation(st)
+    }
+    for _, o := range s.Operators {
+        addOperator(o)
+    }
+    if m, ok := progressStore.(*memoryStore); ok && s.Progress != nil {
+        m.restore(s.Progress)
+    }
+    if s.TrackedRID != "" {
+        setTrackedRID(s.TrackedRID)
+    }
+    restoreAppliedUpdates(s.Applied)
+    ingestProgress.restore(s.Offsets)
+    if s.Journeys == nil {
+        return false
+    }
+    setTimetable(s.Journeys)
+    // Snapshots from before the load time was saved have only their own
+    loadedAt := s.TimetableLoadedAt
+    if loadedAt.IsZero() {
+        loadedAt = s.TakenAt
+    }
+    noteTimetableLoadedAt(s.TimetableID, loadedAt)
+    if s.ReferenceID != "" {
+        noteReferenceLoaded(s.ReferenceID)
+    }
+    log.Printf("Restored state from snapshot taken %s", s.TakenAt.Format(time.RFC3339))
+    return true
+}
+
+// SNAPSHOT_INTERVAL, read each time so a config reload can change it
+func snapshotInterval() time.Duration {
+    interval, err := time.ParseDuration(envOr("SNAPSHOT_INTERVAL", "5m"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid SNAPSHOT_INTERVAL; using 5m")
+        return 5 * time.Minute
+    }
+    return interval
+}
+
+// Rewrite the snapshot every SNAPSHOT_INTERVAL (default 5m)
+func startSnapshots() {
+    path := snapshotPath()
+    if path == "off" {
+        return
+    }
+    for {
+        time.Sleep(snapshotInterval())
+        start := time.Now()
+        if err := writeSnapshot(path); err != nil {
+            log.Printf("Failed to write snapshot %s: %v", path, err)
+            continue
+        }
+        log.Printf("Wrote snapshot to %s in %s", path, time.Since(start).Round(time.Millisecond))
+    }
+}
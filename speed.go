@@ -0,0 +1,121 @@
+package main
+
+import (
+    "log"
+    "math"
+    "net/http"
+)
+
+const kmPerMile = 1.609344
+
+// Distance and average speed between one stop and the previous one. The
+// distance is the straight line between stations, so speeds on winding
+// routes come out low.
+type SegmentSpeed struct {
+    From  string  `json:"from"`
+    To    string  `json:"to"`
+    Miles float64 `json:"miles"`
+    // Only set once the train has actual times at both ends
+    Minutes int     `json:"minutes,omitempty"`
+    MPH     float64 `json:"mph,omitempty"`
+    Known   bool    `json:"-"`
+}
+
+// Segments aligned with p.Stops: entry i covers the run from stop i-1 to
+// stop i, and entry 0 is always empty. Segments without coordinates for
+// both ends are left empty.
+func segmentSpeeds(p TrainProgress) []SegmentSpeed {
+    segs := make([]SegmentSpeed, len(p.Stops))
+    for i := 1; i < len(p.Stops); i++ {
+        prev, cur := p.Stops[i-1], p.Stops[i]
+        a, ok1 := coordsFor(prev.Station)
+        b, ok2 := coordsFor(cur.Station)
+        if !ok1 || !ok2 {
+            continue
+        }
+        seg := SegmentSpeed{From: prev.Station, To: cur.Station, Miles: roundTo(haversineKm(a, b)/kmPerMile, 1), Known: true}
+        if prev.Actual != "" && cur.Actual != "" {
+            if mins, ok := minutesLate(prev.Actual, cur.Actual); ok && mins > 0 {
+                seg.Minutes = mins
+                seg.MPH = roundTo(seg.Miles/(float64(mins)/60), 0)
+            }
+        }
+        segs[i] = seg
+    }
+    return segs
+}
+
+func totalMiles(segs []SegmentSpeed) float64 {
+    var miles float64
+    for _, s := range segs {
+        miles += s.Miles
+    }
+    return roundTo(miles, 1)
+}
+
+func roundTo(v float64, places int) float64 {
+    scale := math.Pow(10, float64(places))
+    return math.Round(v*scale) / scale
+}
+
+// GET /api/v1/journey/{rid}: a train's progress with its segment speeds
+func journeyAPIHandler(w http.ResponseWriter, r *http.Request) {
+    p, ok := requestProgress(w, r)
+    if !ok {
+        return
+    }
+    writeJSON(w, journeyResponse(p))
+}
+
+// Progress of the train a request's {rid} names, from the store or else
+// its schedule, answering 404 or 500 itself if there isn't any
+func requestProgress(w http.ResponseWriter, r *http.Request) (TrainProgress, bool) {
+    rid := r.PathValue("rid")
+    p, ok, err := lookupProgress(rid)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", rid, err)
+        http.Error(w, "failed to load progress", http.StatusInternalServerError)
+        return p, false
+    }
+    if !ok {
+        http.Error(w, "unknown RID", http.StatusNotFound)
+    }
+    return p, ok
+}
+
+// Progress of a train from the store, or else from its schedule
+func lookupProgress(rid string) (TrainProgress, bool, error) {
+    p, ok, err := progressStore.Get(rid)
+    if err != nil || ok {
+        return p, ok, err
+    }
+    j, found := journeyByRID(rid)
+    if !found {
+        return p, false, nil
+    }
+    progressFromJourney(j, &p)
+    return p, true, nil
+}
+
+// A train's progress with its segment speeds and cancellations, as the
+// journey API returns it
+func journeyResponse(p TrainProgress) any {
+    segs := segmentSpeeds(p)
+    ranges, all := cancelledRanges(p.Stops)
+    var prediction *ArrivalPrediction
+    if pr, ok := predictArrival(p); ok {
+        prediction = &pr
+    }
+    return struct {
+        TrainProgress
+        Segments         []SegmentSpeed     `json:"segments"`
+        Miles            float64            `json:"miles"`
+        CancelledRanges  []CancelledRange   `json:"cancelled_ranges,omitempty"`
+        FullyCancelled   bool               `json:"fully_cancelled"`
+        PredictedArrival *ArrivalPrediction `json:"predicted_arrival,omitempty"`
+        Attributes       []string           `json:"attributes,omitempty"`
+        ShortPlatforms   []ShortPlatform    `json:"short_platforms,omitempty"`
+        Freshness        *Freshness         `json:"freshness,omitempty"`
+        Allocation       *Allocation        `json:"allocation,omitempty"`
+    }{p, segs[min(1, len(segs)):], totalMiles(segs), ranges, all, prediction, progressAttributes(p), shortPlatforms(p), p.Freshness(clock.Now()), allocationFor(p.RID, p.TrainID, p.SSD)}
+}
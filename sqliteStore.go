@@ -0,0 +1,123 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "strings"
+    "time"
+
+    _ "modernc.org/sqlite"
+)
+
+// Progress kept in a local SQLite file, so it survives restarts
+type sqliteStore struct {
+    db    *sql.DB
+    locks ridLocks
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+    db, err := sql.Open("sqlite", sqliteDSN(path))
+    if err != nil {
+        return nil, err
+    }
+    // SQLite takes one writer at a time, so writes queue here rather than
+    // failing with SQLITE_BUSY, and the busy timeout covers other processes
+    db.SetMaxOpenConns(1)
+    _, err = db.Exec(`CREATE TABLE IF NOT EXISTS progress (
+        rid TEXT PRIMARY KEY,
+        data TEXT NOT NULL,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    )`)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    // Added after the table; older files won't have it yet
+    if _, err := db.Exec(`ALTER TABLE progress ADD COLUMN finished_at INTEGER`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+        db.Close()
+        return nil, err
+    }
+    return &sqliteStore{db: db}, nil
+}
+
+// Set on every connection the pool opens: wait up to 5s for a lock
+// rather than failing straight away
+func sqliteDSN(path string) string {
+    sep := "?"
+    if strings.Contains(path, "?") {
+        sep = "&"
+    }
+    return path + sep + "_pragma=busy_timeout(5000)"
+}
+
+func (s *sqliteStore) Get(rid string) (TrainProgress, bool, error) {
+    var data string
+    err := s.db.QueryRow(`SELECT data FROM progress WHERE rid = ?`, rid).Scan(&data)
+    if err == sql.ErrNoRows {
+        return TrainProgress{}, false, nil
+    }
+    if err != nil {
+        return TrainProgress{}, false, err
+    }
+    var p TrainProgress
+    if err := json.Unmarshal([]byte(data), &p); err != nil {
+        return TrainProgress{}, false, err
+    }
+    return p, true, nil
+}
+
+func (s *sqliteStore) Put(p TrainProgress) error {
+    return s.PutBatch([]TrainProgress{p})
+}
+
+// Write several trains' progress in one transaction
+func (s *sqliteStore) PutBatch(ps []TrainProgress) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    stmt, err := tx.Prepare(`INSERT INTO progress (rid, data, finished_at) VALUES (?, ?, ?)
+        ON CONFLICT(rid) DO UPDATE SET data = excluded.data, finished_at = excluded.finished_at, updated_at = CURRENT_TIMESTAMP`)
+    if err != nil {
+        return err
+    }
+    defer stmt.Close()
+    for _, p := range ps {
+        data, err := json.Marshal(p)
+        if err != nil {
+            return err
+        }
+        var finished sql.NullInt64
+        if !p.FinishedAt.IsZero() {
+            finished = sql.NullInt64{Int64: p.FinishedAt.Unix(), Valid: true}
+        }
+        if _, err := stmt.Exec(p.RID, string(data), finished); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+func (s *sqliteStore) EvictFinished(before time.Time) ([]string, error) {
+    rows, err := s.db.Query(`DELETE FROM progress WHERE finished_at < ? RETURNING rid`, before.Unix())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var rids []string
+    for rows.Next() {
+        var rid string
+        if err := rows.Scan(&rid); err != nil {
+            return rids, err
+        }
+        rids = append(rids, rid)
+    }
+    return rids, rows.Err()
+}
+
+func (s *sqliteStore) Update(rid string, fn func(p *TrainProgress)) error {
+    return updateViaGetPut(&s.locks, s, rid, fn)
+}
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
@@ -0,0 +1,129 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// Stations shown together on one board, e.g. all the London terminals
+type StationGroup struct {
+    Name string   `json:"name"`
+    CRS  []string `json:"crs"`
+}
+
+// Built-in groups by slug. STATION_GROUPS_FILE can add more or replace these.
+var stationGroups = map[string]StationGroup{
+    "london-terminals": {
+        Name: "London Terminals",
+        CRS:  []string{"BFR", "CHX", "CST", "EUS", "FST", "KGX", "LBG", "LST", "MOG", "MYB", "PAD", "STP", "VIC", "WAT", "WAE"},
+    },
+}
+
+// Load STATION_GROUPS_FILE if configured
+func initStationGroups() {
+    if path := os.Getenv("STATION_GROUPS_FILE"); path != "" {
+        if err := loadStationGroupsFile(path); err != nil {
+            log.Printf("Failed to load station groups from %s: %v", path, err)
+        }
+    }
+}
+
+// Merge groups from a JSON file of {"slug": {"name": "...", "crs": [...]}}
+func loadStationGroupsFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var groups map[string]StationGroup
+    if err := json.Unmarshal(data, &groups); err != nil {
+        return err
+    }
+    for slug, g := range groups {
+        if len(g.CRS) == 0 {
+            return fmt.Errorf("group %q has no stations", slug)
+        }
+        for i, crs := range g.CRS {
+            g.CRS[i] = strings.ToUpper(strings.TrimSpace(crs))
+        }
+        if g.Name == "" {
+            g.Name = slug
+        }
+        stationGroups[strings.ToLower(slug)] = g
+    }
+    return nil
+}
+
+// Combined departures from every station in a group
+func buildGroupBoard(slug string, g StationGroup, opts boardOptions) Board {
+    board := Board{Group: slug, Name: g.Name}
+    for _, crs := range g.CRS {
+        markBoardViewed(crs)
+        board.Tiplocs = append(board.Tiplocs, tiplocsForCRS(crs)...)
+    }
+    return collectDepartures(board, opts, clock.Now())
+}
+
+// Look up the group a request names, answering 404 if there isn't one
+func requestStationGroup(w http.ResponseWriter, r *http.Request) (string, StationGroup, bool) {
+    slug := strings.ToLower(r.PathValue("name"))
+    g, ok := stationGroups[slug]
+    if !ok {
+        http.Error(w, "unknown station group", http.StatusNotFound)
+    }
+    return slug, g, ok
+}
+
+// GET /board/group/{name}. Registered as /board/{prefix}/{name} so it sits
+// below /board/{crs}/departures and friends rather than conflicting.
+func groupBoardPageHandler(w http.ResponseWriter, r *http.Request) {
+    if r.PathValue("prefix") != "group" {
+        http.NotFound(w, r)
+        return
+    }
+    slug, g, ok := requestStationGroup(w, r)
+    if !ok {
+        return
+    }
+    lang := requestLang(w, r)
+    rememberBoardColumns(w, r)
+    rememberEnthusiast(w, r)
+    opts := boardOptionsFor(r)
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(boardPageTmpl, lang)
+            if err != nil {
+                return err
+            }
+            executeTemplate(w, r, tmpl, boardPageData{lang, otherLang(lang), requestTheme(w, r), g.Name, "/board/group/" + slug, opts.query(), "", opts, pageMeta{}, nil, enthusiastToggle(r)})
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                Group      string     `json:"group"`
+                Name       string     `json:"name"`
+                CRS        []string   `json:"crs"`
+                Departures []BoardRow `json:"departures"`
+            }{slug, g.Name, g.CRS, buildGroupBoard(slug, g, opts).allRows()}
+        },
+        Text: func(w io.Writer) { writeBoardText(w, buildGroupBoard(slug, g, opts), lang) },
+    })
+}
+
+// GET /board/group/{name}/departures (htmx partial)
+func groupBoardHandler(w http.ResponseWriter, r *http.Request) {
+    slug, g, ok := requestStationGroup(w, r)
+    if !ok {
+        return
+    }
+    tmpl, err := localisedTemplate(boardTmpl, requestLang(w, r))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    executeTemplate(w, r, tmpl, buildGroupBoard(slug, g, boardOptionsFor(r)))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// NRCC station message from the Push Port
+type DarwinStationMessage struct {
+    ID       string `xml:"id,attr"`
+    Category string `xml:"cat,attr"`
+    Severity string `xml:"sev,attr"`
+    Stations []struct {
+        CRS string `xml:"crs,attr"`
+    } `xml:"Station"`
+    Msg struct {
+        Inner string `xml:",innerxml"`
+    } `xml:"Msg"`
+}
+
+type StationMessage struct {
+    ID       string
+    Category string
+    Severity string
+    CRS      []string
+    Text     string // may contain HTML markup from NRCC
+    Received time.Time
+}
+
+var (
+    stationMessages   = map[string]StationMessage{}
+    stationMessagesMu sync.RWMutex
+)
+
+// A message listing no stations has been withdrawn
+func applyStationMessage(m DarwinStationMessage) {
+    stationMessagesMu.Lock()
+    defer stationMessagesMu.Unlock()
+    if len(m.Stations) == 0 {
+        delete(stationMessages, m.ID)
+        return
+    }
+    msg := StationMessage{
+        ID:       m.ID,
+        Category: m.Category,
+        Severity: m.Severity,
+        Text:     strings.TrimSpace(m.Msg.Inner),
+        Received: clock.Now(),
+    }
+    if old, ok := stationMessages[m.ID]; ok && old.Text == msg.Text {
+        msg.Received = old.Received
+    }
+    for _, s := range m.Stations {
+        msg.CRS = append(msg.CRS, s.CRS)
+    }
+    stationMessages[m.ID] = msg
+}
+
+// Darwin grades messages from 0 (normal) to 3 (severe)
+const prioritySeverity = 2
+
+// Major and severe messages, and those NRCC files under the priority
+// categories (PriorTrains, PriorOther), go at the top of the board. The
+// rest are informational and fold away.
+func (m StationMessage) Priority() bool {
+    sev, _ := strconv.Atoi(m.Severity)
+    return sev >= prioritySeverity || strings.HasPrefix(m.Category, "Prior")
+}
+
+// Split messages into priority and informational, keeping their order
+func splitMessages(msgs []StationMessage) (priority, info []StationMessage) {
+    for _, m := range msgs {
+        if m.Priority() {
+            priority = append(priority, m)
+        } else {
+            info = append(info, m)
+        }
+    }
+    return priority, info
+}
+
+// Current messages for a station, most severe then newest first
+func messagesForStation(crs string) []StationMessage {
+    stationMessagesMu.RLock()
+    defer stationMessagesMu.RUnlock()
+    var out []StationMessage
+    for _, m := range stationMessages {
+        for _, c := range m.CRS {
+            if c == crs {
+                out = append(out, m)
+                break
+            }
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        si, _ := strconv.Atoi(out[i].Severity)
+        sj, _ := strconv.Atoi(out[j].Severity)
+        if si != sj {
+            return si > sj
+        }
+        return out[i].Received.After(out[j].Received)
+    })
+    return out
+}
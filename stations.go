@@ -0,0 +1,113 @@
+package main
+
+import (
+    "slices"
+    "sync"
+)
+
+// Station reference data, keyed by TIPLOC
+type Station struct {
+    Tiploc string
+    CRS    string
+    Name   string
+    NameCy string
+}
+
+var (
+    stations = map[string]Station{}
+    // A CRS can cover several TIPLOCs, e.g. the main and bay platforms at
+    // Clapham Junction or Tamworth's high and low levels
+    crsTiplocs = map[string][]string{}
+    stationsMu sync.RWMutex
+)
+
+// FROM
+// https://en.wikipedia.org/wiki/List_of_railway_stations_in_Wales
+// Only stations whose Welsh name differs from the English one are listed.
+var WelshStationNames = map[string]string{
+    "ABA": "Aberdâr",
+    "AGV": "Y Fenni",
+    "BGN": "Pen-y-bont",
+    "BRM": "Abermaw",
+    "BRY": "Y Barri",
+    "BYI": "Ynys y Barri",
+    "CDB": "Bae Caerdydd",
+    "CDF": "Caerdydd Canolog",
+    "CDQ": "Caerdydd Heol y Frenhines",
+    "CMN": "Caerfyrddin",
+    "CPH": "Caerffili",
+    "CPW": "Cas-gwent",
+    "CWM": "Cwmbrân",
+    "EBV": "Tref Glynebwy",
+    "FGH": "Harbwr Abergwaun",
+    "FLN": "Y Fflint",
+    "HHD": "Caergybi",
+    "HVF": "Hwlffordd",
+    "LLJ": "Cyffordd Llandudno",
+    "MER": "Merthyr Tudful",
+    "MFH": "Aberdaugleddau",
+    "NTH": "Castell-nedd",
+    "NWP": "Casnewydd",
+    "NWT": "Y Drenewydd",
+    "PTA": "Parcffordd Port Talbot",
+    "RHL": "Y Rhyl",
+    "SWA": "Abertawe",
+    "WLP": "Y Trallwng",
+    "WRX": "Wrecsam Cyffredinol",
+}
+
+// Register a station, filling in its Welsh name where we know one
+func addStation(s Station) {
+    if s.NameCy == "" {
+        s.NameCy = WelshStationNames[s.CRS]
+    }
+    stationsMu.Lock()
+    defer stationsMu.Unlock()
+    if old, ok := stations[s.Tiploc]; ok && old.CRS != s.CRS {
+        crsTiplocs[old.CRS] = slices.DeleteFunc(crsTiplocs[old.CRS], func(t string) bool { return t == s.Tiploc })
+        if len(crsTiplocs[old.CRS]) == 0 {
+            delete(crsTiplocs, old.CRS)
+        }
+    }
+    stations[s.Tiploc] = s
+    if s.CRS != "" && !slices.Contains(crsTiplocs[s.CRS], s.Tiploc) {
+        crsTiplocs[s.CRS] = append(crsTiplocs[s.CRS], s.Tiploc)
+        slices.Sort(crsTiplocs[s.CRS])
+    }
+}
+
+// Name to show for a TIPLOC. Stations with a Welsh name are rendered
+// bilingually, with the requested language first.
+func stationDisplayName(tiploc, lang string) string {
+    stationsMu.RLock()
+    s, ok := stations[tiploc]
+    stationsMu.RUnlock()
+    if !ok || s.Name == "" {
+        return tiploc
+    }
+    if s.NameCy == "" || s.NameCy == s.Name {
+        return s.Name
+    }
+    if lang == "cy" {
+        return s.NameCy + " / " + s.Name
+    }
+    return s.Name + " / " + s.NameCy
+}
+
+// All the TIPLOCs that make up the station with a CRS code
+func tiplocsForCRS(crs string) []string {
+    stationsMu.RLock()
+    defer stationsMu.RUnlock()
+    return slices.Clone(crsTiplocs[crs])
+}
+
+// CRS code of the station a TIPLOC belongs to, or the TIPLOC itself for
+// locations without one, so the parts of a station compare equal
+func stationKey(tiploc string) string {
+    stationsMu.RLock()
+    defer stationsMu.RUnlock()
+    if s, ok := stations[tiploc]; ok && s.CRS != "" {
+        return s.CRS
+    }
+    return tiploc
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+    "cmp"
+    "html/template"
+    "io"
+    "math"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+)
+
+// /stats ranks today's operators by how their trains are doing, from the
+// same figures as the railway metrics. The table refreshes itself with
+// htmx, and its headings sort it.
+type OperatorStats struct {
+    TOC           string `json:"toc"`
+    Services      int    `json:"services"`
+    Running       int    `json:"running"`
+    Cancelled     int    `json:"cancelled"`
+    PartCancelled int    `json:"part_cancelled"`
+    // Share of services cancelled, wholly or in part, as a percentage
+    CancelledPct float64 `json:"cancelled_pct"`
+    // Average delay of services that have set off, and the share of calls
+    // made less than punctualMins late, if there are any yet
+    AverageDelay *float64 `json:"average_delay,omitempty"`
+    Punctuality  *float64 `json:"punctuality_pct,omitempty"`
+}
+
+// The average delay for the page to format; only meaningful when there is one
+func (o OperatorStats) AverageDelayMins() float64 {
+    d, _ := ptrValue(o.AverageDelay)
+    return d
+}
+
+func operatorStats(st railwayStats) []OperatorStats {
+    var out []OperatorStats
+    for toc, n := range st.services {
+        o := OperatorStats{TOC: toc, Services: n, Running: st.running[toc], Cancelled: st.cancelled[toc], PartCancelled: st.partCancelled[toc]}
+        o.CancelledPct = roundTo(100*float64(o.Cancelled+o.PartCancelled)/float64(n), 1)
+        if st.delayed[toc] > 0 {
+            d := roundTo(float64(st.delaySum[toc])/float64(st.delayed[toc]), 1)
+            o.AverageDelay = &d
+        }
+        if st.tocCalls[toc] > 0 {
+            p := roundTo(100*float64(st.tocPunctual[toc])/float64(st.tocCalls[toc]), 1)
+            o.Punctuality = &p
+        }
+        out = append(out, o)
+    }
+    return out
+}
+
+// Ways the table sorts with ?sort=, best first unless ?order= says
+// otherwise. Operators without a figure yet go last either way.
+var statsSorts = map[string]struct {
+    value func(OperatorStats) (float64, bool)
+    desc  bool // whether higher is better
+}{
+    "services":    {func(o OperatorStats) (float64, bool) { return float64(o.Services), true }, true},
+    "punctuality": {func(o OperatorStats) (float64, bool) { return ptrValue(o.Punctuality) }, true},
+    "delay":       {func(o OperatorStats) (float64, bool) { return ptrValue(o.AverageDelay) }, false},
+    "cancelled":   {func(o OperatorStats) (float64, bool) { return o.CancelledPct, true }, false},
+}
+
+const defaultStatsSort = "punctuality"
+
+func ptrValue(p *float64) (float64, bool) {
+    if p == nil {
+        return math.NaN(), false
+    }
+    return *p, true
+}
+
+func sortOperatorStats(ops []OperatorStats, key string, desc bool) {
+    s := statsSorts[key]
+    slices.SortStableFunc(ops, func(a, b OperatorStats) int {
+        va, oka := s.value(a)
+        vb, okb := s.value(b)
+        switch {
+        case oka != okb:
+            if oka {
+                return -1
+            }
+            return 1
+        case va != vb && oka:
+            if desc {
+                return cmp.Compare(vb, va)
+            }
+            return cmp.Compare(va, vb)
+        }
+        return cmp.Compare(a.TOC, b.TOC)
+    })
+}
+
+// The sort a request asks for
+func requestStatsSort(r *http.Request) (string, bool) {
+    key := r.URL.Query().Get("sort")
+    s, ok := statsSorts[key]
+    if !ok {
+        key, s = defaultStatsSort, statsSorts[defaultStatsSort]
+    }
+    desc := s.desc
+    switch r.URL.Query().Get("order") {
+    case "asc":
+        desc = false
+    case "desc":
+        desc = true
+    }
+    return key, desc
+}
+
+// A sortable heading: following it sorts by its column, best first, or
+// the other way round if the table is already sorted that way
+type statsHeading struct {
+    Key, Label, Query string
+    Active, Desc      bool
+}
+
+func statsHeadings(key string, desc bool) []statsHeading {
+    var hs []statsHeading
+    for _, h := range []struct{ key, label string }{
+        {"services", "stats_services"}, {"punctuality", "stats_punctuality"}, {"delay", "stats_avg_delay"}, {"cancelled", "stats_cancelled"},
+    } {
+        next := statsSorts[h.key].desc
+        if h.key == key {
+            next = !desc
+        }
+        hs = append(hs, statsHeading{h.key, h.label, statsQuery(h.key, next), h.key == key, desc})
+    }
+    return hs
+}
+
+var statsTmpl = template.Must(template.New("stats").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "stats_title"}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+    <script src="https://unpkg.com/htmx.org@1.9.10"></script>
+</head>
+<body>
+    <h1>{{T "stats_title"}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/">{{T "title"}}</a> | <a href="/disruptions">{{T "disruptions_title"}}</a></p>
+    <p class="muted">{{T "stats_note"}}</p>
+    <div id="stats" hx-get="/stats/table?{{.Query}}" hx-trigger="load" hx-swap="innerHTML">
+        <p>{{T "loading"}}</p>
+    </div>
+</body>
+</html>
+`))
+
+// Template for the leaderboard (htmx partial)
+var statsTableTmpl = template.Must(template.New("statsTable").Funcs(templateFuncs).Parse(`
+<table>
+    <tr>
+        <th>{{T "operator"}}</th>
+        {{range .Headings}}<th><a href="/stats?{{.Query}}" hx-get="/stats/table?{{.Query}}" hx-target="#stats" hx-push-url="/stats?{{.Query}}">{{T .Label}}</a>{{if .Active}} {{if .Desc}}▼{{else}}▲{{end}}{{end}}</th>{{end}}
+        <th>{{T "stats_running"}}</th>
+    </tr>
+    {{range .Operators}}
+    <tr class="toc-row toc-{{.TOC}}">
+        <td>{{operator .TOC}}</td>
+        <td>{{.Services}}</td>
+        <td>{{with .Punctuality}}{{.}}%{{else}}–{{end}}</td>
+        <td>{{if .AverageDelay}}{{T "stats_mins" .AverageDelayMins}}{{else}}–{{end}}</td>
+        <td>{{.CancelledPct}}%{{if or .Cancelled .PartCancelled}} <span class="muted">({{T "stats_full_part" .Cancelled .PartCancelled}})</span>{{end}}</td>
+        <td>{{.Running}}</td>
+    </tr>
+    {{else}}
+    <tr><td colspan="6">{{T "stats_none"}}</td></tr>
+    {{end}}
+</table>
+<span hx-get="/stats/table?{{.Query}}" hx-trigger="load delay:60s" hx-target="#stats" hx-swap="innerHTML"></span>
+`))
+
+// GET /stats: the operator leaderboard page
+func statsPageHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    key, desc := requestStatsSort(r)
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(statsTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme, Query string
+            }{lang, otherLang(lang), requestTheme(w, r), statsQuery(key, desc)}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any { return sortedOperatorStats(key, desc) },
+        Text: func(w io.Writer) { writeStatsText(w, sortedOperatorStats(key, desc), lang) },
+    })
+}
+
+// GET /stats/table: the leaderboard itself
+func statsTableHandler(w http.ResponseWriter, r *http.Request) {
+    tmpl, err := localisedTemplate(statsTableTmpl, requestLang(w, r))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    key, desc := requestStatsSort(r)
+    executeTemplate(w, r, tmpl, struct {
+        Query     string // for the next refresh, sorted the same way
+        Headings  []statsHeading
+        Operators []OperatorStats
+    }{statsQuery(key, desc), statsHeadings(key, desc), sortedOperatorStats(key, desc)})
+}
+
+func sortedOperatorStats(key string, desc bool) []OperatorStats {
+    ops := operatorStats(railwayFigures.current())
+    sortOperatorStats(ops, key, desc)
+    return ops
+}
+
+func statsQuery(key string, desc bool) string {
+    order := "asc"
+    if desc {
+        order = "desc"
+    }
+    return url.Values{"sort": {key}, "order": {order}}.Encode()
+}
+
+func writeStatsText(w io.Writer, ops []OperatorStats, lang string) {
+    figure := func(p *float64) string {
+        if p == nil {
+            return "-"
+        }
+        return strconv.FormatFloat(*p, 'f', 1, 64)
+    }
+    var rows [][]string
+    for _, o := range ops {
+        rows = append(rows, []string{o.TOC, strconv.Itoa(o.Services), figure(o.Punctuality), figure(o.AverageDelay), strconv.FormatFloat(o.CancelledPct, 'f', 1, 64), strconv.Itoa(o.Running)})
+    }
+    writeTextTable(w, []string{translate(lang, "operator"), translate(lang, "stats_services"), translate(lang, "stats_punctuality"), translate(lang, "stats_avg_delay"), translate(lang, "stats_cancelled"), translate(lang, "stats_running")}, rows)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/go-stomp/stomp"
+)
+
+// Network Rail open data feeds (TD, TRUST) share one STOMP broker
+const defaultNROpenDataAddr = "publicdatafeeds.networkrail.co.uk:61618"
+
+// Subscribe to a STOMP topic and pass each message body to handle,
+// reconnecting with backoff whenever the connection drops. Never returns.
+func consumeStompTopic(name, addr, username, password, topic string, handle func([]byte)) {
+    markFeedStarted(name)
+    handleMarked := func(body []byte) {
+        markFeedMessage(name)
+        handle(body)
+    }
+    backoff := time.Second
+    for {
+        connected, err := consumeStompOnce(name, addr, username, password, topic, handleMarked)
+        if connected {
+            backoff = time.Second
+        }
+        log.Printf("%s feed disconnected: %v (retrying in %s)", name, err, backoff)
+        time.Sleep(backoff)
+        if backoff < time.Minute {
+            backoff *= 2
+        }
+    }
+}
+
+func consumeStompOnce(name, addr, username, password, topic string, handle func([]byte)) (bool, error) {
+    conn, err := stomp.Dial("tcp", addr,
+        stomp.ConnOpt.Login(username, password),
+        stomp.ConnOpt.HeartBeat(15*time.Second, 15*time.Second),
+        stomp.ConnOpt.Host("/"),
+    )
+    if err != nil {
+        return false, err
+    }
+    defer conn.Disconnect()
+
+    sub, err := conn.Subscribe(topic, stomp.AckAuto)
+    if err != nil {
+        return true, err
+    }
+    log.Printf("Subscribed to %s on %s", topic, addr)
+    noteFeedConnected(name)
+    for msg := range sub.C {
+        if msg.Err != nil {
+            return true, msg.Err
+        }
+        handle(msg.Body)
+    }
+    return true, fmt.Errorf("subscription to %s closed", topic)
+}
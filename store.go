@@ -0,0 +1,148 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "slices"
+    "time"
+)
+
+// Live progress of every train we've had updates for, keyed by RID.
+// Backends are chosen with PROGRESS_STORE (memory, sqlite, redis or
+// dynamodb).
+//
+// What Get returns is the caller's own: it shares no slices or pointers
+// with the store, so a page can be rendered from it while ingest goes on
+// writing, and changing it doesn't change the store. Update's fn works on
+// a private copy in the same way, which replaces the stored progress once
+// fn returns.
+type ProgressStore interface {
+    Get(rid string) (TrainProgress, bool, error)
+    Put(p TrainProgress) error
+    // Read-modify-write; fn gets a zero TrainProgress if rid is unknown.
+    // Stores shared between hosts may call fn again on newer progress if
+    // another writer got in first.
+    Update(rid string, fn func(p *TrainProgress)) error
+    Close() error
+}
+
+var progressStore ProgressStore = newMemoryStore()
+
+// Implemented by shared stores that fan live schedule changes out to
+// other instances
+type schedulePublisher interface {
+    PublishSchedule(j *Journey)
+}
+
+func initProgressStore() error {
+    var (
+        store ProgressStore
+        err   error
+    )
+    switch kind := envOr("PROGRESS_STORE", "memory"); kind {
+    case "memory":
+        store = newMemoryStore()
+    case "sqlite":
+        store, err = newSQLiteStore(envOr("SQLITE_PATH", "minimaltrains.db"))
+    case "redis":
+        store, err = newRedisStore(envOr("REDIS_URL", "redis://localhost:6379/0"))
+    case "dynamodb":
+        store, err = newDynamoStore(envOr("DYNAMODB_TABLE", "minimaltrains-progress"))
+    default:
+        return fmt.Errorf("unknown PROGRESS_STORE %q", kind)
+    }
+    if err != nil {
+        return err
+    }
+    if b, ok := store.(batchPutter); ok && envOr("STORE_BATCH", "on") != "off" {
+        store = newBatchingStore(store, b)
+    }
+    progressStore = store
+    log.Printf("Using %T for train progress", store)
+    return nil
+}
+
+// Deep copy of a progress, sharing nothing with the original
+func cloneProgress(p TrainProgress) TrainProgress {
+    p.Stops = slices.Clone(p.Stops)
+    p.Events = slices.Clone(p.Events)
+    for i := range p.Events {
+        p.Events[i].Tiplocs = slices.Clone(p.Events[i].Tiplocs)
+    }
+    p.PreviousRIDs = slices.Clone(p.PreviousRIDs)
+    if p.Position != nil {
+        pos := *p.Position
+        p.Position = &pos
+    }
+    return p
+}
+
+type memoryStore struct {
+    progress *shardedMap[TrainProgress]
+}
+
+func newMemoryStore() *memoryStore {
+    return &memoryStore{progress: newShardedMap[TrainProgress]()}
+}
+
+func (m *memoryStore) Get(rid string) (TrainProgress, bool, error) {
+    p, ok := m.progress.load(rid)
+    return cloneProgress(p), ok, nil
+}
+
+func (m *memoryStore) Put(p TrainProgress) error {
+    m.progress.store(p.RID, cloneProgress(p))
+    return nil
+}
+
+func (m *memoryStore) Update(rid string, fn func(p *TrainProgress)) error {
+    m.progress.update(rid, func(old TrainProgress, _ bool) TrainProgress {
+        p := cloneProgress(old)
+        fn(&p)
+        p.RID = rid
+        return p
+    })
+    return nil
+}
+
+func (m *memoryStore) Close() error { return nil }
+
+func (m *memoryStore) EvictFinished(before time.Time) ([]string, error) {
+    return m.progress.deleteFunc(func(_ string, p TrainProgress) bool {
+        return !p.FinishedAt.IsZero() && p.FinishedAt.Before(before)
+    }), nil
+}
+
+// Copy of every train's progress, for snapshots
+func (m *memoryStore) all() map[string]TrainProgress {
+    all := map[string]TrainProgress{}
+    m.progress.each(func(rid string, p TrainProgress) {
+        all[rid] = cloneProgress(p)
+    })
+    return all
+}
+
+func (m *memoryStore) restore(progress map[string]TrainProgress) {
+    for rid, p := range progress {
+        m.progress.store(rid, p)
+    }
+}
+
+// Update for backends without native read-modify-write. Serialised per
+// train within the process; only one ingester should write to a shared
+// store.
+type getPutStore interface {
+    Get(rid string) (TrainProgress, bool, error)
+    Put(p TrainProgress) error
+}
+
+func updateViaGetPut(locks *ridLocks, s getPutStore, rid string, fn func(p *TrainProgress)) error {
+    defer locks.lock(rid)()
+    p, _, err := s.Get(rid)
+    if err != nil {
+        return err
+    }
+    fn(&p)
+    p.RID = rid
+    return s.Put(p)
+}
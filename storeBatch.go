@@ -0,0 +1,172 @@
+package main
+
+import (
+    "log"
+    "sync"
+    "time"
+)
+
+// Backends that can write many trains' progress at once
+type batchPutter interface {
+    PutBatch(ps []TrainProgress) error
+}
+
+// Write-behind in front of a store: Puts land in memory and a single
+// writer flushes them in batches, so a burst of TS messages costs a few
+// transactions instead of one per message. Reads see pending writes. Once
+// queueSize trains are waiting, Put blocks until the writer catches up.
+type batchingStore struct {
+    ProgressStore
+    batch     batchPutter
+    maxBatch  int
+    interval  time.Duration
+    queue     chan string // RIDs with a pending write, each queued once
+    mu        sync.Mutex
+    pending   map[string]pendingPut
+    seq       uint64
+    locks     ridLocks
+    flushed   chan struct{}
+    closeOnce sync.Once
+}
+
+// The latest unwritten progress for a train. seq tells a Put made during
+// a flush apart from the one being flushed.
+type pendingPut struct {
+    p   TrainProgress
+    seq uint64
+}
+
+// Wrap a store that supports PutBatch, sized from STORE_BATCH_SIZE (200),
+// STORE_BATCH_INTERVAL (100ms) and STORE_QUEUE_SIZE (5000)
+func newBatchingStore(s ProgressStore, b batchPutter) *batchingStore {
+    interval, err := time.ParseDuration(envOr("STORE_BATCH_INTERVAL", "100ms"))
+    if err != nil || interval <= 0 {
+        log.Printf("Invalid STORE_BATCH_INTERVAL; using 100ms")
+        interval = 100 * time.Millisecond
+    }
+    bs := &batchingStore{
+        ProgressStore: s,
+        batch:         b,
+        maxBatch:      max(1, envInt("STORE_BATCH_SIZE", 200)),
+        interval:      interval,
+        queue:         make(chan string, max(1, envInt("STORE_QUEUE_SIZE", 5000))),
+        pending:       map[string]pendingPut{},
+        flushed:       make(chan struct{}),
+    }
+    go bs.writer()
+    return bs
+}
+
+func (b *batchingStore) Get(rid string) (TrainProgress, bool, error) {
+    b.mu.Lock()
+    w, ok := b.pending[rid]
+    b.mu.Unlock()
+    if ok {
+        return cloneProgress(w.p), true, nil
+    }
+    return b.ProgressStore.Get(rid)
+}
+
+func (b *batchingStore) Put(p TrainProgress) error {
+    b.mu.Lock()
+    _, queued := b.pending[p.RID]
+    b.seq++
+    b.pending[p.RID] = pendingPut{cloneProgress(p), b.seq}
+    b.mu.Unlock()
+    if !queued {
+        b.queue <- p.RID
+    }
+    return nil
+}
+
+func (b *batchingStore) Update(rid string, fn func(p *TrainProgress)) error {
+    return updateViaGetPut(&b.locks, b, rid, fn)
+}
+
+// Collect queued RIDs until the batch is full or the interval passes, then
+// write them together
+func (b *batchingStore) writer() {
+    defer close(b.flushed)
+    var rids []string
+    timer := time.NewTimer(b.interval)
+    for {
+        select {
+        case rid, ok := <-b.queue:
+            if !ok {
+                b.flush(rids)
+                return
+            }
+            rids = append(rids, rid)
+            if len(rids) < b.maxBatch {
+                continue
+            }
+        case <-timer.C:
+        }
+        rids = b.flush(rids)
+        timer.Reset(b.interval)
+    }
+}
+
+// Write the pending progress for rids, returning those to try again
+func (b *batchingStore) flush(rids []string) []string {
+    if len(rids) == 0 {
+        return nil
+    }
+    b.mu.Lock()
+    var (
+        batch   []TrainProgress
+        written []pendingPut
+    )
+    for _, rid := range rids {
+        // Evicted trains drop out of pending and aren't written
+        if w, ok := b.pending[rid]; ok {
+            batch = append(batch, w.p)
+            written = append(written, w)
+        }
+    }
+    b.mu.Unlock()
+    if len(batch) == 0 {
+        return nil
+    }
+
+    if err := b.batch.PutBatch(batch); err != nil {
+        log.Printf("Failed to write %d progress updates: %v", len(batch), err)
+        return rids
+    }
+    // Drop what was written, unless a newer Put arrived meanwhile; that
+    // one stays pending and goes out with the next batch
+    b.mu.Lock()
+    var again []string
+    for _, w := range written {
+        if cur, ok := b.pending[w.p.RID]; ok && cur.seq == w.seq {
+            delete(b.pending, w.p.RID)
+        } else if ok {
+            again = append(again, w.p.RID)
+        }
+    }
+    b.mu.Unlock()
+    return again
+}
+
+// Write everything still pending and close the store underneath
+func (b *batchingStore) Close() error {
+    b.closeOnce.Do(func() { close(b.queue) })
+    <-b.flushed
+    return b.ProgressStore.Close()
+}
+
+// Flush before evicting so pending writes aren't resurrected afterwards
+func (b *batchingStore) EvictFinished(before time.Time) ([]string, error) {
+    e, ok := b.ProgressStore.(finishedEvicter)
+    if !ok {
+        return nil, nil
+    }
+    b.mu.Lock()
+    for rid, w := range b.pending {
+        if !w.p.FinishedAt.IsZero() && w.p.FinishedAt.Before(before) {
+            delete(b.pending, rid)
+        }
+    }
+    b.mu.Unlock()
+    return e.EvictFinished(before)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "log"
+    "net"
+    "os"
+    "strconv"
+    "time"
+)
+
+// Send a state string such as READY=1 to systemd, if we were started as
+// a Type=notify service. Does nothing without NOTIFY_SOCKET.
+func sdNotify(state string) {
+    socket := os.Getenv("NOTIFY_SOCKET")
+    if socket == "" {
+        return
+    }
+    // Leading @ means an abstract socket
+    if socket[0] == '@' {
+        socket = "\x00" + socket[1:]
+    }
+    conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+    if err != nil {
+        log.Printf("Failed to notify systemd: %v", err)
+        return
+    }
+    defer conn.Close()
+    if _, err := conn.Write([]byte(state)); err != nil {
+        log.Printf("Failed to notify systemd: %v", err)
+    }
+}
+
+// Interval systemd expects watchdog pings at, if WatchdogSec is set for us
+func watchdogInterval() (time.Duration, bool) {
+    usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+    if err != nil || usec <= 0 {
+        return 0, false
+    }
+    if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+        return 0, false
+    }
+    return time.Duration(usec) * time.Microsecond, true
+}
+
+// Ping the systemd watchdog at half its interval while ingestion is
+// healthy. Once the feed stalls the pings stop, and systemd restarts us.
+func startWatchdog() {
+    interval, ok := watchdogInterval()
+    if !ok {
+        return
+    }
+    log.Printf("Pinging systemd watchdog every %s", interval/2)
+    stalled := false
+    for range time.Tick(interval / 2) {
+        if !ingestHealthy() {
+            if !stalled {
+                log.Printf("Darwin feed stalled; withholding watchdog pings")
+                sdNotify("STATUS=Darwin feed stalled")
+            }
+            stalled = true
+            continue
+        }
+        if stalled {
+            sdNotify("STATUS=Running")
+        }
+        stalled = false
+        sdNotify("WATCHDOG=1")
+    }
+}
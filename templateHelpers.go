@@ -0,0 +1,68 @@
+package main
+
+import (
+    "html/template"
+    "log"
+    "strings"
+)
+
+// Snippets of HTML that funcs hand back to the page templates are built
+// from templates too, so upstream data in them gets the same contextual
+// escaping as anything else on the page
+
+var operatorBadgeTmpl = template.Must(template.New("operatorBadge").Parse(
+    `<span class="toc-badge toc-{{.TOC}}">{{.Name}}</span>`))
+
+var modeBadgeTmpl = template.Must(template.New("modeBadge").Parse(
+    `<span class="mode mode-{{.Mode}}" title="{{.Label}}">{{.Icon}} {{.Label}}</span> `))
+
+var platformTmpl = template.Must(template.New("platform").Parse(
+    `<span class="platform platform-{{if .Confirmed}}confirmed{{else}}expected{{end}}" title="{{.Title}}">{{.Number}}</span>`))
+
+var delayBadgeTmpl = template.Must(template.New("delayBadge").Parse(
+    `<span class="delay late" title="{{.Title}}">+{{.Mins}}</span>`))
+
+// Execute a snippet template, giving nothing if it fails
+func htmlFragment(t *template.Template, data any) template.HTML {
+    var b strings.Builder
+    if err := t.Execute(&b, data); err != nil {
+        log.Printf("Failed to render %s: %v", t.Name(), err)
+        return ""
+    }
+    return template.HTML(b.String())
+}
+
+// A Darwin time as HH:MM, dropping the seconds of working times
+func hhmm(s string) string {
+    if _, ok := parseRailTime(s); ok && len(s) > 5 {
+        return s[:5]
+    }
+    return s
+}
+
+// "+5" beside an expected or actual time that's behind schedule
+func delayBadge(lang, scheduled, expected string) template.HTML {
+    late, ok := minutesLate(scheduled, expected)
+    if !ok || late <= 0 {
+        return ""
+    }
+    return htmlFragment(delayBadgeTmpl, struct {
+        Title string
+        Mins  int
+    }{translate(lang, "card_late", late), late})
+}
+
+// A platform number, styled by whether it's confirmed or only expected
+func platformBadge(lang, number string, confirmed bool) template.HTML {
+    if number == "" {
+        return ""
+    }
+    title := translate(lang, "plat_expected")
+    if confirmed {
+        title = translate(lang, "plat_confirmed")
+    }
+    return htmlFragment(platformTmpl, struct {
+        Number, Title string
+        Confirmed     bool
+    }{number, title, confirmed})
+}
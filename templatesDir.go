@@ -0,0 +1,128 @@
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "log"
+    "maps"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "text/template/parse"
+)
+
+// Deployments can restyle pages without forking by pointing
+// --templates-dir (or TEMPLATES_DIR) at a directory of overrides, one
+// <name>.html per page, named after the template it replaces. An override
+// is parsed over a copy of the built-in template, so it sees the same
+// funcs, and a file of {{define}}s alone changes only those blocks. Every
+// file must name a known template and parse, or startup fails rather than
+// serving broken pages.
+var overridableTemplates = map[string]**template.Template{
+    "page":          &pageTmpl,
+    "progress":      &progressTmpl,
+    "boardPage":     &boardPageTmpl,
+    "board":         &boardTmpl,
+    "commuteStatus": &commuteStatusTmpl,
+    "commutes":      &commutesTmpl,
+    "compare":       &compareTmpl,
+    "disruptions":   &disruptionsTmpl,
+    "embed":         &embedTmpl,
+    "stations":      &gazetteerTmpl,
+    "near":          &nearTmpl,
+    "trainChooser":  &trainChooserTmpl,
+    "walkUp":        &walkUpTmpl,
+    "stats":         &statsTmpl,
+    "statsTable":    &statsTableTmpl,
+    "loading":       &loadingTmpl,
+    "error":         &errorFragmentTmpl,
+}
+
+// Layer the overrides in dir over the built-in templates. Nothing is
+// replaced unless all of them load.
+func loadTemplateOverrides(dir string) (int, error) {
+    if _, err := os.Stat(dir); err != nil {
+        return 0, err
+    }
+    paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+    if err != nil {
+        return 0, err
+    }
+    loaded := map[string]*template.Template{}
+    for _, path := range paths {
+        name := strings.TrimSuffix(filepath.Base(path), ".html")
+        builtin, ok := overridableTemplates[name]
+        if !ok {
+            return 0, fmt.Errorf("%s doesn't name a template; expected one of %s", path, strings.Join(slices.Sorted(maps.Keys(overridableTemplates)), ", "))
+        }
+        b, err := os.ReadFile(path)
+        if err != nil {
+            return 0, err
+        }
+        t, err := (*builtin).Clone()
+        if err != nil {
+            return 0, fmt.Errorf("%s: %w", name, err)
+        }
+        if t, err = t.Parse(string(b)); err != nil {
+            return 0, fmt.Errorf("parse %s: %w", path, err)
+        }
+        if missing := undefinedTemplates(t); len(missing) > 0 {
+            return 0, fmt.Errorf("%s uses templates it doesn't define: %s", path, strings.Join(missing, ", "))
+        }
+        loaded[name] = t
+    }
+    for name, t := range loaded {
+        *overridableTemplates[name] = t
+    }
+    return len(loaded), nil
+}
+
+// Apply --templates-dir, if set, before anything is served
+func initTemplateOverrides(dir string) {
+    if dir == "" {
+        return
+    }
+    n, err := loadTemplateOverrides(dir)
+    if err != nil {
+        log.Fatalf("Failed to load templates from %s: %v", dir, err)
+    }
+    log.Printf("Loaded %d template overrides from %s", n, dir)
+}
+
+// Names {{template}}d anywhere in t that aren't defined alongside it,
+// which would otherwise only fail when the page is first served
+func undefinedTemplates(t *template.Template) []string {
+    var missing []string
+    var walk func(n parse.Node)
+    walk = func(n parse.Node) {
+        switch n := n.(type) {
+        case *parse.ListNode:
+            if n == nil {
+                return
+            }
+            for _, c := range n.Nodes {
+                walk(c)
+            }
+        case *parse.TemplateNode:
+            if t.Lookup(n.Name) == nil && !slices.Contains(missing, n.Name) {
+                missing = append(missing, n.Name)
+            }
+        case *parse.IfNode:
+            walk(n.List)
+            walk(n.ElseList)
+        case *parse.RangeNode:
+            walk(n.List)
+            walk(n.ElseList)
+        case *parse.WithNode:
+            walk(n.List)
+            walk(n.ElseList)
+        }
+    }
+    for _, d := range t.Templates() {
+        if d.Tree != nil {
+            walk(d.Tree.Root)
+        }
+    }
+    return missing
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+// A theme is a set of CSS custom properties, without the leading "--"
+type Theme map[string]string
+
+// Built-in themes. THEME_FILE can add more or override these.
+var themes = map[string]Theme{
+    "light": {
+        "bg":        "#ffffff",
+        "fg":        "#1b1b1b",
+        "muted":     "#6b6b6b",
+        "accent":    "#0b5cad",
+        "on-time":   "#1d7a35",
+        "late":      "#b35900",
+        "cancelled": "#c0152f",
+        "font":      "system-ui, sans-serif",
+    },
+    "dark": {
+        "bg":        "#111418",
+        "fg":        "#e8e8e8",
+        "muted":     "#9a9a9a",
+        "accent":    "#6cb4ff",
+        "on-time":   "#5fd47d",
+        "late":      "#ffb347",
+        "cancelled": "#ff6b81",
+        "font":      "system-ui, sans-serif",
+    },
+    // Classic dot-matrix style for station kiosks
+    "departure-board": {
+        "bg":        "#000000",
+        "fg":        "#ffb000",
+        "muted":     "#b37b00",
+        "accent":    "#ffd000",
+        "on-time":   "#ffb000",
+        "late":      "#ffd000",
+        "cancelled": "#ff4000",
+        "font":      "\"Courier New\", monospace",
+    },
+}
+
+// "auto" follows the browser's prefers-color-scheme
+const autoTheme = "auto"
+
+// Stylesheet that consumes the theme variables, so deployments only
+// need to change variables rather than fork the templates
+const baseCSS = `
+body { background: var(--bg); color: var(--fg); font-family: var(--font); }
+a { color: var(--accent); }
+.muted { color: var(--muted); }
+.on-time { color: var(--on-time); }
+.late { color: var(--late); }
+.cancelled { color: var(--cancelled); }
+.mode { border: 1px solid var(--muted); border-radius: 3px; padding: 0 3px; font-size: 0.85em; }
+.toc-row td:first-child { border-left: 4px solid var(--toc, transparent); padding-left: 4px; }
+.fastest { background: var(--on-time); color: var(--bg); border-radius: 3px; padding: 0 4px; font-size: 0.85em; }
+.delay { font-size: 0.85em; }
+.platform-expected { color: var(--muted); font-style: italic; }
+.platform-confirmed { font-weight: bold; }
+.nrcc { border-left: 4px solid var(--late); padding-left: 8px; margin: 8px 0; }
+.nrcc-priority { border-left-color: var(--cancelled); font-weight: bold; font-size: 1.1em; }
+.stale { border: 1px solid var(--late); border-radius: 3px; padding: 0 3px; font-size: 0.85em; }
+.nrcc-info summary { color: var(--muted); cursor: pointer; }
+.toc-badge { background: var(--toc, var(--muted)); color: #ffffff; border-radius: 3px; padding: 0 4px; font-size: 0.85em; }
+`
+
+// Merge themes from a JSON file of {"name": {"var": "value"}}. Custom
+// themes start from the light theme so they only need to list changes.
+func loadThemeFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var custom map[string]Theme
+    if err := json.Unmarshal(data, &custom); err != nil {
+        return err
+    }
+    for name, vars := range custom {
+        base, ok := themes[name]
+        if !ok {
+            base = themes["light"]
+        }
+        merged := Theme{}
+        for k, v := range base {
+            merged[k] = v
+        }
+        for k, v := range vars {
+            merged[strings.TrimPrefix(k, "--")] = v
+        }
+        themes[name] = merged
+    }
+    return nil
+}
+
+// Load THEME_FILE if configured
+func initThemes() {
+    if path := os.Getenv("THEME_FILE"); path != "" {
+        if err := loadThemeFile(path); err != nil {
+            log.Printf("Failed to load theme file %s: %v", path, err)
+        }
+    }
+}
+
+func validTheme(name string) bool {
+    _, ok := themes[name]
+    return ok || name == autoTheme
+}
+
+// Pick the theme for a request: ?theme= (remembered in a cookie), then
+// the theme cookie, then the THEME default
+func requestTheme(w http.ResponseWriter, r *http.Request) string {
+    if name := r.URL.Query().Get("theme"); validTheme(name) {
+        http.SetCookie(w, &http.Cookie{Name: "theme", Value: name, Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds())})
+        return name
+    }
+    if c, err := r.Cookie("theme"); err == nil && validTheme(c.Value) {
+        return c.Value
+    }
+    if name := envOr("THEME", autoTheme); validTheme(name) {
+        return name
+    }
+    return autoTheme
+}
+
+func writeThemeVars(b *strings.Builder, t Theme) {
+    keys := make([]string, 0, len(t))
+    for k := range t {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        fmt.Fprintf(b, "  --%s: %s;\n", k, t[k])
+    }
+}
+
+// Operator brand colours with a theme's own variables layered on top
+func withOperatorColours(brand, t Theme) Theme {
+    merged := Theme{}
+    for k, v := range brand {
+        merged[k] = v
+    }
+    for k, v := range t {
+        merged[k] = v
+    }
+    return merged
+}
+
+// Serve the selected theme's variables plus the base stylesheet
+func themeCSSHandler(w http.ResponseWriter, r *http.Request) {
+    css := themeStylesheet(requestTheme(w, r))
+    w.Header().Set("Content-Type", "text/css; charset=utf-8")
+    w.Header().Set("Vary", "Cookie")
+    w.Write([]byte(css))
+}
+
+// A theme's variables followed by the base stylesheet
+func themeStylesheet(name string) string {
+    brand := operatorThemeVars()
+    var b strings.Builder
+    if name == autoTheme {
+        b.WriteString(":root {\n")
+        writeThemeVars(&b, withOperatorColours(brand, themes["light"]))
+        b.WriteString("}\n@media (prefers-color-scheme: dark) {\n:root {\n")
+        writeThemeVars(&b, withOperatorColours(brand, themes["dark"]))
+        b.WriteString("}\n}\n")
+    } else {
+        b.WriteString(":root {\n")
+        writeThemeVars(&b, withOperatorColours(brand, themes[name]))
+        b.WriteString("}\n")
+    }
+    b.WriteString(baseCSS)
+    b.WriteString(operatorCSS(brand))
+    return b.String()
+}
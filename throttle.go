@@ -0,0 +1,99 @@
+package main
+
+import (
+    "sync"
+    "time"
+)
+
+// Wraps a notifier so a subscriber hears about each train at most
+// maxPerHour times an hour, and not at all when its delay has only moved a
+// minute or two since the last alert. Notifications without a Train, such
+// as digests, pass straight through.
+type throttledNotifier struct {
+    next       Notifier
+    maxPerHour int
+    minChange  int // minutes the delay must move by to be worth another alert
+
+    mu   sync.Mutex
+    sent map[throttleKey]*throttleState
+}
+
+type throttleKey struct{ to, train string }
+
+// What a subscriber was last told about a train
+type throttleState struct {
+    times     []time.Time // sends in the last hour
+    delay     int
+    cancelled bool
+}
+
+// Throttle from NOTIFY_MAX_PER_TRAIN_HOUR (default 4) and
+// NOTIFY_MIN_DELAY_CHANGE (default 3 minutes)
+func newThrottledNotifier(next Notifier) *throttledNotifier {
+    t := &throttledNotifier{sent: map[throttleKey]*throttleState{}}
+    t.reconfigure(next)
+    return t
+}
+
+// Swap the notifier behind the throttle and read its limits again,
+// keeping what's been sent so far
+func (t *throttledNotifier) reconfigure(next Notifier) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.next = next
+    t.maxPerHour = envInt("NOTIFY_MAX_PER_TRAIN_HOUR", 4)
+    t.minChange = envInt("NOTIFY_MIN_DELAY_CHANGE", 3)
+}
+
+func (t *throttledNotifier) Notify(n Notification) error {
+    t.mu.Lock()
+    next := t.next
+    if n.Train == "" {
+        t.mu.Unlock()
+        return next.Notify(n)
+    }
+    key := throttleKey{n.To, n.Train}
+    now := clock.Now()
+
+    // Forget trains nobody has been alerted about for a day
+    for k, st := range t.sent {
+        if len(st.times) == 0 || now.Sub(st.times[len(st.times)-1]) > 24*time.Hour {
+            delete(t.sent, k)
+        }
+    }
+    st, seen := t.sent[key]
+    if !seen {
+        st = &throttleState{}
+        t.sent[key] = st
+    }
+    recent := st.times[:0]
+    for _, at := range st.times {
+        if now.Sub(at) < time.Hour {
+            recent = append(recent, at)
+        }
+    }
+    st.times = recent
+    // A cancellation always gets through; otherwise coalesce small changes
+    // and keep to the hourly quota
+    newlyCancelled := n.Cancelled && !st.cancelled
+    skip := !newlyCancelled && ((seen && n.Cancelled == st.cancelled && abs(n.Delay-st.delay) < t.minChange) ||
+        len(st.times) >= t.maxPerHour)
+    if !skip {
+        // Recorded before sending so alerts racing in behind this one are
+        // throttled too; a failed send still counts towards the quota
+        st.times = append(st.times, now)
+        st.delay, st.cancelled = n.Delay, n.Cancelled
+    }
+    t.mu.Unlock()
+    if skip {
+        return nil
+    }
+    return next.Notify(n)
+}
+
+func abs(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
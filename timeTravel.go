@@ -0,0 +1,272 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Timetable snapshots from past days, for investigating what was planned
+// then. The admin page lists those in the Darwin bucket and any kept in
+// TIMETABLE_ARCHIVE_DIR, and one at a time can be loaded for "time
+// travel": its day's schedules are then answered by journeysOn, so the
+// timetable search works for that date, without touching today's state.
+
+type TimetableSnapshot struct {
+    Source   string    `json:"source"` // s3 or local
+    Name     string    `json:"name"`
+    Date     string    `json:"date,omitempty"` // from the name, 2006-01-02
+    Size     int64     `json:"size"`
+    Modified time.Time `json:"modified"`
+}
+
+// The snapshot loaded for time travel, if any
+type timeTravelDay struct {
+    Snapshot TimetableSnapshot `json:"snapshot"`
+    Date     string            `json:"date"`
+    Journeys int               `json:"journeys"`
+    LoadedAt time.Time         `json:"loaded_at"`
+    journeys map[string]*Journey
+}
+
+var (
+    timeTravel   *timeTravelDay
+    timeTravelMu sync.RWMutex
+)
+
+// Darwin names its snapshots from the date they're for, e.g.
+// PPTimetable/20261013020525_v8.xml.gz
+func snapshotDate(name string) string {
+    base := path.Base(name)
+    if len(base) < 8 {
+        return ""
+    }
+    t, err := time.Parse("20060102", base[:8])
+    if err != nil {
+        return ""
+    }
+    return t.Format("2006-01-02")
+}
+
+func isTimetableSnapshot(name string) bool {
+    return strings.HasSuffix(name, "_v8.xml.gz") || strings.HasSuffix(name, "_v8.xml")
+}
+
+// Snapshots in the bucket (when the timetable comes from S3) and in
+// TIMETABLE_ARCHIVE_DIR, newest first
+func listTimetableSnapshots(ctx context.Context) ([]TimetableSnapshot, error) {
+    var out []TimetableSnapshot
+    if dir := envOr("TIMETABLE_ARCHIVE_DIR", ""); dir != "" {
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            return nil, err
+        }
+        for _, e := range entries {
+            info, err := e.Info()
+            if err != nil || !info.Mode().IsRegular() || !isTimetableSnapshot(e.Name()) {
+                continue
+            }
+            out = append(out, TimetableSnapshot{"local", e.Name(), snapshotDate(e.Name()), info.Size(), info.ModTime()})
+        }
+    }
+    if isS3Source(timetableSource) {
+        client, err := darwinS3Client(ctx)
+        if err != nil {
+            return nil, err
+        }
+        objects, err := listTimetableObjects(ctx, client)
+        if err != nil {
+            return nil, fmt.Errorf("list S3 objects: %w", err)
+        }
+        for _, obj := range objects {
+            if key := *obj.Key; strings.HasSuffix(key, "_v8.xml.gz") {
+                out = append(out, TimetableSnapshot{"s3", key, snapshotDate(key), *obj.Size, *obj.LastModified})
+            }
+        }
+    }
+    slices.SortFunc(out, func(a, b TimetableSnapshot) int { return b.Modified.Compare(a.Modified) })
+    return out, nil
+}
+
+// Load a listed snapshot for time travel. Its day is the one most of its
+// schedules start on.
+func loadTimeTravel(ctx context.Context, source, name string) (*timeTravelDay, error) {
+    snapshots, err := listTimetableSnapshots(ctx)
+    if err != nil {
+        return nil, err
+    }
+    i := slices.IndexFunc(snapshots, func(s TimetableSnapshot) bool { return s.Source == source && s.Name == name })
+    if i < 0 {
+        return nil, fmt.Errorf("no %s snapshot %q", source, name)
+    }
+    var parsed map[string]*Journey
+    read := func(r io.Reader) error {
+        var err error
+        parsed, err = parseTimetable(r)
+        return err
+    }
+    if source == "s3" {
+        client, err := darwinS3Client(ctx)
+        if err != nil {
+            return nil, err
+        }
+        err = withS3Gzip(ctx, client, timetableBucket, name, read)
+    } else {
+        // name is one ReadDir gave, so it can't leave the directory
+        err = withSource(filepath.Join(envOr("TIMETABLE_ARCHIVE_DIR", ""), name), read)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    days := map[string]int{}
+    for _, j := range parsed {
+        days[j.SSD]++
+    }
+    day := &timeTravelDay{Snapshot: snapshots[i], LoadedAt: clock.Now(), journeys: map[string]*Journey{}}
+    for d, n := range days {
+        if n > days[day.Date] || (n == days[day.Date] && d < day.Date) {
+            day.Date = d
+        }
+    }
+    for rid, j := range parsed {
+        if j.SSD == day.Date {
+            day.journeys[rid] = j
+        }
+    }
+    day.Journeys = len(day.journeys)
+    timeTravelMu.Lock()
+    timeTravel = day
+    timeTravelMu.Unlock()
+    log.Printf("Time travel: loaded %d journeys for %s from %s", day.Journeys, day.Date, name)
+    return day, nil
+}
+
+// Journeys of the time-travel day, if date is it
+func timeTravelJourneys(date string) ([]*Journey, bool) {
+    timeTravelMu.RLock()
+    defer timeTravelMu.RUnlock()
+    if timeTravel == nil || timeTravel.Date != date {
+        return nil, false
+    }
+    out := make([]*Journey, 0, len(timeTravel.journeys))
+    for _, j := range timeTravel.journeys {
+        out = append(out, j)
+    }
+    return out, true
+}
+
+func currentTimeTravel() *timeTravelDay {
+    timeTravelMu.RLock()
+    defer timeTravelMu.RUnlock()
+    return timeTravel
+}
+
+var snapshotsTmpl = template.Must(template.New("snapshots").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Timetable snapshots</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>Timetable snapshots</h1>
+    {{with .TimeTravel}}
+    <p><strong>Time travel:</strong> {{.Journeys}} journeys for {{.Date}} from {{.Snapshot.Name}},
+        searchable at <a href="/api/v1/timetable/{{.Date}}">/api/v1/timetable/{{.Date}}</a>. Today's live state is unaffected.</p>
+    <form method="post" action="/admin/snapshots/unload"><input type="hidden" name="csrf" value="{{$.CSRF}}"><button type="submit">Return to today</button></form>
+    {{end}}
+    {{with .Parse}}
+    <p><strong>{{if .Finished}}Last timetable load:{{else}}Loading timetable:{{end}}</strong> {{.Source}}, {{.Journeys}} journeys,
+        {{.BytesRead}}{{with .BytesTotal}} of {{.}}{{end}} bytes read{{with .ETA}}, finishing about {{.Format "15:04:05"}}{{end}}{{with .Finished}}, {{if $.Parse.Error}}failed{{else}}done{{end}} at {{.Format "15:04:05"}}{{end}}{{with .Error}}: {{.}}{{end}}</p>
+    {{end}}
+    {{with .Error}}<p class="late">{{.}}</p>{{end}}
+    <table>
+        <tr><th>Date</th><th>Source</th><th>Name</th><th>Size</th><th>Modified</th><th></th></tr>
+        {{range .Snapshots}}
+        <tr>
+            <td>{{.Date}}</td>
+            <td>{{.Source}}</td>
+            <td>{{.Name}}</td>
+            <td>{{.Size}}</td>
+            <td>{{.Modified.Format "2006-01-02 15:04"}}</td>
+            <td><form method="post" action="/admin/snapshots/load"><input type="hidden" name="csrf" value="{{$.CSRF}}"><input type="hidden" name="source" value="{{.Source}}"><input type="hidden" name="name" value="{{.Name}}"><button type="submit">Load</button></form></td>
+        </tr>
+        {{else}}
+        <tr><td colspan="6">No snapshots found</td></tr>
+        {{end}}
+    </table>
+</body>
+</html>
+`))
+
+// GET /admin/snapshots
+func snapshotsHandler(w http.ResponseWriter, r *http.Request) {
+    snapshots, err := listTimetableSnapshots(r.Context())
+    if err != nil {
+        log.Printf("Failed to list timetable snapshots: %v", err)
+    }
+    tt := currentTimeTravel()
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            data := struct {
+                Theme      string
+                TimeTravel *timeTravelDay
+                Snapshots  []TimetableSnapshot
+                Error      error
+                Parse      *ParseProgress
+                CSRF       string
+            }{requestTheme(w, r), tt, snapshots, err, parseProgress(), csrfToken(r)}
+            executeTemplate(w, r, snapshotsTmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                TimeTravel *timeTravelDay      `json:"time_travel"`
+                Snapshots  []TimetableSnapshot `json:"snapshots"`
+                Parse      *ParseProgress      `json:"timetable_parse,omitempty"`
+            }{tt, snapshots, parseProgress()}
+        },
+        Text: func(w io.Writer) {
+            if tt != nil {
+                fmt.Fprintf(w, "Time travel: %d journeys for %s from %s\n\n", tt.Journeys, tt.Date, tt.Snapshot.Name)
+            }
+            var rows [][]string
+            for _, s := range snapshots {
+                rows = append(rows, []string{s.Date, s.Source, s.Name, strconv.FormatInt(s.Size, 10), s.Modified.Format("2006-01-02 15:04")})
+            }
+            writeTextTable(w, []string{"Date", "Source", "Name", "Size", "Modified"}, rows)
+        },
+    })
+}
+
+// POST /admin/snapshots/load with source and name
+func loadSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+    if _, err := loadTimeTravel(r.Context(), r.FormValue("source"), r.FormValue("name")); err != nil {
+        log.Printf("Failed to load timetable snapshot: %v", err)
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    http.Redirect(w, r, "/admin/snapshots", http.StatusSeeOther)
+}
+
+// POST /admin/snapshots/unload
+func unloadSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+    timeTravelMu.Lock()
+    timeTravel = nil
+    timeTravelMu.Unlock()
+    log.Printf("Time travel ended")
+    http.Redirect(w, r, "/admin/snapshots", http.StatusSeeOther)
+}
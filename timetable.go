@@ -0,0 +1,281 @@
+package main
+
+import (
+    "encoding/xml"
+    "io"
+    "log"
+    "strings"
+    "sync"
+)
+
+// A scheduled run of a train on one day
+type Journey struct {
+    RID         string
+    UID         string
+    TrainID     string
+    SSD         string
+    TOC         string
+    Status      string // Darwin schedule status, e.g. P (permanent) or 1 (STP)
+    TrainCat    string
+    IsPassenger bool
+    IsCharter   bool
+    VSTP        bool // only seen in the live feed, not in the day's timetable snapshot
+    Live        bool // updated from the live feed since the snapshot was published
+    Points      []CallingPoint
+
+    // CIF catering, seating class and sleeper codes, if the timetable has them
+    Catering, SeatingClass, Sleepers string
+
+    CancelReason int // Darwin cancellation reason code, if cancelled
+    // and where the cause was, at or near a TIPLOC
+    CancelReasonAt   string
+    CancelReasonNear bool
+
+    // CIF STP indicator, if the timetable has it: P (permanent), O
+    // (overlay), N (new short-term plan) or C (cancelled for the day)
+    STP string
+}
+
+// One of OR, IP, PP, DT or their operational OPxx equivalents
+type CallingPoint struct {
+    Type      string
+    Tiploc    string
+    Act       string
+    Plat      string
+    Pta       string
+    Ptd       string
+    Wta       string
+    Wtd       string
+    Wtp       string
+    Cancelled bool
+}
+
+// Daily schedules keyed by RID, from the timetable snapshot and the live feed
+var (
+    journeys        = map[string]*Journey{}
+    journeysMu      sync.RWMutex
+    timetableLoaded bool
+)
+
+func journeyFromSchedule(s DarwinSchedule) *Journey {
+    j := &Journey{
+        RID:         s.RID,
+        UID:         s.UID,
+        TrainID:     s.TrainID,
+        SSD:         s.SSD,
+        TOC:         s.TOC,
+        Status:      s.Status,
+        TrainCat:    s.TrainCat,
+        IsPassenger: s.IsPassengerSvc != "false",
+        IsCharter:   s.IsCharter == "true",
+
+        Catering:     s.Catering,
+        SeatingClass: s.SeatingClass,
+        Sleepers:     s.Sleepers,
+        CancelReason: s.CancelReason.Code,
+        STP:          s.STP,
+
+        CancelReasonAt:   s.CancelReason.Tiploc,
+        CancelReasonNear: s.CancelReason.Near,
+    }
+    for _, p := range s.Points {
+        if !schedulePointTypes[p.XMLName.Local] {
+            continue
+        }
+        j.Points = append(j.Points, CallingPoint{
+            Type:      p.XMLName.Local,
+            Tiploc:    p.Tiploc,
+            Act:       strings.TrimSpace(p.Act),
+            Plat:      p.Plat,
+            Pta:       p.Pta,
+            Ptd:       p.Ptd,
+            Wta:       p.Wta,
+            Wtd:       p.Wtd,
+            Wtp:       p.Wtp,
+            Cancelled: p.Can,
+        })
+    }
+    return j
+}
+
+// Store a schedule from the live feed. Anything the day's snapshot didn't
+// contain is a very-short-term-plan (VSTP) service such as a charter or a
+// replacement bus planned today. Reports whether the RID is new to us,
+// and the RID of the schedule it replaces if Darwin reissued the service.
+func upsertLiveJourney(s DarwinSchedule) (*Journey, bool, string) {
+    j := journeyFromSchedule(s)
+    j.Live = true
+    journeysMu.Lock()
+    defer journeysMu.Unlock()
+    old, ok := journeys[j.RID]
+    var replaces string
+    switch {
+    case ok:
+        j.VSTP = old.VSTP
+    default:
+        if replaced := supersedeService(j); replaced != nil {
+            replaces = replaced.RID
+            j.VSTP = replaced.VSTP
+        } else {
+            j.VSTP = timetableLoaded
+        }
+    }
+    storeJourney(j)
+    return j, !ok, replaces
+}
+
+// Store a schedule change another instance received from the live feed
+func applyPublishedJourney(j *Journey) {
+    journeysMu.Lock()
+    var replaced *Journey
+    if _, ok := journeys[j.RID]; !ok {
+        replaced = supersedeService(j)
+    }
+    storeJourney(j)
+    journeysMu.Unlock()
+    if replaced != nil {
+        linkRIDs(replaced.RID, j.RID)
+    }
+    followIfTracked(j)
+}
+
+// Stream the Journey elements out of a Darwin timetable snapshot
+func parseTimetable(r io.Reader) (map[string]*Journey, error) {
+    return parseTimetableCounted(r, nil)
+}
+
+// parseTimetable, counting journeys towards p if it's not nil
+func parseTimetableCounted(r io.Reader, p *timetableParse) (map[string]*Journey, error) {
+    parsed := map[string]*Journey{}
+    dec := xml.NewDecoder(r)
+    for {
+        tok, err := dec.Token()
+        if err == io.EOF {
+            return parsed, nil
+        }
+        if err != nil {
+            return parsed, err
+        }
+        start, ok := tok.(xml.StartElement)
+        if !ok || start.Name.Local != "Journey" {
+            continue
+        }
+        var s DarwinSchedule
+        if err := dec.DecodeElement(&s, &start); err != nil {
+            return parsed, err
+        }
+        parsed[s.RID] = journeyFromSchedule(s)
+        if p != nil {
+            p.journeys.Add(1)
+        }
+    }
+}
+
+// Replace the schedule store with a freshly parsed snapshot, keeping any
+// live-only services the snapshot doesn't know about, and reissued ones
+// in place of those they replaced. Schedules changed by the live feed are
+// newer than the snapshot, so a reload mid-day keeps them.
+func setTimetable(parsed map[string]*Journey) {
+    journeysMu.Lock()
+    defer journeysMu.Unlock()
+    for rid, j := range journeys {
+        _, ok := parsed[rid]
+        if (!ok && (j.VSTP || len(previousRIDs(rid)) > 0)) || (ok && j.Live) {
+            parsed[rid] = j
+        }
+    }
+    // and not the schedules they replaced
+    for rid := range parsed {
+        if next := latestRID(rid); next != rid && parsed[next] != nil {
+            delete(parsed, rid)
+        }
+    }
+    journeys = parsed
+    rebuildLocationIndex()
+    rebuildServiceIndex()
+    timetableLoaded = true
+    log.Printf("Loaded %d journeys from timetable", len(parsed))
+}
+
+// A stored journey is never changed: live schedules and variants replace
+// it with a new one. So the pointer is safe to read from after the lock
+// is released.
+func journeyByRID(rid string) (*Journey, bool) {
+    journeysMu.RLock()
+    defer journeysMu.RUnlock()
+    j, ok := journeys[rid]
+    return j, ok
+}
+
+// Darwin reference data
+type darwinReference struct {
+    Locations []struct {
+        Tiploc  string `xml:"tpl,attr"`
+        CRS     string `xml:"crs,attr"`
+        LocName string `xml:"locname,attr"`
+    } `xml:"LocationRef"`
+    Tocs []struct {
+        TOC  string `xml:"toc,attr"`
+        Name string `xml:"tocname,attr"`
+    } `xml:"TocRef"`
+    LateReasons      []darwinReason `xml:"LateRunningReasons>Reason"`
+    CancelledReasons []darwinReason `xml:"CancellationReasons>Reason"`
+}
+
+func parseReference(r io.Reader) error {
+    var ref darwinReference
+    if err := xml.NewDecoder(r).Decode(&ref); err != nil {
+        return err
+    }
+    for _, l := range ref.Locations {
+        addStation(Station{Tiploc: l.Tiploc, CRS: l.CRS, Name: l.LocName})
+    }
+    for _, t := range ref.Tocs {
+        addOperator(Operator{TOC: t.TOC, Name: t.Name})
+    }
+    added := updateReasonCodes(ref.LateReasons, ref.CancelledReasons)
+    log.Printf("Loaded %d locations and %d operators from reference data", len(ref.Locations), len(ref.Tocs))
+    if n := len(ref.LateReasons) + len(ref.CancelledReasons); n > 0 {
+        log.Printf("Loaded %d reason codes from reference data, %d of them new", n, added)
+    }
+    return nil
+}
+
+// Darwin schedules cover replacement buses and ferries as well as trains
+const (
+    modeTrain = "train"
+    modeBus   = "bus"
+    modeShip  = "ship"
+)
+
+// How the service runs, from its schedule status and train category
+func (j *Journey) Mode() string {
+    switch {
+    case j.Status == "B" || j.Status == "5" || j.TrainCat == "BR" || j.TrainCat == "BS":
+        return modeBus
+    case j.Status == "S" || j.Status == "4" || j.TrainCat == "SS":
+        return modeShip
+    default:
+        return modeTrain
+    }
+}
+
+// Train categories that never carry the public: empty coaching stock,
+// light engines, freight and departmental trains
+// https://wiki.openraildata.com/index.php/Train_Category
+var nonPassengerCategories = map[string]bool{
+    "EE": true, "EL": true, "ES": true, // empty coaching stock
+    "LS": true,                         // light locomotive
+    "PM": true, "PP": true, "PV": true, // parcels and postal
+    "DD": true, "DH": true, "DI": true, "DQ": true, "DT": true, "DY": true, // departmental
+    "ZB": true, "ZZ": true, // light engines and other
+    "J2": true, "H2": true, "J3": true, "J4": true, "J5": true, "J6": true, "J8": true, "J9": true,
+    "H0": true, "H1": true, "H3": true, "H4": true, "H5": true, "H6": true, "H8": true, "H9": true,
+    "A0": true, "E0": true, "B0": true, "B1": true, "B4": true, "B5": true, "B6": true, "B7": true, // freight
+}
+
+// Whether the service belongs on public boards: Darwin marks it as a
+// passenger service and its category isn't a non-passenger one
+func (j *Journey) IsPublic() bool {
+    return j.IsPassenger && !nonPassengerCategories[j.TrainCat]
+}
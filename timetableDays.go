@@ -0,0 +1,380 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "path"
+    "runtime"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// The timetable covers today plus TIMETABLE_DAYS future days. Today lives
+// in journeys as always; later days are downloaded the first time they're
+// asked for, and only TIMETABLE_DAYS_CACHED of them are held in memory.
+// Public pages and feeds only read the days the cache holds, which the
+// web role keeps filled with the nearest ones, so anonymous callers can't
+// churn it or set off downloads; only the timetable API loads other days,
+// and only when API_AUTH=required makes its callers authenticate.
+// TIMETABLE_DAY_SOURCE is a path or URL with {date} (YYYYMMDD) in it, for
+// when the timetable doesn't come from S3.
+var (
+    timetableHorizon    = 0
+    timetableDaysCached = 2
+    timetableDaySource  string
+    // Past this much heap, only the day just loaded is kept. 0 for no limit.
+    timetableDaysMaxHeap uint64
+)
+
+func initTimetableDays() {
+    timetableHorizon = max(0, envInt("TIMETABLE_DAYS", 0))
+    timetableDaysCached = max(1, envInt("TIMETABLE_DAYS_CACHED", 2))
+    timetableDaySource = envOr("TIMETABLE_DAY_SOURCE", "")
+    timetableDaysMaxHeap = uint64(envInt("TIMETABLE_DAYS_MAX_HEAP_MB", 0)) << 20
+}
+
+// One future day's snapshot, loaded once by whoever asks for it first
+type timetableDay struct {
+    once     sync.Once
+    journeys map[string]*Journey
+    err      error
+    done     bool // once has run, guarded by timetableDaysMu
+    used     time.Time
+}
+
+var (
+    timetableDays   = map[string]*timetableDay{}
+    timetableDaysMu sync.Mutex
+)
+
+var (
+    errOutsideHorizon = errors.New("date is outside the timetable horizon")
+    errDayNotCached   = errors.New("the timetable for that date isn't loaded yet")
+)
+
+// Whether the timetable API may download a day that isn't cached
+func apiMayLoadTimetableDays() bool {
+    return os.Getenv("API_AUTH") == "required"
+}
+
+// The dates the timetable can answer for, today first
+func timetableDates(now time.Time) []string {
+    today := now.In(ukLocation)
+    dates := make([]string, timetableHorizon+1)
+    for i := range dates {
+        dates[i] = today.AddDate(0, 0, i).Format("2006-01-02")
+    }
+    return dates
+}
+
+// Journeys scheduled to run on a date (2006-01-02), which may be today,
+// any day up to the horizon or the time-travel day. Schedules for the day
+// that have already come through the live feed replace the snapshot's.
+// A future day that isn't cached is loaded if load is set, and otherwise
+// is errDayNotCached.
+func journeysOn(date string, load bool) ([]*Journey, error) {
+    dates := timetableDates(clock.Now())
+    if !slices.Contains(dates, date) {
+        if js, ok := timeTravelJourneys(date); ok {
+            return js, nil
+        }
+        return nil, errOutsideHorizon
+    }
+    var snapshot map[string]*Journey
+    if date != dates[0] {
+        var err error
+        if load {
+            snapshot, err = loadedTimetableDay(date, dates[0])
+        } else if cached, ok := cachedTimetableDay(date); ok {
+            snapshot = cached
+        } else {
+            err = errDayNotCached
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    var out []*Journey
+    live := map[string]bool{}
+    journeysMu.RLock()
+    for _, j := range journeys {
+        if j.SSD == date {
+            out = append(out, j)
+            live[j.RID] = true
+        }
+    }
+    journeysMu.RUnlock()
+    for rid, j := range snapshot {
+        if j.SSD == date && !live[rid] {
+            out = append(out, j)
+        }
+    }
+    return resolveVariants(out), nil
+}
+
+// A future day's schedules, loading them if they aren't already in memory
+func loadedTimetableDay(date, today string) (map[string]*Journey, error) {
+    timetableDaysMu.Lock()
+    day, ok := timetableDays[date]
+    if !ok {
+        day = &timetableDay{}
+        timetableDays[date] = day
+    }
+    day.used = time.Now()
+    timetableDaysMu.Unlock()
+
+    day.once.Do(func() {
+        day.journeys, day.err = loadTimetableDay(date)
+        if day.err == nil {
+            log.Printf("Loaded %d journeys from the %s timetable", len(day.journeys), date)
+        }
+        timetableDaysMu.Lock()
+        day.done = true
+        timetableDaysMu.Unlock()
+        trimTimetableDays(date, today)
+    })
+    if day.err != nil {
+        // Forget the failure so the next request tries again
+        timetableDaysMu.Lock()
+        if timetableDays[date] == day {
+            delete(timetableDays, date)
+        }
+        timetableDaysMu.Unlock()
+        return nil, day.err
+    }
+    return day.journeys, nil
+}
+
+// A future day's schedules if they're already in memory
+func cachedTimetableDay(date string) (map[string]*Journey, bool) {
+    timetableDaysMu.Lock()
+    defer timetableDaysMu.Unlock()
+    day, ok := timetableDays[date]
+    if !ok || !day.done || day.err != nil {
+        return nil, false
+    }
+    day.used = time.Now()
+    return day.journeys, true
+}
+
+// Keep the nearest future days cached for the public pages, checking
+// every half hour so a new day's are loaded soon after midnight
+func startTimetablePrefetch() {
+    if timetableHorizon == 0 {
+        return
+    }
+    for {
+        dates := timetableDates(clock.Now())
+        if memoryPressure.Load() {
+            dates = dates[:1]
+        }
+        for _, date := range dates[1:min(len(dates), timetableDaysCached+1)] {
+            if _, err := loadedTimetableDay(date, dates[0]); err != nil {
+                log.Printf("Failed to prefetch the %s timetable: %v", date, err)
+            }
+        }
+        time.Sleep(30 * time.Minute)
+    }
+}
+
+// Forget every cached future day, returning how many there were. Any
+// asked for again are downloaded again.
+func dropTimetableDays() int {
+    timetableDaysMu.Lock()
+    defer timetableDaysMu.Unlock()
+    n := len(timetableDays)
+    clear(timetableDays)
+    return n
+}
+
+// Drop days that have passed, then the least recently used ones until
+// there are few enough, or only the one just loaded if the heap is too big
+func trimTimetableDays(loaded, today string) {
+    keep := timetableDaysCached
+    if memoryPressure.Load() {
+        keep = 1
+    } else if timetableDaysMaxHeap > 0 {
+        var m runtime.MemStats
+        runtime.ReadMemStats(&m)
+        if m.HeapAlloc > timetableDaysMaxHeap {
+            log.Printf("Heap is %d MB; keeping only the %s timetable", m.HeapAlloc>>20, loaded)
+            keep = 1
+        }
+    }
+
+    timetableDaysMu.Lock()
+    defer timetableDaysMu.Unlock()
+    var dates []string
+    for date := range timetableDays {
+        if date < today {
+            delete(timetableDays, date)
+            continue
+        }
+        if date != loaded {
+            dates = append(dates, date)
+        }
+    }
+    slices.SortFunc(dates, func(a, b string) int {
+        return timetableDays[a].used.Compare(timetableDays[b].used)
+    })
+    for len(dates) > keep-1 {
+        delete(timetableDays, dates[0])
+        dates = dates[1:]
+    }
+}
+
+// Fetch and parse the snapshot for one day, from TIMETABLE_DAY_SOURCE or
+// the latest one the bucket has published for that date
+func loadTimetableDay(date string) (parsed map[string]*Journey, err error) {
+    ctx, span := tracer.Start(context.Background(), "timetable.load_day", trace.WithAttributes(attribute.String("date", date)))
+    defer func() { endSpan(span, err) }()
+    read := func(r io.Reader) error {
+        var err error
+        parsed, err = parseTimetable(r)
+        return err
+    }
+    compact := strings.ReplaceAll(date, "-", "")
+    switch {
+    case timetableDaySource != "":
+        source := strings.ReplaceAll(timetableDaySource, "{date}", compact)
+        err = withSource(source, read)
+        return parsed, err
+    case isS3Source(timetableSource):
+        client, err := darwinS3Client(ctx)
+        if err != nil {
+            return nil, err
+        }
+        objects, err := listTimetableObjects(ctx, client)
+        if err != nil {
+            return nil, fmt.Errorf("list S3 objects: %w", err)
+        }
+        for _, obj := range objects {
+            key := *obj.Key
+            if strings.HasPrefix(path.Base(key), compact) && strings.HasSuffix(key, "_v8.xml.gz") {
+                log.Printf("Downloading the %s timetable: %s", date, key)
+                err = withS3Gzip(ctx, client, timetableBucket, key, read)
+                return parsed, err
+            }
+        }
+        return nil, fmt.Errorf("no timetable published for %s", date)
+    default:
+        return nil, fmt.Errorf("no timetable for %s: set TIMETABLE_DAY_SOURCE", date)
+    }
+}
+
+// A service in the timetable for some day, as returned by a search
+type ScheduledService struct {
+    RID         string `json:"rid"`
+    UID         string `json:"uid"`
+    TrainID     string `json:"train_id"`
+    SSD         string `json:"ssd"`
+    TOC         string `json:"toc"`
+    Origin      string `json:"origin"`
+    Destination string `json:"destination"`
+    Departs     string `json:"departs,omitempty"`
+    Platform    string `json:"platform,omitempty"`
+    // The variant that runs, for timetables with STP indicators; C means
+    // it's cancelled that day
+    STP       string `json:"stp,omitempty"`
+    Cancelled bool   `json:"cancelled,omitempty"`
+}
+
+var scheduledServiceFields = listFields[ScheduledService]{
+    "train_id":    func(s ScheduledService) string { return s.TrainID },
+    "toc":         func(s ScheduledService) string { return s.TOC },
+    "origin":      func(s ScheduledService) string { return stationKey(s.Origin) },
+    "destination": func(s ScheduledService) string { return stationKey(s.Destination) },
+    "departs":     func(s ScheduledService) string { return s.Departs },
+}
+
+// Public services on a date, optionally only a headcode, only a UID or
+// only those leaving a station. Departs is from that station, or else the
+// origin.
+func searchTimetable(date, headcode, uid, crs string) ([]ScheduledService, error) {
+    js, err := journeysOn(date, apiMayLoadTimetableDays())
+    if err != nil {
+        return nil, err
+    }
+    tiplocs := tiplocsForCRS(crs)
+    var out []ScheduledService
+    for _, j := range js {
+        if !j.IsPublic() || len(j.Points) == 0 || (headcode != "" && j.TrainID != headcode) || (uid != "" && j.UID != uid) {
+            continue
+        }
+        s := ScheduledService{
+            RID:         j.RID,
+            UID:         j.UID,
+            TrainID:     j.TrainID,
+            SSD:         j.SSD,
+            TOC:         j.TOC,
+            Origin:      j.Points[0].Tiploc,
+            Destination: j.Points[len(j.Points)-1].Tiploc,
+            STP:         j.STP,
+            Cancelled:   j.STP == "C",
+        }
+        if crs == "" {
+            s.Departs, s.Platform = j.Points[0].Ptd, j.Points[0].Plat
+            out = append(out, s)
+            continue
+        }
+        found := false
+        for _, p := range j.Points {
+            if slices.Contains(tiplocs, p.Tiploc) && isPublicDeparture(p) {
+                s.Departs, s.Platform, found = p.Ptd, p.Plat, true
+                break
+            }
+        }
+        if found {
+            out = append(out, s)
+        }
+    }
+    slices.SortFunc(out, func(a, b ScheduledService) int {
+        return strings.Compare(a.Departs+a.TrainID, b.Departs+b.TrainID)
+    })
+    return out, nil
+}
+
+// GET /api/v1/timetable/{date}?train=2B15&uid=C12345&crs=MAN: the planned
+// services for today or a day within the timetable horizon
+func timetableSearchHandler(w http.ResponseWriter, r *http.Request) {
+    date := r.PathValue("date")
+    q, err := parseListQuery(r, scheduledServiceFields)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    headcode := strings.ToUpper(r.URL.Query().Get("train"))
+    crs := strings.ToUpper(r.URL.Query().Get("crs"))
+    uid := strings.ToUpper(r.URL.Query().Get("uid"))
+    services, err := searchTimetable(date, headcode, uid, crs)
+    if errors.Is(err, errOutsideHorizon) {
+        dates := timetableDates(clock.Now())
+        http.Error(w, fmt.Sprintf("date must be between %s and %s", dates[0], dates[len(dates)-1]), http.StatusNotFound)
+        return
+    }
+    if errors.Is(err, errDayNotCached) {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+        return
+    }
+    if err != nil {
+        log.Printf("Failed to load the %s timetable: %v", date, err)
+        http.Error(w, "failed to load timetable", http.StatusBadGateway)
+        return
+    }
+    services = applyListQuery(w, r, services, scheduledServiceFields, q)
+    writeJSON(w, struct {
+        Date     string             `json:"date"`
+        Services []ScheduledService `json:"services"`
+    }{date, services})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Where the timetable comes from, set from --timetable and --reference
+var (
+    timetableSource = "s3"
+    referenceSource string
+)
+
+// Held while a timetable load is under way, so reloads don't overlap
+var timetableLoading sync.Mutex
+
+// Load the timetable from its configured source
+func loadTimetable() {
+    if !timetableLoading.TryLock() {
+        log.Println("Timetable load already in progress")
+        return
+    }
+    defer timetableLoading.Unlock()
+    ctx, span := tracer.Start(context.Background(), "timetable.load")
+    defer span.End()
+    start := time.Now()
+    if isS3Source(timetableSource) {
+        loadTimetableFromS3(ctx)
+    } else {
+        loadTimetableFrom(timetableSource, referenceSource)
+    }
+    // The loaders log why they failed; all that's known here is whether
+    // a timetable was loaded. Web replicas and Lambda load it too, but
+    // only the ingester alerts.
+    loadedVersionsMu.RLock()
+    loaded := timetableLoadedAt.After(start)
+    loadedVersionsMu.RUnlock()
+    switch {
+    case !runsIngest:
+    case loaded:
+        clearFeedAlert(feedAlertTimetable)
+    default:
+        raiseFeedAlert(feedAlertTimetable, fmt.Sprintf("failed to load the timetable from %s; see the log", timetableSource))
+    }
+    trackFromTimetable()
+}
+
+// Load the timetable (and optionally reference data) from a local path,
+// file:// URL or HTTP(S) URL instead of S3, e.g. a mirror or a test fixture
+func loadTimetableFrom(timetable, reference string) {
+    if reference != "" {
+        log.Printf("Loading reference data from %s", reference)
+        if err := withSource(reference, parseReference); err != nil {
+            log.Printf("Failed to load reference data: %v", err)
+        } else {
+            noteReferenceLoaded(dataID(reference))
+        }
+    }
+    log.Printf("Loading timetable from %s", timetable)
+    parse := beginParse(timetable)
+    err := withSourceCounted(timetable, parse, func(r io.Reader) error {
+        parsed, err := parseTimetableCounted(r, parse)
+        if err != nil {
+            return err
+        }
+        setTimetable(parsed)
+        noteTimetableLoaded(dataID(timetable))
+        return nil
+    })
+    parse.finish(err)
+}
+
+// Open a path or URL and pass its (ungzipped if necessary) content to read
+func withSource(source string, read func(io.Reader) error) error {
+    return withSourceCounted(source, nil, read)
+}
+
+// withSource, counting the bytes read towards p if it's not nil
+func withSourceCounted(source string, p *timetableParse, read func(io.Reader) error) error {
+    u, err := url.Parse(source)
+    if err != nil {
+        return err
+    }
+    switch u.Scheme {
+    case "http", "https":
+        resp, err := http.Get(source)
+        if err != nil {
+            return err
+        }
+        defer resp.Body.Close()
+        if resp.StatusCode != http.StatusOK {
+            return fmt.Errorf("GET %s: %s", source, resp.Status)
+        }
+        return readMaybeGzip(p.counting(resp.Body, resp.ContentLength), read)
+    case "file", "":
+        path := source
+        if u.Scheme == "file" {
+            path = u.Path
+        }
+        if p == nil {
+            f, err := openXMLFile(path)
+            if err != nil {
+                return err
+            }
+            defer f.Close()
+            return read(f)
+        }
+        f, err := os.Open(path)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        var size int64
+        if fi, err := f.Stat(); err == nil {
+            size = fi.Size()
+        }
+        return readMaybeGzip(p.counting(f, size), read)
+    default:
+        return fmt.Errorf("unsupported timetable source %q", source)
+    }
+}
+
+func readMaybeGzip(r io.Reader, read func(io.Reader) error) error {
+    br := bufio.NewReader(r)
+    magic, _ := br.Peek(2)
+    if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+        gz, err := gzip.NewReader(br)
+        if err != nil {
+            return err
+        }
+        defer gz.Close()
+        return read(gz)
+    }
+    return read(br)
+}
+
+// "s3" (the default) means the Darwin bucket
+func isS3Source(source string) bool {
+    return source == "" || strings.EqualFold(source, "s3")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/xml"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// Problems found in a timetable snapshot. Errors make the snapshot
+// untrustworthy; warnings are oddities worth a look.
+type validationReport struct {
+    Journeys int
+    Errors   map[string][]string
+    Warnings map[string][]string
+}
+
+func (r *validationReport) errorf(kind, format string, args ...any) {
+    r.Errors[kind] = append(r.Errors[kind], fmt.Sprintf(format, args...))
+}
+
+func (r *validationReport) warnf(kind, format string, args ...any) {
+    r.Warnings[kind] = append(r.Warnings[kind], fmt.Sprintf(format, args...))
+}
+
+// Open a timetable or reference file, ungzipping it if needed
+func openXMLFile(path string) (io.ReadCloser, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    br := bufio.NewReader(f)
+    magic, _ := br.Peek(2)
+    if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+        gz, err := gzip.NewReader(br)
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+        return struct {
+            io.Reader
+            io.Closer
+        }{gz, f}, nil
+    }
+    return struct {
+        io.Reader
+        io.Closer
+    }{br, f}, nil
+}
+
+func validRailTime(s string) bool {
+    if _, ok := parseRailTime(s); !ok {
+        return false
+    }
+    parts := strings.Split(s, ":")
+    if len(parts[0]) != 2 || len(parts[1]) != 2 {
+        return false
+    }
+    return len(parts) == 2 || parts[2] == "00" || parts[2] == "30"
+}
+
+// Check every Journey in a snapshot. ref may be nil, in which case
+// TIPLOC and TOC references aren't checked.
+func validateTimetable(r io.Reader, ref *darwinReference) (*validationReport, error) {
+    report := &validationReport{Errors: map[string][]string{}, Warnings: map[string][]string{}}
+    tiplocs, tocs := map[string]bool{}, map[string]bool{}
+    if ref != nil {
+        for _, l := range ref.Locations {
+            tiplocs[l.Tiploc] = true
+        }
+        for _, t := range ref.Tocs {
+            tocs[t.TOC] = true
+        }
+    }
+    seen := map[string]bool{}
+
+    dec := xml.NewDecoder(r)
+    for {
+        tok, err := dec.Token()
+        if err == io.EOF {
+            return report, nil
+        }
+        if err != nil {
+            return report, err
+        }
+        start, ok := tok.(xml.StartElement)
+        if !ok || start.Name.Local != "Journey" {
+            continue
+        }
+        var s DarwinSchedule
+        if err := dec.DecodeElement(&s, &start); err != nil {
+            return report, err
+        }
+        report.Journeys++
+        validateJourney(report, s, seen, tiplocs, tocs)
+    }
+}
+
+func validateJourney(report *validationReport, s DarwinSchedule, seen, tiplocs, tocs map[string]bool) {
+    id := s.RID
+    if id == "" {
+        id = "(no rid, uid " + s.UID + ")"
+        report.errorf("missing attribute", "%s: missing rid", id)
+    }
+    if seen[s.RID] && s.RID != "" {
+        report.errorf("duplicate RID", "%s: appears more than once", id)
+    }
+    seen[s.RID] = true
+    for _, a := range [][2]string{{"uid", s.UID}, {"trainId", s.TrainID}, {"ssd", s.SSD}, {"toc", s.TOC}} {
+        if a[1] == "" {
+            report.errorf("missing attribute", "%s: missing %s", id, a[0])
+        }
+    }
+    if len(tocs) > 0 && s.TOC != "" && !tocs[s.TOC] {
+        report.errorf("unknown TOC", "%s: TOC %s isn't in the reference data", id, s.TOC)
+    }
+
+    var origins, destinations, points int
+    for _, p := range s.Points {
+        kind := p.XMLName.Local
+        if !schedulePointTypes[kind] {
+            continue
+        }
+        points++
+        switch kind {
+        case "OR", "OPOR":
+            origins++
+        case "DT", "OPDT":
+            destinations++
+        }
+        if p.Tiploc == "" {
+            report.errorf("missing TIPLOC", "%s: %s point without a tpl", id, kind)
+        } else if len(tiplocs) > 0 && !tiplocs[p.Tiploc] {
+            report.errorf("unknown TIPLOC", "%s: TIPLOC %s isn't in the reference data", id, p.Tiploc)
+        }
+        for _, a := range [][2]string{{"pta", p.Pta}, {"ptd", p.Ptd}, {"wta", p.Wta}, {"wtd", p.Wtd}, {"wtp", p.Wtp}} {
+            if a[1] != "" && !validRailTime(a[1]) {
+                report.errorf("unparsable time", "%s: %s at %s has %s=%q", id, kind, p.Tiploc, a[0], a[1])
+            }
+        }
+        if kind == "PP" && p.Wtp == "" {
+            report.warnf("missing time", "%s: passing point %s has no wtp", id, p.Tiploc)
+        }
+    }
+    if points == 0 {
+        report.errorf("empty journey", "%s: no calling points", id)
+        return
+    }
+    if origins != 1 || destinations != 1 {
+        report.warnf("origin/destination", "%s: %d origins and %d destinations", id, origins, destinations)
+    }
+}
+
+// Most problems of one kind to list individually
+const maxReportedProblems = 20
+
+func printProblems(w io.Writer, level string, problems map[string][]string) int {
+    kinds := make([]string, 0, len(problems))
+    total := 0
+    for k, v := range problems {
+        kinds = append(kinds, k)
+        total += len(v)
+    }
+    sort.Strings(kinds)
+    for _, k := range kinds {
+        list := problems[k]
+        fmt.Fprintf(w, "%s %s: %d\n", level, k, len(list))
+        for i, p := range list {
+            if i == maxReportedProblems {
+                fmt.Fprintf(w, "    ... and %d more\n", len(list)-i)
+                break
+            }
+            fmt.Fprintf(w, "    %s\n", p)
+        }
+    }
+    return total
+}
+
+// minimaltrains timetable validate [--ref ref.xml.gz] <file>
+func runTimetableValidate(args []string) int {
+    fs := flag.NewFlagSet("timetable validate", flag.ExitOnError)
+    refPath := fs.String("ref", "", "reference data file to check TIPLOCs and TOCs against")
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "usage: minimaltrains timetable validate [--ref ref_v3.xml.gz] <timetable_v8.xml.gz>")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        fs.Usage()
+        return 2
+    }
+
+    var ref *darwinReference
+    if *refPath != "" {
+        f, err := openXMLFile(*refPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to open reference data: %v\n", err)
+            return 1
+        }
+        ref = &darwinReference{}
+        err = xml.NewDecoder(f).Decode(ref)
+        f.Close()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to parse reference data: %v\n", err)
+            return 1
+        }
+    }
+
+    f, err := openXMLFile(fs.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to open timetable: %v\n", err)
+        return 1
+    }
+    defer f.Close()
+    report, err := validateTimetable(f, ref)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to parse timetable after %d journeys: %v\n", report.Journeys, err)
+        return 1
+    }
+
+    fmt.Printf("%d journeys checked\n", report.Journeys)
+    errors := printProblems(os.Stdout, "ERROR", report.Errors)
+    warnings := printProblems(os.Stdout, "WARNING", report.Warnings)
+    fmt.Printf("%d errors, %d warnings\n", errors, warnings)
+    if errors > 0 {
+        return 1
+    }
+    return 0
+}
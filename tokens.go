@@ -0,0 +1,265 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "flag"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "slices"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+// API tokens grant scopes: read for the JSON API (when API_AUTH=required),
+// notify for watchlists and notification rules, admin for operations such
+// as reloading the timetable. Each scope includes the ones before it.
+var tokenScopes = []string{"read", "notify", "admin"}
+
+type apiToken struct {
+    ID        string
+    Name      string
+    Scopes    []string
+    CreatedAt time.Time
+    LastUsed  *time.Time
+    Revoked   bool
+}
+
+// Whether a token's scopes cover the one required
+func (t apiToken) allows(scope string) bool {
+    need := slices.Index(tokenScopes, scope)
+    for _, s := range t.Scopes {
+        if slices.Index(tokenScopes, s) >= need {
+            return true
+        }
+    }
+    return false
+}
+
+const tokenTableSQL = `
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        hash TEXT NOT NULL UNIQUE,
+        scopes TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        last_used_at DATETIME,
+        revoked_at DATETIME
+    );`
+
+// Only a hash of each token is stored, so the database doesn't leak them
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+    b := make([]byte, n)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+// Create a token and return it; it can't be recovered afterwards
+func createToken(db *sql.DB, name string, scopes []string) (string, apiToken, error) {
+    for _, s := range scopes {
+        if !slices.Contains(tokenScopes, s) {
+            return "", apiToken{}, fmt.Errorf("unknown scope %q; expected %s", s, strings.Join(tokenScopes, ", "))
+        }
+    }
+    t := apiToken{ID: randomHex(4), Name: name, Scopes: scopes, CreatedAt: time.Now().UTC()}
+    token := "mt_" + randomHex(24)
+    _, err := db.Exec(`INSERT INTO api_tokens (id, name, hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)`,
+        t.ID, t.Name, hashToken(token), strings.Join(scopes, ","), t.CreatedAt)
+    return token, t, err
+}
+
+func listTokens(db *sql.DB) ([]apiToken, error) {
+    rows, err := db.Query(`SELECT id, name, scopes, created_at, last_used_at, revoked_at IS NOT NULL FROM api_tokens ORDER BY created_at`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var tokens []apiToken
+    for rows.Next() {
+        var (
+            t        apiToken
+            scopes   string
+            lastUsed sql.NullTime
+        )
+        if err := rows.Scan(&t.ID, &t.Name, &scopes, &t.CreatedAt, &lastUsed, &t.Revoked); err != nil {
+            return nil, err
+        }
+        t.Scopes = strings.Split(scopes, ",")
+        if lastUsed.Valid {
+            t.LastUsed = &lastUsed.Time
+        }
+        tokens = append(tokens, t)
+    }
+    return tokens, rows.Err()
+}
+
+func revokeToken(db *sql.DB, id string) error {
+    res, err := db.Exec(`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+    if err != nil {
+        return err
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return fmt.Errorf("no active token with id %s", id)
+    }
+    return nil
+}
+
+// Find the live token a request presents
+func lookupToken(db *sql.DB, token string) (apiToken, bool, error) {
+    var (
+        t      apiToken
+        scopes string
+    )
+    err := db.QueryRow(`SELECT id, name, scopes, created_at FROM api_tokens WHERE hash = ? AND revoked_at IS NULL`, hashToken(token)).
+        Scan(&t.ID, &t.Name, &scopes, &t.CreatedAt)
+    if errors.Is(err, sql.ErrNoRows) {
+        return t, false, nil
+    }
+    if err != nil {
+        return t, false, err
+    }
+    t.Scopes = strings.Split(scopes, ",")
+    db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, time.Now().UTC(), t.ID)
+    return t, true, nil
+}
+
+func bearerToken(r *http.Request) string {
+    token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if !ok {
+        return ""
+    }
+    return strings.TrimSpace(token)
+}
+
+// Wrap a handler so it needs a token with the given scope, from the
+// Authorization header or the admin sign-in cookie
+func requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if archiveDB == nil {
+            http.Error(w, "API tokens need the archive database (ARCHIVE_PATH)", http.StatusServiceUnavailable)
+            return
+        }
+        token, fromCookie := requestToken(r)
+        if token == "" && r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+            http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+            return
+        }
+        if token == "" {
+            w.Header().Set("WWW-Authenticate", `Bearer realm="minimaltrains"`)
+            http.Error(w, "missing bearer token", http.StatusUnauthorized)
+            return
+        }
+        t, ok, err := lookupToken(archiveDB, token)
+        if err != nil {
+            log.Printf("Failed to look up API token: %v", err)
+            http.Error(w, "failed to check token", http.StatusInternalServerError)
+            return
+        }
+        if !ok {
+            w.Header().Set("WWW-Authenticate", `Bearer realm="minimaltrains", error="invalid_token"`)
+            http.Error(w, "invalid or revoked token", http.StatusUnauthorized)
+            return
+        }
+        if !t.allows(scope) {
+            http.Error(w, "token lacks the "+scope+" scope", http.StatusForbidden)
+            return
+        }
+        if fromCookie && !validCSRF(r, token) {
+            http.Error(w, "missing or invalid CSRF token", http.StatusForbidden)
+            return
+        }
+        h(w, r)
+    }
+}
+
+// Read-only API endpoints are open unless API_AUTH=required
+func readAPI(h http.HandlerFunc) http.HandlerFunc {
+    if os.Getenv("API_AUTH") != "required" {
+        return h
+    }
+    return requireScope("read", h)
+}
+
+// minimaltrains token create|list|revoke
+func runToken(args []string) int {
+    usage := func() int {
+        fmt.Fprintln(os.Stderr, "usage: minimaltrains token create --name <name> [--scope read,notify,admin]")
+        fmt.Fprintln(os.Stderr, "       minimaltrains token list")
+        fmt.Fprintln(os.Stderr, "       minimaltrains token revoke <id>")
+        return 2
+    }
+    if len(args) == 0 {
+        return usage()
+    }
+    if err := initArchive(); err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to open archive: %v\n", err)
+        return 1
+    }
+    if archiveDB == nil {
+        fmt.Fprintln(os.Stderr, "API tokens are kept in the archive database; ARCHIVE_PATH is off")
+        return 1
+    }
+    defer archiveDB.Close()
+
+    switch args[0] {
+    case "create":
+        fs := flag.NewFlagSet("token create", flag.ExitOnError)
+        name := fs.String("name", "", "who or what the token is for")
+        scope := fs.String("scope", "read", "comma-separated scopes: read, notify, admin")
+        fs.Parse(args[1:])
+        if *name == "" {
+            return usage()
+        }
+        token, t, err := createToken(archiveDB, *name, strings.Split(*scope, ","))
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to create token: %v\n", err)
+            return 1
+        }
+        fmt.Printf("Created token %s (%s) with scopes %s\n", t.ID, t.Name, strings.Join(t.Scopes, ","))
+        fmt.Println(token)
+        fmt.Fprintln(os.Stderr, "Store it now; it can't be shown again.")
+    case "list":
+        tokens, err := listTokens(archiveDB)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to list tokens: %v\n", err)
+            return 1
+        }
+        tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+        for _, t := range tokens {
+            lastUsed, status := "never", "active"
+            if t.LastUsed != nil {
+                lastUsed = t.LastUsed.Local().Format("2006-01-02 15:04")
+            }
+            if t.Revoked {
+                status = "revoked"
+            }
+            fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), t.CreatedAt.Local().Format("2006-01-02 15:04"), lastUsed, status)
+        }
+        tw.Flush()
+    case "revoke":
+        if len(args) != 2 {
+            return usage()
+        }
+        if err := revokeToken(archiveDB, args[1]); err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to revoke token: %v\n", err)
+            return 1
+        }
+        fmt.Printf("Revoked token %s\n", args[1])
+    default:
+        return usage()
+    }
+    return 0
+}
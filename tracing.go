@@ -0,0 +1,98 @@
+package main
+
+import (
+    "cmp"
+    "context"
+    "log"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// Spans for HTTP requests, S3 downloads and Push Port messages, exported
+// over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces-only
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set. The usual OTEL_* variables
+// for headers, sampling and the service name apply. Without an endpoint
+// the tracer is a no-op.
+var tracer trace.Tracer = otel.Tracer("github.com/jashcroft123/MinimalTrains")
+
+// Set up the exporter, flushing it when the process is asked to stop
+func initTracing() {
+    if envOr("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" && envOr("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") == "" {
+        return
+    }
+    exporter, err := otlptracehttp.New(context.Background())
+    if err != nil {
+        log.Printf("Failed to set up trace exporter: %v", err)
+        return
+    }
+    res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+        semconv.ServiceName(envOr("OTEL_SERVICE_NAME", "minimaltrains")),
+    ))
+    if err != nil {
+        log.Printf("Failed to describe trace resource: %v", err)
+    }
+    provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+    otel.SetTracerProvider(provider)
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+    tracer = provider.Tracer("github.com/jashcroft123/MinimalTrains")
+    log.Printf("Exporting traces over OTLP")
+
+    // Spans still in the batch would be lost on a plain exit
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+    go func() {
+        <-stop
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        if err := provider.Shutdown(ctx); err != nil {
+            log.Printf("Failed to flush traces: %v", err)
+        }
+        cancel()
+        os.Exit(0)
+    }()
+}
+
+// Trace every request through a mux, naming spans by the route pattern
+// that matched rather than the raw path
+func tracedHandler(mux http.Handler) http.Handler {
+    return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        mux.ServeHTTP(w, r)
+        if r.Pattern != "" {
+            trace.SpanFromContext(r.Context()).SetName(r.Pattern)
+        }
+        observeCall("handler", cmp.Or(r.Pattern, "unmatched"), r.URL.Path, start)
+    }), "http")
+}
+
+// An HTTP client whose requests are child spans of the caller's
+func tracedHTTPClient() *http.Client {
+    return &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+}
+
+// Finish a span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
+
+// The S3 object a span is about
+func s3Attributes(bucket, key string) trace.SpanStartOption {
+    return trace.WithAttributes(attribute.String("s3.bucket", bucket), attribute.String("s3.key", key))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+    "encoding/json"
+    "log"
+    "maps"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Train describer (TD) berth messages. A berth roughly corresponds to the
+// section of line protected by a signal, so stepping from one berth to the
+// next tells us which pair of signals a train is between.
+// https://wiki.openraildata.com/index.php/TD
+type tdMessage struct {
+    Time   string `json:"time"`
+    AreaID string `json:"area_id"`
+    From   string `json:"from"`
+    To     string `json:"to"`
+    Descr  string `json:"descr"`
+}
+
+// Each element of a TD message array holds exactly one message type
+type tdEnvelope struct {
+    CA *tdMessage `json:"CA_MSG"` // berth step
+    CB *tdMessage `json:"CB_MSG"` // berth cancel
+    CC *tdMessage `json:"CC_MSG"` // berth interpose
+}
+
+// Last known berth of a train. From is empty when the description was
+// interposed straight into a berth rather than stepped.
+type BerthPosition struct {
+    Area string
+    From string
+    To   string
+    At   time.Time
+}
+
+// Berth positions older than this aren't shown
+const berthPositionMaxAge = 30 * time.Minute
+
+// Latest berth per TD area per headcode. Headcodes are only unique within
+// a region, so TD_AREAS should be set to the areas the tracked train runs
+// through. Positions too old to show are swept out every
+// berthPositionMaxAge, so the map only holds trains moving now.
+var (
+    berthPositions = map[string]map[string]BerthPosition{}
+    berthMu        sync.RWMutex
+    tdAreas        = map[string]bool{}
+)
+
+func startTrainDescriber() {
+    for _, area := range strings.Split(os.Getenv("TD_AREAS"), ",") {
+        if area = strings.TrimSpace(area); area != "" {
+            tdAreas[strings.ToUpper(area)] = true
+        }
+    }
+    go pruneBerthPositions()
+    consumeStompTopic("TD",
+        envOr("NR_STOMP_ADDR", defaultNROpenDataAddr),
+        os.Getenv("NR_USERNAME"),
+        os.Getenv("NR_PASSWORD"),
+        envOr("TD_TOPIC", "/topic/TD_ALL_SIG_AREA"),
+        handleTDMessage,
+    )
+}
+
+func handleTDMessage(body []byte) {
+    var envelopes []tdEnvelope
+    if err := json.Unmarshal(body, &envelopes); err != nil {
+        log.Printf("Failed to parse TD message: %v", err)
+        return
+    }
+    berthMu.Lock()
+    defer berthMu.Unlock()
+    for _, e := range envelopes {
+        switch {
+        case e.CA != nil:
+            setBerth(e.CA, e.CA.From, e.CA.To)
+        case e.CC != nil:
+            setBerth(e.CC, "", e.CC.To)
+        case e.CB != nil:
+            if byArea, ok := berthPositions[e.CB.Descr]; ok {
+                if p, ok := byArea[e.CB.AreaID]; ok && p.To == e.CB.From {
+                    delete(byArea, e.CB.AreaID)
+                }
+            }
+        }
+    }
+}
+
+// Caller must hold berthMu
+func setBerth(m *tdMessage, from, to string) {
+    if m.Descr == "" || (len(tdAreas) > 0 && !tdAreas[m.AreaID]) || memoryPressure.Load() {
+        return
+    }
+    byArea, ok := berthPositions[m.Descr]
+    if !ok {
+        byArea = map[string]BerthPosition{}
+        berthPositions[m.Descr] = byArea
+    }
+    byArea[m.AreaID] = BerthPosition{Area: m.AreaID, From: from, To: to, At: parseEpochMillis(m.Time)}
+}
+
+func pruneBerthPositions() {
+    for range time.Tick(berthPositionMaxAge) {
+        berthMu.Lock()
+        for headcode, byArea := range berthPositions {
+            maps.DeleteFunc(byArea, func(_ string, p BerthPosition) bool {
+                return clock.Now().Sub(p.At) > berthPositionMaxAge
+            })
+            if len(byArea) == 0 {
+                delete(berthPositions, headcode)
+            }
+        }
+        berthMu.Unlock()
+    }
+}
+
+// Forget every berth position, returning how many headcodes had one
+func dropBerthPositions() int {
+    berthMu.Lock()
+    defer berthMu.Unlock()
+    n := len(berthPositions)
+    clear(berthPositions)
+    return n
+}
+
+// Most recent berth position for a headcode from any area
+func berthPosition(headcode string) *BerthPosition {
+    berthMu.RLock()
+    defer berthMu.RUnlock()
+    var latest *BerthPosition
+    for _, p := range berthPositions[headcode] {
+        if clock.Now().Sub(p.At) > berthPositionMaxAge {
+            continue
+        }
+        if latest == nil || p.At.After(latest.At) {
+            p := p
+            latest = &p
+        }
+    }
+    return latest
+}
+
+// Network Rail feeds give times as milliseconds since the epoch
+func parseEpochMillis(s string) time.Time {
+    ms, err := strconv.ParseInt(s, 10, 64)
+    if err != nil || ms == 0 {
+        return clock.Now()
+    }
+    return time.UnixMilli(ms)
+}
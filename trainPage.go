@@ -0,0 +1,170 @@
+package main
+
+import (
+    "html/template"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Headcodes aren't unique: the same one is reused every day, and within a
+// day different operators, or the same one in different areas, can run
+// trains with it. /train/{headcode} only goes straight to a train when
+// there's one it could mean; otherwise it asks which.
+
+// Template for choosing between trains with the same headcode
+var trainChooserTmpl = template.Must(template.New("trainChooser").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "choose_train" .Headcode}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{T "choose_train" .Headcode}}</h1>
+    <p><a href="?lang={{.OtherLang}}">{{T "switch_lang"}}</a> | <a href="/">{{T "title"}}</a> | <a href="/stations">{{T "stations_title"}}</a></p>
+    <p>{{T "choose_prompt" .Headcode}}</p>
+    <ul>
+    {{range .Runs}}
+        <li{{if or .Finished .Cancelled}} class="muted"{{end}}>
+            <a href="/train/{{$.Headcode}}?rid={{.RID}}&amp;date={{$.Date}}"><strong>{{hhmm .Departs}} {{station .Origin}} - {{station .Destination}}</strong></a> {{operator .TOC}}
+            {{if .Cancelled}}{{T "train_cancelled"}}{{else if not .LastStation}}{{T "card_due" (station .Origin) (hhmm .Departs)}}{{else if eq .LastEvent "arr"}}{{T "card_arrived" (station .LastStation) (hhmm .LastTime)}}{{else}}{{T "card_departed" (station .LastStation) (hhmm .LastTime)}}{{end}}
+        </li>
+    {{end}}
+    </ul>
+</body>
+</html>
+`))
+
+// Which of today's runs of a headcode a request means: the one named by
+// ?rid=, else the only one still to finish, else the only one at all
+func chooseRun(runs []TrainProgress, rid string) (TrainProgress, bool) {
+    if rid != "" {
+        for _, p := range runs {
+            if p.RID == rid {
+                return p, true
+            }
+        }
+        return TrainProgress{}, false
+    }
+    var active []TrainProgress
+    for _, p := range runs {
+        if loc := trainLocation(p); !loc.Finished && !loc.Cancelled {
+            active = append(active, p)
+        }
+    }
+    switch {
+    case len(active) == 1:
+        return active[0], true
+    case len(runs) == 1:
+        return runs[0], true
+    }
+    return TrainProgress{}, false
+}
+
+// The runs a train page is for: the headcode's on ?date= (today if
+// there's none), looking further for the one ?rid= names. Reports the
+// date, or writes an error and gives "" if there's nothing to show.
+func requestedRuns(w http.ResponseWriter, r *http.Request, headcode string) ([]TrainProgress, string) {
+    date := r.URL.Query().Get("date")
+    if date == "" {
+        date = ukToday()
+    } else if _, err := time.Parse("2006-01-02", date); err != nil {
+        http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+        return nil, ""
+    }
+    runs, err := runsOn(headcode, date, r.URL.Query().Get("rid"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return nil, ""
+    }
+    if len(runs) == 0 {
+        http.Error(w, "no train with that headcode runs on "+date, http.StatusNotFound)
+        return nil, ""
+    }
+    return runs, date
+}
+
+// GET /train/{headcode}?rid=&date=: a train's progress page, or a list to
+// choose from when the headcode is ambiguous
+func trainPageHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    headcode := strings.ToUpper(r.PathValue("headcode"))
+    runs, date := requestedRuns(w, r, headcode)
+    if date == "" {
+        return
+    }
+    if p, ok := chooseRun(runs, r.URL.Query().Get("rid")); ok {
+        p.Position = berthPosition(headcode)
+        rememberEnthusiast(w, r)
+        tmpl, err := localisedTemplate(pageTmpl, lang)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        data := struct {
+            Lang, OtherLang, Theme string
+            Meta                   pageMeta
+            Commutes               bool
+            ProgressURL            string
+            Watching               bool
+            Enthusiast             bool
+            EnthusiastToggle       string
+            ShortLink              string
+            Share                  *shareForm
+        }{lang, otherLang(lang), requestTheme(w, r), trainMeta(r, p, lang), false, "/train/" + url.PathEscape(headcode) + "/progress?rid=" + url.QueryEscape(p.RID) + "&date=" + date, false, enthusiastMode(r), enthusiastToggle(r), shortLinkFor(r, p), shareFormFor(p)}
+        executeTemplate(w, r, tmpl, data)
+        return
+    }
+
+    var locs []TrainLocation
+    for _, p := range runs {
+        locs = append(locs, trainLocation(p))
+    }
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(trainChooserTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme, Headcode, Date string
+                Runs                                   []TrainLocation
+            }{lang, otherLang(lang), requestTheme(w, r), headcode, date, locs}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                Headcode string          `json:"headcode"`
+                Trains   []TrainLocation `json:"trains"`
+            }{headcode, locs}
+        },
+        Text: func(w io.Writer) {
+            for _, loc := range locs {
+                writeTrainLocation(w, loc, lang)
+                io.WriteString(w, "\n")
+            }
+        },
+    })
+}
+
+// GET /train/{headcode}/progress?rid=&date=: the progress fragment for the
+// train page
+func trainProgressHandler(w http.ResponseWriter, r *http.Request) {
+    headcode := strings.ToUpper(r.PathValue("headcode"))
+    runs, date := requestedRuns(w, r, headcode)
+    if date == "" {
+        return
+    }
+    p, ok := chooseRun(runs, r.URL.Query().Get("rid"))
+    if !ok {
+        http.Error(w, "no such train on "+date, http.StatusNotFound)
+        return
+    }
+    p.Position = berthPosition(headcode)
+    serveProgress(w, r, p, headcode, r.URL.RequestURI())
+}
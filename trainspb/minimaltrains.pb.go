@@ -0,0 +1,972 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        v5.29.3
+// source: minimaltrains.proto
+
+// Typed RPC access to the trains, boards and timetable the JSON API serves.
+// Regenerate the Go code from this directory with:
+//
+//    protoc --go_out=. --go_opt=paths=source_relative \
+//        --go-grpc_out=. --go-grpc_opt=paths=source_relative minimaltrains.proto
+
+package trainspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetTrainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rid           string                 `protobuf:"bytes,1,opt,name=rid,proto3" json:"rid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTrainRequest) Reset() {
+	*x = GetTrainRequest{}
+	mi := &file_minimaltrains_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTrainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTrainRequest) ProtoMessage() {}
+
+func (x *GetTrainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTrainRequest.ProtoReflect.Descriptor instead.
+func (*GetTrainRequest) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetTrainRequest) GetRid() string {
+	if x != nil {
+		return x.Rid
+	}
+	return ""
+}
+
+type Train struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Rid     string                 `protobuf:"bytes,1,opt,name=rid,proto3" json:"rid,omitempty"`
+	TrainId string                 `protobuf:"bytes,2,opt,name=train_id,json=trainId,proto3" json:"train_id,omitempty"`
+	Ssd     string                 `protobuf:"bytes,3,opt,name=ssd,proto3" json:"ssd,omitempty"`
+	Toc     string                 `protobuf:"bytes,4,opt,name=toc,proto3" json:"toc,omitempty"`
+	Mode    string                 `protobuf:"bytes,5,opt,name=mode,proto3" json:"mode,omitempty"`
+	Stops   []*Stop                `protobuf:"bytes,6,rep,name=stops,proto3" json:"stops,omitempty"`
+	// RIDs this service ran under before Darwin reissued its schedule
+	PreviousRids []string `protobuf:"bytes,7,rep,name=previous_rids,json=previousRids,proto3" json:"previous_rids,omitempty"`
+	// Darwin's latest late-running reason code, 0 if none given
+	LateReason    int32 `protobuf:"varint,8,opt,name=late_reason,json=lateReason,proto3" json:"late_reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Train) Reset() {
+	*x = Train{}
+	mi := &file_minimaltrains_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Train) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Train) ProtoMessage() {}
+
+func (x *Train) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Train.ProtoReflect.Descriptor instead.
+func (*Train) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Train) GetRid() string {
+	if x != nil {
+		return x.Rid
+	}
+	return ""
+}
+
+func (x *Train) GetTrainId() string {
+	if x != nil {
+		return x.TrainId
+	}
+	return ""
+}
+
+func (x *Train) GetSsd() string {
+	if x != nil {
+		return x.Ssd
+	}
+	return ""
+}
+
+func (x *Train) GetToc() string {
+	if x != nil {
+		return x.Toc
+	}
+	return ""
+}
+
+func (x *Train) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *Train) GetStops() []*Stop {
+	if x != nil {
+		return x.Stops
+	}
+	return nil
+}
+
+func (x *Train) GetPreviousRids() []string {
+	if x != nil {
+		return x.PreviousRids
+	}
+	return nil
+}
+
+func (x *Train) GetLateReason() int32 {
+	if x != nil {
+		return x.LateReason
+	}
+	return 0
+}
+
+type Stop struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Station string                 `protobuf:"bytes,1,opt,name=station,proto3" json:"station,omitempty"`
+	// HH:MM, of the arrival or departure as event says
+	Scheduled         string `protobuf:"bytes,2,opt,name=scheduled,proto3" json:"scheduled,omitempty"`
+	Expected          string `protobuf:"bytes,3,opt,name=expected,proto3" json:"expected,omitempty"`
+	Actual            string `protobuf:"bytes,4,opt,name=actual,proto3" json:"actual,omitempty"`
+	Status            string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Platform          string `protobuf:"bytes,6,opt,name=platform,proto3" json:"platform,omitempty"`
+	PlatformConfirmed bool   `protobuf:"varint,7,opt,name=platform_confirmed,json=platformConfirmed,proto3" json:"platform_confirmed,omitempty"`
+	// "arr" or "dep"
+	Event string `protobuf:"bytes,8,opt,name=event,proto3" json:"event,omitempty"`
+	Note  string `protobuf:"bytes,9,opt,name=note,proto3" json:"note,omitempty"`
+	// Darwin flagged the delay as indeterminate, so expected is a guess
+	Delayed bool `protobuf:"varint,10,opt,name=delayed,proto3" json:"delayed,omitempty"`
+	// Coaches in the formation, 0 if unknown
+	Length        int32 `protobuf:"varint,11,opt,name=length,proto3" json:"length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stop) Reset() {
+	*x = Stop{}
+	mi := &file_minimaltrains_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stop) ProtoMessage() {}
+
+func (x *Stop) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stop.ProtoReflect.Descriptor instead.
+func (*Stop) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Stop) GetStation() string {
+	if x != nil {
+		return x.Station
+	}
+	return ""
+}
+
+func (x *Stop) GetScheduled() string {
+	if x != nil {
+		return x.Scheduled
+	}
+	return ""
+}
+
+func (x *Stop) GetExpected() string {
+	if x != nil {
+		return x.Expected
+	}
+	return ""
+}
+
+func (x *Stop) GetActual() string {
+	if x != nil {
+		return x.Actual
+	}
+	return ""
+}
+
+func (x *Stop) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Stop) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *Stop) GetPlatformConfirmed() bool {
+	if x != nil {
+		return x.PlatformConfirmed
+	}
+	return false
+}
+
+func (x *Stop) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *Stop) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *Stop) GetDelayed() bool {
+	if x != nil {
+		return x.Delayed
+	}
+	return false
+}
+
+func (x *Stop) GetLength() int32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+type StreamBoardRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Crs   string                 `protobuf:"bytes,1,opt,name=crs,proto3" json:"crs,omitempty"`
+	// Only trains calling at this CRS
+	To string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Include empty stock moves and other non-passenger services
+	All bool `protobuf:"varint,3,opt,name=all,proto3" json:"all,omitempty"`
+	// How often to look for changes, at least 10 and by default 30
+	IntervalSeconds int32 `protobuf:"varint,4,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StreamBoardRequest) Reset() {
+	*x = StreamBoardRequest{}
+	mi := &file_minimaltrains_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamBoardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamBoardRequest) ProtoMessage() {}
+
+func (x *StreamBoardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamBoardRequest.ProtoReflect.Descriptor instead.
+func (*StreamBoardRequest) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamBoardRequest) GetCrs() string {
+	if x != nil {
+		return x.Crs
+	}
+	return ""
+}
+
+func (x *StreamBoardRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *StreamBoardRequest) GetAll() bool {
+	if x != nil {
+		return x.All
+	}
+	return false
+}
+
+func (x *StreamBoardRequest) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type Board struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Crs           string                 `protobuf:"bytes,1,opt,name=crs,proto3" json:"crs,omitempty"`
+	Departures    []*Departure           `protobuf:"bytes,2,rep,name=departures,proto3" json:"departures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Board) Reset() {
+	*x = Board{}
+	mi := &file_minimaltrains_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Board) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Board) ProtoMessage() {}
+
+func (x *Board) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Board.ProtoReflect.Descriptor instead.
+func (*Board) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Board) GetCrs() string {
+	if x != nil {
+		return x.Crs
+	}
+	return ""
+}
+
+func (x *Board) GetDepartures() []*Departure {
+	if x != nil {
+		return x.Departures
+	}
+	return nil
+}
+
+type Departure struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Rid               string                 `protobuf:"bytes,1,opt,name=rid,proto3" json:"rid,omitempty"`
+	TrainId           string                 `protobuf:"bytes,2,opt,name=train_id,json=trainId,proto3" json:"train_id,omitempty"`
+	Time              string                 `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Expected          string                 `protobuf:"bytes,4,opt,name=expected,proto3" json:"expected,omitempty"`
+	Actual            string                 `protobuf:"bytes,5,opt,name=actual,proto3" json:"actual,omitempty"`
+	Destination       string                 `protobuf:"bytes,6,opt,name=destination,proto3" json:"destination,omitempty"`
+	Platform          string                 `protobuf:"bytes,7,opt,name=platform,proto3" json:"platform,omitempty"`
+	PlatformConfirmed bool                   `protobuf:"varint,8,opt,name=platform_confirmed,json=platformConfirmed,proto3" json:"platform_confirmed,omitempty"`
+	Toc               string                 `protobuf:"bytes,9,opt,name=toc,proto3" json:"toc,omitempty"`
+	Status            string                 `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	Mode              string                 `protobuf:"bytes,11,opt,name=mode,proto3" json:"mode,omitempty"`
+	// Why it's late or cancelled, if Darwin says
+	Reason        string `protobuf:"bytes,12,opt,name=reason,proto3" json:"reason,omitempty"`
+	Length        int32  `protobuf:"varint,13,opt,name=length,proto3" json:"length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Departure) Reset() {
+	*x = Departure{}
+	mi := &file_minimaltrains_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Departure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Departure) ProtoMessage() {}
+
+func (x *Departure) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Departure.ProtoReflect.Descriptor instead.
+func (*Departure) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Departure) GetRid() string {
+	if x != nil {
+		return x.Rid
+	}
+	return ""
+}
+
+func (x *Departure) GetTrainId() string {
+	if x != nil {
+		return x.TrainId
+	}
+	return ""
+}
+
+func (x *Departure) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *Departure) GetExpected() string {
+	if x != nil {
+		return x.Expected
+	}
+	return ""
+}
+
+func (x *Departure) GetActual() string {
+	if x != nil {
+		return x.Actual
+	}
+	return ""
+}
+
+func (x *Departure) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *Departure) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *Departure) GetPlatformConfirmed() bool {
+	if x != nil {
+		return x.PlatformConfirmed
+	}
+	return false
+}
+
+func (x *Departure) GetToc() string {
+	if x != nil {
+		return x.Toc
+	}
+	return ""
+}
+
+func (x *Departure) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Departure) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *Departure) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Departure) GetLength() int32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+type SearchTimetableRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 2006-01-02
+	Date string `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	// Only this headcode
+	TrainId string `protobuf:"bytes,2,opt,name=train_id,json=trainId,proto3" json:"train_id,omitempty"`
+	// Only services leaving this station
+	Crs           string `protobuf:"bytes,3,opt,name=crs,proto3" json:"crs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchTimetableRequest) Reset() {
+	*x = SearchTimetableRequest{}
+	mi := &file_minimaltrains_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchTimetableRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchTimetableRequest) ProtoMessage() {}
+
+func (x *SearchTimetableRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchTimetableRequest.ProtoReflect.Descriptor instead.
+func (*SearchTimetableRequest) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SearchTimetableRequest) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *SearchTimetableRequest) GetTrainId() string {
+	if x != nil {
+		return x.TrainId
+	}
+	return ""
+}
+
+func (x *SearchTimetableRequest) GetCrs() string {
+	if x != nil {
+		return x.Crs
+	}
+	return ""
+}
+
+type SearchTimetableResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Services      []*ScheduledService    `protobuf:"bytes,2,rep,name=services,proto3" json:"services,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchTimetableResponse) Reset() {
+	*x = SearchTimetableResponse{}
+	mi := &file_minimaltrains_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchTimetableResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchTimetableResponse) ProtoMessage() {}
+
+func (x *SearchTimetableResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchTimetableResponse.ProtoReflect.Descriptor instead.
+func (*SearchTimetableResponse) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchTimetableResponse) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *SearchTimetableResponse) GetServices() []*ScheduledService {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+type ScheduledService struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Rid         string                 `protobuf:"bytes,1,opt,name=rid,proto3" json:"rid,omitempty"`
+	TrainId     string                 `protobuf:"bytes,2,opt,name=train_id,json=trainId,proto3" json:"train_id,omitempty"`
+	Ssd         string                 `protobuf:"bytes,3,opt,name=ssd,proto3" json:"ssd,omitempty"`
+	Toc         string                 `protobuf:"bytes,4,opt,name=toc,proto3" json:"toc,omitempty"`
+	Origin      string                 `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`
+	Destination string                 `protobuf:"bytes,6,opt,name=destination,proto3" json:"destination,omitempty"`
+	// From the station searched for, or else the origin
+	Departs       string `protobuf:"bytes,7,opt,name=departs,proto3" json:"departs,omitempty"`
+	Platform      string `protobuf:"bytes,8,opt,name=platform,proto3" json:"platform,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScheduledService) Reset() {
+	*x = ScheduledService{}
+	mi := &file_minimaltrains_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduledService) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduledService) ProtoMessage() {}
+
+func (x *ScheduledService) ProtoReflect() protoreflect.Message {
+	mi := &file_minimaltrains_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduledService.ProtoReflect.Descriptor instead.
+func (*ScheduledService) Descriptor() ([]byte, []int) {
+	return file_minimaltrains_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ScheduledService) GetRid() string {
+	if x != nil {
+		return x.Rid
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetTrainId() string {
+	if x != nil {
+		return x.TrainId
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetSsd() string {
+	if x != nil {
+		return x.Ssd
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetToc() string {
+	if x != nil {
+		return x.Toc
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetDeparts() string {
+	if x != nil {
+		return x.Departs
+	}
+	return ""
+}
+
+func (x *ScheduledService) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+var File_minimaltrains_proto protoreflect.FileDescriptor
+
+var file_minimaltrains_proto_rawDesc = string([]byte{
+	0x0a, 0x13, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72,
+	0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x22, 0x23, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x54, 0x72,
+	0x61, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x69, 0x64, 0x22, 0xe0, 0x01, 0x0a,
+	0x05, 0x54, 0x72, 0x61, 0x69, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x69, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x74, 0x72, 0x61, 0x69,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x69,
+	0x6e, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x73, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x73, 0x73, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x6f, 0x63, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x74, 0x6f, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x05, 0x73,
+	0x74, 0x6f, 0x70, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6d, 0x69, 0x6e,
+	0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x6f, 0x70, 0x52, 0x05, 0x73, 0x74, 0x6f, 0x70, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x70, 0x72, 0x65,
+	0x76, 0x69, 0x6f, 0x75, 0x73, 0x5f, 0x72, 0x69, 0x64, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x0c, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x52, 0x69, 0x64, 0x73, 0x12, 0x1f,
+	0x0a, 0x0b, 0x6c, 0x61, 0x74, 0x65, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0a, 0x6c, 0x61, 0x74, 0x65, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22,
+	0xb1, 0x02, 0x0a, 0x04, 0x53, 0x74, 0x6f, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x74, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x63, 0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x64,
+	0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06,
+	0x61, 0x63, 0x74, 0x75, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63,
+	0x74, 0x75, 0x61, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x2d, 0x0a, 0x12, 0x70, 0x6c, 0x61, 0x74,
+	0x66, 0x6f, 0x72, 0x6d, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x6f, 0x74, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x6f, 0x74,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x65, 0x64, 0x18, 0x0a, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c,
+	0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6c, 0x65, 0x6e,
+	0x67, 0x74, 0x68, 0x22, 0x73, 0x0a, 0x12, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x6f, 0x61,
+	0x72, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x72, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x72, 0x73, 0x12, 0x0e, 0x0a, 0x02, 0x74,
+	0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x74, 0x6f, 0x12, 0x10, 0x0a, 0x03, 0x61,
+	0x6c, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03, 0x61, 0x6c, 0x6c, 0x12, 0x29, 0x0a,
+	0x10, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64,
+	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61,
+	0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22, 0x56, 0x0a, 0x05, 0x42, 0x6f, 0x61, 0x72,
+	0x64, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x72, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x63, 0x72, 0x73, 0x12, 0x3b, 0x0a, 0x0a, 0x64, 0x65, 0x70, 0x61, 0x72, 0x74, 0x75, 0x72, 0x65,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61,
+	0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x70, 0x61, 0x72,
+	0x74, 0x75, 0x72, 0x65, 0x52, 0x0a, 0x64, 0x65, 0x70, 0x61, 0x72, 0x74, 0x75, 0x72, 0x65, 0x73,
+	0x22, 0xdb, 0x02, 0x0a, 0x09, 0x44, 0x65, 0x70, 0x61, 0x72, 0x74, 0x75, 0x72, 0x65, 0x12, 0x10,
+	0x0a, 0x03, 0x72, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x69, 0x64,
+	0x12, 0x19, 0x0a, 0x08, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x61,
+	0x63, 0x74, 0x75, 0x61, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74,
+	0x75, 0x61, 0x6c, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72,
+	0x6d, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72,
+	0x6d, 0x12, 0x2d, 0x0a, 0x12, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x5f, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x70,
+	0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64,
+	0x12, 0x10, 0x0a, 0x03, 0x74, 0x6f, 0x63, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74,
+	0x6f, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x0a, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f,
+	0x64, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x22, 0x59,
+	0x0a, 0x16, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x74, 0x61, 0x62, 0x6c,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x65, 0x12, 0x19, 0x0a, 0x08,
+	0x74, 0x72, 0x61, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x74, 0x72, 0x61, 0x69, 0x6e, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x72, 0x73, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x72, 0x73, 0x22, 0x6d, 0x0a, 0x17, 0x53, 0x65, 0x61,
+	0x72, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x65, 0x12, 0x3e, 0x0a, 0x08, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x6d, 0x69, 0x6e,
+	0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63,
+	0x68, 0x65, 0x64, 0x75, 0x6c, 0x65, 0x64, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x52, 0x08,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x22, 0xd3, 0x01, 0x0a, 0x10, 0x53, 0x63, 0x68,
+	0x65, 0x64, 0x75, 0x6c, 0x65, 0x64, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x72, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x69, 0x64, 0x12,
+	0x19, 0x0a, 0x08, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x73,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x73, 0x73, 0x64, 0x12, 0x10, 0x0a, 0x03,
+	0x74, 0x6f, 0x63, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x6f, 0x63, 0x12, 0x16,
+	0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73,
+	0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x64, 0x65, 0x70, 0x61,
+	0x72, 0x74, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x64, 0x65, 0x70, 0x61, 0x72,
+	0x74, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x32, 0x88,
+	0x02, 0x0a, 0x06, 0x54, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x12, 0x46, 0x0a, 0x08, 0x47, 0x65, 0x74,
+	0x54, 0x72, 0x61, 0x69, 0x6e, 0x12, 0x21, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74,
+	0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x61, 0x69,
+	0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d,
+	0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x69,
+	0x6e, 0x12, 0x4e, 0x0a, 0x0b, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x6f, 0x61, 0x72, 0x64,
+	0x12, 0x24, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x6f, 0x61, 0x72, 0x64, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c,
+	0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6f, 0x61, 0x72, 0x64, 0x30,
+	0x01, 0x12, 0x66, 0x0a, 0x0f, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x74,
+	0x61, 0x62, 0x6c, 0x65, 0x12, 0x28, 0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72,
+	0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x54, 0x69,
+	0x6d, 0x65, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x29,
+	0x2e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x74, 0x61, 0x62, 0x6c,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x30, 0x5a, 0x2e, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61, 0x73, 0x68, 0x63, 0x72, 0x6f, 0x66,
+	0x74, 0x31, 0x32, 0x33, 0x2f, 0x4d, 0x69, 0x6e, 0x69, 0x6d, 0x61, 0x6c, 0x54, 0x72, 0x61, 0x69,
+	0x6e, 0x73, 0x2f, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+})
+
+var (
+	file_minimaltrains_proto_rawDescOnce sync.Once
+	file_minimaltrains_proto_rawDescData []byte
+)
+
+func file_minimaltrains_proto_rawDescGZIP() []byte {
+	file_minimaltrains_proto_rawDescOnce.Do(func() {
+		file_minimaltrains_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_minimaltrains_proto_rawDesc), len(file_minimaltrains_proto_rawDesc)))
+	})
+	return file_minimaltrains_proto_rawDescData
+}
+
+var file_minimaltrains_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_minimaltrains_proto_goTypes = []any{
+	(*GetTrainRequest)(nil),         // 0: minimaltrains.v1.GetTrainRequest
+	(*Train)(nil),                   // 1: minimaltrains.v1.Train
+	(*Stop)(nil),                    // 2: minimaltrains.v1.Stop
+	(*StreamBoardRequest)(nil),      // 3: minimaltrains.v1.StreamBoardRequest
+	(*Board)(nil),                   // 4: minimaltrains.v1.Board
+	(*Departure)(nil),               // 5: minimaltrains.v1.Departure
+	(*SearchTimetableRequest)(nil),  // 6: minimaltrains.v1.SearchTimetableRequest
+	(*SearchTimetableResponse)(nil), // 7: minimaltrains.v1.SearchTimetableResponse
+	(*ScheduledService)(nil),        // 8: minimaltrains.v1.ScheduledService
+}
+var file_minimaltrains_proto_depIdxs = []int32{
+	2, // 0: minimaltrains.v1.Train.stops:type_name -> minimaltrains.v1.Stop
+	5, // 1: minimaltrains.v1.Board.departures:type_name -> minimaltrains.v1.Departure
+	8, // 2: minimaltrains.v1.SearchTimetableResponse.services:type_name -> minimaltrains.v1.ScheduledService
+	0, // 3: minimaltrains.v1.Trains.GetTrain:input_type -> minimaltrains.v1.GetTrainRequest
+	3, // 4: minimaltrains.v1.Trains.StreamBoard:input_type -> minimaltrains.v1.StreamBoardRequest
+	6, // 5: minimaltrains.v1.Trains.SearchTimetable:input_type -> minimaltrains.v1.SearchTimetableRequest
+	1, // 6: minimaltrains.v1.Trains.GetTrain:output_type -> minimaltrains.v1.Train
+	4, // 7: minimaltrains.v1.Trains.StreamBoard:output_type -> minimaltrains.v1.Board
+	7, // 8: minimaltrains.v1.Trains.SearchTimetable:output_type -> minimaltrains.v1.SearchTimetableResponse
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_minimaltrains_proto_init() }
+func file_minimaltrains_proto_init() {
+	if File_minimaltrains_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_minimaltrains_proto_rawDesc), len(file_minimaltrains_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_minimaltrains_proto_goTypes,
+		DependencyIndexes: file_minimaltrains_proto_depIdxs,
+		MessageInfos:      file_minimaltrains_proto_msgTypes,
+	}.Build()
+	File_minimaltrains_proto = out.File
+	file_minimaltrains_proto_goTypes = nil
+	file_minimaltrains_proto_depIdxs = nil
+}
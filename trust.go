@@ -0,0 +1,125 @@
+package main
+
+import (
+    "encoding/json"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// TRUST train movement messages, used as a second source of actual times
+// https://wiki.openraildata.com/index.php/Train_Movements
+type trustMessage struct {
+    Header struct {
+        MsgType string `json:"msg_type"`
+    } `json:"header"`
+    Body struct {
+        TrainID         string `json:"train_id"`
+        EventType       string `json:"event_type"`
+        ActualTimestamp string `json:"actual_timestamp"`
+        LocStanox       string `json:"loc_stanox"`
+    } `json:"body"`
+}
+
+// CORPUS reference data, for mapping TRUST STANOX codes to TIPLOCs
+// https://wiki.openraildata.com/index.php/Reference_Data#CORPUS:_Location_Reference_Data
+type corpusFile struct {
+    TiplocData []struct {
+        Stanox string `json:"STANOX"`
+        Tiploc string `json:"TIPLOC"`
+    } `json:"TIPLOCDATA"`
+}
+
+type trustActual struct {
+    Date string
+    Time string
+}
+
+// TRUST actuals for the tracked train, keyed by TIPLOC and event
+var (
+    trustActuals   = map[string]trustActual{}
+    trustMu        sync.RWMutex
+    stanoxToTiploc = map[string]string{}
+)
+
+func startTrustFeed() {
+    path := os.Getenv("CORPUS_FILE")
+    if path == "" {
+        log.Println("CORPUS_FILE must be set to map TRUST locations; TRUST feed disabled.")
+        return
+    }
+    if err := loadCorpus(path); err != nil {
+        log.Printf("Failed to load CORPUS file %s: %v", path, err)
+        return
+    }
+    consumeStompTopic("TRUST",
+        envOr("NR_STOMP_ADDR", defaultNROpenDataAddr),
+        os.Getenv("NR_USERNAME"),
+        os.Getenv("NR_PASSWORD"),
+        envOr("TRUST_TOPIC", "/topic/TRAIN_MVT_ALL_TOC"),
+        handleTrustMessage,
+    )
+}
+
+func loadCorpus(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var corpus corpusFile
+    if err := json.Unmarshal(data, &corpus); err != nil {
+        return err
+    }
+    for _, loc := range corpus.TiplocData {
+        stanox, tiploc := strings.TrimSpace(loc.Stanox), strings.TrimSpace(loc.Tiploc)
+        if stanox != "" && tiploc != "" {
+            stanoxToTiploc[stanox] = tiploc
+        }
+    }
+    log.Printf("Loaded %d STANOX codes from CORPUS", len(stanoxToTiploc))
+    return nil
+}
+
+func handleTrustMessage(body []byte) {
+    var msgs []trustMessage
+    if err := json.Unmarshal(body, &msgs); err != nil {
+        log.Printf("Failed to parse TRUST message: %v", err)
+        return
+    }
+    for _, m := range msgs {
+        // 0003 is a train movement; the headcode is characters 3-6 of the TRUST ID
+        if m.Header.MsgType != "0003" || len(m.Body.TrainID) != 10 || m.Body.TrainID[2:6] != trackedHeadcode {
+            continue
+        }
+        tiploc, ok := stanoxToTiploc[m.Body.LocStanox]
+        if !ok {
+            continue
+        }
+        ms, err := strconv.ParseInt(m.Body.ActualTimestamp, 10, 64)
+        if err != nil {
+            continue
+        }
+        // TRUST timestamps are UK local time written as if they were UTC
+        at := time.UnixMilli(ms).UTC()
+        event := "dep"
+        if m.Body.EventType == "ARRIVAL" {
+            event = "arr"
+        }
+        trustMu.Lock()
+        trustActuals[tiploc+"/"+event] = trustActual{Date: at.Format("2006-01-02"), Time: at.Format("15:04")}
+        trustMu.Unlock()
+    }
+}
+
+func trustActualFor(tiploc, event string) (string, bool) {
+    trustMu.RLock()
+    defer trustMu.RUnlock()
+    a, ok := trustActuals[tiploc+"/"+event]
+    if !ok || a.Date != ukToday() {
+        return "", false
+    }
+    return a.Time, true
+}
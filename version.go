@@ -0,0 +1,120 @@
+package main
+
+import (
+    "net/http"
+    "os"
+    "path"
+    "runtime"
+    "runtime/debug"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.buildTime=$(date -u +%FT%TZ)"
+//
+// The commit comes from the VCS stamp Go adds to the binary.
+var (
+    version   = "dev"
+    buildTime string
+)
+
+// Which timetable and reference data this instance has loaded, so two
+// instances giving different answers can be told apart
+var (
+    loadedTimetableID string
+    loadedReferenceID string
+    timetableLoadedAt time.Time
+    loadedVersionsMu  sync.RWMutex
+)
+
+// Reference files read at startup, by the variable naming them
+var referenceFileVars = []string{"STATION_COORDS_FILE", "PLATFORM_LENGTHS_FILE", "STATION_GROUPS_FILE", "CORPUS_FILE"}
+
+type ReferenceFile struct {
+    Name     string    `json:"name"`
+    Path     string    `json:"path"`
+    Modified time.Time `json:"modified"`
+    Size     int64     `json:"size"`
+}
+
+type VersionInfo struct {
+    Version   string `json:"version"`
+    Commit    string `json:"commit,omitempty"`
+    BuildTime string `json:"build_time,omitempty"`
+    GoVersion string `json:"go_version"`
+
+    TimetableID       string          `json:"timetable_id,omitempty"`
+    TimetableLoadedAt *time.Time      `json:"timetable_loaded_at,omitempty"`
+    ReferenceID       string          `json:"reference_id,omitempty"`
+    ReferenceFiles    []ReferenceFile `json:"reference_files,omitempty"`
+}
+
+// A timetable or reference file's ID from its S3 key, path or URL, e.g.
+// 20261014020508 from PPTimetable/20261014020508_v8.xml.gz
+func dataID(source string) string {
+    id := path.Base(source)
+    for _, suffix := range []string{".gz", ".xml", "_v8", "_ref_v3"} {
+        id = strings.TrimSuffix(id, suffix)
+    }
+    return id
+}
+
+func noteTimetableLoaded(id string) {
+    noteTimetableLoadedAt(id, time.Now())
+}
+
+// For a timetable restored from a snapshot, loaded before it was taken
+func noteTimetableLoadedAt(id string, at time.Time) {
+    loadedVersionsMu.Lock()
+    loadedTimetableID, timetableLoadedAt = id, at
+    loadedVersionsMu.Unlock()
+}
+
+func noteReferenceLoaded(id string) {
+    loadedVersionsMu.Lock()
+    loadedReferenceID = id
+    loadedVersionsMu.Unlock()
+}
+
+func versionInfo() VersionInfo {
+    v := VersionInfo{Version: version, BuildTime: buildTime, GoVersion: runtime.Version()}
+    if info, ok := debug.ReadBuildInfo(); ok {
+        var dirty bool
+        for _, s := range info.Settings {
+            switch s.Key {
+            case "vcs.revision":
+                v.Commit = s.Value
+            case "vcs.modified":
+                dirty = s.Value == "true"
+            }
+        }
+        if dirty && v.Commit != "" {
+            v.Commit += "-dirty"
+        }
+    }
+    loadedVersionsMu.RLock()
+    v.TimetableID, v.ReferenceID = loadedTimetableID, loadedReferenceID
+    if !timetableLoadedAt.IsZero() {
+        at := timetableLoadedAt
+        v.TimetableLoadedAt = &at
+    }
+    loadedVersionsMu.RUnlock()
+    for _, name := range referenceFileVars {
+        p := os.Getenv(name)
+        if p == "" {
+            continue
+        }
+        if fi, err := os.Stat(p); err == nil {
+            v.ReferenceFiles = append(v.ReferenceFiles, ReferenceFile{name, p, fi.ModTime(), fi.Size()})
+        }
+    }
+    return v
+}
+
+// GET /version
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, versionInfo())
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "slices"
+    "strings"
+)
+
+// The walk-up timetable: a station's departures for a whole day as
+// planned, like the poster on the platform, for planning ahead. It's
+// built from the schedules alone, so it can be shown for any day within
+// the timetable horizon, and cancelled variants are left off.
+type WalkUpDeparture struct {
+    RID         string   `json:"rid"`
+    TrainID     string   `json:"train_id"`
+    Time        string   `json:"time"`
+    Destination string   `json:"destination"`
+    Platform    string   `json:"platform,omitempty"`
+    TOC         string   `json:"toc"`
+    CallingAt   []string `json:"calling_at"` // TIPLOCs of its later public calls
+}
+
+// Departures in the hour they leave in
+type walkUpHour struct {
+    Hour       string
+    Departures []WalkUpDeparture
+}
+
+// A station's planned public departures on a date, in time order. Calls
+// after midnight by trains that started the day before sort last.
+func walkUpTimetable(crs, date string) ([]WalkUpDeparture, error) {
+    js, err := journeysOn(date, false)
+    if err != nil {
+        return nil, err
+    }
+    tiplocs := tiplocsForCRS(crs)
+    type departure struct {
+        WalkUpDeparture
+        mins int
+    }
+    var deps []departure
+    for _, j := range js {
+        if !j.IsPublic() || j.STP == "C" || len(j.Points) == 0 {
+            continue
+        }
+        origin, _ := callMinutes(j.Points[0])
+        for i, p := range j.Points {
+            if !slices.Contains(tiplocs, p.Tiploc) || !isPublicDeparture(p) {
+                continue
+            }
+            d := departure{WalkUpDeparture: WalkUpDeparture{
+                RID:         j.RID,
+                TrainID:     j.TrainID,
+                Time:        p.Ptd,
+                Destination: j.Points[len(j.Points)-1].Tiploc,
+                Platform:    p.Plat,
+                TOC:         j.TOC,
+            }}
+            d.mins, _ = parseRailTime(p.Ptd)
+            if d.mins < origin {
+                d.mins += 24 * 60
+            }
+            for _, later := range j.Points[i+1:] {
+                if isPublicCall(later) && later.Pta != "" && !slices.Contains(tiplocs, later.Tiploc) {
+                    d.CallingAt = append(d.CallingAt, later.Tiploc)
+                }
+            }
+            deps = append(deps, d)
+            // Calling at the same station twice only shows the first visit
+            break
+        }
+    }
+    slices.SortFunc(deps, func(a, b departure) int {
+        if a.mins != b.mins {
+            return a.mins - b.mins
+        }
+        return strings.Compare(a.TrainID, b.TrainID)
+    })
+    out := make([]WalkUpDeparture, len(deps))
+    for i, d := range deps {
+        out[i] = d.WalkUpDeparture
+    }
+    return out, nil
+}
+
+func walkUpHours(deps []WalkUpDeparture) []walkUpHour {
+    var hours []walkUpHour
+    for _, d := range deps {
+        hour := d.Time[:min(2, len(d.Time))]
+        if len(hours) == 0 || hours[len(hours)-1].Hour != hour {
+            hours = append(hours, walkUpHour{Hour: hour})
+        }
+        hours[len(hours)-1].Departures = append(hours[len(hours)-1].Departures, d)
+    }
+    return hours
+}
+
+var walkUpTmpl = template.Must(template.New("walkUp").Funcs(templateFuncs).Parse(`
+<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{T "walkup_title" .CRS}}</title>
+    <link rel="stylesheet" href="/theme.css?theme={{.Theme}}">
+</head>
+<body>
+    <h1>{{T "walkup_title" .CRS}}</h1>
+    <p><a href="?lang={{.OtherLang}}&date={{.Date}}">{{T "switch_lang"}}</a> | <a href="/board/{{.CRS}}">{{T "walkup_live"}}</a></p>
+    <form method="get">
+        <label>{{T "date_col"}} <select name="date">{{range .Dates}}<option{{if eq . $.Date}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+        <button type="submit">{{T "show_columns"}}</button>
+    </form>
+    <table>
+        <tr><th>{{T "time"}}</th><th>{{T "destination"}}</th><th>{{T "platform"}}</th><th>{{T "operator"}}</th><th>{{T "calling_at"}}</th></tr>
+        {{range .Hours}}
+        <tr class="group"><th colspan="5">{{.Hour}}:00</th></tr>
+        {{range .Departures}}
+        <tr class="toc-row toc-{{.TOC}}">
+            <td>{{hhmm .Time}}</td>
+            <td><strong>{{station .Destination}}</strong></td>
+            <td>{{.Platform}}</td>
+            <td>{{operator .TOC}}</td>
+            <td class="muted">{{range $i, $t := .CallingAt}}{{if $i}}, {{end}}{{station $t}}{{end}}</td>
+        </tr>
+        {{end}}
+        {{else}}
+        <tr><td colspan="5">{{T "walkup_none"}}</td></tr>
+        {{end}}
+    </table>
+</body>
+</html>
+`))
+
+// GET /board/{crs}/timetable?date=: the walk-up timetable for today or a
+// day within the timetable horizon
+func walkUpHandler(w http.ResponseWriter, r *http.Request) {
+    lang := requestLang(w, r)
+    crs := strings.ToUpper(r.PathValue("crs"))
+    dates := timetableDates(clock.Now())
+    date := r.URL.Query().Get("date")
+    if date == "" {
+        date = dates[0]
+    }
+    deps, err := walkUpTimetable(crs, date)
+    if errors.Is(err, errOutsideHorizon) {
+        http.Error(w, fmt.Sprintf("date must be between %s and %s", dates[0], dates[len(dates)-1]), http.StatusNotFound)
+        return
+    }
+    if errors.Is(err, errDayNotCached) {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+        return
+    }
+    if err != nil {
+        log.Printf("Failed to load the %s timetable: %v", date, err)
+        http.Error(w, "failed to load timetable", http.StatusBadGateway)
+        return
+    }
+    render(w, r, rendering{
+        HTML: func(w http.ResponseWriter) error {
+            tmpl, err := localisedTemplate(walkUpTmpl, lang)
+            if err != nil {
+                return err
+            }
+            data := struct {
+                Lang, OtherLang, Theme string
+                CRS, Date              string
+                Dates                  []string
+                Hours                  []walkUpHour
+            }{lang, otherLang(lang), requestTheme(w, r), crs, date, dates, walkUpHours(deps)}
+            executeTemplate(w, r, tmpl, data)
+            return nil
+        },
+        JSON: func() any {
+            return struct {
+                CRS        string            `json:"crs"`
+                Date       string            `json:"date"`
+                Departures []WalkUpDeparture `json:"departures"`
+            }{crs, date, deps}
+        },
+        Text: func(w io.Writer) {
+            fmt.Fprintf(w, "%s, %s\n\n", translate(lang, "walkup_title", crs), date)
+            var rows [][]string
+            for _, d := range deps {
+                rows = append(rows, []string{d.Time, stationDisplayName(d.Destination, lang), d.Platform, d.TOC})
+            }
+            writeTextTable(w, []string{translate(lang, "time"), translate(lang, "destination"), translate(lang, "platform"), translate(lang, "operator")}, rows)
+        },
+    })
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "net/url"
+)
+
+// A board row's "track this" button watches that train for the rest of
+// the browser session: it's kept in a session cookie, and the home page
+// follows it in place of the tracked train until the session ends or
+// the user stops watching.
+const watchCookie = "watch"
+
+// POST /watch with rid: watch a train and go to its live page
+func watchHandler(w http.ResponseWriter, r *http.Request) {
+    j, ok := journeyByRID(r.FormValue("rid"))
+    if !ok {
+        http.Error(w, "no such train", http.StatusNotFound)
+        return
+    }
+    http.SetCookie(w, &http.Cookie{Name: watchCookie, Value: j.RID, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+    http.Redirect(w, r, "/train/"+url.PathEscape(j.TrainID)+"?rid="+url.QueryEscape(j.RID), http.StatusSeeOther)
+}
+
+// POST /watch/stop: go back to following the tracked train
+func unwatchHandler(w http.ResponseWriter, r *http.Request) {
+    http.SetCookie(w, &http.Cookie{Name: watchCookie, Path: "/", MaxAge: -1})
+    http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// The train this session is watching, if it's still in the timetable
+func requestWatch(r *http.Request) (*Journey, bool) {
+    c, err := r.Cookie(watchCookie)
+    if err != nil {
+        return nil, false
+    }
+    return journeyByRID(c.Value)
+}
+
+// What the home page follows: the watched train, else the tracked one
+func homeProgress(r *http.Request) (TrainProgress, string, bool) {
+    j, ok := requestWatch(r)
+    if !ok {
+        return fetchTrackedProgress(), trackedHeadcode, false
+    }
+    progress, _, err := lookupProgress(j.RID)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", j.RID, err)
+    }
+    progress.Position = berthPosition(j.TrainID)
+    return progress, j.TrainID, true
+}
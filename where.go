@@ -0,0 +1,280 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Where a train is now, in brief: the last stop it reported at, how late
+// it is and where it calls next
+type TrainLocation struct {
+    RID           string         `json:"rid"`
+    TrainID       string         `json:"train_id"`
+    TOC           string         `json:"toc"`
+    Origin        string         `json:"origin"`
+    Destination   string         `json:"destination"`
+    Departs       string         `json:"departs"` // scheduled, from the origin
+    LastStation   string         `json:"last_station,omitempty"`
+    LastEvent     string         `json:"last_event,omitempty"`
+    LastTime      string         `json:"last_time,omitempty"`
+    Delay         *int           `json:"delay,omitempty"`
+    NextStation   string         `json:"next_station,omitempty"`
+    NextScheduled string         `json:"next_scheduled,omitempty"`
+    NextExpected  string         `json:"next_expected,omitempty"`
+    NextPlatform  string         `json:"next_platform,omitempty"`
+    Position      *BerthPosition `json:"position,omitempty"`
+    Cancelled     bool           `json:"cancelled"`
+    Finished      bool           `json:"finished"`
+
+    // Whether NextPlatform is confirmed rather than expected
+    NextPlatformConfirmed bool `json:"next_platform_confirmed"`
+}
+
+func trainLocation(p TrainProgress) TrainLocation {
+    loc := TrainLocation{RID: p.RID, TrainID: p.TrainID, TOC: p.TOC, Position: p.Position, Finished: journeyFinished(p)}
+    if len(p.Stops) == 0 {
+        return loc
+    }
+    loc.Origin, loc.Destination = p.Stops[0].Station, p.Stops[len(p.Stops)-1].Station
+    loc.Departs = p.Stops[0].Scheduled
+    _, loc.Cancelled = cancelledRanges(p.Stops)
+    if d, ok := trainDelay(p); ok {
+        loc.Delay = &d
+    }
+    last := -1
+    for i, s := range p.Stops {
+        if s.Actual != "" {
+            last = i
+        }
+    }
+    if last >= 0 {
+        s := p.Stops[last]
+        loc.LastStation, loc.LastEvent, loc.LastTime = s.Station, s.Event, s.Actual
+    }
+    for _, s := range p.Stops[last+1:] {
+        if s.Status == "Cancelled" {
+            continue
+        }
+        loc.NextStation, loc.NextScheduled, loc.NextExpected, loc.NextPlatform = s.Station, s.Scheduled, s.Expected, s.Platform
+        loc.NextPlatformConfirmed = s.PlatformConfirmed
+        break
+    }
+    return loc
+}
+
+// Every run today of a headcode, whichever operator runs it, with the
+// progress known for each, in order of departure
+func todaysRuns(headcode string) ([]TrainProgress, error) {
+    return runsOn(headcode, ukToday(), "")
+}
+
+// todaysRuns for any date (2006-01-02). Schedules from days gone by are
+// evicted, so a run named by rid that isn't held any more is looked for
+// in the progress store and then the archive.
+func runsOn(headcode, date, rid string) ([]TrainProgress, error) {
+    var js []*Journey
+    journeysMu.RLock()
+    for _, j := range journeys {
+        if j.TrainID == headcode && j.SSD == date {
+            js = append(js, j)
+        }
+    }
+    journeysMu.RUnlock()
+
+    var runs []TrainProgress
+    found := false
+    for _, j := range js {
+        p, ok, err := progressStore.Get(j.RID)
+        if err != nil {
+            return nil, err
+        }
+        if !ok {
+            progressFromJourney(j, &p)
+        }
+        runs = append(runs, p)
+        found = found || j.RID == rid
+    }
+    if rid != "" && !found {
+        p, ok, err := progressStore.Get(rid)
+        if err == nil && !ok {
+            p, ok, err = archivedProgress(rid)
+        }
+        if err != nil {
+            return nil, err
+        }
+        if ok && p.TrainID == headcode && p.SSD == date {
+            runs = append(runs, p)
+        }
+    }
+    sort.Slice(runs, func(i, k int) bool {
+        a, b := trainLocation(runs[i]), trainLocation(runs[k])
+        if a.Departs != b.Departs {
+            return a.Departs < b.Departs
+        }
+        return a.RID < b.RID
+    })
+    return runs, nil
+}
+
+// Today's run of a headcode that's most worth reporting: one under way,
+// else the next to start, else the last to finish
+func currentRun(headcode string) (TrainProgress, bool, error) {
+    runs, err := todaysRuns(headcode)
+    if err != nil {
+        return TrainProgress{}, false, err
+    }
+    var best TrainProgress
+    bestRank, found := 0, false
+    for _, p := range runs {
+        loc := trainLocation(p)
+        rank := 2
+        switch {
+        case loc.Finished || loc.Cancelled:
+            rank = 1
+        case loc.LastStation != "":
+            rank = 3
+        }
+        // Among equals, the earliest still to start or the latest finished
+        better := rank > bestRank ||
+            (rank == bestRank && rank == 2 && loc.Departs < trainLocation(best).Departs) ||
+            (rank == bestRank && rank != 2 && loc.Departs > trainLocation(best).Departs)
+        if !found || better {
+            best, bestRank, found = p, rank, true
+        }
+    }
+    return best, found, nil
+}
+
+// GET /api/v1/train/{headcode}/where, as JSON or with ?format=text the
+// lines the where command prints
+func whereHandler(w http.ResponseWriter, r *http.Request) {
+    headcode := strings.ToUpper(r.PathValue("headcode"))
+    p, ok, err := currentRun(headcode)
+    if err != nil {
+        log.Printf("Failed to load progress for %s: %v", headcode, err)
+        http.Error(w, "failed to load progress", http.StatusInternalServerError)
+        return
+    }
+    if !ok {
+        http.Error(w, "no train with that headcode runs today", http.StatusNotFound)
+        return
+    }
+    p.Position = berthPosition(headcode)
+    if r.URL.Query().Get("format") == "text" {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        writeTrainLocation(w, trainLocation(p), requestLang(w, r))
+        return
+    }
+    writeJSON(w, trainLocation(p))
+}
+
+// A location as a few lines for a terminal
+func writeTrainLocation(w io.Writer, loc TrainLocation, lang string) {
+    station := func(t string) string { return stationDisplayName(t, lang) }
+    fmt.Fprintf(w, "%s %s %s - %s\n", loc.TrainID, operatorName(loc.TOC), station(loc.Origin), station(loc.Destination))
+    switch {
+    case loc.Cancelled:
+        fmt.Fprintln(w, translate(lang, "train_cancelled"))
+        return
+    case loc.LastStation == "":
+        fmt.Fprintln(w, translate(lang, "card_due", station(loc.Origin), loc.Departs))
+    case loc.LastEvent == "arr":
+        fmt.Fprintln(w, translate(lang, "card_arrived", station(loc.LastStation), loc.LastTime))
+    default:
+        fmt.Fprintln(w, translate(lang, "card_departed", station(loc.LastStation), loc.LastTime))
+    }
+    if loc.Position != nil {
+        if loc.Position.From != "" {
+            fmt.Fprintln(w, translate(lang, "between_signals", loc.Position.From, loc.Position.To))
+        } else {
+            fmt.Fprintln(w, translate(lang, "at_signal", loc.Position.To))
+        }
+    }
+    if loc.Delay != nil && *loc.Delay > 0 {
+        fmt.Fprintln(w, translate(lang, "card_late", *loc.Delay))
+    } else if loc.Delay != nil {
+        fmt.Fprintln(w, translate(lang, "On time"))
+    }
+    if loc.NextStation != "" && !loc.Finished {
+        next := loc.NextScheduled
+        if loc.NextExpected != "" && loc.NextExpected != loc.NextScheduled {
+            next += " (" + translate(lang, "meta_expected", loc.NextExpected) + ")"
+        }
+        fmt.Fprintln(w, translate(lang, "where_next", station(loc.NextStation), next))
+    }
+}
+
+// "minimaltrains where 2B15": where a train is right now. It asks the
+// instance at --url if one is given, and otherwise reads the local
+// progress store and snapshot the server would use.
+func runWhere(args []string) int {
+    fs := flag.NewFlagSet("where", flag.ExitOnError)
+    base := fs.String("url", os.Getenv("MINIMALTRAINS_URL"), "instance to ask; empty to read the local store")
+    token := fs.String("token", os.Getenv("MINIMALTRAINS_TOKEN"), "API token, if the instance needs one")
+    lang := fs.String("lang", defaultLang, "language: en or cy")
+    asJSON := fs.Bool("json", false, "print the location as JSON")
+    var headcode string
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        headcode, args = args[0], args[1:]
+    }
+    fs.Parse(args)
+    if headcode == "" {
+        headcode = fs.Arg(0)
+    }
+    if headcode == "" {
+        fmt.Fprintln(os.Stderr, "usage: minimaltrains where <headcode> [--url URL] [--json] [--lang cy]")
+        return 2
+    }
+    headcode = strings.ToUpper(headcode)
+
+    if *base != "" {
+        q := url.Values{"format": {"text"}, "lang": {*lang}}
+        if *asJSON {
+            q.Set("format", "json")
+        }
+        whereURL := strings.TrimSuffix(*base, "/") + "/api/v1/train/" + url.PathEscape(headcode) + "/where?" + q.Encode()
+        text, err := fetchBoardText(&http.Client{Timeout: 10 * time.Second}, whereURL, *token)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Failed to fetch %s: %v\n", headcode, err)
+            return 1
+        }
+        fmt.Print(text)
+        return 0
+    }
+
+    // The store and snapshot log as they open; that's noise here
+    log.SetOutput(io.Discard)
+    if err := initProgressStore(); err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to open progress store: %v\n", err)
+        return 1
+    }
+    defer progressStore.Close()
+    loadSnapshot()
+    p, ok, err := currentRun(headcode)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Failed to load progress for %s: %v\n", headcode, err)
+        return 1
+    }
+    if !ok {
+        fmt.Fprintf(os.Stderr, "No %s runs today, or there's no timetable snapshot to look it up in\n", headcode)
+        return 1
+    }
+    loc := trainLocation(p)
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        enc.Encode(loc)
+        return 0
+    }
+    writeTrainLocation(os.Stdout, loc, *lang)
+    return 0
+}